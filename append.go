@@ -0,0 +1,66 @@
+package lrucache
+
+import "fmt"
+
+// Append adds suffix to the end of the string or []byte stored under key in
+// one step under the write lock and returns the new length of the value.
+// The serialized bytes are extended as they are, so the value is not
+// decoded and encoded again, and the entry keeps its deadline. Missing keys
+// return ErrItemNotFound and expired ones ErrItemExpired, as Get does; a
+// value of any other type fails with ErrNotAppendable.
+func (l *LRU) Append(key string, suffix []byte) (int, error) {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+	if err := l.checkWritable(key); err != nil {
+		return 0, err
+	}
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		return 0, ErrItemNotFound
+	}
+	prev := raw.(*CacheItem)
+	if prev.expired(l.clock()) {
+		return 0, ErrItemExpired
+	}
+	if err := l.checkMutable(prev); err != nil {
+		return 0, err
+	}
+	if tag := prev.Value[0]; tag != tagString && tag != tagBytes {
+		value, _ := l.decode(prev.Value)
+		return 0, fmt.Errorf("%w: %s holds %T", ErrNotAppendable, key, value)
+	}
+	if err := l.makeRoom(int64(len(suffix)), key); err != nil {
+		return 0, err
+	}
+
+	// Rows are shared with readers, so the value is extended into a copy.
+	data := make([]byte, 0, len(prev.Value)+len(suffix))
+	data = append(append(data, prev.Value...), suffix...)
+
+	txn := l.db.Txn(true)
+	// Making room can cascade to entries derived from the evicted ones.
+	if cur, err := txn.First("cache", "id", key); err != nil || cur != raw {
+		txn.Abort()
+		return 0, ErrItemNotFound
+	}
+	item := l.copyItem(prev)
+	item.Value, item.LastModified = data, l.modifiedAfter(prev)
+	if err := txn.Insert("cache", item); err != nil {
+		txn.Abort()
+		return 0, fmt.Errorf("failed to insert item: %v", err)
+	}
+	txn.Commit()
+	l.resized(int64(len(suffix)))
+	l.retire(prev)
+	l.notifyWatchers(key, data)
+	l.invalidateDependents(key)
+
+	l.log("debug", "Appended %d bytes to key: %s", len(suffix), l.redact(key))
+	return len(data) - 1, nil
+}
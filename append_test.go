@@ -0,0 +1,69 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRUAppend(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	if _, err := cache.Append("log", []byte("x")); err != ErrItemNotFound {
+		t.Fatalf("Expected ErrItemNotFound, got %v", err)
+	}
+
+	cache.Set("log", "start;", time.Minute)
+	now = now.Add(10 * time.Second)
+	if n, err := cache.Append("log", []byte("more;")); n != 11 || err != nil {
+		t.Fatalf("Expected length 11, got %d, %v", n, err)
+	}
+	if v, _ := cache.Get("log"); v != "start;more;" {
+		t.Errorf("Expected the appended string, got %q", v)
+	}
+	if got := expiresAt(t, cache, "log"); !got.Equal(now.Add(50 * time.Second)) {
+		t.Errorf("Expected Append to keep the deadline, got %v", got)
+	}
+
+	cache.Set("raw", []byte{1, 2}, time.Minute)
+	if n, err := cache.Append("raw", []byte{3}); n != 3 || err != nil {
+		t.Errorf("Expected length 3, got %d, %v", n, err)
+	}
+	if v, _ := cache.Get("raw"); string(v.([]byte)) != "\x01\x02\x03" {
+		t.Errorf("Expected the appended bytes, got %v", v)
+	}
+
+	cache.Set("count", 3, time.Minute)
+	if _, err := cache.Append("count", []byte("4")); !errors.Is(err, ErrNotAppendable) {
+		t.Errorf("Expected ErrNotAppendable, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := cache.Append("log", []byte("late")); err != ErrItemExpired {
+		t.Errorf("Expected ErrItemExpired, got %v", err)
+	}
+}
+
+func TestLRUAppendConcurrent(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("log", "", time.Hour)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				cache.Append("log", []byte(fmt.Sprint(g)))
+			}
+		}(g)
+	}
+	wg.Wait()
+	if v, _ := cache.Get("log"); len(v.(string)) != 400 {
+		t.Errorf("Expected every append to land, got %d bytes", len(v.(string)))
+	}
+}
@@ -0,0 +1,98 @@
+package lrucache
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// itemArena hands out CacheItems from large preallocated blocks and reuses the
+// slots of removed items through a free list. It is only touched under the
+// write lock, and a slot is recycled only once its item has left the table,
+// at which point no reader can still reach it.
+type itemArena struct {
+	blockSize int
+	block     []CacheItem
+	free      []*CacheItem
+}
+
+func (a *itemArena) alloc() *CacheItem {
+	if n := len(a.free); n > 0 {
+		item := a.free[n-1]
+		a.free[n-1] = nil
+		a.free = a.free[:n-1]
+		return item
+	}
+	if len(a.block) == 0 {
+		a.block = make([]CacheItem, a.blockSize)
+	}
+	item := &a.block[0]
+	a.block = a.block[1:]
+	item.pooled = true
+	return item
+}
+
+func (a *itemArena) release(item *CacheItem) {
+	*item = CacheItem{pooled: true}
+	a.free = append(a.free, item)
+}
+
+func (l *LRU) allocItem() *CacheItem {
+	if l.arena == nil {
+		return &CacheItem{}
+	}
+	return l.arena.alloc()
+}
+
+func (l *LRU) newItem(key string, value []byte, ttl time.Duration) *CacheItem {
+	item := l.allocItem()
+	item.Key = key
+	item.Value = value
+	item.ExpiresAt, item.deadline = l.expiry(ttl)
+	item.CreatedAt = l.now()
+	item.LastModified = item.CreatedAt
+	item.access = &accessStats{}
+	item.gen, item.gens = l.gens.current.Load(), &l.gens
+	return item
+}
+
+// copyItem returns a copy of src that can replace it in the table.
+func (l *LRU) copyItem(src *CacheItem) *CacheItem {
+	item := l.allocItem()
+	pooled := item.pooled
+	*item = *src
+	item.pooled = pooled
+	// A copy replaces the entry with a modified one, which a delta export
+	// has to include; invalidated entries stay invalidated.
+	if !src.gens.stale(src.gen) {
+		item.gen = l.gens.current.Load()
+	}
+	return item
+}
+
+// previous returns the row for key that the transaction is about to replace
+// or delete, so its slot can be retired after the commit. It skips the lookup
+// when the arena is disabled.
+func (l *LRU) previous(txn *memdb.Txn, key string) *CacheItem {
+	if l.arena == nil {
+		return nil
+	}
+	raw, err := txn.First("cache", "id", key)
+	if err != nil || raw == nil {
+		return nil
+	}
+	return raw.(*CacheItem)
+}
+
+// retire hands the slots of items that have left the table back to the
+// arena. The caller must hold the write lock and have committed.
+func (l *LRU) retire(items ...*CacheItem) {
+	if l.arena == nil {
+		return
+	}
+	for _, item := range items {
+		if item != nil && item.pooled {
+			l.arena.release(item)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package lrucache
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestArenaRecyclesSlots(t *testing.T) {
+	cache, _ := NewLRUWithTTL(3, Options{LogLevel: "error", Preallocate: 4})
+
+	cache.Set("key1", 1, 1*time.Hour)
+	cache.Set("key2", 2, 2*time.Hour)
+	cache.Set("key1", 10, 3*time.Hour) // overwrite retires the old slot
+	if n := len(cache.arena.free); n != 1 {
+		t.Fatalf("Expected 1 free slot after overwrite, got %d", n)
+	}
+
+	cache.Set("key3", 3, 4*time.Hour) // reuses the free slot
+	cache.Set("key4", 4, 5*time.Hour) // evicts key2
+	cache.Delete("key3")
+	cache.TouchMany([]string{"key1"}, 6*time.Hour)
+
+	if _, err := cache.Get("key2"); err != ErrItemNotFound {
+		t.Errorf("key2 should have been evicted, got %v", err)
+	}
+	if v, err := cache.Get("key1"); err != nil || v.(int) != 10 {
+		t.Errorf("Get key1 failed. Got %v, %v", v, err)
+	}
+	if v, err := cache.Get("key4"); err != nil || v.(int) != 4 {
+		t.Errorf("Get key4 failed. Got %v, %v", v, err)
+	}
+	if l := cache.Len(); l != 2 {
+		t.Errorf("Expected len 2, got %d", l)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent heap, got %v", err)
+	}
+
+	for _, item := range cache.arena.free {
+		if item.Key != "" || item.Value != nil || item.access != nil {
+			t.Errorf("Expected retired slots to be cleared, got %+v", item)
+		}
+	}
+}
+
+func TestArenaExpiry(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", Preallocate: 2})
+
+	cache.Set("key1", "value1", 50*time.Millisecond)
+	cache.Set("key2", "value2", 1*time.Hour)
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := cache.Get("key1"); err != ErrItemExpired {
+		t.Errorf("Expected key1 to be expired, got %v", err)
+	}
+	cache.Set("key3", "value3", 1*time.Hour)
+	if v, _ := cache.Get("key2"); v.(string) != "value2" {
+		t.Errorf("Expected key2 to be intact, got %v", v)
+	}
+	if v, _ := cache.Get("key3"); v.(string) != "value3" {
+		t.Errorf("Expected key3 to be intact, got %v", v)
+	}
+}
+
+// benchmarkGCPause fills a cache with 5M entries and reports the number of
+// collections and the total GC pause incurred while doing so. Each iteration
+// takes minutes, so run it with -benchtime 1x and a generous -timeout.
+func benchmarkGCPause(b *testing.B, preallocate int) {
+	if testing.Short() {
+		b.Skip("skipping 5M entry benchmark in short mode")
+	}
+	const entries = 5000000
+	for i := 0; i < b.N; i++ {
+		cache, _ := NewLRUWithTTL(entries, Options{LogLevel: "error", Preallocate: preallocate})
+
+		runtime.GC()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		for j := 0; j < entries; j++ {
+			cache.Set(fmt.Sprintf("key%d", j), j, 1*time.Hour)
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+
+		b.ReportMetric(float64(after.NumGC-before.NumGC), "gcs")
+		b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/1e6, "pause-ms")
+	}
+}
+
+func BenchmarkGCPauseArenaDisabled(b *testing.B) {
+	benchmarkGCPause(b, 0)
+}
+
+func BenchmarkGCPauseArenaEnabled(b *testing.B) {
+	benchmarkGCPause(b, 65536)
+}
@@ -0,0 +1,312 @@
+package lrucache
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// auditReport lists the disagreements between the memdb table and the
+// expiration heap and byte counter.
+type auditReport struct {
+	heapOrphans        []string // heap entries without a row
+	rowOrphans         []string // rows without a heap entry
+	duplicates         []string // keys present on the heap more than once
+	deadlineMismatches []string // heap deadline differs from the row's
+	indexMismatches    []string // heap position index disagrees with the heap
+	usedBytes          int64    // byte counter, while MaxBytes is set
+	tableBytes         int64    // what the rows add up to, while MaxBytes is set
+}
+
+func (r auditReport) total() int {
+	n := len(r.heapOrphans) + len(r.rowOrphans) + len(r.duplicates) +
+		len(r.deadlineMismatches) + len(r.indexMismatches)
+	if r.usedBytes != r.tableBytes {
+		n++
+	}
+	return n
+}
+
+func (r auditReport) String() string {
+	return fmt.Sprintf("heap orphans: %v, row orphans: %v, duplicates: %v, deadline mismatches: %v, index mismatches: %v, byte counter: %d of %d",
+		r.heapOrphans, r.rowOrphans, r.duplicates, r.deadlineMismatches, r.indexMismatches, r.usedBytes, r.tableBytes)
+}
+
+// redacted returns the report with its keys passed through redact.
+func (r auditReport) redacted(redact func([]string) []string) auditReport {
+	return auditReport{
+		heapOrphans:        redact(r.heapOrphans),
+		rowOrphans:         redact(r.rowOrphans),
+		duplicates:         redact(r.duplicates),
+		deadlineMismatches: redact(r.deadlineMismatches),
+		indexMismatches:    redact(r.indexMismatches),
+		usedBytes:          r.usedBytes,
+		tableBytes:         r.tableBytes,
+	}
+}
+
+func (l *LRU) auditManager() {
+	ticker := time.NewTicker(l.opts.AuditInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.audit()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// Validate checks that the expiration heap and the byte counter agree with
+// the memdb table and returns an error describing every inconsistency
+// found. It never repairs.
+func (l *LRU) Validate() error {
+	l.readLock("scan")
+	defer l.lock.RUnlock()
+
+	report, _, err := l.inspect()
+	if err != nil {
+		return err
+	}
+	if report.total() > 0 {
+		return fmt.Errorf("cache inconsistent: %s", report.redacted(l.redactAll))
+	}
+	return nil
+}
+
+// audit runs one consistency pass and rebuilds the heap and byte counter
+// from the table if anything disagrees.
+func (l *LRU) audit() {
+	l.writeLock("sweep")
+	defer l.lock.Unlock()
+
+	report, rows, err := l.inspect()
+	if err != nil {
+		l.log("error", "Audit failed: %v", err)
+		l.backgroundError(err)
+		return
+	}
+	if n := report.total(); n > 0 {
+		l.log("warn", "Audit repaired %d inconsistencies: %s", n, report.redacted(l.redactAll))
+		l.stats.inconsistenciesFound.Add(uint64(n))
+		l.rebuildHeap(rows)
+		l.recountBytes(report.tableBytes)
+	}
+}
+
+const (
+	// rebuildBatch is how many rows RebuildIndexes reads per read lock.
+	rebuildBatch = 1024
+	// rebuildAttempts is how many batched scans RebuildIndexes makes before
+	// it stops waiting for writes to pause and scans under the write lock.
+	rebuildAttempts = 3
+)
+
+// indexScan is what RebuildIndexes builds from one scan of the table, ready
+// to replace the cache's own structures.
+type indexScan struct {
+	changed <-chan struct{} // closed once the table changes after the scan
+	undated []*CacheItem    // rows missing a deadline or read statistics
+	present map[string]bool // keys of every row
+	heap    *expirationHeap // heap of every row
+	filter  *bloomFilter    // miss filter of every key, if there is one
+	bytes   int64           // size of the table
+	rows    int
+}
+
+// RebuildIndexes reconstructs everything the cache keeps beside the memdb
+// table from the table alone: the expiration heap with its fairness counts,
+// the byte counter, the miss filter, the dependency edges of derived keys
+// that are gone, and missing read statistics. It reads the table in batches
+// under the read lock and builds the new structures without holding any,
+// then swaps them in under a short write lock. A partly rebuilt heap would
+// let eviction pick the wrong entries, so if the table changed during the
+// scan it starts over, and after a few such attempts it scans under the
+// write lock instead.
+func (l *LRU) RebuildIndexes() error {
+	for attempt := 0; attempt < rebuildAttempts; attempt++ {
+		scan, err := l.scanIndexes(true)
+		if err != nil {
+			return err
+		}
+		l.writeLock("sweep")
+		select {
+		case <-scan.changed:
+			l.lock.Unlock()
+			continue
+		default:
+		}
+		l.installIndexes(scan)
+		l.lock.Unlock()
+		return nil
+	}
+
+	l.writeLock("sweep")
+	defer l.lock.Unlock()
+	scan, err := l.scanIndexes(false)
+	if err != nil {
+		return err
+	}
+	l.installIndexes(scan)
+	return nil
+}
+
+// scanIndexes reads every row from one snapshot of the table and builds the
+// structures RebuildIndexes installs. When batched, it takes the read lock
+// for every rebuildBatch rows, which keeps the arena from reusing a row while
+// it is read, and stops early once the table changes; otherwise the caller
+// must hold the lock.
+func (l *LRU) scanIndexes(batched bool) (*indexScan, error) {
+	if batched {
+		l.readLock("scan")
+	}
+	it, err := l.db.Txn(false).Get("cache", "id")
+	if err != nil {
+		if batched {
+			l.lock.RUnlock()
+		}
+		return nil, fmt.Errorf("failed to get all items: %v", err)
+	}
+	scan := &indexScan{changed: it.WatchCh(), present: make(map[string]bool)}
+	if l.missFilter != nil {
+		scan.filter = newBloomFilter(l.missFilter.cfg)
+	}
+	now, clock := l.now(), l.clock()
+	var keys []string
+	deadlines := make(map[string]time.Time)
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		item := obj.(*CacheItem)
+		// Rows written without going through the cache carry only their
+		// wall clock deadline.
+		deadline := item.deadline
+		switch {
+		case !deadline.IsZero():
+		case item.persistent():
+			deadline = neverDeadline
+		default:
+			deadline = clock.Add(item.ExpiresAt.Sub(now))
+		}
+		if item.deadline.IsZero() || item.access == nil {
+			scan.undated = append(scan.undated, item)
+		}
+		keys = append(keys, item.Key)
+		deadlines[item.Key] = deadline
+		scan.present[item.Key] = true
+		scan.bytes += entryBytes(item.Key, item.Value)
+		if scan.filter != nil {
+			scan.filter.add(item.Key)
+		}
+
+		if batched && len(keys)%rebuildBatch == 0 {
+			l.lock.RUnlock()
+			select {
+			case <-scan.changed:
+				return scan, nil
+			default:
+			}
+			l.readLock("scan")
+		}
+	}
+	if batched {
+		l.lock.RUnlock()
+	}
+	scan.rows = len(keys)
+	scan.heap = heapOf(l.size, keys, deadlines)
+	return scan, nil
+}
+
+// installIndexes replaces the cache's structures with those of scan, taken
+// from the table as it still is. The caller must hold the write lock.
+func (l *LRU) installIndexes(scan *indexScan) {
+	for _, item := range scan.undated {
+		if item.deadline.IsZero() {
+			item.deadline = scan.heap.deadlines[item.Key]
+		}
+		if item.access == nil {
+			item.access = &accessStats{}
+		}
+	}
+	l.installHeap(scan.heap)
+	l.recountBytes(scan.bytes)
+	if scan.filter != nil {
+		l.installFilter(scan.filter)
+	}
+	for key := range l.deps.deps {
+		if !scan.present[key] {
+			l.deps.forget(key)
+		}
+	}
+	l.log("info", "Rebuilt indexes from %d rows", scan.rows)
+}
+
+// inspect compares the heap and byte counter with the table. The caller must
+// hold the lock.
+func (l *LRU) inspect() (auditReport, []*CacheItem, error) {
+	var report auditReport
+
+	txn := l.db.Txn(false)
+	it, err := txn.Get("cache", "id")
+	if err != nil {
+		return report, nil, fmt.Errorf("failed to get all items: %v", err)
+	}
+	var rows []*CacheItem
+	byKey := make(map[string]*CacheItem)
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		item := obj.(*CacheItem)
+		rows = append(rows, item)
+		byKey[item.Key] = item
+		if l.opts.MaxBytes > 0 {
+			report.tableBytes += entryBytes(item.Key, item.Value)
+		}
+	}
+	report.usedBytes = l.usedBytes.Load()
+
+	onHeap := make(map[string]int, len(l.expHeap.items))
+	for _, key := range l.expHeap.items {
+		onHeap[key]++
+	}
+	for key, count := range onHeap {
+		item, ok := byKey[key]
+		switch {
+		case !ok:
+			report.heapOrphans = append(report.heapOrphans, key)
+		case count > 1:
+			report.duplicates = append(report.duplicates, key)
+		case !l.expHeap.deadlines[key].Equal(item.deadline):
+			report.deadlineMismatches = append(report.deadlineMismatches, key)
+		case !l.indexed(key):
+			report.indexMismatches = append(report.indexMismatches, key)
+		}
+	}
+	for key := range l.expHeap.index {
+		if _, ok := onHeap[key]; !ok {
+			report.indexMismatches = append(report.indexMismatches, key)
+		}
+	}
+	for key := range l.expHeap.deadlines {
+		if _, ok := onHeap[key]; !ok {
+			if _, ok := byKey[key]; !ok {
+				report.heapOrphans = append(report.heapOrphans, key)
+			}
+		}
+	}
+	for key := range byKey {
+		if _, ok := onHeap[key]; !ok {
+			report.rowOrphans = append(report.rowOrphans, key)
+		}
+	}
+
+	sort.Strings(report.heapOrphans)
+	sort.Strings(report.rowOrphans)
+	sort.Strings(report.duplicates)
+	sort.Strings(report.deadlineMismatches)
+	sort.Strings(report.indexMismatches)
+	return report, rows, nil
+}
+
+// indexed reports whether the heap position index points at key.
+func (l *LRU) indexed(key string) bool {
+	i, ok := l.expHeap.index[key]
+	return ok && i < len(l.expHeap.items) && l.expHeap.items[i] == key
+}
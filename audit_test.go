@@ -0,0 +1,167 @@
+package lrucache
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAuditRepairsCorruption(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	cache.Set("key1", 1, 1*time.Hour)
+	cache.Set("key2", 2, 1*time.Hour)
+	cache.Set("key3", 3, 100*time.Millisecond)
+
+	if err := cache.Validate(); err != nil {
+		t.Fatalf("Expected consistent cache, got %v", err)
+	}
+
+	// Corrupt the side structures: a ghost entry, a duplicate, a key whose
+	// heap entry went missing and a deadline that drifted.
+	cache.lock.Lock()
+	cache.expHeap.deadlines["ghost"] = time.Now().Add(time.Hour)
+	heap.Push(cache.expHeap, "ghost")
+	heap.Push(cache.expHeap, "key1")
+	for i, key := range cache.expHeap.items {
+		if key == "key2" {
+			heap.Remove(cache.expHeap, i)
+			break
+		}
+	}
+	delete(cache.expHeap.deadlines, "key2")
+	cache.expHeap.deadlines["key3"] = time.Now().Add(time.Hour)
+	cache.lock.Unlock()
+
+	if err := cache.Validate(); err == nil {
+		t.Fatal("Expected Validate to detect corruption")
+	}
+
+	cache.audit()
+
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected audit to repair the cache, got %v", err)
+	}
+	if n := cache.Stats().InconsistenciesFound; n != 4 {
+		t.Errorf("Expected 4 inconsistencies, got %d", n)
+	}
+
+	// The repaired heap drives expiry from the real deadlines again.
+	time.Sleep(150 * time.Millisecond)
+	cache.removeExpiredItems()
+	if _, err := cache.Get("key3"); err != ErrItemNotFound {
+		t.Errorf("Expected key3 to be swept, got %v", err)
+	}
+	if l := cache.Len(); l != 2 {
+		t.Errorf("Expected len 2, got %d", l)
+	}
+}
+
+func TestAuditBackground(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", AuditInterval: 10 * time.Millisecond})
+	cache.Set("key1", 1, 1*time.Hour)
+
+	cache.lock.Lock()
+	heap.Push(cache.expHeap, "key1")
+	cache.lock.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for cache.Stats().InconsistenciesFound == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected background audit to repair the cache, got %v", err)
+	}
+}
+
+func TestAuditRebuildIndexes(t *testing.T) {
+	cache, _ := NewLRUWithTTL(3, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("short", 1, time.Minute)
+	cache.Set("medium", 2, time.Hour)
+	cache.Set("long", 3, 2*time.Hour)
+
+	// Wreck the heap: lose one entry, leave a ghost and scramble the order.
+	cache.expHeap.remove("medium")
+	cache.expHeap.set("ghost", now)
+	cache.expHeap.items[0], cache.expHeap.items[1] = cache.expHeap.items[1], cache.expHeap.items[0]
+	if err := cache.Validate(); err == nil {
+		t.Fatal("Expected the corrupted heap to fail validation")
+	}
+
+	if err := cache.RebuildIndexes(); err != nil {
+		t.Fatalf("RebuildIndexes failed: %v", err)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent cache after rebuilding, got %v", err)
+	}
+
+	// Eviction picks the entry closest to expiring.
+	cache.Set("new", 4, 3*time.Hour)
+	if _, err := cache.Get("short"); err != ErrItemNotFound {
+		t.Errorf("Expected short to be evicted first, got %v", err)
+	}
+
+	now = now.Add(90 * time.Minute)
+	cache.removeExpiredItems()
+	if _, err := cache.Get("medium"); err != ErrItemNotFound {
+		t.Errorf("Expected medium to expire after rebuilding, got %v", err)
+	}
+	if l := cache.Len(); l != 2 {
+		t.Errorf("Expected 2 entries left, got %d", l)
+	}
+}
+
+func TestAuditRebuildIndexesConcurrentWrites(t *testing.T) {
+	const n = 3 * rebuildBatch
+	cache, _ := NewLRUWithTTL(2*n, Options{LogLevel: "error", Preallocate: 64, MaxBytes: 1 << 20})
+	for i := 0; i < n; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i, time.Duration(i+1)*time.Minute)
+	}
+	cache.expHeap.remove("key0")
+	if err := cache.RebuildIndexes(); err != nil {
+		t.Fatalf("RebuildIndexes failed: %v", err)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Fatalf("Expected a consistent cache after rebuilding in batches, got %v", err)
+	}
+	if key := cache.expHeap.items[0]; key != "key0" {
+		t.Errorf("Expected key0 to expire first, got %s", key)
+	}
+
+	// Writers landing between batches send the rebuild back to the start.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			cache.Set(fmt.Sprintf("key%d", i%n), i, time.Hour)
+			cache.Delete(fmt.Sprintf("new%d", i%16))
+			cache.Set(fmt.Sprintf("new%d", (i+1)%16), i, time.Hour)
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		cache.lock.Lock()
+		cache.expHeap.remove(fmt.Sprintf("key%d", i))
+		cache.usedBytes.Add(7)
+		cache.lock.Unlock()
+		if err := cache.RebuildIndexes(); err != nil {
+			t.Fatalf("RebuildIndexes failed: %v", err)
+		}
+		if err := cache.Validate(); err != nil {
+			t.Fatalf("Expected a consistent cache after rebuilding under writes, got %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
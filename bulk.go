@@ -0,0 +1,377 @@
+package lrucache
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TTLValue is a value to store with its own TTL, for SetMultiTTL.
+type TTLValue struct {
+	Value interface{}
+	TTL   time.Duration
+}
+
+// SetMulti stores every value in items with the same ttl. The entries go in
+// under one write lock and one transaction, and capacity is enforced once at
+// the end, which makes loading many keys much cheaper than calling Set for
+// each. The batch is all or nothing: if a value fails to serialize or a key
+// is refused, nothing is stored and the error names the key. SetMulti does
+// not apply Options.SetRateLimit.
+func (l *LRU) SetMulti(items map[string]interface{}, ttl time.Duration) error {
+	batch := make(map[string]TTLValue, len(items))
+	for key, value := range items {
+		batch[key] = TTLValue{Value: value, TTL: ttl}
+	}
+	return l.SetMultiTTL(batch)
+}
+
+// SetMultiTTL is SetMulti with a TTL per key.
+func (l *LRU) SetMultiTTL(items map[string]TTLValue) error {
+	defer l.checkWatermarks()
+
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	// Sorted, so that keys normalizing to the same entry resolve the same
+	// way on every call.
+	sort.Strings(keys)
+
+	data := make([][]byte, len(keys))
+	for i, key := range keys {
+		ttl := items[key].TTL
+		if ttl <= 0 && ttl != NoExpiration {
+			return l.invalid(l.NormalizeKey(key), "ttl must be positive or NoExpiration")
+		}
+		if err := l.checkUsefulTTL(ttl); err != nil {
+			return fmt.Errorf("key %s: %w", l.NormalizeKey(key), err)
+		}
+		var err error
+		if data[i], err = serialize(items[key].Value); err != nil {
+			return fmt.Errorf("failed to serialize value of key %s: %v", l.NormalizeKey(key), err)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	batch := make([]*CacheItem, len(keys))
+	for i, key := range keys {
+		batch[i] = l.newItem(l.NormalizeKey(key), data[i], items[key].TTL)
+		if err := l.admitKey(batch[i].Key); err != nil {
+			return err
+		}
+	}
+	return l.storeMany(batch)
+}
+
+// SetItem is one entry for SetManyItems.
+type SetItem struct {
+	Key   string
+	Value interface{}
+	TTL   time.Duration
+}
+
+// SetManyItems stores items, each with its own TTL, in one write transaction
+// as SetMultiTTL does. Items that cannot be stored, because of an empty key,
+// a TTL that is not positive or below Options.MinUsefulTTL, a value that
+// fails to serialize or is larger than Options.MaxBytes, a key refused by
+// the cardinality guard, or a key holding an entry stored with SetImmutable,
+// are reported in failed by key and the others are stored. With
+// Options.SetManyAllOrNothing set, any failed item keeps the whole batch out
+// and err wraps ErrNotStored. err also reports failures of the batch as a
+// whole, such as ErrClosed, in which case nothing is stored. A key given
+// more than once is stored with its last valid item.
+func (l *LRU) SetManyItems(items []SetItem) (failed map[string]error, err error) {
+	defer l.checkWatermarks()
+
+	errs := make([]error, len(items))
+	data := make([][]byte, len(items))
+	for i, it := range items {
+		errs[i] = l.checkItem(l.NormalizeKey(it.Key), it, &data[i])
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	if err := l.checkWritable(""); err != nil {
+		return nil, err
+	}
+	failed = make(map[string]error)
+	n := 0
+	for i, it := range items {
+		if errs[i] == nil {
+			errs[i] = l.admitKey(l.NormalizeKey(it.Key))
+		}
+		if errs[i] == nil {
+			errs[i] = l.checkMutableKey(l.NormalizeKey(it.Key))
+		}
+		if errs[i] != nil {
+			failed[it.Key] = errs[i]
+			n++
+		}
+	}
+	if n > 0 && l.opts.SetManyAllOrNothing {
+		return failed, fmt.Errorf("%w: %d of %d items failed", ErrNotStored, n, len(items))
+	}
+
+	batch := make([]*CacheItem, 0, len(items))
+	for i, it := range items {
+		if errs[i] == nil {
+			batch = append(batch, l.newItem(l.NormalizeKey(it.Key), data[i], it.TTL))
+		}
+	}
+	if len(batch) == 0 {
+		return failed, nil
+	}
+	return failed, l.storeMany(batch)
+}
+
+// checkItem validates one SetManyItems item stored under key and serializes
+// its value into data.
+func (l *LRU) checkItem(key string, it SetItem, data *[]byte) error {
+	if key == "" {
+		return l.invalid("", "key must not be empty")
+	}
+	if it.TTL <= 0 && it.TTL != NoExpiration {
+		return l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+	if err := l.checkUsefulTTL(it.TTL); err != nil {
+		return err
+	}
+	var err error
+	if *data, err = serialize(it.Value); err != nil {
+		return fmt.Errorf("failed to serialize value: %v", err)
+	}
+	if limit := l.opts.MaxBytes; limit > 0 && entryBytes(key, *data) > limit {
+		return fmt.Errorf("%w: %d bytes exceed MaxBytes %d", ErrNotStored, entryBytes(key, *data), limit)
+	}
+	return nil
+}
+
+// storeMany is store for a batch of items. The rows go in under one
+// transaction and the heap takes all deadlines in one setMany, which rebuilds
+// it at once for large batches instead of fixing it once per key. Capacity is
+// enforced once at the end, so a batch larger than the cache keeps the
+// entries expiring last. Later items win over earlier ones with the same key.
+// The caller must hold the write lock.
+func (l *LRU) storeMany(items []*CacheItem) error {
+	if err := l.checkWritable(""); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.Key == "" {
+			return l.invalid("", "key must not be empty")
+		}
+		if err := l.checkReplace(item); err != nil {
+			return err
+		}
+	}
+	if growth, keys := l.batchGrowth(items); growth > 0 {
+		if err := l.makeRoom(growth, keys...); err != nil {
+			return err
+		}
+	}
+	deadlines := make(map[string]time.Time, len(items))
+	var retired []*CacheItem
+
+	growth, _ := l.batchGrowth(items)
+	txn := l.db.Txn(true)
+	for _, item := range items {
+		l.filterAdd(item.Key)
+		l.stampModified(txn, item)
+		retired = append(retired, l.previous(txn, item.Key))
+		if err := txn.Insert("cache", item); err != nil {
+			txn.Abort()
+			return fmt.Errorf("failed to insert item: %v", err)
+		}
+		deadlines[item.Key] = item.deadline
+	}
+	txn.Commit()
+	l.resized(growth)
+	l.retire(retired...)
+
+	l.expHeap.setMany(deadlines)
+	for _, item := range items {
+		l.notifyWatchers(item.Key, item.Value)
+	}
+	for key := range deadlines {
+		l.groups.set(key)
+		l.deps.forget(key)
+		l.invalidateDependents(key)
+	}
+	if err := l.evictOverCapacity(); err != nil && l.opts.StrictErrors {
+		return err
+	}
+
+	l.log("debug", "Stored %d keys", len(deadlines))
+	return nil
+}
+
+// GetMulti looks up every key in one read transaction under a single read
+// lock and returns the values found, keyed as passed in, and the keys that
+// were missing or expired. Expired entries are reported as missing and left
+// for the sweep, so the lookups never write; only an entry whose last
+// allowed read GetMulti took is removed afterwards, as Get would, and under
+// Options.SlidingTTL the deadlines of the entries read are moved in one
+// write transaction afterwards. Hits and misses count as they do for Get,
+// but GetMulti does not run GetMiddleware or Options.Loader.
+func (l *LRU) GetMulti(keys []string) (map[string]interface{}, []string, error) {
+	values := make(map[string]interface{}, len(keys))
+	seen := make(map[string]bool, len(keys))
+	var missing, consumed []string
+	var read map[string]time.Time
+	if l.opts.SlidingTTL {
+		read = make(map[string]time.Time, len(keys))
+	}
+
+	l.readLock("get")
+	txn := l.db.Txn(false)
+	clock, now := l.clock(), l.now()
+	serveStale := l.drain.Load() == drainStale
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		norm := l.NormalizeKey(key)
+		var item *CacheItem
+		if !l.definitelyMissing(norm) {
+			raw, err := txn.First("cache", "id", norm)
+			if err != nil {
+				l.lock.RUnlock()
+				return nil, nil, fmt.Errorf("failed to retrieve item: %v", err)
+			}
+			item, _ = raw.(*CacheItem)
+		}
+		if item == nil || (item.expired(clock) && !serveStale) {
+			missing = append(missing, key)
+			l.stats.misses.Add(1)
+			l.groups.miss(norm)
+			continue
+		}
+		value, err := l.decode(item.Value)
+		if err != nil {
+			l.lock.RUnlock()
+			return nil, nil, fmt.Errorf("failed to deserialize value of key %s: %w", norm, err)
+		}
+		switch item.consumeRead() {
+		case ErrItemNotFound:
+			missing = append(missing, key)
+			l.stats.misses.Add(1)
+			l.groups.miss(norm)
+			continue
+		case errLastRead:
+			consumed = append(consumed, norm)
+		default:
+			if read != nil {
+				read[norm] = item.ExpiresAt
+			}
+		}
+		item.recordAccess(now)
+		values[key] = value
+		l.stats.hits.Add(1)
+		l.groups.hit(norm)
+	}
+	l.lock.RUnlock()
+
+	for _, key := range consumed {
+		if err := l.removeConsumed(key); err != nil && l.opts.StrictErrors {
+			return values, missing, fmt.Errorf("failed to remove consumed item: %w", err)
+		}
+	}
+	l.slideMany(read)
+	return values, missing, nil
+}
+
+// DeleteMulti removes every given key, and the variants stored under it, in
+// one write transaction and returns how many of the keys had a live entry.
+// Missing keys are skipped. The removal callbacks only fire when
+// Options.DeleteMultiCallbacks is set.
+func (l *LRU) DeleteMulti(keys []string) (int, error) {
+	defer l.checkWatermarks()
+	l.writeLock("delete")
+	defer l.lock.Unlock()
+	return l.deleteMany(keys)
+}
+
+// deleteMany is DeleteMulti for a caller that holds the write lock.
+func (l *LRU) deleteMany(keys []string) (int, error) {
+	txn := l.db.Txn(true)
+	clock := l.clock()
+	seen := make(map[string]bool, len(keys))
+	var removed []*CacheItem
+	var freed int64
+	present := 0
+	for _, key := range keys {
+		key = l.NormalizeKey(key)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		raw, err := txn.First("cache", "id", key)
+		if err != nil {
+			txn.Abort()
+			return 0, fmt.Errorf("failed to find item: %v", err)
+		}
+		it, err := txn.Get("cache", "base", key)
+		if err != nil {
+			txn.Abort()
+			return 0, fmt.Errorf("failed to get variants: %v", err)
+		}
+		rows := make([]*CacheItem, 0, 1)
+		if raw != nil {
+			rows = append(rows, raw.(*CacheItem))
+		}
+		for obj := it.Next(); obj != nil; obj = it.Next() {
+			rows = append(rows, obj.(*CacheItem))
+		}
+		live := false
+		for _, item := range rows {
+			live = live || !item.expired(clock)
+			freed += entryBytes(item.Key, item.Value)
+			if err := txn.Delete("cache", item); err != nil {
+				txn.Abort()
+				return 0, fmt.Errorf("failed to delete item: %v", err)
+			}
+		}
+		if live {
+			present++
+		}
+		removed = append(removed, rows...)
+	}
+	if len(removed) == 0 {
+		txn.Abort()
+		return 0, nil
+	}
+	txn.Commit()
+	l.resized(-freed)
+	l.filterRemoved(len(removed))
+
+	for _, item := range removed {
+		l.expHeap.remove(item.Key)
+		if l.opts.DeleteMultiCallbacks {
+			if l.opts.EvictCallback != nil {
+				l.opts.EvictCallback(item.Key, nil)
+			}
+			if l.opts.RemovalCallback != nil {
+				value, _ := l.decode(item.Value)
+				l.opts.RemovalCallback(item.Key, value, ReasonDeleted)
+			}
+		}
+		l.closeWatchers(item.Key)
+		l.recordDeletion(item.Key)
+		l.deps.forget(item.Key)
+	}
+	for _, item := range removed {
+		l.invalidateDependents(item.Key)
+	}
+	l.retire(removed...)
+
+	l.log("debug", "Deleted %d keys", present)
+	return present, nil
+}
@@ -0,0 +1,410 @@
+package lrucache
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bulkItems(cache *LRU, n int) []*CacheItem {
+	items := make([]*CacheItem, n)
+	for i := range items {
+		// Spread the TTLs so that no two deadlines tie.
+		ttl := time.Duration((i*7919)%n+1) * time.Second
+		items[i] = cache.newItem(fmt.Sprintf("key%d", i), []byte{tagString}, ttl)
+	}
+	return items
+}
+
+func expirationOrder(cache *LRU) []string {
+	var keys []string
+	for cache.expHeap.Len() > 0 {
+		keys = append(keys, heap.Pop(cache.expHeap).(string))
+	}
+	return keys
+}
+
+func TestStoreManyMatchesStore(t *testing.T) {
+	for _, existing := range []int{0, 50, 1000} {
+		t.Run(fmt.Sprintf("%d existing", existing), func(t *testing.T) {
+			perKey, _ := NewLRUWithTTL(2000, Options{LogLevel: "error"})
+			bulk, _ := NewLRUWithTTL(2000, Options{LogLevel: "error"})
+			now := time.Unix(1700000000, 0)
+			perKey.now = func() time.Time { return now }
+			bulk.now = func() time.Time { return now }
+			for i := 0; i < existing; i++ {
+				// Half seconds keep these apart from the bulk deadlines.
+				ttl := time.Duration(i)*time.Minute + 1500*time.Millisecond
+				perKey.Set(fmt.Sprintf("old%d", i), i, ttl)
+				bulk.Set(fmt.Sprintf("old%d", i), i, ttl)
+			}
+
+			for _, item := range bulkItems(perKey, 100) {
+				if err := perKey.store(item, nil); err != nil {
+					t.Fatalf("store failed: %v", err)
+				}
+			}
+			if err := bulk.storeMany(bulkItems(bulk, 100)); err != nil {
+				t.Fatalf("storeMany failed: %v", err)
+			}
+
+			if got, want := liveKeys(bulk), liveKeys(perKey); !reflect.DeepEqual(got, want) {
+				t.Fatalf("Expected the same keys, got %d and %d", len(got), len(want))
+			}
+			if got, want := expirationOrder(bulk), expirationOrder(perKey); !reflect.DeepEqual(got, want) {
+				t.Errorf("Expiration order differs from the per-key path")
+			}
+		})
+	}
+}
+
+func TestStoreManyOverCapacity(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	if err := cache.storeMany(bulkItems(cache, 100)); err != nil {
+		t.Fatalf("storeMany failed: %v", err)
+	}
+	if n := cache.expHeap.Len(); n != 10 {
+		t.Fatalf("Expected 10 entries on the heap, got %d", n)
+	}
+	if got := liveKeys(cache); len(got) != 10 {
+		t.Fatalf("Expected 10 live entries, got %d", len(got))
+	}
+	// The survivors are the ten entries expiring last.
+	order := expirationOrder(cache)
+	for _, key := range order {
+		var i int
+		fmt.Sscanf(key, "key%d", &i)
+		if ttl := (i*7919)%100 + 1; ttl <= 90 {
+			t.Errorf("Expected only the latest deadlines to survive, %s has ttl %ds", key, ttl)
+		}
+	}
+}
+
+// BenchmarkPreloadPerKey and BenchmarkPreloadBulk load 100k entries per
+// op, so ns/op is the cost of the whole load, not of one entry.
+func BenchmarkPreloadPerKey(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		cache, _ := NewLRUWithTTL(100000, Options{LogLevel: "error"})
+		items := bulkItems(cache, 100000)
+		b.StartTimer()
+
+		cache.writeLock("set")
+		for _, item := range items {
+			cache.store(item, nil)
+		}
+		cache.lock.Unlock()
+	}
+}
+
+func BenchmarkPreloadBulk(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		cache, _ := NewLRUWithTTL(100000, Options{LogLevel: "error"})
+		items := bulkItems(cache, 100000)
+		b.StartTimer()
+
+		cache.writeLock("set")
+		cache.storeMany(items)
+		cache.lock.Unlock()
+	}
+}
+
+func TestLRUSetMulti(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	cache.Set("a", "old", time.Hour)
+
+	if err := cache.SetMulti(map[string]interface{}{"a": "1", "b": 2}, time.Minute); err != nil {
+		t.Fatalf("SetMulti failed: %v", err)
+	}
+	err := cache.SetMultiTTL(map[string]TTLValue{
+		"c": {Value: "3", TTL: time.Hour},
+		"d": {Value: "4", TTL: 30 * time.Second},
+	})
+	if err != nil {
+		t.Fatalf("SetMultiTTL failed: %v", err)
+	}
+	if v, err := cache.Get("a"); err != nil || v != "1" {
+		t.Errorf("Expected a to be replaced with 1, got %v, %v", v, err)
+	}
+	if v, err := cache.Get("b"); err != nil || v != 2 {
+		t.Errorf("Expected b to be 2, got %v, %v", v, err)
+	}
+	if order := expirationOrder(cache); len(order) != 4 || order[0] != "d" || order[3] != "c" {
+		t.Errorf("Expected d to expire first and c last, got %v", order)
+	}
+}
+
+func TestLRUSetMultiAbortsOnBadValue(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("a", "old", time.Hour)
+
+	err := cache.SetMulti(map[string]interface{}{"a": "new", "bad": make(chan int), "c": 3}, time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "bad") {
+		t.Fatalf("Expected an error naming the bad key, got %v", err)
+	}
+	if v, _ := cache.Get("a"); v != "old" {
+		t.Errorf("Expected a to be untouched, got %v", v)
+	}
+	if cache.Contains("c") {
+		t.Errorf("Expected no entry of the failed batch to be stored")
+	}
+
+	err = cache.SetMultiTTL(map[string]TTLValue{"a": {Value: "new", TTL: time.Hour}, "c": {Value: 3}})
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected ErrInvalidArgument for a missing ttl, got %v", err)
+	}
+	if cache.Contains("c") {
+		t.Errorf("Expected no entry of the failed batch to be stored")
+	}
+}
+
+func TestLRUSetMultiOverCapacity(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	items := make(map[string]TTLValue, 100)
+	for i := 0; i < 100; i++ {
+		items[fmt.Sprintf("key%d", i)] = TTLValue{Value: i, TTL: time.Duration(i+1) * time.Second}
+	}
+	if err := cache.SetMultiTTL(items); err != nil {
+		t.Fatalf("SetMultiTTL failed: %v", err)
+	}
+	if n := cache.Len(); n != 10 {
+		t.Fatalf("Expected 10 entries, got %d", n)
+	}
+	for i := 90; i < 100; i++ {
+		if !cache.Contains(fmt.Sprintf("key%d", i)) {
+			t.Errorf("Expected key%d, expiring last, to survive", i)
+		}
+	}
+}
+
+func BenchmarkSetMulti(b *testing.B) {
+	items := make(map[string]interface{}, 5000)
+	for i := 0; i < 5000; i++ {
+		items[fmt.Sprintf("key%d", i)] = i
+	}
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		cache, _ := NewLRUWithTTL(10000, Options{LogLevel: "error"})
+		b.StartTimer()
+
+		cache.SetMulti(items, time.Hour)
+	}
+}
+
+func mixedSetItems() []SetItem {
+	return []SetItem{
+		{Key: "a", Value: "ok", TTL: time.Hour},
+		{Key: "zero", Value: 1, TTL: 0},
+		{Key: "", Value: 1, TTL: time.Hour},
+		{Key: "chan", Value: make(chan int), TTL: time.Hour},
+		{Key: "big", Value: strings.Repeat("x", 200), TTL: time.Hour},
+		{Key: "brief", Value: 1, TTL: time.Millisecond},
+		{Key: "b", Value: "ok", TTL: 2 * time.Hour},
+	}
+}
+
+func checkSetItemsFailures(t *testing.T, failed map[string]error) {
+	t.Helper()
+	want := map[string]error{
+		"zero":  ErrInvalidArgument,
+		"":      ErrInvalidArgument,
+		"chan":  nil,
+		"big":   ErrNotStored,
+		"brief": ErrNotStored,
+	}
+	if len(failed) != len(want) {
+		t.Errorf("Expected failures for %d items, got %v", len(want), failed)
+	}
+	for key, target := range want {
+		err, ok := failed[key]
+		if !ok {
+			t.Errorf("Expected %q to fail", key)
+			continue
+		}
+		if target != nil && !errors.Is(err, target) {
+			t.Errorf("Expected %q to fail with %v, got %v", key, target, err)
+		}
+	}
+}
+
+func TestLRUSetManyItemsPartial(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", MaxBytes: 100, MinUsefulTTL: time.Second})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	failed, err := cache.SetManyItems(mixedSetItems())
+	if err != nil {
+		t.Fatalf("SetManyItems failed: %v", err)
+	}
+	checkSetItemsFailures(t, failed)
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("Expected only the valid items to land, got %v", keys)
+	}
+	if ttl, _ := cache.TTL("b"); ttl != 2*time.Hour {
+		t.Errorf("Expected b to keep its own TTL, got %v", ttl)
+	}
+	if order := expirationOrder(cache); !reflect.DeepEqual(order, []string{"a", "b"}) {
+		t.Errorf("Expected the heap to hold a then b, got %v", order)
+	}
+}
+
+func TestLRUSetManyItemsAllOrNothing(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", MaxBytes: 100, MinUsefulTTL: time.Second, SetManyAllOrNothing: true})
+	cache.Set("a", "old", time.Hour)
+
+	failed, err := cache.SetManyItems(mixedSetItems())
+	if !errors.Is(err, ErrNotStored) {
+		t.Errorf("Expected ErrNotStored, got %v", err)
+	}
+	checkSetItemsFailures(t, failed)
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Errorf("Expected nothing to land, got %v", keys)
+	}
+	if v, _ := cache.Get("a"); v != "old" {
+		t.Errorf("Expected a to be untouched, got %v", v)
+	}
+
+	failed, err = cache.SetManyItems([]SetItem{{Key: "a", Value: "new", TTL: time.Hour}, {Key: "c", Value: 3, TTL: time.Minute}})
+	if err != nil || len(failed) != 0 {
+		t.Fatalf("Expected a valid batch to land, got %v, %v", failed, err)
+	}
+	if v, _ := cache.Get("a"); v != "new" {
+		t.Errorf("Expected a to be replaced, got %v", v)
+	}
+	if !cache.Contains("c") {
+		t.Errorf("Expected c to be stored")
+	}
+}
+
+func TestLRUGetMulti(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", "1", time.Hour)
+	cache.Set("b", 2, time.Hour)
+	cache.Set("old", "v", time.Minute)
+	cache.SetWithReadLimit("once", "v", time.Hour, 1)
+	now = now.Add(2 * time.Minute)
+
+	values, missing, err := cache.GetMulti([]string{"a", "b", "old", "none", "once", "a"})
+	if err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	if want := map[string]interface{}{"a": "1", "b": 2, "once": "v"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("Expected %v, got %v", want, values)
+	}
+	if want := []string{"old", "none"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("Expected missing %v, got %v", want, missing)
+	}
+	if s := cache.Stats(); s.Hits != 3 || s.Misses != 2 {
+		t.Errorf("Expected 3 hits and 2 misses, got %d and %d", s.Hits, s.Misses)
+	}
+
+	// The expired entry is left for the sweep; the consumed one is gone.
+	if n := cache.Len(); n != 3 {
+		t.Errorf("Expected the expired entry to stay in the table, got %d entries", n)
+	}
+	if _, missing, _ := cache.GetMulti([]string{"once"}); len(missing) != 1 {
+		t.Errorf("Expected the read limited entry to be used up, got missing %v", missing)
+	}
+}
+
+func benchmarkGetMany(b *testing.B, get func(cache *LRU, keys []string)) {
+	cache, _ := NewLRUWithTTL(1000, Options{LogLevel: "error"})
+	keys := make([]string, 50)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		cache.Set(keys[i], i, time.Hour)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		get(cache, keys)
+	}
+}
+
+func BenchmarkGet50PerKey(b *testing.B) {
+	benchmarkGetMany(b, func(cache *LRU, keys []string) {
+		for _, key := range keys {
+			cache.Get(key)
+		}
+	})
+}
+
+func BenchmarkGet50Multi(b *testing.B) {
+	benchmarkGetMany(b, func(cache *LRU, keys []string) {
+		cache.GetMulti(keys)
+	})
+}
+
+func TestLRUDeleteMulti(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	cache.Set("a", 1, time.Hour)
+	cache.Set("b", 2, time.Hour)
+	cache.Set("c", 3, time.Hour)
+	cache.Set("old", 4, time.Minute)
+	cache.SetVariant("page", "gzip", []byte("z"), time.Hour)
+	now = now.Add(2 * time.Minute)
+
+	n, err := cache.DeleteMulti([]string{"a", "none", "page", "old", "a"})
+	if err != nil {
+		t.Fatalf("DeleteMulti failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 keys present, got %d", n)
+	}
+	if got := cache.Keys(); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("Expected b and c to remain, got %v", got)
+	}
+	if n := cache.expHeap.Len(); n != 2 {
+		t.Errorf("Expected 2 entries on the heap, got %d", n)
+	}
+	if n, err := cache.DeleteMulti([]string{"none"}); err != nil || n != 0 {
+		t.Errorf("Expected missing keys to be skipped, got %d, %v", n, err)
+	}
+}
+
+func TestLRUDeleteMultiCallbacks(t *testing.T) {
+	for _, fire := range []bool{false, true} {
+		t.Run(fmt.Sprintf("callbacks %v", fire), func(t *testing.T) {
+			var evicted []string
+			removed := make(map[string]EvictReason)
+			cache, _ := NewLRUWithTTL(10, Options{
+				LogLevel:             "error",
+				DeleteMultiCallbacks: fire,
+				EvictCallback:        func(key string, value interface{}) { evicted = append(evicted, key) },
+				RemovalCallback: func(key string, value interface{}, reason EvictReason) {
+					removed[key] = reason
+				},
+			})
+			cache.Set("a", 1, time.Hour)
+			cache.Set("b", 2, time.Hour)
+			cache.SetDerived("sum", 3, time.Hour, []string{"a", "b"})
+
+			if _, err := cache.DeleteMulti([]string{"a", "b"}); err != nil {
+				t.Fatalf("DeleteMulti failed: %v", err)
+			}
+			if cache.Contains("sum") {
+				t.Errorf("Expected the derived entry to be invalidated")
+			}
+			want := map[string]EvictReason{"sum": ReasonDependency}
+			if fire {
+				want["a"], want["b"] = ReasonDeleted, ReasonDeleted
+			}
+			if !reflect.DeepEqual(removed, want) {
+				t.Errorf("Expected removals %v, got %v", want, removed)
+			}
+			if len(evicted) != len(want) {
+				t.Errorf("Expected %d evict callbacks, got %v", len(want), evicted)
+			}
+		})
+	}
+}
@@ -1,9 +1,111 @@
 package lrucache
 
-import "time"
+import (
+	"sync/atomic"
+	"time"
+)
 
 type CacheItem struct {
-	Key       string
-	Value     []byte
+	Key   string
+	Value []byte
+	// ExpiresAt is zero for entries that never expire; see Persist.
 	ExpiresAt time.Time
+	CreatedAt time.Time
+	// LastModified is when the value was last written. Changing only the
+	// deadline, as Touch does, leaves it alone, and each write of a key gets
+	// a later LastModified than the one before even if the clock has not
+	// moved.
+	LastModified time.Time
+
+	// Base and Variant are set for entries stored with SetVariant; Key is
+	// then derived from both.
+	Base    string
+	Variant string
+
+	// Immutable is set for entries stored with SetImmutable.
+	Immutable bool
+
+	// deadline is ExpiresAt on the cache's monotonic clock; expiry decisions
+	// use it so that wall clock jumps do not affect them.
+	deadline time.Time
+
+	// access is shared by every copy of the item made while its value is
+	// updated in place, so read statistics survive TTL refreshes.
+	access *accessStats
+	// reads counts the reads left for entries stored with SetWithReadLimit
+	// and is nil for the rest. Like access it is shared between copies.
+	reads *atomic.Int64
+	// gen is the generation the item was written in; gens holds the
+	// cache's invalidation floor for it.
+	gen  uint64
+	gens *generations
+	// pooled marks items whose memory belongs to the item arena.
+	pooled bool
+	// overwrite lets a write replace an immutable entry. It is cleared
+	// before the item is committed.
+	overwrite bool
+}
+
+// accessStats records reads of an entry without taking the write lock.
+type accessStats struct {
+	hits       atomic.Uint64
+	lastAccess atomic.Int64 // unix nanoseconds, zero if never read
+}
+
+// neverDeadline is the deadline of entries that never expire. It sorts after
+// every real deadline, so no sweep reaches them: the sweep stops at the
+// first deadline still ahead. They are kept on the expiration heap rather
+// than left out of it because the heap is also the capacity eviction order,
+// and being on it is what lets them be evicted, after every expiring entry,
+// when the cache is full.
+var neverDeadline = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// NoExpiration, passed as the ttl of Set and the other writes, stores an
+// entry that never expires. It still counts toward the size limits and can
+// be evicted for capacity, after every entry that does expire. It is also
+// what TTL reports for such entries.
+const NoExpiration time.Duration = -1
+
+// expired reports whether the item's deadline has passed at clock, a time
+// on the cache's monotonic timeline, or its generation was invalidated.
+func (i *CacheItem) expired(clock time.Time) bool {
+	return clock.After(i.deadline) || i.gens.stale(i.gen)
+}
+
+// persistent reports whether the item never expires.
+func (i *CacheItem) persistent() bool {
+	return i.ExpiresAt.IsZero()
+}
+
+// ttl returns the TTL the item was written with, or NoExpiration.
+func (i *CacheItem) ttl() time.Duration {
+	if i.persistent() {
+		return NoExpiration
+	}
+	return i.ExpiresAt.Sub(i.CreatedAt)
+}
+
+func (i *CacheItem) recordAccess(now time.Time) {
+	if i.access != nil {
+		i.access.hits.Add(1)
+		i.access.lastAccess.Store(now.UnixNano())
+	}
+}
+
+// lastActive returns the time of the last read, or of the write if the item
+// has never been read.
+func (i *CacheItem) lastActive() time.Time {
+	if i.access != nil {
+		if ns := i.access.lastAccess.Load(); ns != 0 {
+			return time.Unix(0, ns)
+		}
+	}
+	return i.CreatedAt
+}
+
+func (i *CacheItem) hits() uint64 {
+	if i.access == nil {
+		return 0
+	}
+	return i.access.hits.Load()
 }
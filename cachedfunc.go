@@ -0,0 +1,166 @@
+package lrucache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyFunc derives the part of a memoized call's cache key that follows the
+// prefix from the call's arguments.
+type KeyFunc func(args ...interface{}) string
+
+// FuncOption configures CachedFunc1 and CachedFunc2.
+type FuncOption func(*funcConfig)
+
+type funcConfig struct {
+	key KeyFunc
+}
+
+// WithKeyFunc replaces the default key derivation, which quotes the string
+// form of each argument.
+func WithKeyFunc(key KeyFunc) FuncOption {
+	return func(cfg *funcConfig) { cfg.key = key }
+}
+
+func defaultKey(args ...interface{}) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = strconv.Quote(fmt.Sprint(arg))
+	}
+	return strings.Join(parts, ",")
+}
+
+// CachedFunc1 memoizes fn in c under keyPrefix plus a key derived from the
+// argument. Concurrent calls with the same argument that miss the cache share
+// a single call to fn. Errors are returned to every waiting caller but never
+// cached. While an LRU is in drain mode, a miss returns ErrDraining without
+// calling fn.
+func CachedFunc1[A comparable, R any](c Cacher, ttl time.Duration, keyPrefix string, fn func(ctx context.Context, a A) (R, error), opts ...FuncOption) func(ctx context.Context, a A) (R, error) {
+	m := newMemoizer[R](c, ttl, keyPrefix, opts)
+	return func(ctx context.Context, a A) (R, error) {
+		return m.call(ctx, m.key(a), func(ctx context.Context) (R, error) { return fn(ctx, a) })
+	}
+}
+
+// CachedFunc2 is CachedFunc1 for functions of two arguments.
+func CachedFunc2[A, B comparable, R any](c Cacher, ttl time.Duration, keyPrefix string, fn func(ctx context.Context, a A, b B) (R, error), opts ...FuncOption) func(ctx context.Context, a A, b B) (R, error) {
+	m := newMemoizer[R](c, ttl, keyPrefix, opts)
+	return func(ctx context.Context, a A, b B) (R, error) {
+		return m.call(ctx, m.key(a, b), func(ctx context.Context) (R, error) { return fn(ctx, a, b) })
+	}
+}
+
+type memoizer[R any] struct {
+	c      Cacher
+	ttl    time.Duration
+	prefix string
+	cfg    funcConfig
+	calls  flightGroup
+}
+
+func newMemoizer[R any](c Cacher, ttl time.Duration, prefix string, opts []FuncOption) *memoizer[R] {
+	m := &memoizer[R]{c: c, ttl: ttl, prefix: prefix, cfg: funcConfig{key: defaultKey}}
+	for _, opt := range opts {
+		opt(&m.cfg)
+	}
+	return m
+}
+
+func (m *memoizer[R]) key(args ...interface{}) string {
+	return m.prefix + m.cfg.key(args...)
+}
+
+func (m *memoizer[R]) call(ctx context.Context, key string, fn func(ctx context.Context) (R, error)) (R, error) {
+	if v, err := m.c.Get(key); err == nil {
+		if r, err := decodeInto[R](v); err == nil {
+			return r, nil
+		}
+	}
+
+	// A draining cache serves what it has and nothing more.
+	if d, ok := m.c.(interface{ Draining() bool }); ok && d.Draining() {
+		var zero R
+		return zero, ErrDraining
+	}
+
+	v, err := m.calls.do(key, func() (interface{}, error) {
+		// An earlier flight may have stored the result since the miss.
+		if v, err := m.c.Get(key); err == nil {
+			if r, err := decodeInto[R](v); err == nil {
+				return r, nil
+			}
+		}
+		r, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// A failed store only costs a later call; the result is still good.
+		_ = m.c.Set(key, r, m.ttl)
+		return r, nil
+	})
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+	return v.(R), nil
+}
+
+// decodeInto converts a value read back from a cache into R. Values stored
+// as JSON come back as generic maps and slices and are re-decoded into R.
+func decodeInto[R any](v interface{}) (R, error) {
+	if r, ok := v.(R); ok {
+		return r, nil
+	}
+	var r R
+	data, err := json.Marshal(v)
+	if err != nil {
+		return r, err
+	}
+	err = json.Unmarshal(data, &r)
+	return r, err
+}
+
+// flightGroup runs one call per key at a time and hands its result to every
+// caller that asked for the key in the meantime.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+var errFlightPanicked = errors.New("call panicked")
+
+func (g *flightGroup) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.value, c.err
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	c := &flightCall{done: make(chan struct{}), err: errFlightPanicked}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = fn()
+	return c.value, c.err
+}
@@ -0,0 +1,93 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type profile struct {
+	ID   int
+	Name string
+}
+
+func TestCachedFunc1SingleExecution(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{LogLevel: "error"})
+	var calls [3]atomic.Int32
+	release := make(chan struct{})
+	load := CachedFunc1(cache, time.Hour, "profile:", func(ctx context.Context, id int) (profile, error) {
+		calls[id].Add(1)
+		<-release
+		return profile{ID: id, Name: fmt.Sprintf("user%d", id)}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			p, err := load(context.Background(), id)
+			if err != nil || p.ID != id {
+				t.Errorf("Expected profile %d, got %+v, %v", id, p, err)
+			}
+		}(i % 3)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for id := range calls {
+		if n := calls[id].Load(); n != 1 {
+			t.Errorf("Expected one call for argument %d, got %d", id, n)
+		}
+	}
+
+	// Later calls are served from the cache and decoded back into the type.
+	p, err := load(context.Background(), 2)
+	if err != nil || p != (profile{ID: 2, Name: "user2"}) {
+		t.Errorf("Expected cached profile, got %+v, %v", p, err)
+	}
+	if n := calls[2].Load(); n != 1 {
+		t.Errorf("Expected the cached result to be reused, got %d calls", n)
+	}
+	if _, err := cache.Get(`profile:"2"`); err != nil {
+		t.Errorf("Expected the result under the derived key, got %v", err)
+	}
+}
+
+func TestCachedFunc2ErrorsAndKeys(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{LogLevel: "error"})
+	var calls atomic.Int32
+	errBoom := errors.New("boom")
+	sum := CachedFunc2(cache, time.Hour, "sum:", func(ctx context.Context, a, b int) (int, error) {
+		calls.Add(1)
+		if a < 0 {
+			return 0, errBoom
+		}
+		return a + b, nil
+	}, WithKeyFunc(func(args ...interface{}) string { return fmt.Sprintf("%v+%v", args...) }))
+
+	if v, err := sum(context.Background(), 1, 2); err != nil || v != 3 {
+		t.Errorf("Expected 3, got %v, %v", v, err)
+	}
+	if v, _ := cache.Get("sum:1+2"); v != 3 {
+		t.Errorf("Expected result under the custom key, got %v", v)
+	}
+	sum(context.Background(), 1, 2)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected one call, got %d", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := sum(context.Background(), -1, 0); err != errBoom {
+			t.Errorf("Expected errBoom, got %v", err)
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("Expected errors not to be cached, got %d calls", n)
+	}
+}
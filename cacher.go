@@ -0,0 +1,20 @@
+package lrucache
+
+import "time"
+
+// Cacher is the common API of LRU and MiniCache, so callers can switch
+// between them. Both report the same errors: Get returns ErrItemNotFound
+// and ErrItemExpired, Delete of a missing key ErrItemNotFound, and Set an
+// error wrapping ErrInvalidArgument for an empty key or a TTL that is
+// neither positive nor NoExpiration.
+type Cacher interface {
+	Set(key string, value interface{}, ttl time.Duration) error
+	Get(key string) (interface{}, error)
+	Delete(key string) error
+	Len() int
+}
+
+var (
+	_ Cacher = (*LRU)(nil)
+	_ Cacher = (*MiniCache)(nil)
+)
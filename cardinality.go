@@ -0,0 +1,128 @@
+package lrucache
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// CardinalityGuard protects the cache from a flood of unique keys, such as
+// an upstream bug minting a new key per request. While more than
+// MaxNewKeyRate never-before-seen keys arrive per second, only AdmitFraction
+// of the writes creating a new key are stored; the rest fail with
+// ErrNotStored. Writes to keys already in the cache always go ahead, and a
+// refused key counts as seen, so keys that recur get in on a later write
+// while one-off keys mostly do not. New keys are counted over ten-second
+// periods: the guard engages as soon as a period goes over the rate and is
+// released at the end of the first period under it. A zero WindowKeys or
+// MaxNewKeyRate disables the guard.
+type CardinalityGuard struct {
+	// WindowKeys is how many distinct keys the guard remembers as seen,
+	// including keys no longer cached.
+	WindowKeys int
+	// MaxNewKeyRate is the number of new keys per second that engages the
+	// guard.
+	MaxNewKeyRate float64
+	// AdmitFraction is the share of new keys stored while the guard is
+	// engaged; defaults to 0.1.
+	AdmitFraction float64
+}
+
+const (
+	defaultAdmitFraction = 0.1
+
+	// cardinalityPeriod is how long new keys are counted before the rate is
+	// compared with MaxNewKeyRate again.
+	cardinalityPeriod = 10 * time.Second
+)
+
+// cardinalityGuard estimates the rate of new keys with two rotating bloom
+// filters of seen keys. It is only used under the write lock, apart from
+// active, which Stats reads.
+type cardinalityGuard struct {
+	cfg      CardinalityGuard
+	current  *bloomFilter
+	previous *bloomFilter
+	added    int // keys added to current
+
+	start  time.Time // beginning of the counting period
+	count  int       // new keys seen in the period
+	active atomic.Bool
+}
+
+func newCardinalityGuard(cfg CardinalityGuard) *cardinalityGuard {
+	if cfg.AdmitFraction == 0 {
+		cfg.AdmitFraction = defaultAdmitFraction
+	}
+	return &cardinalityGuard{cfg: cfg, current: newBloomFilter(MissFilter{ExpectedItems: cfg.WindowKeys})}
+}
+
+// seen records key and reports whether it was seen before.
+func (g *cardinalityGuard) seen(key string) bool {
+	if g.current.mayContain(key) || g.previous != nil && g.previous.mayContain(key) {
+		return true
+	}
+	if g.added >= g.cfg.WindowKeys {
+		g.previous, g.current, g.added = g.current, newBloomFilter(MissFilter{ExpectedItems: g.cfg.WindowKeys}), 0
+	}
+	g.current.add(key)
+	g.added++
+	return false
+}
+
+// observe counts a new key arriving at now and returns the change of state,
+// if any, as a log message.
+func (g *cardinalityGuard) observe(now time.Time) string {
+	if g.start.IsZero() {
+		g.start = now
+	}
+	g.count++
+
+	elapsed := now.Sub(g.start)
+	limit := g.cfg.MaxNewKeyRate * cardinalityPeriod.Seconds()
+	switch {
+	case elapsed >= cardinalityPeriod:
+		rate := float64(g.count) / elapsed.Seconds()
+		g.start, g.count = now, 0
+		if over := rate > g.cfg.MaxNewKeyRate; over != g.active.Load() {
+			g.active.Store(over)
+			if over {
+				return fmt.Sprintf("Cardinality guard engaged: %.1f new keys per second", rate)
+			}
+			return fmt.Sprintf("Cardinality guard released: %.1f new keys per second", rate)
+		}
+	case float64(g.count) > limit && !g.active.Load():
+		// Do not wait for the period to end to stop a flood.
+		g.active.Store(true)
+		return fmt.Sprintf("Cardinality guard engaged: %d new keys in %v", g.count, elapsed)
+	}
+	return ""
+}
+
+// admitKey decides whether a write of key may be stored under the
+// cardinality guard. The caller must hold the write lock.
+func (l *LRU) admitKey(key string) error {
+	g := l.guard
+	if g == nil {
+		return nil
+	}
+	if raw, err := l.db.Txn(false).First("cache", "id", key); err == nil && raw != nil {
+		return nil
+	}
+	if g.seen(key) {
+		return nil
+	}
+	if msg := g.observe(l.now()); msg != "" {
+		l.log("warn", "%s", msg)
+	}
+	if !g.active.Load() || rand.Float64() < g.cfg.AdmitFraction {
+		return nil
+	}
+
+	if decisionTracing {
+		l.decide("set", key, "reject", "new key refused by the cardinality guard")
+	}
+	l.stats.guardedSets.Add(1)
+	return fmt.Errorf("%w: too many new keys, %s refused by the cardinality guard", ErrNotStored, key)
+}
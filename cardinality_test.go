@@ -0,0 +1,104 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCardinalityGuardFlood(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10000, Options{
+		LogLevel:         "error",
+		CardinalityGuard: CardinalityGuard{WindowKeys: 10000, MaxNewKeyRate: 10, AdmitFraction: 0.1},
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	// A steady trickle of new keys stays below the limit.
+	for i := 0; i < 50; i++ {
+		if err := cache.Set(fmt.Sprintf("steady:%d", i), i, time.Hour); err != nil {
+			t.Fatalf("Set below the rate limit failed: %v", err)
+		}
+		now = now.Add(200 * time.Millisecond)
+	}
+	if cache.Stats().CardinalityGuardActive {
+		t.Fatal("Expected the guard to stay off for 5 new keys per second")
+	}
+
+	// A flood of unique keys within a second.
+	stored := 0
+	for i := 0; i < 2000; i++ {
+		err := cache.Set(fmt.Sprintf("flood:%d", i), i, time.Hour)
+		switch {
+		case err == nil:
+			stored++
+		case !errors.Is(err, ErrNotStored):
+			t.Fatalf("Expected ErrNotStored, got %v", err)
+		}
+		now = now.Add(100 * time.Microsecond)
+	}
+	stats := cache.Stats()
+	if !stats.CardinalityGuardActive {
+		t.Fatal("Expected the guard to engage during the flood")
+	}
+	if stored < 150 || stored > 500 {
+		t.Errorf("Expected about a tenth of the flood stored, got %d of 2000", stored)
+	}
+	if stats.GuardedSets != uint64(2000-stored) {
+		t.Errorf("Expected %d guarded sets, got %d", 2000-stored, stats.GuardedSets)
+	}
+
+	// Existing keys stay writable while the guard is on.
+	if err := cache.Set("steady:0", "updated", time.Hour); err != nil {
+		t.Errorf("Expected an existing key to be updatable, got %v", err)
+	}
+
+	// The period holding the flood keeps the guard on; a quiet period after
+	// it releases the guard.
+	now = now.Add(cardinalityPeriod)
+	cache.Set("after:1", 1, time.Hour)
+	if !cache.Stats().CardinalityGuardActive {
+		t.Fatal("Expected the flood period to keep the guard engaged")
+	}
+	now = now.Add(cardinalityPeriod)
+	if err := cache.Set("after:2", 1, time.Hour); err != nil {
+		t.Fatalf("Set after the flood failed: %v", err)
+	}
+	if cache.Stats().CardinalityGuardActive {
+		t.Error("Expected the guard to be released after the flood")
+	}
+	for i := 0; i < 20; i++ {
+		now = now.Add(time.Second)
+		if err := cache.Set(fmt.Sprintf("recovered:%d", i), i, time.Hour); err != nil {
+			t.Errorf("Expected new keys to be admitted after recovery, got %v", err)
+		}
+	}
+}
+
+func TestCardinalityGuardRecurringKey(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{
+		LogLevel:         "error",
+		CardinalityGuard: CardinalityGuard{WindowKeys: 1000, MaxNewKeyRate: 1, AdmitFraction: 0.1},
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("flood:%d", i), i, time.Hour)
+	}
+	if !cache.Stats().CardinalityGuardActive {
+		t.Fatal("Expected the guard to engage")
+	}
+
+	// A refused key has been seen and is admitted when written again.
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("retry:%d", i)
+		if err := cache.Set(key, 1, time.Hour); err == nil {
+			continue
+		}
+		if err := cache.Set(key, 2, time.Hour); err != nil {
+			t.Errorf("Expected a recurring key to be admitted, got %v", err)
+		}
+		break
+	}
+}
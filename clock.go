@@ -0,0 +1,98 @@
+package lrucache
+
+import "time"
+
+// ClockJumpPolicy decides what happens to stored wall clock deadlines when
+// the system clock is stepped backwards.
+type ClockJumpPolicy int
+
+const (
+	// ClockJumpReanchor moves every ExpiresAt by the size of the jump, so
+	// entries keep their intended relative lifetime.
+	ClockJumpReanchor ClockJumpPolicy = iota
+	// ClockJumpLeave keeps ExpiresAt as stored and lets entries expire when
+	// the wall clock reaches it again, as if the deadlines were absolute.
+	ClockJumpLeave
+)
+
+// clockJumpThreshold is how far the wall clock may drift from the monotonic
+// clock between two checks before the difference counts as a jump.
+const clockJumpThreshold = time.Second
+
+// clock returns the current time on the cache's monotonic timeline. It
+// starts at the wall time the cache was created and only ever moves forward
+// with the monotonic clock, so every expiry decision is made against it.
+func (l *LRU) clock() time.Time {
+	if l.elapsed != nil {
+		return l.epoch.Add(l.elapsed())
+	}
+	return l.epoch.Add(l.now().Sub(l.epoch))
+}
+
+// expiry returns the wall clock and monotonic deadlines of an entry written
+// now with the given ttl. A ttl of NoExpiration gives a zero ExpiresAt and
+// neverDeadline.
+func (l *LRU) expiry(ttl time.Duration) (expiresAt, deadline time.Time) {
+	if ttl == NoExpiration {
+		return time.Time{}, neverDeadline
+	}
+	return l.now().Add(ttl), l.clock().Add(ttl)
+}
+
+// expiryAt returns the deadlines of an entry expiring at the wall clock time
+// expiresAt.
+func (l *LRU) expiryAt(expiresAt time.Time) (time.Time, time.Time) {
+	return expiresAt, l.clock().Add(expiresAt.Sub(l.now()))
+}
+
+// checkClock compares how far the wall clock and the monotonic clock have
+// moved since the last check and handles any jump between them. The caller
+// must hold the write lock.
+func (l *LRU) checkClock() {
+	wall, mono := l.now(), l.clock()
+	skew := wall.Round(0).Sub(l.lastWall.Round(0)) - mono.Sub(l.lastClock)
+	l.lastWall, l.lastClock = wall, mono
+	if skew > -clockJumpThreshold && skew < clockJumpThreshold {
+		return
+	}
+
+	l.stats.clockJumps.Add(1)
+	if skew < 0 && l.opts.ClockJumpPolicy == ClockJumpLeave {
+		l.log("warn", "Clock jumped by %v, leaving deadlines in place", skew)
+		l.shiftDeadlines(0, -skew)
+		return
+	}
+	l.log("warn", "Clock jumped by %v, re-anchoring deadlines", skew)
+	l.shiftDeadlines(skew, 0)
+}
+
+// shiftDeadlines moves the wall clock and monotonic deadlines of every entry.
+// Every entry moves by the same amount, so the heap order stays valid.
+func (l *LRU) shiftDeadlines(wall, mono time.Duration) {
+	txn := l.db.Txn(true)
+	it, err := txn.Get("cache", "id")
+	if err != nil {
+		txn.Abort()
+		l.log("error", "Failed to get all items: %v", err)
+		return
+	}
+
+	var retired []*CacheItem
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		item := l.copyItem(obj.(*CacheItem))
+		if !item.persistent() {
+			item.ExpiresAt = item.ExpiresAt.Add(wall)
+		}
+		item.deadline = item.deadline.Add(mono)
+		if err := txn.Insert("cache", item); err != nil {
+			txn.Abort()
+			l.log("error", "Failed to shift deadline of key %s: %v", l.redact(item.Key), err)
+			return
+		}
+		retired = append(retired, obj.(*CacheItem))
+	}
+	txn.Commit()
+	l.retire(retired...)
+
+	l.expHeap.shift(mono)
+}
@@ -0,0 +1,141 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock drives the wall and monotonic clocks of a cache separately.
+type fakeClock struct {
+	wall time.Time
+	mono time.Duration
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.wall = c.wall.Add(d)
+	c.mono += d
+}
+
+func (c *fakeClock) jump(d time.Duration) {
+	c.wall = c.wall.Add(d)
+}
+
+func withFakeClock(cache *LRU) *fakeClock {
+	c := &fakeClock{wall: time.Unix(1700000000, 0)}
+	cache.now = func() time.Time { return c.wall }
+	cache.elapsed = func() time.Duration { return c.mono }
+	cache.epoch = c.wall
+	cache.lastWall, cache.lastClock = c.wall, c.wall
+	return c
+}
+
+func expiresAt(t *testing.T, cache *LRU, key string) time.Time {
+	t.Helper()
+	raw, err := cache.db.Txn(false).First("cache", "id", key)
+	if err != nil || raw == nil {
+		t.Fatalf("Expected %s to be stored, got %v", key, err)
+	}
+	return raw.(*CacheItem).ExpiresAt
+}
+
+func TestClockBackwardJumpReanchors(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	clock := withFakeClock(cache)
+
+	cache.Set("key1", "value1", 15*time.Minute)
+	clock.advance(time.Minute)
+	clock.jump(-10 * time.Minute)
+	cache.removeExpiredItems()
+
+	if jumps := cache.Stats().ClockJumps; jumps != 1 {
+		t.Errorf("Expected 1 clock jump, got %d", jumps)
+	}
+	if got, want := expiresAt(t, cache, "key1"), clock.wall.Add(14*time.Minute); !got.Equal(want) {
+		t.Errorf("Expected ExpiresAt %v after re-anchoring, got %v", want, got)
+	}
+
+	clock.advance(13 * time.Minute)
+	if _, err := cache.Get("key1"); err != nil {
+		t.Errorf("Expected key1 to live for its full TTL, got %v", err)
+	}
+	clock.advance(2 * time.Minute)
+	cache.removeExpiredItems()
+	if _, err := cache.Get("key1"); err != ErrItemNotFound {
+		t.Errorf("Expected key1 to expire after 15 minutes, got %v", err)
+	}
+}
+
+func TestClockBackwardJumpLeave(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", ClockJumpPolicy: ClockJumpLeave})
+	clock := withFakeClock(cache)
+
+	cache.Set("key1", "value1", 15*time.Minute)
+	before := expiresAt(t, cache, "key1")
+	clock.advance(time.Minute)
+	clock.jump(-10 * time.Minute)
+	cache.removeExpiredItems()
+
+	if got := expiresAt(t, cache, "key1"); !got.Equal(before) {
+		t.Errorf("Expected ExpiresAt to stay %v, got %v", before, got)
+	}
+
+	// The wall clock needs 24 more minutes to reach the stored deadline.
+	clock.advance(23 * time.Minute)
+	if _, err := cache.Get("key1"); err != nil {
+		t.Errorf("Expected key1 to live until its wall deadline, got %v", err)
+	}
+	clock.advance(2 * time.Minute)
+	cache.removeExpiredItems()
+	if _, err := cache.Get("key1"); err != ErrItemNotFound {
+		t.Errorf("Expected key1 to expire at its wall deadline, got %v", err)
+	}
+}
+
+func TestClockForwardJumpDoesNotMassExpire(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", ClockJumpPolicy: ClockJumpLeave})
+	clock := withFakeClock(cache)
+
+	cache.Set("key1", "value1", 5*time.Minute)
+	cache.Set("key2", "value2", 15*time.Minute)
+	clock.advance(time.Minute)
+	clock.jump(10 * time.Minute)
+
+	// Expiry follows the monotonic clock even before the jump is detected.
+	if _, err := cache.Get("key1"); err != nil {
+		t.Errorf("Expected key1 to survive the jump, got %v", err)
+	}
+
+	cache.removeExpiredItems()
+	if l := cache.Len(); l != 2 {
+		t.Errorf("Expected both keys to survive the jump, got len %d", l)
+	}
+	if got, want := expiresAt(t, cache, "key1"), clock.wall.Add(4*time.Minute); !got.Equal(want) {
+		t.Errorf("Expected ExpiresAt %v after re-anchoring, got %v", want, got)
+	}
+
+	clock.advance(5 * time.Minute)
+	cache.removeExpiredItems()
+	if _, err := cache.Get("key1"); err != ErrItemNotFound {
+		t.Errorf("Expected key1 to expire after 5 minutes, got %v", err)
+	}
+	if _, err := cache.Get("key2"); err != nil {
+		t.Errorf("Expected key2 to be live, got %v", err)
+	}
+	if jumps := cache.Stats().ClockJumps; jumps != 1 {
+		t.Errorf("Expected 1 clock jump, got %d", jumps)
+	}
+}
+
+func TestClockSteadyNoJump(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	clock := withFakeClock(cache)
+
+	cache.Set("key1", "value1", time.Hour)
+	clock.advance(30 * time.Minute)
+	clock.jump(500 * time.Millisecond)
+	cache.removeExpiredItems()
+
+	if jumps := cache.Stats().ClockJumps; jumps != 0 {
+		t.Errorf("Expected drift below the threshold to be ignored, got %d jumps", jumps)
+	}
+}
@@ -0,0 +1,116 @@
+package lrucache
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// KeyStat describes the footprint and read activity of one entry.
+type KeyStat struct {
+	Key          string
+	Size         int // stored bytes
+	Age          time.Duration
+	Hits         uint64
+	LastAccessed time.Time // zero if never read
+}
+
+// ColdKeys returns up to n live entries that have not been read within
+// olderThan, coldest first. Entries that were never read count from the time
+// they were written, so fresh writes are not reported. n <= 0 returns all of
+// them. Values are not deserialized.
+func (l *LRU) ColdKeys(n int, olderThan time.Duration) []KeyStat {
+	l.readLock("scan")
+	defer l.lock.RUnlock()
+
+	items, err := l.coldItems(olderThan)
+	if err != nil {
+		l.log("error", "Failed to list cold keys: %v", err)
+		return nil
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].lastActive().Before(items[j].lastActive())
+	})
+	if n > 0 && len(items) > n {
+		items = items[:n]
+	}
+
+	now := l.now()
+	stats := make([]KeyStat, len(items))
+	for i, item := range items {
+		stats[i] = KeyStat{
+			Key:  item.Key,
+			Size: len(item.Value),
+			Age:  now.Sub(item.CreatedAt),
+			Hits: item.hits(),
+		}
+		if stats[i].Hits > 0 {
+			stats[i].LastAccessed = item.lastActive()
+		}
+	}
+	return stats
+}
+
+// DeleteColdKeys removes every live entry ColdKeys would report for
+// olderThan and returns how many were removed.
+func (l *LRU) DeleteColdKeys(olderThan time.Duration) (int, error) {
+	defer l.checkWatermarks()
+
+	if olderThan <= 0 {
+		return 0, l.invalid("", "olderThan must be positive")
+	}
+
+	l.writeLock("delete")
+	defer l.lock.Unlock()
+
+	items, err := l.coldItems(olderThan)
+	if err != nil {
+		return 0, err
+	}
+
+	txn := l.db.Txn(true)
+	var freed int64
+	for _, item := range items {
+		if err := txn.Delete("cache", item); err != nil {
+			txn.Abort()
+			return 0, fmt.Errorf("failed to delete item: %v", err)
+		}
+		freed += entryBytes(item.Key, item.Value)
+	}
+	txn.Commit()
+	l.resized(-freed)
+	l.filterRemoved(len(items))
+
+	for _, item := range items {
+		l.expHeap.remove(item.Key)
+		l.closeWatchers(item.Key)
+		l.recordDeletion(item.Key)
+		l.deps.forget(item.Key)
+		l.invalidateDependents(item.Key)
+	}
+	l.retire(items...)
+	l.log("info", "Deleted %d cold keys", len(items))
+	return len(items), nil
+}
+
+// coldItems returns the live items inactive for at least olderThan. The
+// caller must hold the lock.
+func (l *LRU) coldItems(olderThan time.Duration) ([]*CacheItem, error) {
+	txn := l.db.Txn(false)
+	it, err := txn.Get("cache", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all items: %v", err)
+	}
+
+	now := l.now()
+	cutoff := now.Add(-olderThan)
+	var items []*CacheItem
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		item := obj.(*CacheItem)
+		if item.expired(l.clock()) || item.lastActive().After(cutoff) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,62 @@
+package lrucache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestColdKeys(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("never-read", strings.Repeat("x", 100), time.Hour)
+	cache.Set("read-early", strings.Repeat("y", 50), time.Hour)
+	cache.Set("read-late", "z", time.Hour)
+	cache.Set("expired", "e", time.Minute)
+
+	now = now.Add(5 * time.Minute)
+	cache.Get("read-early")
+	now = now.Add(20 * time.Minute)
+	cache.Get("read-late")
+	cache.Set("fresh", "f", time.Hour)
+	now = now.Add(5 * time.Minute)
+
+	cold := cache.ColdKeys(0, 10*time.Minute)
+	if len(cold) != 2 {
+		t.Fatalf("Expected 2 cold keys, got %v", cold)
+	}
+	if cold[0].Key != "never-read" || cold[0].Hits != 0 || cold[0].Age != 30*time.Minute || !cold[0].LastAccessed.IsZero() {
+		t.Errorf("Unexpected stat for never-read: %+v", cold[0])
+	}
+	if cold[1].Key != "read-early" || cold[1].Hits != 1 || cold[1].Size != 51 {
+		t.Errorf("Unexpected stat for read-early: %+v", cold[1])
+	}
+
+	if limited := cache.ColdKeys(1, 10*time.Minute); len(limited) != 1 || limited[0].Key != "never-read" {
+		t.Errorf("Expected only the coldest key, got %v", limited)
+	}
+
+	var reclaimed int
+	for _, stat := range cold {
+		reclaimed += stat.Size
+	}
+	if reclaimed != 101+51 {
+		t.Errorf("Expected 152 reclaimable bytes, got %d", reclaimed)
+	}
+
+	n, err := cache.DeleteColdKeys(10 * time.Minute)
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 cold keys deleted, got %d, %v", n, err)
+	}
+	if _, err := cache.Get("never-read"); err != ErrItemNotFound {
+		t.Errorf("Expected never-read to be deleted, got %v", err)
+	}
+	if _, err := cache.Get("read-late"); err != nil {
+		t.Errorf("Expected read-late to survive, got %v", err)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent heap, got %v", err)
+	}
+}
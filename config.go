@@ -0,0 +1,233 @@
+package lrucache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConfigError lists every problem found in an Options value.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid options: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks o for out of range and contradictory settings and returns
+// a *ConfigError listing all of them, or nil. NewLRUWithTTL runs it too, so
+// calling it directly is only needed to test configurations ahead of time.
+func (o Options) Validate() error {
+	var problems []string
+	add := func(format string, v ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, v...))
+	}
+
+	switch o.LogLevel {
+	case "", "debug", "info", "warn", "error":
+	default:
+		add("unknown LogLevel %q", o.LogLevel)
+	}
+	for i, m := range o.GetMiddleware {
+		if m == nil {
+			add("GetMiddleware[%d] is nil", i)
+		}
+	}
+	if o.MaxDependencies < 0 {
+		add("MaxDependencies must not be negative")
+	}
+	if o.AuditInterval < 0 {
+		add("AuditInterval must not be negative")
+	}
+	if o.Preallocate < 0 {
+		add("Preallocate must not be negative")
+	}
+	if o.ClockJumpPolicy != ClockJumpReanchor && o.ClockJumpPolicy != ClockJumpLeave {
+		add("unknown ClockJumpPolicy %d", o.ClockJumpPolicy)
+	}
+	if o.MinUsefulTTL < 0 {
+		add("MinUsefulTTL must not be negative")
+	}
+	if o.DefaultTTL < 0 && o.DefaultTTL != NoExpiration {
+		add("DefaultTTL must not be negative")
+	} else if o.DefaultTTL > 0 && o.DefaultTTL < o.MinUsefulTTL {
+		add("DefaultTTL is below MinUsefulTTL")
+	}
+	if o.NegativeTTL < 0 {
+		add("NegativeTTL must not be negative")
+	}
+	if o.NegativeTTL > 0 && o.Loader == nil {
+		add("NegativeTTL is set but Loader is nil")
+	}
+	if o.ReadRepairLimit < 0 {
+		add("ReadRepairLimit must not be negative")
+	}
+	if o.ReadRepairWindow < 0 {
+		add("ReadRepairWindow must not be negative")
+	}
+	if o.DeletionLogSize < 0 {
+		add("DeletionLogSize must not be negative")
+	}
+	if o.MaxBytes < 0 {
+		add("MaxBytes must not be negative")
+	}
+	if o.SharedSnapshot.LockTimeout < 0 {
+		add("SharedSnapshot.LockTimeout must not be negative")
+	}
+	if o.SharedSnapshot.LockTimeout > 0 && o.SharedSnapshot.Path == "" {
+		add("SharedSnapshot.LockTimeout is set but Path is empty")
+	}
+	if o.ColdBehavior != ColdServe && o.ColdBehavior != ColdReject && o.ColdBehavior != ColdWait {
+		add("unknown ColdBehavior %d", o.ColdBehavior)
+	}
+	if o.ColdWaitTimeout < 0 {
+		add("ColdWaitTimeout must not be negative")
+	}
+	if o.ColdWaitTimeout > 0 && o.ColdBehavior != ColdWait {
+		add("ColdWaitTimeout is set but ColdBehavior is not ColdWait")
+	}
+	if o.WarmupTarget < 0 {
+		add("WarmupTarget must not be negative")
+	}
+	if !o.RequireWarmup && (o.ColdBehavior != ColdServe || o.WarmupTarget != 0) {
+		add("warm-up is configured but RequireWarmup is false")
+	}
+
+	sw := o.SweepSchedule
+	if sw.PeakStart < 0 || sw.PeakStart >= 24*time.Hour || sw.PeakEnd < 0 || sw.PeakEnd >= 24*time.Hour {
+		add("SweepSchedule.PeakStart and PeakEnd must be in [0, 24h)")
+	}
+	if sw.PeakMaxEntries < 0 {
+		add("SweepSchedule.PeakMaxEntries must not be negative")
+	}
+	if sw.PeakMaxDuration < 0 {
+		add("SweepSchedule.PeakMaxDuration must not be negative")
+	}
+	if !sw.enabled() && (sw.PeakMaxEntries != 0 || sw.PeakMaxDuration != 0) {
+		add("SweepSchedule caps are set but the peak window is empty")
+	}
+
+	if o.SlidingTTL && o.SlidingWindow <= 0 {
+		add("SlidingWindow must be positive")
+	} else if !o.SlidingTTL && o.SlidingWindow != 0 {
+		add("SlidingWindow is set but SlidingTTL is false")
+	}
+
+	r := o.SetRateLimit
+	switch {
+	case r.PerKeyPerSecond < 0:
+		add("SetRateLimit.PerKeyPerSecond must not be negative")
+	case r.PerKeyPerSecond == 0 && (r.Burst != 0 || r.RefreshTTL || r.MaxKeys != 0):
+		add("SetRateLimit is configured but PerKeyPerSecond is zero")
+	}
+	if r.Burst < 0 {
+		add("SetRateLimit.Burst must not be negative")
+	}
+	if r.MaxKeys < 0 {
+		add("SetRateLimit.MaxKeys must not be negative")
+	}
+
+	t := o.TombstoneRetention
+	if t.Count < 0 {
+		add("TombstoneRetention.Count must not be negative")
+	}
+	if t.MaxAge < 0 {
+		add("TombstoneRetention.MaxAge must not be negative")
+	}
+	if t.Count == 0 && t.MaxAge > 0 {
+		add("TombstoneRetention.MaxAge is set but Count is zero")
+	}
+
+	f := o.MissFilter
+	if f.ExpectedItems < 0 {
+		add("MissFilter.ExpectedItems must not be negative")
+	}
+	if f.FalsePositiveRate < 0 || f.FalsePositiveRate >= 1 {
+		add("MissFilter.FalsePositiveRate must be in [0, 1)")
+	}
+	if f.ExpectedItems == 0 && f.FalsePositiveRate != 0 {
+		add("MissFilter.FalsePositiveRate is set but ExpectedItems is zero")
+	}
+
+	if o.MaxDecodeDepth < 0 {
+		add("MaxDecodeDepth must not be negative")
+	}
+	if o.MaxDecodeBytes < 0 {
+		add("MaxDecodeBytes must not be negative")
+	}
+
+	if o.MaxStatGroups < 0 {
+		add("MaxStatGroups must not be negative")
+	}
+	if o.MaxStatGroups > 0 && o.StatsKeyGrouper == nil {
+		add("MaxStatGroups is set but StatsKeyGrouper is nil")
+	}
+
+	if n := o.PreExpiryNotice; n.Callback != nil && n.Lead <= 0 {
+		add("PreExpiryNotice.Lead must be positive")
+	} else if n.Callback == nil && n.Lead != 0 {
+		add("PreExpiryNotice.Lead is set but Callback is nil")
+	}
+
+	if o.ObservabilityMaxBytes < 0 {
+		add("ObservabilityMaxBytes must not be negative")
+	}
+
+	for i, w := range o.Watermarks {
+		if w.Level <= 0 || w.Level > 1 {
+			add("Watermarks[%d].Level must be in (0, 1]", i)
+		}
+		if w.Direction != Rising && w.Direction != Falling {
+			add("unknown Watermarks[%d].Direction %d", i, w.Direction)
+		}
+		if w.Callback == nil {
+			add("Watermarks[%d].Callback is nil", i)
+		}
+	}
+
+	var total float64
+	for group, share := range o.FairnessShares {
+		if share < 0 || share > 1 {
+			add("FairnessShares[%q] must be in [0, 1]", group)
+		}
+		total += share
+	}
+	if total > 1 {
+		add("FairnessShares must not add up to more than 1")
+	}
+	if len(o.FairnessShares) > 0 && o.FairnessGroups == nil {
+		add("FairnessShares is set but FairnessGroups is nil")
+	}
+
+	g := o.CardinalityGuard
+	if g.WindowKeys < 0 {
+		add("CardinalityGuard.WindowKeys must not be negative")
+	}
+	if g.MaxNewKeyRate < 0 {
+		add("CardinalityGuard.MaxNewKeyRate must not be negative")
+	}
+	if g.AdmitFraction < 0 || g.AdmitFraction > 1 {
+		add("CardinalityGuard.AdmitFraction must be in [0, 1]")
+	}
+	if (g.WindowKeys == 0) != (g.MaxNewKeyRate == 0) {
+		add("CardinalityGuard needs both WindowKeys and MaxNewKeyRate")
+	}
+
+	a := o.HitRatioAlert
+	if a.Callback != nil {
+		if a.Threshold <= 0 || a.Threshold > 1 {
+			add("HitRatioAlert.Threshold must be in (0, 1]")
+		}
+		if a.Window <= 0 {
+			add("HitRatioAlert.Window must be positive")
+		}
+	} else if a.Threshold != 0 || a.Window != 0 {
+		add("HitRatioAlert is configured but Callback is nil")
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package lrucache
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"unknown log level", Options{LogLevel: "verbose"}, []string{`unknown LogLevel "verbose"`}},
+		{"nil middleware", Options{GetMiddleware: []Middleware{nil}}, []string{"GetMiddleware[0] is nil"}},
+		{"negative counts", Options{MaxDependencies: -1, AuditInterval: -time.Second, Preallocate: -1}, []string{
+			"MaxDependencies must not be negative",
+			"AuditInterval must not be negative",
+			"Preallocate must not be negative",
+		}},
+		{"unknown clock jump policy", Options{ClockJumpPolicy: 7}, []string{"unknown ClockJumpPolicy 7"}},
+		{"negative MinUsefulTTL", Options{MinUsefulTTL: -1}, []string{"MinUsefulTTL must not be negative"}},
+		{"negative DefaultTTL", Options{DefaultTTL: -time.Second}, []string{"DefaultTTL must not be negative"}},
+		{"useless DefaultTTL", Options{DefaultTTL: time.Second, MinUsefulTTL: time.Minute}, []string{"DefaultTTL is below MinUsefulTTL"}},
+		{"rate limit without rate", Options{SetRateLimit: SetRateLimit{Burst: 3}}, []string{"SetRateLimit is configured but PerKeyPerSecond is zero"}},
+		{"negative rate limit", Options{SetRateLimit: SetRateLimit{PerKeyPerSecond: -1, Burst: -1, MaxKeys: -1}}, []string{
+			"SetRateLimit.PerKeyPerSecond must not be negative",
+			"SetRateLimit.Burst must not be negative",
+			"SetRateLimit.MaxKeys must not be negative",
+		}},
+		{"tombstone age without count", Options{TombstoneRetention: TombstoneRetention{MaxAge: time.Minute}}, []string{"TombstoneRetention.MaxAge is set but Count is zero"}},
+		{"negative tombstones", Options{TombstoneRetention: TombstoneRetention{Count: -1, MaxAge: -1}}, []string{
+			"TombstoneRetention.Count must not be negative",
+			"TombstoneRetention.MaxAge must not be negative",
+		}},
+		{"miss filter rate out of range", Options{MissFilter: MissFilter{ExpectedItems: 10, FalsePositiveRate: 1}}, []string{"MissFilter.FalsePositiveRate must be in [0, 1)"}},
+		{"miss filter rate without size", Options{MissFilter: MissFilter{FalsePositiveRate: 0.1}}, []string{"MissFilter.FalsePositiveRate is set but ExpectedItems is zero"}},
+		{"hit ratio alert out of range", Options{HitRatioAlert: HitRatioAlert{Threshold: 1.5, Callback: func(float64, Stats) {}}}, []string{
+			"HitRatioAlert.Threshold must be in (0, 1]",
+			"HitRatioAlert.Window must be positive",
+		}},
+		{"hit ratio alert without callback", Options{HitRatioAlert: HitRatioAlert{Threshold: 0.5}}, []string{"HitRatioAlert is configured but Callback is nil"}},
+		{"negative decode limits", Options{MaxDecodeDepth: -1, MaxDecodeBytes: -1}, []string{
+			"MaxDecodeDepth must not be negative",
+			"MaxDecodeBytes must not be negative",
+		}},
+		{"stat groups without grouper", Options{MaxStatGroups: 8}, []string{"MaxStatGroups is set but StatsKeyGrouper is nil"}},
+		{"negative stat groups", Options{StatsKeyGrouper: func(string) string { return "" }, MaxStatGroups: -1}, []string{"MaxStatGroups must not be negative"}},
+		{"pre-expiry notice without lead", Options{PreExpiryNotice: PreExpiryNotice{Callback: func(string, time.Time) {}}}, []string{"PreExpiryNotice.Lead must be positive"}},
+		{"pre-expiry lead without callback", Options{PreExpiryNotice: PreExpiryNotice{Lead: time.Second}}, []string{"PreExpiryNotice.Lead is set but Callback is nil"}},
+		{"negative observability budget", Options{ObservabilityMaxBytes: -1}, []string{"ObservabilityMaxBytes must not be negative"}},
+		{"bad watermark", Options{Watermarks: []Watermark{{Level: 0.5, Callback: func(float64, int) {}}, {Level: 1.5, Direction: 3}}}, []string{
+			"Watermarks[1].Level must be in (0, 1]",
+			"unknown Watermarks[1].Direction 3",
+			"Watermarks[1].Callback is nil",
+		}},
+		{"negative ttl without loader", Options{NegativeTTL: time.Second}, []string{"NegativeTTL is set but Loader is nil"}},
+		{"negative read repair limits", Options{ReadRepairLimit: -1, ReadRepairWindow: -time.Second}, []string{
+			"ReadRepairLimit must not be negative",
+			"ReadRepairWindow must not be negative",
+		}},
+		{"negative deletion log", Options{DeletionLogSize: -1}, []string{"DeletionLogSize must not be negative"}},
+		{"negative max bytes", Options{MaxBytes: -1}, []string{"MaxBytes must not be negative"}},
+		{"negative lock timeout", Options{SharedSnapshot: SharedSnapshot{LockTimeout: -1}}, []string{"SharedSnapshot.LockTimeout must not be negative"}},
+		{"lock timeout without path", Options{SharedSnapshot: SharedSnapshot{LockTimeout: time.Second}}, []string{"SharedSnapshot.LockTimeout is set but Path is empty"}},
+		{"warm-up without RequireWarmup", Options{ColdBehavior: ColdReject, WarmupTarget: 10}, []string{"warm-up is configured but RequireWarmup is false"}},
+		{"bad cold wait", Options{RequireWarmup: true, ColdBehavior: ColdBehavior(7), ColdWaitTimeout: -1, WarmupTarget: -1}, []string{
+			"unknown ColdBehavior 7",
+			"ColdWaitTimeout must not be negative",
+			"WarmupTarget must not be negative",
+		}},
+		{"cold wait timeout without ColdWait", Options{RequireWarmup: true, ColdBehavior: ColdReject, ColdWaitTimeout: time.Second}, []string{"ColdWaitTimeout is set but ColdBehavior is not ColdWait"}},
+		{"bad sweep schedule", Options{SweepSchedule: SweepSchedule{PeakStart: 25 * time.Hour, PeakMaxEntries: -1, PeakMaxDuration: -1}}, []string{
+			"SweepSchedule.PeakStart and PeakEnd must be in [0, 24h)",
+			"SweepSchedule.PeakMaxEntries must not be negative",
+			"SweepSchedule.PeakMaxDuration must not be negative",
+		}},
+		{"sweep caps without window", Options{SweepSchedule: SweepSchedule{PeakMaxEntries: 10}}, []string{"SweepSchedule caps are set but the peak window is empty"}},
+		{"sliding TTL without window", Options{SlidingTTL: true}, []string{"SlidingWindow must be positive"}},
+		{"sliding window without SlidingTTL", Options{SlidingWindow: time.Minute}, []string{"SlidingWindow is set but SlidingTTL is false"}},
+		{"bad fairness shares", Options{FairnessShares: map[string]float64{"a": 0.8, "b": 1.5}}, []string{
+			`FairnessShares["b"] must be in [0, 1]`,
+			"FairnessShares must not add up to more than 1",
+			"FairnessShares is set but FairnessGroups is nil",
+		}},
+		{"bad cardinality guard", Options{CardinalityGuard: CardinalityGuard{WindowKeys: 100, AdmitFraction: 2}}, []string{
+			"CardinalityGuard.AdmitFraction must be in [0, 1]",
+			"CardinalityGuard needs both WindowKeys and MaxNewKeyRate",
+		}},
+		{"several problems", Options{LogLevel: "loud", Preallocate: -5, MinUsefulTTL: -1}, []string{
+			`unknown LogLevel "loud"`,
+			"Preallocate must not be negative",
+			"MinUsefulTTL must not be negative",
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.opts.Validate()
+			var cfgErr *ConfigError
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("Expected a ConfigError, got %v", err)
+			}
+			if !reflect.DeepEqual(cfgErr.Problems, c.want) {
+				t.Errorf("Expected problems %q, got %q", c.want, cfgErr.Problems)
+			}
+			if _, err := NewLRUWithTTL(10, c.opts); !errors.As(err, &cfgErr) {
+				t.Errorf("Expected NewLRUWithTTL to reject the options, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOptionsValidateAcceptsValidOptions(t *testing.T) {
+	opts := Options{
+		LogLevel:           "warn",
+		StrictErrors:       true,
+		OnError:            func(error) {},
+		SetRateLimit:       SetRateLimit{PerKeyPerSecond: 5, Burst: 2},
+		TombstoneRetention: TombstoneRetention{Count: 10, MaxAge: time.Minute},
+		MissFilter:         MissFilter{ExpectedItems: 100, FalsePositiveRate: 0.05},
+	}
+	if err := opts.Validate(); err != nil {
+		t.Errorf("Expected valid options, got %v", err)
+	}
+	if err := (Options{}).Validate(); err != nil {
+		t.Errorf("Expected zero options to be valid, got %v", err)
+	}
+}
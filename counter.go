@@ -0,0 +1,129 @@
+package lrucache
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Increment adds delta to the integer stored under key in one step under the
+// write lock and returns the new value. A missing or expired key starts a
+// counter at delta that lives for ttl, stored as an int64. An existing
+// counter keeps its integer type and its deadline, unless
+// Options.IncrementResetsTTL is set. A value that is not an integer fails
+// with ErrNotAnInteger, and a result its type cannot hold fails without
+// changing the counter.
+func (l *LRU) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	if ttl <= 0 && ttl != NoExpiration {
+		return 0, l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+	if err := l.checkWritable(key); err != nil {
+		return 0, err
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if item, _ := raw.(*CacheItem); item == nil || item.expired(l.clock()) || (item.reads != nil && item.reads.Load() <= 0) {
+		if err := l.set(key, delta, ttl, nil); err != nil {
+			return 0, err
+		}
+		return delta, nil
+	}
+
+	if !l.opts.IncrementResetsTTL {
+		ttl = 0
+	}
+	var result int64
+	err = l.update(key, ttl, func(value interface{}) (interface{}, error) {
+		sum, n, err := addInt(value, delta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to increment %s: %w", key, err)
+		}
+		result = n
+		return sum, nil
+	})
+	return result, err
+}
+
+// Decrement subtracts delta from the integer stored under key, as Increment
+// adds to it.
+func (l *LRU) Decrement(key string, delta int64, ttl time.Duration) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, l.invalid(key, "delta out of range")
+	}
+	return l.Increment(key, -delta, ttl)
+}
+
+// addInt adds delta to an integer of any of Go's integer types, returning the
+// sum both in that type and as an int64.
+func addInt(value interface{}, delta int64) (interface{}, int64, error) {
+	var sum interface{}
+	var n int64
+	var ok bool
+	switch v := value.(type) {
+	case int:
+		sum, n, ok = addSigned(v, delta)
+	case int8:
+		sum, n, ok = addSigned(v, delta)
+	case int16:
+		sum, n, ok = addSigned(v, delta)
+	case int32:
+		sum, n, ok = addSigned(v, delta)
+	case int64:
+		sum, n, ok = addSigned(v, delta)
+	case uint:
+		sum, n, ok = addUnsigned(v, delta)
+	case uint8:
+		sum, n, ok = addUnsigned(v, delta)
+	case uint16:
+		sum, n, ok = addUnsigned(v, delta)
+	case uint32:
+		sum, n, ok = addUnsigned(v, delta)
+	case uint64:
+		sum, n, ok = addUnsigned(v, delta)
+	default:
+		return nil, 0, fmt.Errorf("%w: %T", ErrNotAnInteger, value)
+	}
+	if !ok {
+		return nil, 0, fmt.Errorf("%v plus %d overflows %T", value, delta, value)
+	}
+	return sum, n, nil
+}
+
+func addSigned[T int | int8 | int16 | int32 | int64](n T, delta int64) (interface{}, int64, bool) {
+	sum := int64(n) + delta
+	if (delta > 0 && sum < int64(n)) || (delta < 0 && sum > int64(n)) || int64(T(sum)) != sum {
+		return nil, 0, false
+	}
+	return T(sum), sum, true
+}
+
+// addUnsigned also fails for sums above math.MaxInt64, which Increment could
+// not return.
+func addUnsigned[T uint | uint8 | uint16 | uint32 | uint64](n T, delta int64) (interface{}, int64, bool) {
+	var sum uint64
+	if delta >= 0 {
+		sum = uint64(n) + uint64(delta)
+		if sum < uint64(n) {
+			return nil, 0, false
+		}
+	} else {
+		d := uint64(-(delta + 1)) + 1
+		if d > uint64(n) {
+			return nil, 0, false
+		}
+		sum = uint64(n) - d
+	}
+	if sum > math.MaxInt64 || uint64(T(sum)) != sum {
+		return nil, 0, false
+	}
+	return T(sum), int64(sum), true
+}
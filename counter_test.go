@@ -0,0 +1,96 @@
+package lrucache
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRUIncrement(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	if n, err := cache.Increment("hits", 5, time.Minute); n != 5 || err != nil {
+		t.Fatalf("Expected a missing counter to start at 5, got %d, %v", n, err)
+	}
+	if got := expiresAt(t, cache, "hits"); !got.Equal(now.Add(time.Minute)) {
+		t.Errorf("Expected a new counter to use the ttl, got %v", got)
+	}
+
+	now = now.Add(30 * time.Second)
+	if n, err := cache.Increment("hits", 2, time.Hour); n != 7 || err != nil {
+		t.Errorf("Expected 7, got %d, %v", n, err)
+	}
+	if n, err := cache.Decrement("hits", 10, time.Hour); n != -3 || err != nil {
+		t.Errorf("Expected -3, got %d, %v", n, err)
+	}
+	if got := expiresAt(t, cache, "hits"); !got.Equal(now.Add(30 * time.Second)) {
+		t.Errorf("Expected an existing counter to keep its deadline, got %v", got)
+	}
+	if v, _ := cache.Get("hits"); v != int64(-3) {
+		t.Errorf("Expected an int64 counter, got %T %v", v, v)
+	}
+
+	// Existing integers keep their type.
+	cache.Set("small", uint8(250), time.Minute)
+	if n, err := cache.Increment("small", 5, time.Minute); n != 255 || err != nil {
+		t.Errorf("Expected 255, got %d, %v", n, err)
+	}
+	if _, err := cache.Increment("small", 1, time.Minute); err == nil {
+		t.Error("Expected an overflow of uint8 to fail")
+	}
+	if v, _ := cache.Get("small"); v != uint8(255) {
+		t.Errorf("Expected a failed increment to leave the counter alone, got %T %v", v, v)
+	}
+	cache.Set("big", int64(math.MaxInt64), time.Minute)
+	if _, err := cache.Increment("big", 1, time.Minute); err == nil {
+		t.Error("Expected an overflow of int64 to fail")
+	}
+
+	cache.Set("name", "alice", time.Minute)
+	if _, err := cache.Increment("name", 1, time.Minute); !errors.Is(err, ErrNotAnInteger) {
+		t.Errorf("Expected ErrNotAnInteger, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if n, err := cache.Increment("hits", 1, time.Minute); n != 1 || err != nil {
+		t.Errorf("Expected an expired counter to start over, got %d, %v", n, err)
+	}
+}
+
+func TestLRUIncrementResetsTTL(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", IncrementResetsTTL: true})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Increment("hits", 1, time.Minute)
+	now = now.Add(30 * time.Second)
+	cache.Increment("hits", 1, time.Hour)
+	if got := expiresAt(t, cache, "hits"); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected the TTL to be reset, got %v", got)
+	}
+}
+
+func TestLRUIncrementConcurrent(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if _, err := cache.Increment("hits", 1, time.Hour); err != nil {
+					t.Errorf("Increment failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := cache.Get("hits"); v != int64(800) {
+		t.Errorf("Expected 800, got %v", v)
+	}
+}
@@ -0,0 +1,60 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// packagePrefix starts the function names of this package, but not of its
+// subpackages.
+const packagePrefix = "github.com/shammianand/lrucache."
+
+// callerBug reports whether err comes from misusing the cache rather than
+// from the data or the environment. Only these errors panic under
+// Options.DebugPanics:
+//   - ErrInvalidArgument: an empty key or variant, a TTL, limit or size out
+//     of range, too many dependencies or a malformed field path
+//   - ErrClosed: a write after Close
+//   - ErrDependencyCycle: a derived entry depending on itself
+//
+// Misses, rate limiting, skipped stores, draining, values of the wrong
+// shape, patch conflicts, decode limits and storage failures are not caller
+// bugs.
+func callerBug(err error) bool {
+	return errors.Is(err, ErrInvalidArgument) ||
+		errors.Is(err, ErrClosed) ||
+		errors.Is(err, ErrDependencyCycle)
+}
+
+// misuse returns err, or panics with it when Options.DebugPanics is set and
+// err is a caller bug. The panic value is an error wrapping err that names
+// the key and the first call site outside the package.
+func (l *LRU) misuse(key string, err error) error {
+	if l.opts.DebugPanics && callerBug(err) {
+		panic(fmt.Errorf("lrucache: %w (key %q, called from %s)", err, l.redact(key), callSite()))
+	}
+	return err
+}
+
+// invalid returns an ErrInvalidArgument error for key through misuse.
+func (l *LRU) invalid(key, msg string) error {
+	return l.misuse(key, fmt.Errorf("%w: %s", ErrInvalidArgument, msg))
+}
+
+// callSite returns the file and line of the innermost caller outside the
+// package, counting the package's tests as outside.
+func callSite() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, packagePrefix) || strings.HasSuffix(f.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
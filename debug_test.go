@@ -0,0 +1,128 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCallerBugClassification(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{ErrInvalidArgument, true},
+		{fmt.Errorf("%w: ttl must be positive", ErrInvalidArgument), true},
+		{ErrClosed, true},
+		{ErrDependencyCycle, true},
+		{ErrItemNotFound, false},
+		{ErrItemExpired, false},
+		{ErrRateLimited, false},
+		{ErrNotStored, false},
+		{ErrNotAnObject, false},
+		{ErrPatchConflict, false},
+		{ErrDeserialization, false},
+		{ErrDraining, false},
+		{errInjected, false},
+		{fmt.Errorf("failed to insert item: %v", errInjected), false},
+	}
+	for _, c := range cases {
+		if got := callerBug(c.err); got != c.want {
+			t.Errorf("callerBug(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+// panics runs fn and returns the error it panicked with, or nil.
+func panics(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+	fn()
+	return nil
+}
+
+func TestDebugPanicsOnMisuse(t *testing.T) {
+	newCache := func() *LRU {
+		cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", DebugPanics: true})
+		return cache
+	}
+	cache := newCache()
+	cache.Set("obj", map[string]interface{}{}, time.Minute)
+	cache.SetDerived("derived", 1, time.Minute, []string{"obj"})
+
+	misuses := map[string]func(){
+		"empty key":       func() { cache.Set("", 1, time.Minute) },
+		"zero ttl":        func() { cache.Set("k", 1, 0) },
+		"negative ttl":    func() { cache.GetOrSet("k", 1, -time.Second) },
+		"empty variant":   func() { cache.SetVariant("k", "", nil, time.Minute) },
+		"no reads":        func() { cache.SetWithReadLimit("k", 1, time.Minute, 0) },
+		"bad field path":  func() { cache.SetField("obj", "a..b", 1) },
+		"dependency loop": func() { cache.SetDerived("obj", 1, time.Minute, []string{"derived"}) },
+		"set after close": func() {
+			closed := newCache()
+			closed.Close()
+			closed.Set("k", 1, time.Minute)
+		},
+	}
+	for name, fn := range misuses {
+		err := panics(fn)
+		if err == nil {
+			t.Errorf("%s: expected a panic", name)
+			continue
+		}
+		if !callerBug(errors.Unwrap(err)) {
+			t.Errorf("%s: expected the panic to wrap a caller bug, got %v", name, err)
+		}
+		if !strings.Contains(err.Error(), "debug_test.go:") {
+			t.Errorf("%s: expected the call site in %q", name, err)
+		}
+	}
+	if err := panics(func() { cache.Set("", 1, time.Minute) }); !strings.Contains(err.Error(), `key ""`) {
+		t.Errorf("Expected the key in %q", err)
+	}
+	if err := panics(func() { cache.Set("k", 1, 0) }); !strings.Contains(err.Error(), `key "k"`) {
+		t.Errorf("Expected the key in %q", err)
+	}
+}
+
+func TestDebugPanicsKeepsOtherErrors(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", DebugPanics: true, StrictErrors: true})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	cache.Set("key", 1, time.Second)
+	now = now.Add(2 * time.Second)
+	cache.faultHook = failing("delete")
+
+	var err error
+	if p := panics(func() { _, err = cache.Get("key") }); p != nil {
+		t.Fatalf("Expected a storage failure not to panic, got %v", p)
+	}
+	if !errors.Is(err, errInjected) {
+		t.Errorf("Expected the injected failure, got %v", err)
+	}
+	if p := panics(func() { _, err = cache.Get("missing") }); p != nil || err != ErrItemNotFound {
+		t.Errorf("Expected a plain miss, got %v, panic %v", err, p)
+	}
+}
+
+func TestMisuseErrorsWithoutDebugPanics(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	if err := cache.Set("", 1, time.Minute); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected ErrInvalidArgument for an empty key, got %v", err)
+	}
+	if err := cache.Set("k", 1, 0); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected ErrInvalidArgument for a zero TTL, got %v", err)
+	}
+	cache.Close()
+	if err := cache.Set("k", 1, time.Minute); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+	if _, err := cache.Get("k"); err != ErrItemNotFound {
+		t.Errorf("Expected reads to keep working after Close, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package lrucache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Decision is one internal choice the cache made while serving an
+// operation. Decisions are only recorded in builds with the cachetrace tag.
+type Decision struct {
+	Seq    uint64 // position in the trace, starting at 1
+	Op     string // the operation that made the decision: set, get or sweep
+	Key    string // the key the decision is about, after Options.KeyRedactor
+	Step   string // admit, reject, heap, victim, expire or filter
+	Reason string
+}
+
+func (d Decision) String() string {
+	return fmt.Sprintf("#%d %s %s %s: %s", d.Seq, d.Op, d.Step, d.Key, d.Reason)
+}
+
+// decisionLogSize is how many recent decisions are kept.
+const decisionLogSize = 1024
+
+type decisionLog struct {
+	mu      sync.Mutex
+	entries []Decision
+	seq     uint64
+}
+
+// decide records a decision. Call sites guard it with decisionTracing so
+// that it compiles away in normal builds.
+func (l *LRU) decide(op, key, step, format string, args ...interface{}) {
+	d := &l.decisions
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.seq++
+	if len(d.entries) == decisionLogSize {
+		d.entries = append(d.entries[:0], d.entries[1:]...)
+	}
+	d.entries = append(d.entries, Decision{Seq: d.seq, Op: op, Key: l.redact(key), Step: step, Reason: fmt.Sprintf(format, args...)})
+}
+
+// LastDecisions returns up to n of the most recent decisions, oldest first.
+// It always returns nil unless the cache was built with the cachetrace tag,
+// which is meant for tests:
+//
+//	go test -tags cachetrace ./...
+func (l *LRU) LastDecisions(n int) []Decision {
+	if !decisionTracing {
+		return nil
+	}
+	d := &l.decisions
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	return append([]Decision(nil), d.entries[len(d.entries)-n:]...)
+}
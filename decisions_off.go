@@ -0,0 +1,6 @@
+//go:build !cachetrace
+
+package lrucache
+
+// decisionTracing turns decision recording on; see LastDecisions.
+const decisionTracing = false
@@ -0,0 +1,17 @@
+//go:build !cachetrace
+
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLastDecisionsDisabled(t *testing.T) {
+	cache, _ := NewLRUWithTTL(1, Options{LogLevel: "error"})
+	cache.Set("a", 1, time.Minute)
+	cache.Set("b", 2, time.Minute)
+	if got := cache.LastDecisions(10); got != nil {
+		t.Errorf("Expected no decisions without the cachetrace tag, got %v", got)
+	}
+}
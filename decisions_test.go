@@ -0,0 +1,99 @@
+//go:build cachetrace
+
+package lrucache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func steps(decisions []Decision) []string {
+	var out []string
+	for _, d := range decisions {
+		out = append(out, d.Op+" "+d.Step+" "+d.Key)
+	}
+	return out
+}
+
+func TestDecisionsExplainEviction(t *testing.T) {
+	cache, _ := NewLRUWithTTL(2, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", 1, 3*time.Minute)
+	cache.Set("b", 2, time.Minute)
+	cache.Set("a", 3, 3*time.Minute)
+	cache.Set("c", 4, 2*time.Minute)
+
+	want := []string{
+		"set admit a", "set heap a",
+		"set admit b", "set heap b",
+		"set admit a", "set heap a",
+		"set admit c", "set heap c",
+		// b is evicted despite being written after a: the policy picks the
+		// entry closest to its deadline, not the least recently written.
+		"set victim b",
+	}
+	got := cache.LastDecisions(100)
+	if !reflect.DeepEqual(steps(got), want) {
+		t.Fatalf("Expected decisions %v, got %v", want, got)
+	}
+	if got[5].Reason != "fix existing entry" {
+		t.Errorf("Expected the rewrite of a to fix its heap entry, got %q", got[5].Reason)
+	}
+	if got[8].Reason != "earliest deadline of 3 entries, capacity 2" {
+		t.Errorf("Unexpected victim reason %q", got[8].Reason)
+	}
+	if got[0].Seq != 1 || got[8].Seq != 9 {
+		t.Errorf("Expected sequence numbers 1 to 9, got %d to %d", got[0].Seq, got[8].Seq)
+	}
+
+	now = now.Add(150 * time.Second)
+	cache.removeExpiredItems()
+	if got := steps(cache.LastDecisions(1)); !reflect.DeepEqual(got, []string{"sweep expire c"}) {
+		t.Errorf("Expected c to expire in the sweep, got %v", got)
+	}
+}
+
+func TestDecisionsExplainRejections(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:     "error",
+		MinUsefulTTL: time.Second,
+		SetRateLimit: SetRateLimit{PerKeyPerSecond: 1, Burst: 1},
+		MissFilter:   MissFilter{ExpectedItems: 100},
+	})
+	cache.Set("short", 1, time.Millisecond)
+	cache.Set("hot", 1, time.Minute)
+	cache.Set("hot", 2, time.Minute)
+	cache.Get("never-stored")
+
+	var got []string
+	for _, d := range cache.LastDecisions(10) {
+		if d.Step != "admit" && d.Step != "heap" {
+			got = append(got, d.Step+": "+d.Reason)
+		}
+	}
+	want := []string{
+		"reject: ttl 1ms below MinUsefulTTL 1s",
+		"reject: over the per-key rate limit",
+		"filter: ruled out by the miss filter",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestLastDecisionsBounded(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	for i := 0; i < decisionLogSize; i++ {
+		cache.Set("key", i, time.Minute)
+	}
+	got := cache.LastDecisions(2 * decisionLogSize)
+	if len(got) != decisionLogSize {
+		t.Fatalf("Expected %d decisions, got %d", decisionLogSize, len(got))
+	}
+	if last := got[len(got)-1].Seq; last != 2*decisionLogSize {
+		t.Errorf("Expected the newest decision to be #%d, got #%d", 2*decisionLogSize, last)
+	}
+}
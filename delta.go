@@ -0,0 +1,279 @@
+package lrucache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	snapshotFormat  = "lrucache"
+	snapshotVersion = 1
+
+	defaultDeletionLogSize = 4096
+)
+
+// snapshotHeader opens every export. Full exports replace the contents of
+// the cache they are applied to; deltas are applied on top.
+type snapshotHeader struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	Full       bool   `json:"full"`
+	Since      uint64 `json:"since"`
+	Generation uint64 `json:"generation"`
+}
+
+// snapshotRecord is one line after the header: either the key of a deleted
+// entry or a stored entry with its serialized value.
+type snapshotRecord struct {
+	Delete       string    `json:"delete,omitempty"`
+	Key          string    `json:"key,omitempty"`
+	Value        []byte    `json:"value,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+	CreatedAt    time.Time `json:"created_at,omitempty"`
+	LastModified time.Time `json:"last_modified,omitempty"`
+	Base         string    `json:"base,omitempty"`
+	Variant      string    `json:"variant,omitempty"`
+	Immutable    bool      `json:"immutable,omitempty"`
+	Reads        *int64    `json:"reads,omitempty"`
+}
+
+type deletion struct {
+	key string
+	gen uint64
+}
+
+// deletionLog remembers the most recent deletions for ExportDelta and
+// snapshot reads in a ring. It is changed under the write lock and read
+// under the read lock.
+type deletionLog struct {
+	entries []deletion
+	next    int    // slot the next deletion goes to
+	full    bool   // whether the ring has wrapped
+	lostGen uint64 // generation of the newest overwritten deletion, plus one
+}
+
+func newDeletionLog(size int) deletionLog {
+	if size <= 0 {
+		size = defaultDeletionLogSize
+	}
+	return deletionLog{entries: make([]deletion, size)}
+}
+
+func (d *deletionLog) add(key string, gen uint64) {
+	if d.full {
+		d.lostGen = d.entries[d.next].gen + 1
+	}
+	d.entries[d.next] = deletion{key: key, gen: gen}
+	d.next = (d.next + 1) % len(d.entries)
+	if d.next == 0 {
+		d.full = true
+	}
+}
+
+// since returns the keys deleted in generation gen or later, oldest first,
+// or false if some of them have been overwritten.
+func (d *deletionLog) since(gen uint64) ([]string, bool) {
+	if gen < d.lostGen {
+		return nil, false
+	}
+	var keys []string
+	start, n := 0, d.next
+	if d.full {
+		start, n = d.next, len(d.entries)
+	}
+	for i := 0; i < n; i++ {
+		if e := d.entries[(start+i)%len(d.entries)]; e.gen >= gen {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys, true
+}
+
+// deletedSince reports whether key may have been deleted in generation gen
+// or later, which it cannot rule out once deletions that recent have been
+// overwritten.
+func (d *deletionLog) deletedSince(key string, gen uint64) bool {
+	if gen < d.lostGen {
+		return true
+	}
+	for _, e := range d.entries {
+		if e.key == key && e.gen >= gen {
+			return true
+		}
+	}
+	return false
+}
+
+// recordDeletion notes that key left the cache. The caller must hold the
+// write lock.
+func (l *LRU) recordDeletion(key string) {
+	l.deletions.add(key, l.gens.current.Load())
+}
+
+// ExportDelta writes the live entries written or modified in generation
+// sinceGeneration or later, and the keys deleted since then, for ApplyDelta
+// on another cache. A sinceGeneration of 0 writes a full export, which
+// replaces everything in the cache it is applied to. ExportDelta starts a new
+// generation first, so the next delta is taken since the generation
+// CurrentGeneration reports after it returns. It returns ErrDeltaUnavailable
+// when more deletions happened than Options.DeletionLogSize remembers.
+//
+// The export is consistent: it holds the read lock while writing to w.
+func (l *LRU) ExportDelta(w io.Writer, sinceGeneration uint64) error {
+	gen := l.BumpGeneration()
+
+	l.readLock("scan")
+	defer l.lock.RUnlock()
+
+	header := snapshotHeader{
+		Format:     snapshotFormat,
+		Version:    snapshotVersion,
+		Full:       sinceGeneration == 0,
+		Since:      sinceGeneration,
+		Generation: gen,
+	}
+	var deleted []string
+	if !header.Full {
+		var ok bool
+		if deleted, ok = l.deletions.since(sinceGeneration); !ok {
+			return fmt.Errorf("%w: deletions since generation %d were dropped", ErrDeltaUnavailable, sinceGeneration)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(header); err != nil {
+		return fmt.Errorf("failed to write snapshot header: %v", err)
+	}
+	for _, key := range deleted {
+		if err := enc.Encode(snapshotRecord{Delete: key}); err != nil {
+			return fmt.Errorf("failed to write deletion: %v", err)
+		}
+	}
+
+	it, err := l.db.Txn(false).Get("cache", "id")
+	if err != nil {
+		return fmt.Errorf("failed to get all items: %v", err)
+	}
+	clock := l.clock()
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		item := obj.(*CacheItem)
+		if item.gen < sinceGeneration || item.expired(clock) {
+			continue
+		}
+		rec := snapshotRecord{
+			Key:          item.Key,
+			Value:        item.Value,
+			ExpiresAt:    item.ExpiresAt,
+			CreatedAt:    item.CreatedAt,
+			LastModified: item.LastModified,
+			Base:         item.Base,
+			Variant:      item.Variant,
+			Immutable:    item.Immutable,
+		}
+		if item.reads != nil {
+			reads := item.reads.Load()
+			rec.Reads = &reads
+		}
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("failed to write entry %s: %v", item.Key, err)
+		}
+	}
+	return nil
+}
+
+// ApplyDelta reads an export written by ExportDelta and applies it: a full
+// export replaces the whole contents, a delta deletes the keys it lists and
+// stores its entries with their original expiry times. Entries that have
+// expired by now are skipped.
+func (l *LRU) ApplyDelta(r io.Reader) error {
+	defer l.checkWatermarks()
+
+	header, records, err := readExport(r)
+	if err != nil {
+		return err
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	// The whole export is applied under one write lock, so no other write
+	// lands between clearing and storing; the items are built under it too,
+	// as the arena they come from is guarded by it.
+	if header.Full {
+		if err := l.clear(ClearOptions{}); err != nil {
+			return err
+		}
+	}
+	var items []*CacheItem
+	now := l.now()
+	for _, rec := range records {
+		if rec.Delete == "" {
+			if item := l.itemFromRecord(rec, now); item != nil {
+				items = append(items, item)
+			}
+			continue
+		}
+		if raw, err := l.db.Txn(false).First("cache", "id", rec.Delete); err != nil || raw == nil {
+			continue
+		}
+		if err := l.deleteKey(rec.Delete); err != nil {
+			return err
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return l.storeMany(items)
+}
+
+// readExport reads the header and records of an export.
+func readExport(r io.Reader) (snapshotHeader, []snapshotRecord, error) {
+	dec := json.NewDecoder(r)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return header, nil, fmt.Errorf("failed to read snapshot header: %v", err)
+	}
+	if header.Format != snapshotFormat || header.Version != snapshotVersion {
+		return header, nil, fmt.Errorf("unsupported snapshot format %q version %d", header.Format, header.Version)
+	}
+	var records []snapshotRecord
+	for {
+		var rec snapshotRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			return header, records, nil
+		} else if err != nil {
+			return header, nil, fmt.Errorf("failed to read snapshot record: %v", err)
+		}
+		records = append(records, rec)
+	}
+}
+
+// itemFromRecord builds the item for an entry record, or returns nil if it
+// has expired by now. The item replaces an immutable entry under its key,
+// which the source has already let go of.
+func (l *LRU) itemFromRecord(rec snapshotRecord, now time.Time) *CacheItem {
+	ttl := rec.ExpiresAt.Sub(now)
+	if ttl <= 0 && !rec.ExpiresAt.IsZero() {
+		return nil
+	}
+	item := l.newItem(rec.Key, rec.Value, ttl)
+	if rec.ExpiresAt.IsZero() {
+		item.ExpiresAt, item.deadline = time.Time{}, neverDeadline
+	}
+	item.Base, item.Variant = rec.Base, rec.Variant
+	item.Immutable, item.overwrite = rec.Immutable, true
+	if !rec.CreatedAt.IsZero() {
+		item.CreatedAt = rec.CreatedAt
+	}
+	if !rec.LastModified.IsZero() {
+		item.LastModified = rec.LastModified
+	}
+	if rec.Reads != nil {
+		item.reads = &atomic.Int64{}
+		item.reads.Store(*rec.Reads)
+	}
+	return item
+}
@@ -0,0 +1,162 @@
+package lrucache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sameContents(t *testing.T, source, replica *LRU) {
+	t.Helper()
+	report, err := source.Diff(replica, DiffOptions{CompareValues: true, CompareExpiry: true})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if !reflect.DeepEqual(report, DiffReport{}) {
+		t.Errorf("Replica differs from source: %+v", report)
+	}
+	if got, want := replica.Variants("page"), source.Variants("page"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected variants %v, got %v", want, got)
+	}
+}
+
+func TestLRUExportDelta(t *testing.T) {
+	source, _ := NewLRUWithTTL(100, Options{LogLevel: "error"})
+	replica, _ := NewLRUWithTTL(100, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	source.now = func() time.Time { return now }
+	replica.now = func() time.Time { return now }
+
+	source.Set("kept", "v", time.Hour)
+	source.Set("changed", "old", time.Hour)
+	source.Set("deleted", "v", time.Hour)
+	source.Set("user", map[string]interface{}{"name": "a"}, time.Hour)
+	source.SetVariant("page", "gzip", []byte("z"), time.Hour)
+	source.SetWithReadLimit("once", "v", time.Hour, 3)
+	replica.Set("stale", "v", time.Hour)
+
+	var full bytes.Buffer
+	if err := source.ExportDelta(&full, 0); err != nil {
+		t.Fatalf("ExportDelta failed: %v", err)
+	}
+	since := source.CurrentGeneration()
+	if err := replica.ApplyDelta(&full); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	sameContents(t, source, replica)
+	if _, err := replica.Get("stale"); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("Expected a full export to replace the replica's contents, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	source.Set("changed", "new", 2*time.Hour)
+	source.Set("added", "v", time.Hour)
+	source.Delete("deleted")
+	source.SetField("user", "name", "b")
+	source.SetVariant("page", "br", []byte("b"), time.Hour)
+
+	var delta bytes.Buffer
+	if err := source.ExportDelta(&delta, since); err != nil {
+		t.Fatalf("ExportDelta failed: %v", err)
+	}
+	if err := replica.ApplyDelta(&delta); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	sameContents(t, source, replica)
+	value, err := replica.Get("user")
+	if err != nil || value.(map[string]interface{})["name"] != "b" {
+		t.Errorf("Expected the modified field to replicate, got %v, %v", value, err)
+	}
+
+	// The unchanged entries stay out of the delta.
+	if bytes.Contains(delta.Bytes(), []byte(`"kept"`)) {
+		t.Errorf("Expected the delta to skip unchanged entries:\n%s", delta.String())
+	}
+
+	// A later delta picks up where the last one stopped.
+	since = source.CurrentGeneration()
+	source.Delete("page")
+	delta.Reset()
+	source.Set("last", "v", time.Hour)
+	if err := source.ExportDelta(&delta, since); err != nil {
+		t.Fatalf("ExportDelta failed: %v", err)
+	}
+	if err := replica.ApplyDelta(&delta); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	sameContents(t, source, replica)
+}
+
+func TestLRUExportDeltaUnavailable(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{LogLevel: "error", DeletionLogSize: 2})
+	cache.Set("a", "v", time.Hour)
+	cache.Set("b", "v", time.Hour)
+	cache.Set("c", "v", time.Hour)
+	var buf bytes.Buffer
+	cache.ExportDelta(&buf, 0)
+	since := cache.CurrentGeneration()
+
+	cache.Delete("a")
+	cache.Delete("b")
+	if err := cache.ExportDelta(&buf, since); err != nil {
+		t.Fatalf("Expected a delta while the log holds every deletion, got %v", err)
+	}
+	cache.Delete("c")
+	if err := cache.ExportDelta(&buf, since); !errors.Is(err, ErrDeltaUnavailable) {
+		t.Errorf("Expected ErrDeltaUnavailable, got %v", err)
+	}
+	if err := cache.ExportDelta(&buf, 0); err != nil {
+		t.Errorf("Expected a full export to stay available, got %v", err)
+	}
+}
+
+func TestLRUApplyDeltaRejectsUnknownFormat(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	if err := cache.ApplyDelta(bytes.NewBufferString(`{"format":"other","version":1}`)); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestLRUApplyDeltaConcurrentWrites(t *testing.T) {
+	source, _ := NewLRUWithTTL(64, Options{LogLevel: "error"})
+	for i := 0; i < 32; i++ {
+		source.Set(fmt.Sprintf("src%d", i), i, time.Hour)
+	}
+	var full bytes.Buffer
+	if err := source.ExportDelta(&full, 0); err != nil {
+		t.Fatalf("ExportDelta failed: %v", err)
+	}
+
+	replica, _ := NewLRUWithTTL(64, Options{LogLevel: "error", Preallocate: 64})
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			replica.Set(fmt.Sprintf("local%d", i%16), i, time.Hour)
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if err := replica.ApplyDelta(bytes.NewReader(full.Bytes())); err != nil {
+			t.Errorf("ApplyDelta failed: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	for i := 0; i < 32; i++ {
+		if v, err := replica.Get(fmt.Sprintf("src%d", i)); err != nil || v != i {
+			t.Errorf("Expected src%d to be applied, got %v, %v", i, v, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package lrucache
+
+import (
+	"fmt"
+	"time"
+)
+
+const defaultMaxDependencies = 32
+
+// dependencyGraph records which derived entries were computed from which
+// keys. It is only used under the write lock.
+type dependencyGraph struct {
+	deps       map[string][]string            // derived key -> keys it depends on
+	dependents map[string]map[string]struct{} // key -> derived keys depending on it
+}
+
+func (g *dependencyGraph) set(key string, deps []string) {
+	if g.deps == nil {
+		g.deps = make(map[string][]string)
+		g.dependents = make(map[string]map[string]struct{})
+	}
+	g.deps[key] = deps
+	for _, dep := range deps {
+		if g.dependents[dep] == nil {
+			g.dependents[dep] = make(map[string]struct{})
+		}
+		g.dependents[dep][key] = struct{}{}
+	}
+}
+
+// forget drops the edges from key to the keys it depends on.
+func (g *dependencyGraph) forget(key string) {
+	for _, dep := range g.deps[key] {
+		delete(g.dependents[dep], key)
+		if len(g.dependents[dep]) == 0 {
+			delete(g.dependents, dep)
+		}
+	}
+	delete(g.deps, key)
+}
+
+func (g *dependencyGraph) dependentsOf(key string) []string {
+	var keys []string
+	for d := range g.dependents[key] {
+		keys = append(keys, d)
+	}
+	return keys
+}
+
+// reaches reports whether target is key or one of the keys key was derived
+// from, directly or transitively.
+func (g *dependencyGraph) reaches(key, target string) bool {
+	seen := make(map[string]bool)
+	stack := []string{key}
+	for len(stack) > 0 {
+		k := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if k == target {
+			return true
+		}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		stack = append(stack, g.deps[k]...)
+	}
+	return false
+}
+
+// SetDerived stores a value computed from the keys in dependsOn. Any later
+// Set, Delete, expiry or eviction of one of those keys invalidates it, firing
+// the RemovalCallback with ReasonDependency. Dependencies do not need to be
+// cached themselves; a dependency that would create a cycle is rejected with
+// ErrDependencyCycle.
+func (l *LRU) SetDerived(key string, value interface{}, ttl time.Duration, dependsOn []string) error {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	if ttl <= 0 && ttl != NoExpiration {
+		return l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+	if err := l.checkUsefulTTL(ttl); err != nil {
+		return err
+	}
+	maxDeps := l.opts.MaxDependencies
+	if maxDeps <= 0 {
+		maxDeps = defaultMaxDependencies
+	}
+	if len(dependsOn) > maxDeps {
+		return l.misuse(key, fmt.Errorf("%w: too many dependencies: %d, at most %d allowed", ErrInvalidArgument, len(dependsOn), maxDeps))
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	deps := make([]string, 0, len(dependsOn))
+	seen := make(map[string]bool, len(dependsOn))
+	for _, dep := range dependsOn {
+		dep = l.NormalizeKey(dep)
+		if l.deps.reaches(dep, key) {
+			return l.misuse(key, fmt.Errorf("%w: %s depends on %s", ErrDependencyCycle, dep, key))
+		}
+		if !seen[dep] {
+			seen[dep] = true
+			deps = append(deps, dep)
+		}
+	}
+
+	return l.set(key, value, ttl, deps)
+}
+
+// invalidateDependents removes the entries derived from key, cascading to
+// entries derived from those. The caller must hold the write lock.
+func (l *LRU) invalidateDependents(key string) {
+	for _, d := range l.deps.dependentsOf(key) {
+		raw, err := l.db.Txn(false).First("cache", "id", d)
+		if err != nil || raw == nil {
+			l.deps.forget(d)
+			continue
+		}
+		if err := l.removeItem(d, ReasonDependency); err == nil {
+			l.log("debug", "Invalidated key: %s, dependency changed: %s", l.redact(d), l.redact(key))
+		}
+	}
+}
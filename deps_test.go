@@ -0,0 +1,89 @@
+package lrucache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSetDerivedInvalidation(t *testing.T) {
+	removed := make(map[string]EvictReason)
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		RemovalCallback: func(key string, value interface{}, reason EvictReason) {
+			removed[key] = reason
+		},
+	})
+
+	cache.Set("product:1", 1, time.Hour)
+	cache.Set("product:2", 2, time.Hour)
+	cache.Set("product:3", 3, time.Hour)
+	cache.SetDerived("top-products", []int{1, 2}, time.Hour, []string{"product:1", "product:2"})
+	cache.SetDerived("featured", []int{3}, time.Hour, []string{"product:3"})
+
+	cache.Set("product:1", 10, time.Hour)
+
+	if _, err := cache.Get("top-products"); err != ErrItemNotFound {
+		t.Errorf("Expected top-products to be invalidated, got %v", err)
+	}
+	if removed["top-products"] != ReasonDependency {
+		t.Errorf("Expected ReasonDependency for top-products, got %v", removed["top-products"])
+	}
+	if _, err := cache.Get("featured"); err != nil {
+		t.Errorf("Expected unrelated featured to survive, got %v", err)
+	}
+	if _, ok := removed["product:1"]; ok {
+		t.Errorf("Expected no callback for the overwritten dependency")
+	}
+
+	cache.Delete("product:3")
+	if _, err := cache.Get("featured"); err != ErrItemNotFound {
+		t.Errorf("Expected featured to be invalidated by Delete, got %v", err)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent heap, got %v", err)
+	}
+}
+
+func TestSetDerivedExpiryAndCascade(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("base", 1, time.Minute)
+	cache.SetDerived("mid", 2, time.Hour, []string{"base"})
+	cache.SetDerived("top", 3, time.Hour, []string{"mid", "never-cached"})
+
+	now = now.Add(2 * time.Minute)
+	cache.removeExpiredItems()
+
+	for _, key := range []string{"base", "mid", "top"} {
+		if _, err := cache.Get(key); err != ErrItemNotFound {
+			t.Errorf("Expected %s to be gone after base expired, got %v", key, err)
+		}
+	}
+	if len(cache.deps.deps) != 0 || len(cache.deps.dependents) != 0 {
+		t.Errorf("Expected dependency edges to be dropped, got %v %v", cache.deps.deps, cache.deps.dependents)
+	}
+}
+
+func TestSetDerivedRejectsCycles(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", MaxDependencies: 2})
+
+	if err := cache.SetDerived("a", 1, time.Hour, []string{"a"}); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("Expected self dependency to be rejected, got %v", err)
+	}
+
+	cache.SetDerived("b", 2, time.Hour, []string{"c"})
+	cache.SetDerived("a", 1, time.Hour, []string{"b"})
+	if err := cache.SetDerived("c", 3, time.Hour, []string{"a"}); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("Expected transitive cycle to be rejected, got %v", err)
+	}
+	if _, err := cache.Get("c"); err != ErrItemNotFound {
+		t.Errorf("Expected rejected entry not to be stored, got %v", err)
+	}
+
+	if err := cache.SetDerived("d", 4, time.Hour, []string{"x", "y", "z"}); err == nil {
+		t.Errorf("Expected too many dependencies to be rejected")
+	}
+}
@@ -0,0 +1,118 @@
+package lrucache
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+	"unsafe"
+)
+
+// DiffOptions controls what Diff treats as a difference.
+type DiffOptions struct {
+	// CompareValues reports keys present in both caches whose values differ.
+	CompareValues bool
+	// CompareExpiry reports keys whose ExpiresAt differs by more than
+	// ExpiryTolerance.
+	CompareExpiry   bool
+	ExpiryTolerance time.Duration
+	// MaxKeys caps the length of each key list in the report; the counts
+	// always cover every difference. Zero lists every key.
+	MaxKeys int
+}
+
+// DiffReport lists the live keys that differ between two caches, in key
+// order.
+type DiffReport struct {
+	OnlyInA       []string
+	OnlyInB       []string
+	ValueMismatch []string
+
+	OnlyInACount       int
+	OnlyInBCount       int
+	ValueMismatchCount int
+}
+
+// Diff compares the live entries of l (A) with those of other (B). Both
+// tables are walked in key order side by side, so neither key set is
+// materialized. Both caches are read locked for the duration.
+func (l *LRU) Diff(other *LRU, opts DiffOptions) (DiffReport, error) {
+	var report DiffReport
+	if other == l {
+		return report, nil
+	}
+
+	// Lock in a fixed order so that concurrent Diffs in opposite directions
+	// cannot deadlock behind waiting writers.
+	first, second := l, other
+	if uintptr(unsafe.Pointer(other)) < uintptr(unsafe.Pointer(l)) {
+		first, second = other, l
+	}
+	first.readLock("scan")
+	defer first.lock.RUnlock()
+	second.readLock("scan")
+	defer second.lock.RUnlock()
+
+	nextA, err := l.liveItems()
+	if err != nil {
+		return report, err
+	}
+	nextB, err := other.liveItems()
+	if err != nil {
+		return report, err
+	}
+
+	add := func(keys *[]string, count *int, key string) {
+		*count++
+		if opts.MaxKeys <= 0 || len(*keys) < opts.MaxKeys {
+			*keys = append(*keys, key)
+		}
+	}
+	a, b := nextA(), nextB()
+	for a != nil || b != nil {
+		switch {
+		case b == nil || (a != nil && a.Key < b.Key):
+			add(&report.OnlyInA, &report.OnlyInACount, a.Key)
+			a = nextA()
+		case a == nil || b.Key < a.Key:
+			add(&report.OnlyInB, &report.OnlyInBCount, b.Key)
+			b = nextB()
+		default:
+			if !sameEntry(a, b, opts) {
+				add(&report.ValueMismatch, &report.ValueMismatchCount, a.Key)
+			}
+			a, b = nextA(), nextB()
+		}
+	}
+	return report, nil
+}
+
+// liveItems returns an iterator over the unexpired items in key order. The
+// caller must hold the lock.
+func (l *LRU) liveItems() (func() *CacheItem, error) {
+	it, err := l.db.Txn(false).Get("cache", "id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all items: %v", err)
+	}
+	clock := l.clock()
+	return func() *CacheItem {
+		for obj := it.Next(); obj != nil; obj = it.Next() {
+			if item := obj.(*CacheItem); !item.expired(clock) {
+				return item
+			}
+		}
+		return nil
+	}, nil
+}
+
+func sameEntry(a, b *CacheItem, opts DiffOptions) bool {
+	if opts.CompareValues && !bytes.Equal(a.Value, b.Value) {
+		return false
+	}
+	if opts.CompareExpiry {
+		delta := a.ExpiresAt.Sub(b.ExpiresAt)
+		if delta < -opts.ExpiryTolerance || delta > opts.ExpiryTolerance {
+			return false
+		}
+	}
+	return true
+}
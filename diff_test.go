@@ -0,0 +1,65 @@
+package lrucache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLRUDiff(t *testing.T) {
+	a, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	b, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	a.now = func() time.Time { return now }
+	b.now = func() time.Time { return now }
+
+	a.Set("same", "v", time.Hour)
+	b.Set("same", "v", time.Hour)
+	a.Set("changed", "old", time.Hour)
+	b.Set("changed", "new", time.Hour)
+	a.Set("drifted", "v", time.Hour)
+	b.Set("drifted", "v", time.Hour+2*time.Second)
+	a.Set("shifted", "v", time.Hour)
+	b.Set("shifted", "v", time.Hour+time.Minute)
+	a.Set("onlyA1", "v", time.Hour)
+	a.Set("onlyA2", "v", time.Hour)
+	b.Set("onlyB", "v", time.Hour)
+	b.Set("expired", "v", time.Minute)
+	a.Set("expired", "v", time.Hour)
+	now = now.Add(2 * time.Minute)
+
+	report, err := a.Diff(b, DiffOptions{
+		CompareValues:   true,
+		CompareExpiry:   true,
+		ExpiryTolerance: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	want := DiffReport{
+		OnlyInA:            []string{"expired", "onlyA1", "onlyA2"},
+		OnlyInB:            []string{"onlyB"},
+		ValueMismatch:      []string{"changed", "shifted"},
+		OnlyInACount:       3,
+		OnlyInBCount:       1,
+		ValueMismatchCount: 2,
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Expected %+v, got %+v", want, report)
+	}
+
+	report, _ = a.Diff(b, DiffOptions{MaxKeys: 1})
+	want = DiffReport{
+		OnlyInA:      []string{"expired"},
+		OnlyInB:      []string{"onlyB"},
+		OnlyInACount: 3,
+		OnlyInBCount: 1,
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Expected keys only, capped lists %+v, got %+v", want, report)
+	}
+
+	if report, _ := a.Diff(a, DiffOptions{CompareValues: true}); !reflect.DeepEqual(report, DiffReport{}) {
+		t.Errorf("Expected a cache to match itself, got %+v", report)
+	}
+}
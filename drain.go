@@ -0,0 +1,49 @@
+package lrucache
+
+// Drain modes; see EnterDrainMode.
+const (
+	drainOff int32 = iota
+	drainFresh
+	drainStale
+)
+
+// EnterDrainMode prepares the cache for a shutdown drain: it keeps serving
+// what it holds while doing nothing on its own. Writes that store or refresh
+// values fail with ErrDraining, reads no longer remove expired or used up
+// entries and so fire no removal callbacks, the background sweep, schedules,
+// notices and alerts pause, and CachedFunc stops calling through on a miss.
+// Delete and Clear still work. With staleOK, Get also serves entries whose
+// TTL has passed.
+func (l *LRU) EnterDrainMode(staleOK bool) {
+	mode := drainFresh
+	if staleOK {
+		mode = drainStale
+	}
+	l.drain.Store(mode)
+	l.log("info", "Entered drain mode, stale reads allowed: %v", staleOK)
+}
+
+// ExitDrainMode returns the cache to normal operation. Entries that expired
+// during the drain are removed by the next sweep.
+func (l *LRU) ExitDrainMode() {
+	if l.drain.Swap(drainOff) != drainOff {
+		l.log("info", "Left drain mode")
+	}
+}
+
+// Draining reports whether the cache is in drain mode.
+func (l *LRU) Draining() bool {
+	return l.drain.Load() != drainOff
+}
+
+// checkWritable returns ErrClosed after Close and ErrDraining while the
+// cache is draining.
+func (l *LRU) checkWritable(key string) error {
+	if l.closed.Load() {
+		return l.misuse(key, ErrClosed)
+	}
+	if l.Draining() {
+		return ErrDraining
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDrainModeRejectsWrites(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("k", map[string]interface{}{"a": 1}, time.Minute)
+	cache.Set("gone", "v", time.Minute)
+
+	cache.EnterDrainMode(false)
+	if !cache.Stats().Draining {
+		t.Error("Expected Stats to report drain mode")
+	}
+	writes := map[string]error{
+		"Set":              cache.Set("new", 1, time.Minute),
+		"SetPreservingTTL": cache.SetPreservingTTL("k", 2),
+		"SetField":         cache.SetField("k", "a", 3),
+		"SetVariant":       cache.SetVariant("k", "gzip", []byte("x"), time.Minute),
+	}
+	_, _, writes["TouchMany"] = cache.TouchMany([]string{"k"}, time.Hour)
+	_, _, writes["GetOrSet"] = cache.GetOrSet("new", 1, time.Minute)
+	for name, err := range writes {
+		if !errors.Is(err, ErrDraining) {
+			t.Errorf("Expected %s to fail with ErrDraining, got %v", name, err)
+		}
+	}
+	if v, err := cache.Get("k"); err != nil || v.(map[string]interface{})["a"] != float64(1) {
+		t.Errorf("Expected reads to serve the cached value, got %v, %v", v, err)
+	}
+	if err := cache.Delete("gone"); err != nil {
+		t.Errorf("Expected Delete to work while draining, got %v", err)
+	}
+
+	cache.ExitDrainMode()
+	if cache.Stats().Draining {
+		t.Error("Expected drain mode to be off")
+	}
+	if err := cache.Set("new", 1, time.Minute); err != nil {
+		t.Errorf("Expected Set to work after the drain, got %v", err)
+	}
+}
+
+func TestDrainModeStaleReads(t *testing.T) {
+	removed := 0
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:        "error",
+		RemovalCallback: func(string, interface{}, EvictReason) { removed++ },
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	cache.Set("k", "v", time.Second)
+	now = now.Add(2 * time.Second)
+
+	cache.EnterDrainMode(false)
+	if _, err := cache.Get("k"); err != ErrItemExpired {
+		t.Errorf("Expected ErrItemExpired without staleOK, got %v", err)
+	}
+
+	cache.EnterDrainMode(true)
+	if v, err := cache.Get("k"); err != nil || v != "v" {
+		t.Errorf("Expected the stale value with staleOK, got %v, %v", v, err)
+	}
+	if removed != 0 || cache.Len() != 1 {
+		t.Errorf("Expected reads to leave the entry in place, %d removals, %d rows", removed, cache.Len())
+	}
+
+	cache.ExitDrainMode()
+	if _, err := cache.Get("k"); err != ErrItemExpired {
+		t.Errorf("Expected the entry to be expired after the drain, got %v", err)
+	}
+	if removed != 1 || cache.Len() != 0 {
+		t.Errorf("Expected the expired entry removed after the drain, %d removals, %d rows", removed, cache.Len())
+	}
+}
+
+func TestDrainModeSkipsLoaders(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	calls := 0
+	double := CachedFunc1(cache, time.Minute, "double:", func(ctx context.Context, n int) (int, error) {
+		calls++
+		return 2 * n, nil
+	})
+	double(context.Background(), 1)
+
+	cache.EnterDrainMode(true)
+	if v, err := double(context.Background(), 1); err != nil || v != 2 {
+		t.Errorf("Expected the cached result, got %v, %v", v, err)
+	}
+	if _, err := double(context.Background(), 2); !errors.Is(err, ErrDraining) {
+		t.Errorf("Expected ErrDraining on a miss, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no calls during the drain, got %d in total", calls)
+	}
+}
+
+func TestDrainModeSkipsReadThrough(t *testing.T) {
+	calls := 0
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		Loader: func(string) (interface{}, time.Duration, error) {
+			calls++
+			return "v", time.Minute, nil
+		},
+	})
+
+	cache.EnterDrainMode(false)
+	if _, err := cache.Get("k"); err != ErrItemNotFound {
+		t.Errorf("Expected a plain miss while draining, got %v", err)
+	}
+	cache.ExitDrainMode()
+	if v, err := cache.Get("k"); err != nil || v != "v" {
+		t.Errorf("Expected a load after the drain, got %v, %v", v, err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected one load, got %d", calls)
+	}
+}
@@ -0,0 +1,246 @@
+package lrucache
+
+import (
+	"cmp"
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	defaultDumpKeyWidth = 48
+	dumpValueWidth      = 60
+)
+
+// DumpOptions selects and orders the rows of DumpTable.
+type DumpOptions struct {
+	// SortBy names the column to sort by: "key", the default, "size",
+	// "age", "ttl" (or "expiry"), "hits" or "pinned". Rows sort ascending,
+	// or descending with Descending, and ties sort by key.
+	SortBy     string
+	Descending bool
+
+	// Limit, when positive, prints only the first Limit rows. Only that
+	// many rows are held while the table is scanned.
+	Limit int
+	// Prefix, when set, restricts the table to keys starting with it.
+	Prefix string
+
+	// ShowValues adds a column with the decoded values, shortened to fit.
+	ShowValues bool
+	// MaxKeyWidth shortens longer keys and defaults to 48.
+	MaxKeyWidth int
+}
+
+// dumpRow is one entry selected by DumpTable.
+type dumpRow struct {
+	item   *CacheItem
+	age    time.Duration
+	ttl    time.Duration
+	hits   uint64
+	pinned bool
+}
+
+// dumpLess orders rows by one column, breaking ties by key.
+type dumpLess func(a, b *dumpRow) bool
+
+func dumpOrder(sortBy string, descending bool) (dumpLess, bool) {
+	var compare func(a, b *dumpRow) int
+	switch sortBy {
+	case "", "key":
+		compare = func(a, b *dumpRow) int { return 0 }
+	case "size":
+		compare = func(a, b *dumpRow) int { return cmp.Compare(len(a.item.Value), len(b.item.Value)) }
+	case "age":
+		compare = func(a, b *dumpRow) int { return cmp.Compare(a.age, b.age) }
+	case "ttl", "expiry":
+		compare = func(a, b *dumpRow) int { return cmp.Compare(a.ttl, b.ttl) }
+	case "hits":
+		compare = func(a, b *dumpRow) int { return cmp.Compare(a.hits, b.hits) }
+	case "pinned":
+		compare = func(a, b *dumpRow) int { return cmp.Compare(btoi(a.pinned), btoi(b.pinned)) }
+	default:
+		return nil, false
+	}
+	return func(a, b *dumpRow) bool {
+		c := compare(a, b)
+		if c == 0 {
+			c = strings.Compare(a.item.Key, b.item.Key)
+		}
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	}, true
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// dumpSelection keeps the first limit rows in a given order. It is a heap
+// with the last of the kept rows on top, so that a row sorting after it is
+// dropped at once.
+type dumpSelection struct {
+	rows  []*dumpRow
+	less  dumpLess
+	limit int
+}
+
+func (s *dumpSelection) Len() int           { return len(s.rows) }
+func (s *dumpSelection) Less(i, j int) bool { return s.less(s.rows[j], s.rows[i]) }
+func (s *dumpSelection) Swap(i, j int)      { s.rows[i], s.rows[j] = s.rows[j], s.rows[i] }
+func (s *dumpSelection) Push(x interface{}) { s.rows = append(s.rows, x.(*dumpRow)) }
+func (s *dumpSelection) Pop() interface{} {
+	row := s.rows[len(s.rows)-1]
+	s.rows = s.rows[:len(s.rows)-1]
+	return row
+}
+
+func (s *dumpSelection) offer(row *dumpRow) {
+	switch {
+	case s.limit <= 0:
+		s.rows = append(s.rows, row)
+	case len(s.rows) < s.limit:
+		heap.Push(s, row)
+	case s.less(row, s.rows[0]):
+		s.rows[0] = row
+		heap.Fix(s, 0)
+	}
+}
+
+// DumpTable writes an aligned table of the live entries to w for debugging,
+// with their key, stored size, age, remaining TTL, hits and whether they are
+// pinned. Keys are shown through Options.KeyRedactor, and variants as the
+// base key followed by the variant name in brackets. The rows are collected
+// under the read lock and written after it is released, so a slow writer
+// does not hold up the cache.
+func (l *LRU) DumpTable(w io.Writer, opts DumpOptions) error {
+	less, ok := dumpOrder(opts.SortBy, opts.Descending)
+	if !ok {
+		return l.invalid("", fmt.Sprintf("unknown SortBy %q", opts.SortBy))
+	}
+	prefix := l.NormalizeKey(opts.Prefix)
+	keyWidth := opts.MaxKeyWidth
+	if keyWidth <= 0 {
+		keyWidth = defaultDumpKeyWidth
+	}
+
+	lines, err := l.dumpLines(prefix, less, opts, keyWidth)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := "KEY\tSIZE\tAGE\tTTL\tHITS\tPINNED"
+	if opts.ShowValues {
+		header += "\tVALUE"
+	}
+	fmt.Fprintln(tw, header)
+	for _, line := range lines {
+		fmt.Fprintln(tw, line)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write table: %v", err)
+	}
+	return nil
+}
+
+// dumpLines selects and formats the rows of DumpTable under the read lock.
+func (l *LRU) dumpLines(prefix string, less dumpLess, opts DumpOptions, keyWidth int) ([]string, error) {
+	l.readLock("scan")
+	defer l.lock.RUnlock()
+
+	it, err := l.db.Txn(false).Get("cache", "id_prefix", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get items: %v", err)
+	}
+
+	byKey := opts.SortBy == "" || opts.SortBy == "key"
+	sel := &dumpSelection{less: less, limit: opts.Limit}
+	clock, now := l.clock(), l.now()
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		item := obj.(*CacheItem)
+		if item.expired(clock) {
+			continue
+		}
+		sel.offer(&dumpRow{
+			item:   item,
+			age:    now.Sub(item.CreatedAt),
+			ttl:    item.deadline.Sub(clock),
+			hits:   item.hits(),
+			pinned: l.pins[item.Key] > 0,
+		})
+		// The index yields keys in order, so an ascending key sort is done
+		// once it has Limit rows.
+		if byKey && !opts.Descending && opts.Limit > 0 && len(sel.rows) == opts.Limit {
+			break
+		}
+	}
+	rows := sel.rows
+	sort.Slice(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		item := row.item
+		key := l.redact(item.Key)
+		if item.Variant != "" {
+			key = l.redact(item.Base) + " [" + item.Variant + "]"
+		}
+		cols := []string{
+			shorten(key, keyWidth),
+			strconv.Itoa(len(item.Value)),
+			row.age.Truncate(time.Second).String(),
+			dumpTTL(row),
+			strconv.FormatUint(row.hits, 10),
+			strconv.FormatBool(row.pinned),
+		}
+		if opts.ShowValues {
+			value, err := l.decode(item.Value)
+			text := fmt.Sprint(value)
+			if b, ok := value.([]byte); ok {
+				text = string(b)
+			}
+			if err != nil {
+				text = "<" + err.Error() + ">"
+			}
+			cols = append(cols, shorten(text, dumpValueWidth))
+		}
+		lines[i] = strings.Join(cols, "\t")
+	}
+	return lines, nil
+}
+
+func dumpTTL(row *dumpRow) string {
+	if row.item.persistent() {
+		return "never"
+	}
+	return row.ttl.Truncate(time.Second).String()
+}
+
+// shorten cuts s to at most width runes, marking the cut with "...", and
+// replaces the characters that would break the table's layout.
+func shorten(s string, width int) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || r < ' ' {
+			return ' '
+		}
+		return r
+	}, s)
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width <= 3 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-3]) + "..."
+}
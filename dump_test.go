@@ -0,0 +1,118 @@
+package lrucache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dumpRows runs DumpTable and splits each row of its output into columns.
+func dumpRows(t *testing.T, cache *LRU, opts DumpOptions) [][]string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := cache.DumpTable(&buf, opts); err != nil {
+		t.Fatalf("DumpTable failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if header := strings.Fields(lines[0]); header[0] != "KEY" || header[len(header)-1] == "" {
+		t.Fatalf("Expected a header row, got %q", lines[0])
+	}
+	var rows [][]string
+	for _, line := range lines[1:] {
+		rows = append(rows, strings.Fields(line))
+	}
+	return rows
+}
+
+func dumpKeys(rows [][]string) []string {
+	keys := make([]string, len(rows))
+	for i, row := range rows {
+		keys[i] = row[0]
+	}
+	return keys
+}
+
+func dumpFixture(t *testing.T) *LRU {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("user:2", "bob", time.Hour)
+	now = now.Add(time.Minute)
+	cache.Set("user:1", "alice and a long tail", 10*time.Minute)
+	cache.Set("session:9", 42, 30*time.Minute)
+	cache.Get("user:1")
+	cache.Get("user:1")
+	cache.Get("session:9")
+	if _, err := cache.PinCtx(context.Background(), "session:9"); err != nil {
+		t.Fatalf("Pin failed: %v", err)
+	}
+	now = now.Add(time.Minute)
+	return cache
+}
+
+func TestLRUDumpTable(t *testing.T) {
+	cache := dumpFixture(t)
+
+	rows := dumpRows(t, cache, DumpOptions{})
+	if got, want := strings.Join(dumpKeys(rows), " "), "session:9 user:1 user:2"; got != want {
+		t.Fatalf("Expected rows %q, got %q", want, got)
+	}
+	// KEY SIZE AGE TTL HITS PINNED
+	if want := []string{"user:1", "22", "1m0s", "9m0s", "2", "false"}; strings.Join(rows[1], " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v, got %v", want, rows[1])
+	}
+	if rows[0][5] != "true" {
+		t.Errorf("Expected session:9 to show as pinned, got %v", rows[0])
+	}
+	if rows[2][2] != "2m0s" || rows[2][3] != "58m0s" {
+		t.Errorf("Expected user:2 to be 2m old with 58m left, got %v", rows[2])
+	}
+
+	for _, tc := range []struct {
+		opts DumpOptions
+		want string
+	}{
+		{DumpOptions{SortBy: "expiry"}, "user:1 session:9 user:2"},
+		{DumpOptions{SortBy: "hits", Descending: true}, "user:1 session:9 user:2"},
+		{DumpOptions{SortBy: "age"}, "session:9 user:1 user:2"},
+		{DumpOptions{SortBy: "size"}, "session:9 user:2 user:1"},
+		{DumpOptions{SortBy: "pinned", Descending: true}, "session:9 user:2 user:1"},
+		{DumpOptions{SortBy: "ttl", Limit: 2}, "user:1 session:9"},
+		{DumpOptions{Limit: 2}, "session:9 user:1"},
+		{DumpOptions{Descending: true, Limit: 1}, "user:2"},
+		{DumpOptions{Prefix: "user:"}, "user:1 user:2"},
+		{DumpOptions{Prefix: "user:", SortBy: "expiry", Descending: true, Limit: 1}, "user:2"},
+	} {
+		if got := strings.Join(dumpKeys(dumpRows(t, cache, tc.opts)), " "); got != tc.want {
+			t.Errorf("%+v: expected %q, got %q", tc.opts, tc.want, got)
+		}
+	}
+}
+
+func TestLRUDumpTableValuesAndTruncation(t *testing.T) {
+	cache := dumpFixture(t)
+	cache.Set(strings.Repeat("k", 100), "v", time.Hour)
+	cache.SetVariant("page", "gzip", []byte("z"), time.Hour)
+
+	var buf bytes.Buffer
+	if err := cache.DumpTable(&buf, DumpOptions{ShowValues: true, MaxKeyWidth: 12}); err != nil {
+		t.Fatalf("DumpTable failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"VALUE", strings.Repeat("k", 9) + "...", "alice and a long tail", "page [gzip]", " z\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected the table to contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, strings.Repeat("k", 10)) {
+		t.Errorf("Expected the long key to be shortened:\n%s", out)
+	}
+
+	if err := cache.DumpTable(&buf, DumpOptions{SortBy: "color"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected ErrInvalidArgument for an unknown column, got %v", err)
+	}
+}
@@ -6,4 +6,20 @@ var (
 	ErrCacheNotInitialized = errors.New("cache not initialized")
 	ErrItemExpired         = errors.New("item expired")
 	ErrItemNotFound        = errors.New("item not found")
+	ErrRateLimited         = errors.New("set rate limit exceeded")
+	ErrDependencyCycle     = errors.New("dependency cycle")
+	ErrNotStored           = errors.New("item not stored")
+	ErrNotAnObject         = errors.New("value is not a JSON object")
+	ErrPatchConflict       = errors.New("patch does not apply")
+	ErrDeserialization     = errors.New("value exceeds decode limits")
+	ErrDraining            = errors.New("cache is draining")
+	ErrInvalidArgument     = errors.New("invalid argument")
+	ErrClosed              = errors.New("cache is closed")
+	ErrDeltaUnavailable    = errors.New("delta no longer available")
+	ErrNotAnInteger        = errors.New("value is not an integer")
+	ErrNotAppendable       = errors.New("value is not a string or []byte")
+	ErrSnapshotCorrupt     = errors.New("snapshot is corrupt")
+	ErrSnapshotStale       = errors.New("entry changed since the snapshot")
+	ErrColdStart           = errors.New("cache is warming up")
+	ErrImmutable           = errors.New("entry is immutable")
 )
@@ -1,26 +1,106 @@
 package lrucache
 
-import "time"
+import (
+	"container/heap"
+	"time"
+)
 
 type expirationHeap struct {
 	items     []string
-	expiresAt map[string]time.Time
+	index     map[string]int
+	deadlines map[string]time.Time
+
+	// notices, when set, follows every deadline change to schedule
+	// PreExpiryNotice callbacks.
+	notices *noticeSchedule
+	// fair, when set, counts the entries of each fairness group.
+	fair *fairness
+}
+
+func newExpirationHeap(size int) *expirationHeap {
+	return &expirationHeap{
+		items:     make([]string, 0, size),
+		index:     make(map[string]int, size),
+		deadlines: make(map[string]time.Time, size),
+	}
 }
 
 func (h *expirationHeap) Len() int { return len(h.items) }
 func (h *expirationHeap) Less(i, j int) bool {
-	return h.expiresAt[h.items[i]].Before(h.expiresAt[h.items[j]])
+	return h.deadlines[h.items[i]].Before(h.deadlines[h.items[j]])
 }
 func (h *expirationHeap) Swap(i, j int) {
 	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.index[h.items[i]] = i
+	h.index[h.items[j]] = j
 }
 func (h *expirationHeap) Push(x interface{}) {
-	h.items = append(h.items, x.(string))
+	key := x.(string)
+	h.index[key] = len(h.items)
+	h.items = append(h.items, key)
+	h.fair.add(key)
 }
 func (h *expirationHeap) Pop() interface{} {
 	old := h.items
 	n := len(old)
 	x := old[n-1]
 	h.items = old[0 : n-1]
+	delete(h.index, x)
+	h.notices.disarm(x)
+	h.fair.remove(x)
 	return x
 }
+
+// set records the deadline for key, moving its existing entry into place or
+// pushing a new one, so a key is never on the heap twice.
+func (h *expirationHeap) set(key string, deadline time.Time) {
+	h.deadlines[key] = deadline
+	h.notices.arm(key, deadline)
+	if i, ok := h.index[key]; ok {
+		heap.Fix(h, i)
+		return
+	}
+	heap.Push(h, key)
+}
+
+// remove drops key from the heap if it is present.
+func (h *expirationHeap) remove(key string) {
+	if i, ok := h.index[key]; ok {
+		heap.Remove(h, i)
+	}
+	delete(h.deadlines, key)
+	h.notices.disarm(key)
+}
+
+// setMany records several deadlines at once. Past a quarter of the heap a
+// single re-init is cheaper than one Fix per key.
+func (h *expirationHeap) setMany(deadlines map[string]time.Time) {
+	if len(deadlines)*4 < len(h.items) {
+		for key, deadline := range deadlines {
+			h.set(key, deadline)
+		}
+		return
+	}
+
+	for key, deadline := range deadlines {
+		h.deadlines[key] = deadline
+		h.notices.arm(key, deadline)
+		if _, ok := h.index[key]; !ok {
+			h.index[key] = len(h.items)
+			h.items = append(h.items, key)
+			h.fair.add(key)
+		}
+	}
+	heap.Init(h)
+}
+
+// shift moves every deadline, and every armed notice, by d. The order of the
+// heap is unaffected.
+func (h *expirationHeap) shift(d time.Duration) {
+	for key, deadline := range h.deadlines {
+		h.deadlines[key] = deadline.Add(d)
+	}
+	if h.notices != nil {
+		h.notices.due.shift(d)
+	}
+}
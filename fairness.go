@@ -0,0 +1,71 @@
+package lrucache
+
+import "strings"
+
+// fairness counts the entries of each Options.FairnessGroups group and
+// reserves each group its FairnessShares fraction of the capacity. It
+// follows the expiration heap, which holds one entry per cached key, and is
+// only used under the write lock.
+type fairness struct {
+	group   func(key string) string
+	reserve map[string]int
+	counts  map[string]int
+}
+
+func newFairness(group func(string) string, shares map[string]float64, size int) *fairness {
+	f := &fairness{group: group, reserve: make(map[string]int, len(shares)), counts: make(map[string]int)}
+	for g, share := range shares {
+		f.reserve[g] = int(share * float64(size))
+	}
+	return f
+}
+
+// groupOf returns the group of an entry key. Variants belong to the group of
+// their base key.
+func (f *fairness) groupOf(key string) string {
+	base, _, _ := strings.Cut(key, variantSep)
+	return f.group(base)
+}
+
+func (f *fairness) add(key string) {
+	if f != nil {
+		f.counts[f.groupOf(key)]++
+	}
+}
+
+func (f *fairness) remove(key string) {
+	if f == nil {
+		return
+	}
+	g := f.groupOf(key)
+	if f.counts[g]--; f.counts[g] <= 0 {
+		delete(f.counts, g)
+	}
+}
+
+// reset forgets all counts, for a heap rebuilt from scratch.
+func (f *fairness) reset() {
+	if f != nil {
+		f.counts = make(map[string]int)
+	}
+}
+
+// protected reports whether evicting key would take its group below its
+// reserved share. held counts the entries of each group taken off the heap
+// but kept in the cache during the current eviction pass.
+func (f *fairness) protected(key string, held map[string]int) bool {
+	if f == nil {
+		return false
+	}
+	g := f.groupOf(key)
+	reserve, ok := f.reserve[g]
+	return ok && f.counts[g]+held[g] <= reserve
+}
+
+func (f *fairness) snapshot() map[string]int {
+	occupancy := make(map[string]int, len(f.counts))
+	for g, n := range f.counts {
+		occupancy[g] = n
+	}
+	return occupancy
+}
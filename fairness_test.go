@@ -0,0 +1,76 @@
+package lrucache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func prefixGroup(key string) string {
+	group, _, _ := strings.Cut(key, ":")
+	return group
+}
+
+func TestFairnessKeepsReservedShare(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{
+		LogLevel:       "error",
+		FairnessGroups: prefixGroup,
+		FairnessShares: map[string]float64{"quiet": 0.3, "burst": 0.3},
+	})
+
+	// The quiet group expires first, so plain capacity eviction would take
+	// all of it.
+	for i := 0; i < 50; i++ {
+		cache.Set(fmt.Sprintf("quiet:%d", i), i, time.Duration(i+1)*time.Minute)
+	}
+	for i := 0; i < 500; i++ {
+		cache.Set(fmt.Sprintf("burst:%d", i), i, 24*time.Hour)
+	}
+
+	occupancy := cache.Stats().Occupancy
+	if occupancy["quiet"] != 30 || occupancy["burst"] != 70 {
+		t.Errorf("Expected 30 quiet and 70 burst entries, got %v", occupancy)
+	}
+	if cache.Len() != 100 {
+		t.Errorf("Expected the cache at capacity, got %d", cache.Len())
+	}
+	// The quiet entries kept are those expiring last.
+	for i := 20; i < 50; i++ {
+		if !cache.Contains(fmt.Sprintf("quiet:%d", i)) {
+			t.Errorf("Expected quiet:%d to be kept", i)
+		}
+	}
+
+	// The burst group only borrowed the space: it gives it back once quiet
+	// writes again.
+	for i := 50; i < 120; i++ {
+		cache.Set(fmt.Sprintf("quiet:%d", i), i, 48*time.Hour)
+	}
+	if occupancy := cache.Stats().Occupancy; occupancy["burst"] != 30 || occupancy["quiet"] != 70 {
+		t.Errorf("Expected burst down to its reserve of 30, got %v", occupancy)
+	}
+}
+
+func TestFairnessOccupancyFollowsRemovals(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", FairnessGroups: prefixGroup})
+
+	cache.Set("a:1", 1, time.Minute)
+	cache.Set("a:2", 2, time.Minute)
+	cache.Set("a:2", 3, time.Minute)
+	cache.SetVariant("b:page", "gzip", []byte("x"), time.Minute)
+	cache.SetVariant("b:page", "br", []byte("x"), time.Minute)
+	if occupancy := cache.Stats().Occupancy; occupancy["a"] != 2 || occupancy["b"] != 2 {
+		t.Errorf("Expected 2 entries in each group, got %v", occupancy)
+	}
+
+	cache.Delete("a:1")
+	cache.Delete("b:page")
+	if occupancy := cache.Stats().Occupancy; len(occupancy) != 1 || occupancy["a"] != 1 {
+		t.Errorf("Expected only a:2 left, got %v", occupancy)
+	}
+	cache.Clear()
+	if occupancy := cache.Stats().Occupancy; len(occupancy) != 0 {
+		t.Errorf("Expected no occupancy after Clear, got %v", occupancy)
+	}
+}
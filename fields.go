@@ -0,0 +1,156 @@
+package lrucache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SetField sets the field at a dotted path, such as "address.city", inside
+// the JSON object stored under key. Missing objects along the path are
+// created. The value is decoded and encoded once under the write lock and
+// the entry keeps its TTL. Paths cannot index into arrays: a path crossing a
+// value that is not an object, including the stored value itself, fails
+// with ErrNotAnObject.
+func (l *LRU) SetField(key, path string, fieldValue interface{}) error {
+	key = l.NormalizeKey(key)
+	segments, err := splitPath(path)
+	if err != nil {
+		return l.misuse(key, err)
+	}
+	return l.updateValue(key, 0, func(value interface{}) (interface{}, error) {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, ErrNotAnObject
+		}
+		parent := obj
+		for _, segment := range segments[:len(segments)-1] {
+			next, ok := parent[segment]
+			if !ok || next == nil {
+				child := make(map[string]interface{})
+				parent[segment] = child
+				parent = child
+				continue
+			}
+			if parent, ok = next.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("%w: %s", ErrNotAnObject, segment)
+			}
+		}
+		parent[segments[len(segments)-1]] = fieldValue
+		return obj, nil
+	})
+}
+
+// DeleteField removes the field at a dotted path from the JSON object stored
+// under key, keeping its TTL. Deleting a field that does not exist is not an
+// error.
+func (l *LRU) DeleteField(key, path string) error {
+	key = l.NormalizeKey(key)
+	segments, err := splitPath(path)
+	if err != nil {
+		return l.misuse(key, err)
+	}
+	return l.updateValue(key, 0, func(value interface{}) (interface{}, error) {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, ErrNotAnObject
+		}
+		parent := obj
+		for _, segment := range segments[:len(segments)-1] {
+			next, ok := parent[segment]
+			if !ok {
+				return obj, nil
+			}
+			if parent, ok = next.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("%w: %s", ErrNotAnObject, segment)
+			}
+		}
+		delete(parent, segments[len(segments)-1])
+		return obj, nil
+	})
+}
+
+func splitPath(path string) ([]string, error) {
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("%w: invalid field path %q", ErrInvalidArgument, path)
+		}
+	}
+	return segments, nil
+}
+
+// updateValue replaces the value of a live entry with update(value) in one
+// step under the write lock. A positive ttl or NoExpiration also resets the
+// entry's TTL; otherwise the deadline is kept. Missing keys return
+// ErrItemNotFound and expired ones ErrItemExpired, as Get does.
+func (l *LRU) updateValue(key string, ttl time.Duration, update func(value interface{}) (interface{}, error)) error {
+	defer l.checkWatermarks()
+	if err := l.checkWritable(key); err != nil {
+		return err
+	}
+	l.writeLock("set")
+	defer l.lock.Unlock()
+	return l.update(key, ttl, update)
+}
+
+// update is updateValue for a caller that holds the write lock.
+func (l *LRU) update(key string, ttl time.Duration, update func(value interface{}) (interface{}, error)) error {
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		return ErrItemNotFound
+	}
+	if raw.(*CacheItem).expired(l.clock()) {
+		return ErrItemExpired
+	}
+	if err := l.checkMutable(raw.(*CacheItem)); err != nil {
+		return err
+	}
+
+	value, err := l.decode(raw.(*CacheItem).Value)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize value: %w", err)
+	}
+	value, err = update(value)
+	if err != nil {
+		return err
+	}
+	data, err := serialize(value)
+	if err != nil {
+		return fmt.Errorf("failed to serialize value: %v", err)
+	}
+	growth := int64(len(data) - len(raw.(*CacheItem).Value))
+	if err := l.makeRoom(growth, key); err != nil {
+		return err
+	}
+
+	txn := l.db.Txn(true)
+	// Making room can cascade to entries derived from the evicted ones.
+	if cur, err := txn.First("cache", "id", key); err != nil || cur != raw {
+		txn.Abort()
+		return ErrItemNotFound
+	}
+	item := l.copyItem(raw.(*CacheItem))
+	item.Value, item.LastModified = data, l.modifiedAfter(raw.(*CacheItem))
+	if ttl > 0 || ttl == NoExpiration {
+		item.ExpiresAt, item.deadline = l.expiry(ttl)
+	}
+	if err := txn.Insert("cache", item); err != nil {
+		txn.Abort()
+		return fmt.Errorf("failed to insert item: %v", err)
+	}
+	txn.Commit()
+	l.resized(growth)
+	l.retire(raw.(*CacheItem))
+	l.notifyWatchers(key, data)
+
+	if ttl > 0 || ttl == NoExpiration {
+		l.expHeap.set(key, item.deadline)
+	}
+	l.invalidateDependents(key)
+	l.log("debug", "Updated value of key: %s", l.redact(key))
+	return nil
+}
@@ -0,0 +1,91 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetFieldNestedPaths(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("user", map[string]interface{}{"name": "ann", "address": map[string]interface{}{"city": "Oslo"}}, time.Hour)
+	raw, _ := cache.db.Txn(false).First("cache", "id", "user")
+	deadline := raw.(*CacheItem).deadline
+
+	if err := cache.SetField("user", "address.city", "Bergen"); err != nil {
+		t.Fatalf("SetField failed: %v", err)
+	}
+	if err := cache.SetField("user", "prefs.theme.color", "dark"); err != nil {
+		t.Fatalf("SetField with missing parents failed: %v", err)
+	}
+	if err := cache.DeleteField("user", "name"); err != nil {
+		t.Fatalf("DeleteField failed: %v", err)
+	}
+	if err := cache.DeleteField("user", "missing.field"); err != nil {
+		t.Errorf("Expected deleting a missing field to succeed, got %v", err)
+	}
+
+	v, _ := cache.Get("user")
+	want := map[string]interface{}{
+		"address": map[string]interface{}{"city": "Bergen"},
+		"prefs":   map[string]interface{}{"theme": map[string]interface{}{"color": "dark"}},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("Expected %v, got %v", want, v)
+	}
+	raw, _ = cache.db.Txn(false).First("cache", "id", "user")
+	if raw.(*CacheItem).deadline != deadline {
+		t.Error("Expected SetField to keep the TTL")
+	}
+}
+
+func TestSetFieldRejectsNonObjects(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("list", []interface{}{1, 2}, time.Hour)
+	cache.Set("doc", map[string]interface{}{"tags": []interface{}{"a"}, "n": 1}, time.Hour)
+	cache.Set("text", "plain", time.Hour)
+
+	if err := cache.SetField("list", "a", 1); !errors.Is(err, ErrNotAnObject) {
+		t.Errorf("Expected ErrNotAnObject for an array value, got %v", err)
+	}
+	if err := cache.SetField("text", "a", 1); !errors.Is(err, ErrNotAnObject) {
+		t.Errorf("Expected ErrNotAnObject for a string value, got %v", err)
+	}
+	if err := cache.SetField("doc", "tags.0", "b"); !errors.Is(err, ErrNotAnObject) {
+		t.Errorf("Expected paths not to index arrays, got %v", err)
+	}
+	if err := cache.DeleteField("doc", "n.x"); !errors.Is(err, ErrNotAnObject) {
+		t.Errorf("Expected ErrNotAnObject through a number, got %v", err)
+	}
+	if err := cache.SetField("doc", "a..b", 1); err == nil {
+		t.Error("Expected an empty path segment to be rejected")
+	}
+	if err := cache.SetField("missing", "a", 1); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+}
+
+func TestSetFieldConcurrent(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("doc", map[string]interface{}{}, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := cache.SetField("doc", fmt.Sprintf("f%d", i), i); err != nil {
+				t.Errorf("SetField failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	v, _ := cache.Get("doc")
+	if n := len(v.(map[string]interface{})); n != 50 {
+		t.Errorf("Expected all 50 fields to survive, got %d", n)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package lrucache
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	return false, errors.New("file locks are not supported on " + runtime.GOOS)
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,128 @@
+package lrucache
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// generationReclaimBatch bounds the rows the sweeper examines per run when
+// reclaiming entries of invalidated generations.
+const generationReclaimBatch = 1024
+
+// generations stamps entries with the write generation and holds the floor
+// below which entries count as expired.
+type generations struct {
+	current atomic.Uint64
+	floor   atomic.Uint64
+
+	// cursor is where the sweeper resumes reclaiming, and reclaimed the
+	// floor the last complete pass enforced. Both are guarded by the cache
+	// lock.
+	cursor    string
+	reclaimed uint64
+}
+
+// stale reports whether gen is below the invalidation floor.
+func (g *generations) stale(gen uint64) bool {
+	return g != nil && gen < g.floor.Load()
+}
+
+// CurrentGeneration returns the generation stamped on entries written now.
+func (l *LRU) CurrentGeneration() uint64 {
+	return l.gens.current.Load()
+}
+
+// BumpGeneration starts a new generation and returns it. Entries written
+// from now on carry it; entries already cached keep theirs, so a deploy hook
+// can follow it with InvalidateGenerationsBefore.
+func (l *LRU) BumpGeneration() uint64 {
+	return l.gens.current.Add(1)
+}
+
+// InvalidateGenerationsBefore makes every entry written in a generation
+// below gen read as expired at once, in constant time. The sweeper removes
+// the entries in the background, a batch per run, and reads that find one
+// remove it early; both report ReasonGeneration.
+// Writing the same key again stores a live entry of the current generation.
+// A gen beyond the current generation also advances it to gen, so later
+// writes are not invalidated; a gen at or below an earlier floor changes
+// nothing.
+func (l *LRU) InvalidateGenerationsBefore(gen uint64) {
+	for {
+		cur := l.gens.current.Load()
+		if cur >= gen || l.gens.current.CompareAndSwap(cur, gen) {
+			break
+		}
+	}
+	for {
+		floor := l.gens.floor.Load()
+		if floor >= gen || l.gens.floor.CompareAndSwap(floor, gen) {
+			break
+		}
+	}
+	l.log("info", "Invalidated generations before %d", gen)
+}
+
+// reclaimGenerations removes up to generationReclaimBatch entries of
+// invalidated generations, resuming where the previous run stopped. The
+// caller must hold the write lock.
+func (l *LRU) reclaimGenerations() {
+	g := &l.gens
+	floor := g.floor.Load()
+	if floor == g.reclaimed {
+		return
+	}
+
+	it, err := l.db.Txn(false).LowerBound("cache", "id", g.cursor)
+	if err != nil {
+		l.log("error", "Failed to reclaim invalidated generations: %v", err)
+		return
+	}
+	var stale []string
+	scanned := 0
+	obj := it.Next()
+	for ; obj != nil && scanned < generationReclaimBatch; obj = it.Next() {
+		item := obj.(*CacheItem)
+		if item.gen < floor {
+			stale = append(stale, item.Key)
+		}
+		g.cursor = item.Key
+		scanned++
+	}
+	if obj == nil {
+		// A complete pass: nothing below floor is left behind the cursor.
+		g.cursor, g.reclaimed = "", floor
+	}
+
+	for _, key := range stale {
+		if decisionTracing {
+			l.decide("sweep", key, "expire", "generation below %d", floor)
+		}
+		if err := l.removeItem(key, ReasonGeneration); err != nil {
+			l.backgroundError(err)
+		}
+	}
+}
+
+// SnapshotGeneration starts a new generation and returns it as a token for
+// WithSnapshotGeneration. An exporter takes one before listing keys with
+// Keys or KeysWithValues; Gets under the token then return ErrSnapshotStale
+// for every entry written, modified or deleted after the token was taken,
+// instead of a value or ErrItemNotFound that mixes in later changes.
+func (l *LRU) SnapshotGeneration() uint64 {
+	return l.BumpGeneration()
+}
+
+type snapshotGenerationKey struct{}
+
+// WithSnapshotGeneration returns a context under which GetContext checks
+// entries against a token from SnapshotGeneration. Such Gets do not fall
+// back to Options.Loader.
+func WithSnapshotGeneration(ctx context.Context, gen uint64) context.Context {
+	return context.WithValue(ctx, snapshotGenerationKey{}, gen)
+}
+
+func snapshotGenerationFrom(ctx context.Context) uint64 {
+	gen, _ := ctx.Value(snapshotGenerationKey{}).(uint64)
+	return gen
+}
@@ -0,0 +1,215 @@
+package lrucache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInvalidateGenerationsBefore(t *testing.T) {
+	var reasons []EvictReason
+	cache, _ := NewLRUWithTTL(5000, Options{
+		LogLevel:        "error",
+		RemovalCallback: func(key string, value interface{}, reason EvictReason) { reasons = append(reasons, reason) },
+	})
+
+	const n = 2500
+	for i := 0; i < n; i++ {
+		cache.Set(fmt.Sprintf("k%04d", i), i, time.Hour)
+	}
+	if gen := cache.CurrentGeneration(); gen != 0 {
+		t.Fatalf("Expected generation 0, got %d", gen)
+	}
+
+	gen := cache.BumpGeneration()
+	if gen != 1 || cache.CurrentGeneration() != 1 {
+		t.Fatalf("Expected generation 1 after the bump, got %d", gen)
+	}
+	cache.Set("k0001", "fresh", time.Hour)
+	cache.InvalidateGenerationsBefore(gen)
+
+	for _, key := range []string{"k0000", "k0002", fmt.Sprintf("k%04d", n-1)} {
+		if _, err := cache.Get(key); err == nil {
+			t.Errorf("Expected %s to read as a miss after the invalidation", key)
+		}
+	}
+	if v, err := cache.Get("k0001"); err != nil || v != "fresh" {
+		t.Errorf("Expected the entry written after the bump, got %v, %v", v, err)
+	}
+	if cache.Contains("k0000") || len(cache.Keys()) != 1 {
+		t.Errorf("Expected only k0001 to be live, got %v", cache.Keys())
+	}
+	cache.Set("k0002", "again", time.Hour)
+	if v, _ := cache.Get("k0002"); v != "again" {
+		t.Errorf("Expected a rewritten key to be live, got %v", v)
+	}
+
+	// Apart from the two old rows the Gets above found expired and removed,
+	// the rows stay until the sweeper reclaims them in batches.
+	left := cache.Len()
+	if left != n-2 {
+		t.Fatalf("Expected %d rows before sweeping, got %d", n-2, left)
+	}
+	for runs := 0; left > 2; runs++ {
+		if runs > n/generationReclaimBatch+1 {
+			t.Fatalf("Expected reclaiming to finish, %d rows left", left)
+		}
+		cache.removeExpiredItems()
+		now := cache.Len()
+		if now >= left {
+			t.Fatalf("Expected a sweep to reclaim rows, still %d", now)
+		}
+		if left-now > generationReclaimBatch {
+			t.Fatalf("Expected at most %d rows per sweep, reclaimed %d", generationReclaimBatch, left-now)
+		}
+		left = now
+	}
+	if got := liveKeys(cache); len(got) != 2 || got[0] != "k0001" || got[1] != "k0002" {
+		t.Errorf("Expected k0001 and k0002 to remain, got %v", got)
+	}
+	generation := 0
+	for _, r := range reasons {
+		if r == ReasonGeneration {
+			generation++
+		}
+	}
+	if generation != n-1 {
+		t.Errorf("Expected %d removals with ReasonGeneration, got %d", n-1, generation)
+	}
+
+	// Nothing is left to scan until the floor rises again.
+	cache.removeExpiredItems()
+	if cache.gens.cursor != "" || cache.gens.reclaimed != gen {
+		t.Errorf("Expected a finished pass, cursor %q, reclaimed %d", cache.gens.cursor, cache.gens.reclaimed)
+	}
+}
+
+func TestInvalidateGenerationsBeyondCurrent(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("old", 1, time.Hour)
+
+	cache.InvalidateGenerationsBefore(5)
+	if gen := cache.CurrentGeneration(); gen != 5 {
+		t.Errorf("Expected the generation to advance to 5, got %d", gen)
+	}
+	cache.Set("new", 2, time.Hour)
+	if _, err := cache.Get("new"); err != nil {
+		t.Errorf("Expected a write after the invalidation to be live, got %v", err)
+	}
+	if _, err := cache.Get("old"); err == nil {
+		t.Error("Expected the old entry to be invalidated")
+	}
+
+	// A lower floor does not bring entries back.
+	cache.InvalidateGenerationsBefore(1)
+	if _, err := cache.Get("old"); err == nil {
+		t.Error("Expected the floor not to move down")
+	}
+}
+
+func TestLRUKeysWithValues(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("b", 2, time.Hour)
+	cache.Set("a", "1", time.Hour)
+	cache.SetVariant("a", "gzip", []byte("z"), time.Hour)
+	cache.Set("c", true, time.Hour)
+
+	kvs, err := cache.KeysWithValues(0)
+	if err != nil {
+		t.Fatalf("KeysWithValues failed: %v", err)
+	}
+	if want := []KV{{"a", "1"}, {"b", 2}, {"c", true}}; !reflect.DeepEqual(kvs, want) {
+		t.Errorf("Expected %v, got %v", want, kvs)
+	}
+	if kvs, _ := cache.KeysWithValues(2); len(kvs) != 2 || kvs[1].Key != "b" {
+		t.Errorf("Expected the first two entries, got %v", kvs)
+	}
+}
+
+func TestLRUSnapshotGeneration(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{LogLevel: "error", MissFilter: MissFilter{ExpectedItems: 100}})
+	for _, key := range []string{"kept", "deleted", "rewritten", "modified", "recreated"} {
+		cache.Set(key, map[string]interface{}{"v": key}, time.Hour)
+	}
+	gen := cache.SnapshotGeneration()
+	cache.Delete("deleted")
+	cache.Set("rewritten", "new", time.Hour)
+	cache.SetField("modified", "v", "new")
+	cache.Delete("recreated")
+	cache.Set("recreated", "new", time.Hour)
+	cache.Set("added", "new", time.Hour)
+
+	ctx := WithSnapshotGeneration(context.Background(), gen)
+	if v, err := cache.GetContext(ctx, "kept"); err != nil || v.(map[string]interface{})["v"] != "kept" {
+		t.Errorf("Expected the unchanged entry, got %v, %v", v, err)
+	}
+	for _, key := range []string{"deleted", "rewritten", "modified", "recreated", "added"} {
+		if _, err := cache.GetContext(ctx, key); err != ErrSnapshotStale {
+			t.Errorf("%s: expected ErrSnapshotStale, got %v", key, err)
+		}
+	}
+	if _, err := cache.GetContext(ctx, "never"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound for a key that never existed, got %v", err)
+	}
+	if _, err := cache.Get("deleted"); err != ErrItemNotFound {
+		t.Errorf("Expected a plain Get to see the deletion, got %v", err)
+	}
+}
+
+func TestLRUSnapshotGenerationAfterDeletionLogOverflow(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", DeletionLogSize: 2})
+	for _, key := range []string{"a", "b", "c"} {
+		cache.Set(key, "v", time.Hour)
+	}
+	gen := cache.SnapshotGeneration()
+	for _, key := range []string{"a", "b", "c"} {
+		cache.Delete(key)
+	}
+	// The deletion of a is no longer remembered, so it cannot be ruled out.
+	ctx := WithSnapshotGeneration(context.Background(), gen)
+	for _, key := range []string{"a", "never"} {
+		if _, err := cache.GetContext(ctx, key); err != ErrSnapshotStale {
+			t.Errorf("%s: expected ErrSnapshotStale, got %v", key, err)
+		}
+	}
+}
+
+func TestLRUSnapshotGenerationWithConcurrentWriter(t *testing.T) {
+	cache, _ := NewLRUWithTTL(1000, Options{LogLevel: "error"})
+	for i := 0; i < 200; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), fmt.Sprintf("v%d", i), time.Hour)
+	}
+	gen := cache.SnapshotGeneration()
+	keys := cache.Keys()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i += 3 {
+			key := fmt.Sprintf("key%d", i)
+			if i%2 == 0 {
+				cache.Delete(key)
+			} else {
+				cache.Set(key, "changed", time.Hour)
+			}
+		}
+	}()
+
+	ctx := WithSnapshotGeneration(context.Background(), gen)
+	for _, key := range keys {
+		v, err := cache.GetContext(ctx, key)
+		switch err {
+		case nil:
+			if want := "v" + strings.TrimPrefix(key, "key"); v != want {
+				t.Errorf("%s: expected the snapshot value %s, got %v", key, want, v)
+			}
+		case ErrSnapshotStale:
+		default:
+			t.Errorf("%s: expected a value or ErrSnapshotStale, got %v", key, err)
+		}
+	}
+	<-done
+}
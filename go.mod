@@ -2,9 +2,9 @@ module github.com/shammianand/lrucache
 
 go 1.22.1
 
-require github.com/hashicorp/go-memdb v1.3.4
-
 require (
-	github.com/hashicorp/go-immutable-radix v1.3.0 // indirect
-	github.com/hashicorp/golang-lru v0.5.4 // indirect
+	github.com/hashicorp/go-memdb v1.3.4
+	github.com/hashicorp/golang-lru v0.5.4
 )
+
+require github.com/hashicorp/go-immutable-radix v1.3.0 // indirect
@@ -0,0 +1,76 @@
+package lrucache
+
+import (
+	"sync"
+	"time"
+)
+
+// hitRatioMinLookups is the number of Gets a window needs before its hit
+// ratio is trusted.
+const hitRatioMinLookups = 100
+
+// HitRatioAlert calls Callback when the hit ratio over the last Window drops
+// below Threshold, and once more when it recovers. The ratio is evaluated on
+// every background sweep; windows with too few Gets are skipped.
+type HitRatioAlert struct {
+	Threshold float64
+	Window    time.Duration
+	// Callback receives the current ratio and the Hits and Misses counted
+	// within the window. A ratio below Threshold signals a breach, one at or
+	// above it the recovery.
+	Callback func(current float64, window Stats)
+}
+
+type hitSample struct {
+	at           time.Time
+	hits, misses uint64
+}
+
+type hitRatioMonitor struct {
+	cfg      HitRatioAlert
+	mu       sync.Mutex
+	samples  []hitSample
+	breached bool
+}
+
+// checkHitRatio samples the Get counters and fires the alert callback when
+// the windowed hit ratio crosses the threshold.
+func (l *LRU) checkHitRatio() {
+	if l.hitRatio == nil {
+		return
+	}
+	m := l.hitRatio
+	now := l.now()
+	sample := hitSample{at: now, hits: l.stats.hits.Load(), misses: l.stats.misses.Load()}
+
+	m.mu.Lock()
+	m.samples = append(m.samples, sample)
+	// Keep the newest sample taken at or before the start of the window as
+	// the base of the deltas.
+	start := now.Add(-m.cfg.Window)
+	for len(m.samples) > 1 && !m.samples[1].at.After(start) {
+		m.samples = m.samples[1:]
+	}
+	base := m.samples[0]
+	window := Stats{Hits: sample.hits - base.hits, Misses: sample.misses - base.misses}
+	lookups := window.Hits + window.Misses
+	if lookups < hitRatioMinLookups {
+		m.mu.Unlock()
+		return
+	}
+	ratio := float64(window.Hits) / float64(lookups)
+	fire := ratio < m.cfg.Threshold != m.breached
+	if fire {
+		m.breached = !m.breached
+	}
+	m.mu.Unlock()
+
+	if fire {
+		if ratio < m.cfg.Threshold {
+			l.log("warn", "Hit ratio %.3f fell below %.3f", ratio, m.cfg.Threshold)
+		} else {
+			l.log("info", "Hit ratio %.3f recovered above %.3f", ratio, m.cfg.Threshold)
+		}
+		m.cfg.Callback(ratio, window)
+	}
+}
@@ -0,0 +1,96 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHitRatioAlert(t *testing.T) {
+	type call struct {
+		ratio float64
+		stats Stats
+	}
+	var calls []call
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		HitRatioAlert: HitRatioAlert{
+			Threshold: 0.5,
+			Window:    5 * time.Minute,
+			Callback:  func(ratio float64, window Stats) { calls = append(calls, call{ratio, window}) },
+		},
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	cache.Set("hot", "value", 24*time.Hour)
+
+	gets := func(hits, misses int) {
+		for i := 0; i < hits; i++ {
+			cache.Get("hot")
+		}
+		for i := 0; i < misses; i++ {
+			cache.Get("cold")
+		}
+	}
+	tick := func() {
+		now = now.Add(time.Minute)
+		cache.checkHitRatio()
+	}
+
+	cache.checkHitRatio()
+	gets(10, 40)
+	tick()
+	if len(calls) != 0 {
+		t.Fatalf("Expected a tiny sample to be skipped, got %v", calls)
+	}
+
+	gets(190, 60)
+	tick()
+	if len(calls) != 0 {
+		t.Fatalf("Expected no alert at a healthy ratio, got %v", calls)
+	}
+
+	gets(0, 300)
+	tick()
+	if len(calls) != 1 || calls[0].ratio >= 0.5 {
+		t.Fatalf("Expected one breach alert, got %v", calls)
+	}
+	if calls[0].stats.Hits != 200 || calls[0].stats.Misses != 400 {
+		t.Errorf("Expected window deltas of 200 hits and 400 misses, got %+v", calls[0].stats)
+	}
+
+	gets(0, 100)
+	tick()
+	gets(50, 100)
+	tick()
+	if len(calls) != 1 {
+		t.Fatalf("Expected the alert to fire once per breach, got %v", calls)
+	}
+
+	// Only healthy minutes remain once the window slides past the misses.
+	for i := 0; i < 5; i++ {
+		gets(100, 10)
+		tick()
+	}
+	if len(calls) != 2 || calls[1].ratio < 0.5 {
+		t.Fatalf("Expected one recovery notification, got %v", calls)
+	}
+	for i := 0; i < 3; i++ {
+		gets(100, 10)
+		tick()
+	}
+	if len(calls) != 2 {
+		t.Errorf("Expected no further callbacks, got %v", calls)
+	}
+}
+
+func TestStatsHitsAndMisses(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("key1", "value1", time.Hour)
+	cache.Get("key1")
+	cache.Get("key1")
+	cache.Get("missing")
+
+	if s := cache.Stats(); s.Hits != 2 || s.Misses != 1 {
+		t.Errorf("Expected 2 hits and 1 miss, got %d and %d", s.Hits, s.Misses)
+	}
+}
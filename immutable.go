@@ -0,0 +1,78 @@
+package lrucache
+
+import (
+	"fmt"
+	"time"
+)
+
+// SetImmutable stores value under key like Set and marks the entry
+// immutable: until it expires or is removed, every other write of the key
+// fails with ErrImmutable. That covers Set, SetMulti, Replace,
+// CompareAndSwap, SetImmutable itself and the in-place updates such as
+// SetField, Append and RangeMutate. Overwrite replaces an immutable entry on
+// purpose. Delete, Pop, Clear and the expiry and eviction passes remove it
+// as usual.
+func (l *LRU) SetImmutable(key string, value interface{}, ttl time.Duration) error {
+	return l.storeFlagged(key, value, ttl, func(item *CacheItem) { item.Immutable = true })
+}
+
+// Overwrite stores value under key like Set, replacing the entry even if it
+// was stored with SetImmutable. The new entry is not immutable.
+func (l *LRU) Overwrite(key string, value interface{}, ttl time.Duration) error {
+	return l.storeFlagged(key, value, ttl, func(item *CacheItem) { item.overwrite = true })
+}
+
+// storeFlagged stores value under key with mark applied to the new item.
+func (l *LRU) storeFlagged(key string, value interface{}, ttl time.Duration, mark func(*CacheItem)) error {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	if ttl <= 0 && ttl != NoExpiration {
+		return l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+	if err := l.checkUsefulTTL(ttl); err != nil {
+		return err
+	}
+
+	data, err := serialize(value)
+	if err != nil {
+		return fmt.Errorf("failed to serialize value: %v", err)
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	item := l.newItem(key, data, ttl)
+	mark(item)
+	return l.store(item, nil)
+}
+
+// checkMutable returns an error wrapping ErrImmutable if prev, the row a
+// write is about to replace, is a live entry stored with SetImmutable.
+func (l *LRU) checkMutable(prev *CacheItem) error {
+	if prev == nil || !prev.Immutable || prev.expired(l.clock()) || (prev.reads != nil && prev.reads.Load() <= 0) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrImmutable, prev.Key)
+}
+
+// checkMutableKey is checkMutable for the row stored under key. The caller
+// must hold the write lock.
+func (l *LRU) checkMutableKey(key string) error {
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	prev, _ := raw.(*CacheItem)
+	return l.checkMutable(prev)
+}
+
+// checkReplace is checkMutableKey for a new item, which Overwrite lets
+// through. The caller must hold the write lock.
+func (l *LRU) checkReplace(item *CacheItem) error {
+	if item.overwrite {
+		item.overwrite = false
+		return nil
+	}
+	return l.checkMutableKey(item.Key)
+}
@@ -0,0 +1,125 @@
+package lrucache
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetImmutable(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	if err := cache.SetImmutable("config", map[string]interface{}{"mode": "a"}, time.Hour); err != nil {
+		t.Fatalf("SetImmutable failed: %v", err)
+	}
+	cache.Set("note", "n", time.Hour)
+	if !cache.Items()["config"].Immutable || cache.Items()["note"].Immutable {
+		t.Error("Expected Items to report only config as immutable")
+	}
+
+	writes := map[string]func() error{
+		"Set":          func() error { return cache.Set("config", "b", time.Hour) },
+		"SetImmutable": func() error { return cache.SetImmutable("config", "b", time.Hour) },
+		"SetMulti":     func() error { return cache.SetMulti(map[string]interface{}{"config": "b", "other": 1}, time.Hour) },
+		"Replace":      func() error { return cache.Replace("config", "b", time.Hour) },
+		"CompareAndSwap": func() error {
+			_, err := cache.CompareAndSwap("config", map[string]interface{}{"mode": "a"}, "b", time.Hour)
+			return err
+		},
+		"SetManyItems": func() error {
+			failed, err := cache.SetManyItems([]SetItem{{Key: "config", Value: "b", TTL: time.Hour}})
+			if err != nil {
+				return err
+			}
+			return failed["config"]
+		},
+		"SetField":         func() error { return cache.SetField("config", "mode", "b") },
+		"SetPreservingTTL": func() error { return cache.SetPreservingTTL("config", "b") },
+		"RangeMutate": func() error {
+			return cache.RangeMutate(func(key string, _ interface{}) (interface{}, RangeAction) {
+				return "b", ActionUpdate
+			})
+		},
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, ErrImmutable) {
+			t.Errorf("Expected %s to fail with ErrImmutable, got %v", name, err)
+		}
+	}
+	if v, err := cache.Get("config"); err != nil || v.(map[string]interface{})["mode"] != "a" {
+		t.Errorf("Expected the immutable value to be untouched, got %v, %v", v, err)
+	}
+	if _, err := cache.Get("other"); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("Expected the refused SetMulti to store nothing, got %v", err)
+	}
+	if v, _ := cache.Get("note"); v != "n" {
+		t.Errorf("Expected RangeMutate to leave the batch alone, got %v", v)
+	}
+
+	// Overwrite replaces it on purpose, with a mutable entry.
+	if err := cache.Overwrite("config", "b", time.Hour); err != nil {
+		t.Fatalf("Overwrite failed: %v", err)
+	}
+	if err := cache.Set("config", "c", time.Hour); err != nil {
+		t.Errorf("Expected the overwritten entry to be mutable, got %v", err)
+	}
+
+	// Delete still works, and the key can be stored again afterwards.
+	cache.SetImmutable("frozen", "v", time.Hour)
+	if err := cache.Delete("frozen"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := cache.Set("frozen", "w", time.Hour); err != nil {
+		t.Errorf("Expected a deleted immutable key to be writable, got %v", err)
+	}
+}
+
+func TestSetImmutableExpired(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.SetImmutable("k", "v", time.Minute)
+	if _, err := cache.Append("k", []byte("!")); !errors.Is(err, ErrImmutable) {
+		t.Errorf("Expected Append to fail with ErrImmutable, got %v", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if err := cache.Set("k", "w", time.Hour); err != nil {
+		t.Errorf("Expected an expired immutable entry to be replaceable, got %v", err)
+	}
+}
+
+func TestSetImmutableReplicates(t *testing.T) {
+	source, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	replica, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	source.SetImmutable("k", "v", time.Hour)
+
+	var full bytes.Buffer
+	if err := source.ExportDelta(&full, 0); err != nil {
+		t.Fatalf("ExportDelta failed: %v", err)
+	}
+	since := source.CurrentGeneration()
+	if err := replica.ApplyDelta(&full); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	if err := replica.Set("k", "w", time.Hour); !errors.Is(err, ErrImmutable) {
+		t.Errorf("Expected the replica to keep the entry immutable, got %v", err)
+	}
+
+	// An overwrite on the source carries over to the replica.
+	source.Overwrite("k", "w", time.Hour)
+	var delta bytes.Buffer
+	if err := source.ExportDelta(&delta, since); err != nil {
+		t.Fatalf("ExportDelta failed: %v", err)
+	}
+	if strings.Contains(delta.String(), `"immutable"`) {
+		t.Errorf("Expected the overwritten entry to be exported as mutable:\n%s", delta.String())
+	}
+	if err := replica.ApplyDelta(&delta); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	if v, err := replica.Get("k"); err != nil || v != "w" {
+		t.Errorf("Expected the overwrite to replicate, got %v, %v", v, err)
+	}
+}
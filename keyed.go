@@ -0,0 +1,137 @@
+package lrucache
+
+import (
+	"encoding"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// KeyEncoder turns a typed key into the string key stored in the cache.
+// Distinct keys must encode to distinct strings.
+type KeyEncoder[K any] func(key K) (string, error)
+
+// KeyedLRU is a view of an LRU with typed keys and values. Every key goes
+// through one KeyEncoder, so a key type is formatted the same way at every
+// call site. Values read back are converted to V as CachedFunc1 does.
+// Several views may share one LRU; their encoders should then keep their
+// keys apart, for example with a prefix.
+type KeyedLRU[K comparable, V any] struct {
+	cache  *LRU
+	encode KeyEncoder[K]
+}
+
+// NewKeyedLRU returns a view of cache whose keys are encoded with encode.
+func NewKeyedLRU[K comparable, V any](cache *LRU, encode KeyEncoder[K]) *KeyedLRU[K, V] {
+	return &KeyedLRU[K, V]{cache: cache, encode: encode}
+}
+
+func (c *KeyedLRU[K, V]) key(key K) (string, error) {
+	s, err := c.encode(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key %v: %w", key, err)
+	}
+	return s, nil
+}
+
+func (c *KeyedLRU[K, V]) Set(key K, value V, ttl time.Duration) error {
+	s, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	return c.cache.Set(s, value, ttl)
+}
+
+func (c *KeyedLRU[K, V]) Get(key K) (V, error) {
+	var zero V
+	s, err := c.key(key)
+	if err != nil {
+		return zero, err
+	}
+	v, err := c.cache.Get(s)
+	if err != nil {
+		return zero, err
+	}
+	value, err := decodeInto[V](v)
+	if err != nil {
+		return zero, fmt.Errorf("failed to convert value of key %s: %w", s, err)
+	}
+	return value, nil
+}
+
+func (c *KeyedLRU[K, V]) Delete(key K) error {
+	s, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	return c.cache.Delete(s)
+}
+
+// GetMany looks up keys as LRU.GetMulti does and returns the values found
+// and the keys that were missing or expired.
+func (c *KeyedLRU[K, V]) GetMany(keys []K) (map[K]V, []K, error) {
+	encoded := make([]string, len(keys))
+	byKey := make(map[string]K, len(keys))
+	for i, key := range keys {
+		s, err := c.key(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		encoded[i] = s
+		byKey[s] = key
+	}
+
+	found, missing, err := c.cache.GetMulti(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make(map[K]V, len(found))
+	for s, v := range found {
+		value, err := decodeInto[V](v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to convert value of key %s: %w", s, err)
+		}
+		values[byKey[s]] = value
+	}
+	missingKeys := make([]K, len(missing))
+	for i, s := range missing {
+		missingKeys[i] = byKey[s]
+	}
+	return values, missingKeys, nil
+}
+
+// Integer is the constraint of IntKey.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// IntKey encodes integer keys in decimal.
+func IntKey[K Integer](key K) (string, error) {
+	if key < 0 {
+		return strconv.FormatInt(int64(key), 10), nil
+	}
+	return strconv.FormatUint(uint64(key), 10), nil
+}
+
+// StringerKey encodes keys with their String method, which must then tell
+// distinct keys apart.
+func StringerKey[K fmt.Stringer](key K) (string, error) {
+	return key.String(), nil
+}
+
+// TextKey encodes keys with their MarshalText method.
+func TextKey[K encoding.TextMarshaler](key K) (string, error) {
+	text, err := key.MarshalText()
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+// CompositeKey joins the parts of a composite key, such as the fields of a
+// struct, into one string. Each part is formatted with fmt and quoted, so
+// parts containing the separator cannot make two keys collide.
+func CompositeKey(parts ...interface{}) string {
+	return defaultKey(parts...)
+}
@@ -0,0 +1,136 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type userID int64
+
+type userProfile struct {
+	Name string
+	Age  int
+}
+
+func TestKeyedLRUInt64Keys(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	users := NewKeyedLRU[userID, userProfile](cache, IntKey[userID])
+
+	if err := users.Set(42, userProfile{Name: "alice", Age: 30}, time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	users.Set(-42, userProfile{Name: "bob"}, time.Hour)
+
+	if p, err := users.Get(42); err != nil || p != (userProfile{Name: "alice", Age: 30}) {
+		t.Errorf("Expected alice, got %+v, %v", p, err)
+	}
+	if _, err := cache.Get("42"); err != nil {
+		t.Errorf("Expected the key to be stored in decimal, got %v", err)
+	}
+
+	values, missing, err := users.GetMany([]userID{42, -42, 7})
+	if err != nil {
+		t.Fatalf("GetMany failed: %v", err)
+	}
+	if len(values) != 2 || values[-42].Name != "bob" {
+		t.Errorf("Expected alice and bob, got %+v", values)
+	}
+	if !reflect.DeepEqual(missing, []userID{7}) {
+		t.Errorf("Expected 7 to be missing, got %v", missing)
+	}
+
+	if err := users.Delete(42); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := users.Get(42); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+}
+
+type order struct {
+	Tenant string
+	ID     int
+}
+
+func orderKey(o order) (string, error) {
+	return CompositeKey("order", o.Tenant, o.ID), nil
+}
+
+func TestKeyedLRUStructKeys(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	orders := NewKeyedLRU[order, int](cache, orderKey)
+
+	// Both would be "a,b,1" if the parts were joined as they are.
+	a, b := order{Tenant: "a,b", ID: 1}, order{Tenant: "a", ID: 0}
+	orders.Set(a, 1, time.Hour)
+	orders.Set(b, 2, time.Hour)
+	if v, err := orders.Get(a); err != nil || v != 1 {
+		t.Errorf("Expected 1, got %v, %v", v, err)
+	}
+	if v, err := orders.Get(b); err != nil || v != 2 {
+		t.Errorf("Expected 2, got %v, %v", v, err)
+	}
+}
+
+func TestKeyedLRUEncoderErrors(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	fail := errors.New("no key")
+	c := NewKeyedLRU[string, string](cache, func(string) (string, error) { return "", fail })
+
+	if err := c.Set("a", "v", time.Hour); !errors.Is(err, fail) {
+		t.Errorf("Expected the encoder error, got %v", err)
+	}
+	if _, _, err := c.GetMany([]string{"a"}); !errors.Is(err, fail) {
+		t.Errorf("Expected the encoder error, got %v", err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected nothing to be stored")
+	}
+}
+
+type sku struct{ code string }
+
+func (s sku) String() string { return "sku:" + s.code }
+
+func TestKeyEncodersDoNotCollide(t *testing.T) {
+	seen := make(map[string]string)
+	check := func(what string, key string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Encoding %s failed: %v", what, err)
+		}
+		if prev, ok := seen[key]; ok {
+			t.Errorf("%s and %s both encode to %q", prev, what, key)
+		}
+		seen[key] = what
+	}
+
+	for _, n := range []int64{math.MinInt64, -1, 0, 1, 10, math.MaxInt64} {
+		key, err := IntKey(n)
+		check(fmt.Sprintf("int64 %d", n), key, err)
+	}
+	key, err := IntKey(uint64(math.MaxUint64))
+	check("uint64 max", key, err)
+
+	seen = make(map[string]string)
+	for _, code := range []string{"", "a", "b", "ab"} {
+		key, err := StringerKey(sku{code})
+		check("sku "+code, key, err)
+	}
+
+	seen = make(map[string]string)
+	for _, addr := range []string{"10.0.0.1", "10.0.0.10", "::1"} {
+		key, err := TextKey(netip.MustParseAddr(addr))
+		check("addr "+addr, key, err)
+	}
+
+	seen = make(map[string]string)
+	for _, parts := range [][]interface{}{{"a,b", 1}, {"a", "b,1"}, {"a", "b", 1}, {`a"`, 1}, {"a", 1}} {
+		check(fmt.Sprintf("parts %q", parts), CompositeKey(parts...), nil)
+	}
+}
@@ -0,0 +1,59 @@
+package lrucache
+
+import (
+	"strings"
+	"unicode"
+)
+
+// KeyNormalizer maps a key to its canonical spelling. Normalizers must be
+// idempotent, since keys may be normalized more than once on their way in.
+type KeyNormalizer func(key string) string
+
+// LowercaseKeys lowercases keys by the Unicode case mapping, which does not
+// depend on the locale.
+func LowercaseKeys(key string) string {
+	return strings.ToLower(key)
+}
+
+// FoldCaseKeys maps every rune to one representative of its Unicode simple
+// case folding orbit, so that spellings differing only in case compare
+// equal even where lowercasing does not unify them, such as the Kelvin sign
+// and the letter k.
+func FoldCaseKeys(key string) string {
+	return strings.Map(func(r rune) rune {
+		folded := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < folded {
+				folded = f
+			}
+		}
+		return folded
+	}, key)
+}
+
+// TrimSpaceKeys removes leading and trailing white space from keys.
+func TrimSpaceKeys(key string) string {
+	return strings.TrimSpace(key)
+}
+
+// ChainKeyNormalizers applies normalizers in order. Unicode normalization
+// forms such as NFC are not built in; pass norm.NFC.String from
+// golang.org/x/text/unicode/norm to add one.
+func ChainKeyNormalizers(normalizers ...KeyNormalizer) KeyNormalizer {
+	return func(key string) string {
+		for _, n := range normalizers {
+			key = n(key)
+		}
+		return key
+	}
+}
+
+// NormalizeKey returns key as the cache stores it under Options.KeyNormalizer.
+// Every method taking keys or key prefixes applies it, so callers only need
+// it to precompute keys or to compare them with keys reported by the cache.
+func (l *LRU) NormalizeKey(key string) string {
+	if l.opts.KeyNormalizer == nil {
+		return key
+	}
+	return l.opts.KeyNormalizer(key)
+}
@@ -0,0 +1,69 @@
+package lrucache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// composeAcute stands in for a real NFC normalizer in tests: it composes the
+// one combining sequence they use.
+func composeAcute(key string) string {
+	return strings.ReplaceAll(key, "e\u0301", "\u00e9")
+}
+
+func TestKeyNormalizerCaseFolding(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:      "error",
+		KeyNormalizer: ChainKeyNormalizers(TrimSpaceKeys, FoldCaseKeys),
+	})
+
+	cache.Set("  ÅSA@Example.COM ", "asa", time.Minute)
+	for _, key := range []string{"åsa@example.com", "ÅSA@EXAMPLE.COM", "\tåSa@eXample.com"} {
+		if v, err := cache.Get(key); err != nil || v != "asa" {
+			t.Errorf("Get(%q): expected asa, got %v, %v", key, v, err)
+		}
+	}
+
+	// The Kelvin sign lowercases to k but is its own uppercase letter.
+	cache.Set("Kelvin", "kelvin", time.Minute)
+	if v, err := cache.Get("kelvin"); err != nil || v != "kelvin" {
+		t.Errorf("Expected the Kelvin sign to fold to k, got %v, %v", v, err)
+	}
+	if got := cache.NormalizeKey(" ÅSA "); got != cache.NormalizeKey("åsa") {
+		t.Errorf("Expected NormalizeKey to agree for both spellings, got %q", got)
+	}
+}
+
+func TestKeyNormalizerUnicodeForms(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:      "error",
+		KeyNormalizer: ChainKeyNormalizers(composeAcute, LowercaseKeys),
+	})
+
+	nfd, nfc := "Rene\u0301@example.com", "ren\u00e9@example.com"
+	cache.Set(nfd, "rené", time.Minute)
+	if v, err := cache.Get(nfc); err != nil || v != "rené" {
+		t.Fatalf("Expected the NFC spelling to find the NFD one, got %v, %v", v, err)
+	}
+	if got := liveKeys(cache); len(got) != 1 || got[0] != nfc {
+		t.Errorf("Expected the key to be stored normalized, got %q", got)
+	}
+
+	if err := cache.Delete("REN\u00c9@EXAMPLE.COM"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := cache.Get(nfc); err != ErrItemNotFound {
+		t.Errorf("Expected Delete with another spelling to remove the entry, got %v", err)
+	}
+}
+
+func TestKeyNormalizerTouchMany(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", KeyNormalizer: LowercaseKeys})
+	cache.Set("a", 1, time.Minute)
+
+	touched, missing, err := cache.TouchMany([]string{"A", "B"}, time.Hour)
+	if err != nil || touched != 1 || len(missing) != 1 || missing[0] != "B" {
+		t.Errorf("Expected A touched and B reported as given, got %d, %v, %v", touched, missing, err)
+	}
+}
@@ -0,0 +1,127 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// LoaderFunc loads the value of a key missing from the cache, along with the
+// TTL to store it with. A value loaded with NoExpiration is stored to never
+// expire; one loaded with any other TTL that is not positive is returned
+// without being stored.
+type LoaderFunc func(key string) (value interface{}, ttl time.Duration, err error)
+
+// negativeCache remembers recent loader errors. It holds at most as many
+// keys as the cache itself.
+type negativeCache struct {
+	mu      sync.Mutex
+	entries *simplelru.LRU
+}
+
+type negativeEntry struct {
+	err     error
+	expires time.Time
+}
+
+func newNegativeCache(size int) *negativeCache {
+	entries, _ := simplelru.NewLRU(size, nil)
+	return &negativeCache{entries: entries}
+}
+
+// get returns the error cached for key, if it is still current at now.
+func (c *negativeCache) get(key string, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.entries.Get(key)
+	if !ok {
+		return nil
+	}
+	if e := v.(negativeEntry); now.Before(e.expires) {
+		return e.err
+	}
+	c.entries.Remove(key)
+	return nil
+}
+
+func (c *negativeCache) add(key string, err error, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.Add(key, negativeEntry{err: err, expires: expires})
+}
+
+// readThrough loads a key Get missed with Options.Loader and stores the
+// result, unless a value was stored during the load, which wins. Concurrent
+// misses of one key share a single load and its result, error or not;
+// loads of different keys run in parallel.
+func (l *LRU) readThrough(key string) (interface{}, error) {
+	return l.loads.do(key, func() (interface{}, error) {
+		// An earlier load may have finished since the miss.
+		if value, err := l.peek(key); err == nil {
+			return value, nil
+		}
+		return l.load(key)
+	})
+}
+
+// load calls Options.Loader for key and stores its result.
+func (l *LRU) load(key string) (interface{}, error) {
+	if l.negative != nil {
+		if err := l.negative.get(key, l.clock()); err != nil {
+			return nil, err
+		}
+	}
+
+	value, ttl, err := l.opts.Loader(key)
+	if err != nil {
+		if l.negative != nil {
+			l.negative.add(key, err, l.clock().Add(l.opts.NegativeTTL))
+		}
+		return nil, err
+	}
+	if ttl <= 0 && ttl != NoExpiration {
+		return value, nil
+	}
+	defer l.checkWatermarks()
+	actual, _, err := l.getOrSet(key, value, ttl)
+	if errors.Is(err, ErrDraining) {
+		return value, nil
+	}
+	return actual, err
+}
+
+// GetOrLoad returns the value of key, calling loader on a miss and storing
+// its result with ttl. The cache lock is not held while loader runs; if the
+// key was stored in the meantime, that value wins and is returned instead of
+// the loaded one. A loader error is returned as is and nothing is cached;
+// Options.Loader and NegativeTTL do not apply to GetOrLoad. While the cache
+// is draining a miss returns ErrDraining without calling loader.
+func (l *LRU) GetOrLoad(key string, ttl time.Duration, loader func() (interface{}, error)) (interface{}, error) {
+	key = l.NormalizeKey(key)
+	if ttl <= 0 && ttl != NoExpiration {
+		return nil, l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+
+	value, err := l.getChain(context.Background(), key)
+	if err != ErrItemNotFound && err != ErrItemExpired {
+		return value, err
+	}
+	if l.Draining() {
+		return nil, ErrDraining
+	}
+
+	value, err = loader()
+	if err != nil {
+		return nil, err
+	}
+	defer l.checkWatermarks()
+	actual, _, err := l.getOrSet(key, value, ttl)
+	if errors.Is(err, ErrDraining) {
+		// The drain began during the load; the value is still good.
+		return value, nil
+	}
+	return actual, err
+}
@@ -0,0 +1,230 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	calls := 0
+	loader := func() (interface{}, error) {
+		calls++
+		return "loaded", nil
+	}
+	for i := 0; i < 3; i++ {
+		if v, err := cache.GetOrLoad("k", time.Minute, loader); err != nil || v != "loaded" {
+			t.Fatalf("Expected the loaded value, got %v, %v", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected one load, got %d", calls)
+	}
+	if v, _ := cache.Get("k"); v != "loaded" {
+		t.Errorf("Expected the loaded value to be stored, got %v", v)
+	}
+
+	now = now.Add(2 * time.Minute)
+	cache.GetOrLoad("k", time.Minute, loader)
+	if calls != 2 {
+		t.Errorf("Expected an expired entry to be loaded again, got %d loads", calls)
+	}
+}
+
+func TestGetOrLoadError(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	boom := errors.New("boom")
+
+	_, err := cache.GetOrLoad("k", time.Minute, func() (interface{}, error) { return nil, boom })
+	if err != boom {
+		t.Fatalf("Expected the loader error, got %v", err)
+	}
+	if cache.Contains("k") {
+		t.Error("Expected nothing cached after a failed load")
+	}
+}
+
+func TestGetOrLoadPrefersStoredValue(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	// The loader runs without the lock, so a write can land meanwhile.
+	v, err := cache.GetOrLoad("k", time.Minute, func() (interface{}, error) {
+		if err := cache.Set("k", "concurrent", time.Minute); err != nil {
+			t.Errorf("Set during the load failed: %v", err)
+		}
+		return "loaded", nil
+	})
+	if err != nil || v != "concurrent" {
+		t.Errorf("Expected the value stored during the load, got %v, %v", v, err)
+	}
+	if v, _ := cache.Get("k"); v != "concurrent" {
+		t.Errorf("Expected the stored value to be kept, got %v", v)
+	}
+}
+
+func TestLoaderOption(t *testing.T) {
+	var loads []string
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		Loader: func(key string) (interface{}, time.Duration, error) {
+			loads = append(loads, key)
+			switch key {
+			case "volatile":
+				return "once", 0, nil
+			case "forever":
+				return "kept", NoExpiration, nil
+			}
+			return "value of " + key, time.Minute, nil
+		},
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if v, err := cache.Get("a"); err != nil || v != "value of a" {
+			t.Fatalf("Expected the loaded value, got %v, %v", v, err)
+		}
+	}
+	if len(loads) != 1 {
+		t.Errorf("Expected one load, got %v", loads)
+	}
+	if ttl := expiresAt(t, cache, "a").Sub(now); ttl != time.Minute {
+		t.Errorf("Expected the loader's TTL, got %v", ttl)
+	}
+
+	cache.Get("volatile")
+	if cache.Contains("volatile") {
+		t.Error("Expected a value without a TTL not to be stored")
+	}
+	cache.Get("forever")
+	if ttl, err := cache.TTL("forever"); err != nil || ttl != NoExpiration {
+		t.Errorf("Expected a value loaded with NoExpiration to be stored for good, got %v, %v", ttl, err)
+	}
+
+	// GetOrLoad uses its own loader.
+	if v, _ := cache.GetOrLoad("b", time.Minute, func() (interface{}, error) { return "own", nil }); v != "own" {
+		t.Errorf("Expected GetOrLoad's loader, got %v", v)
+	}
+	if len(loads) != 3 {
+		t.Errorf("Expected Options.Loader not to run for GetOrLoad, got %v", loads)
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	boom := errors.New("boom")
+	calls := 0
+	loader := func(string) (interface{}, time.Duration, error) {
+		calls++
+		return nil, 0, boom
+	}
+
+	plain, _ := NewLRUWithTTL(10, Options{LogLevel: "error", Loader: loader})
+	for i := 0; i < 2; i++ {
+		if _, err := plain.Get("k"); err != boom {
+			t.Fatalf("Expected the loader error, got %v", err)
+		}
+	}
+	if calls != 2 || plain.Contains("k") {
+		t.Errorf("Expected errors not to be cached by default, %d calls", calls)
+	}
+
+	calls = 0
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", Loader: loader, NegativeTTL: 5 * time.Second})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get("k"); err != boom {
+			t.Fatalf("Expected the cached loader error, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected one load within NegativeTTL, got %d", calls)
+	}
+	if cache.Len() != 0 {
+		t.Error("Expected the negative entry to stay out of the table")
+	}
+	now = now.Add(6 * time.Second)
+	cache.Get("k")
+	if calls != 2 {
+		t.Errorf("Expected a new load after NegativeTTL, got %d", calls)
+	}
+}
+
+func TestLoaderSingleflight(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		var calls atomic.Int32
+		release := make(chan struct{})
+		boom := errors.New("boom")
+		cache, _ := NewLRUWithTTL(10, Options{
+			LogLevel: "error",
+			Loader: func(key string) (interface{}, time.Duration, error) {
+				calls.Add(1)
+				<-release
+				if fail {
+					return nil, 0, boom
+				}
+				return "v", time.Minute, nil
+			},
+		})
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 100)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := cache.Get("hot")
+				if err == nil && v != "v" {
+					err = fmt.Errorf("unexpected value %v", v)
+				}
+				errs <- err
+			}()
+		}
+		// Let the goroutines pile up behind the first load.
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		close(errs)
+
+		if n := calls.Load(); n != 1 {
+			t.Errorf("fail=%v: expected one loader call, got %d", fail, n)
+		}
+		for err := range errs {
+			if fail && err != boom || !fail && err != nil {
+				t.Errorf("fail=%v: unexpected result %v", fail, err)
+				break
+			}
+		}
+	}
+}
+
+func TestLoaderSingleflightPerKey(t *testing.T) {
+	slow := make(chan struct{})
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		Loader: func(key string) (interface{}, time.Duration, error) {
+			if key == "slow" {
+				<-slow
+			}
+			return key, time.Minute, nil
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		cache.Get("slow")
+		close(done)
+	}()
+	if v, err := cache.Get("fast"); err != nil || v != "fast" {
+		t.Errorf("Expected another key to load while one is in flight, got %v, %v", v, err)
+	}
+	close(slow)
+	<-done
+}
@@ -0,0 +1,184 @@
+package lrucache
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// lockWaitSamples is how many recent waits per operation kind are kept for
+// the percentiles.
+const lockWaitSamples = 1024
+
+const sampleBytes = int64(unsafe.Sizeof(time.Duration(0)))
+
+// LockWaitStats describes how long one kind of operation waited for the
+// cache lock. Percentiles cover the most recent acquisitions only.
+type LockWaitStats struct {
+	Acquisitions uint64
+	Total        time.Duration
+	Max          time.Duration
+	P50          time.Duration
+	P99          time.Duration
+}
+
+type lockWaits struct {
+	count   uint64
+	total   time.Duration
+	max     time.Duration
+	samples []time.Duration // ring buffer of recent waits
+	next    int
+}
+
+// lockTracker records lock wait times by operation kind when
+// Options.TrackLockContention is set.
+type lockTracker struct {
+	mu  sync.Mutex
+	ops map[string]*lockWaits
+	// limit is the number of samples kept per kind, lowered below
+	// lockWaitSamples by the observability budget.
+	limit int
+}
+
+func newLockTracker() *lockTracker {
+	return &lockTracker{ops: make(map[string]*lockWaits), limit: lockWaitSamples}
+}
+
+func (t *lockTracker) record(op string, wait time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.ops[op]
+	if !ok {
+		w = &lockWaits{samples: make([]time.Duration, 0, t.limit)}
+		t.ops[op] = w
+	}
+	w.count++
+	w.total += wait
+	if wait > w.max {
+		w.max = wait
+	}
+	switch {
+	case t.limit == 0:
+	case len(w.samples) < t.limit:
+		w.samples = append(w.samples, wait)
+	default:
+		w.samples[w.next] = wait
+		w.next = (w.next + 1) % t.limit
+	}
+}
+
+// bytes returns the memory held by the wait samples.
+func (t *lockTracker) bytes() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var n int64
+	for _, w := range t.ops {
+		n += int64(len(w.samples)) * sampleBytes
+	}
+	return n
+}
+
+// demand returns the bytes the samples take when every kind seen so far
+// keeps lockWaitSamples of them.
+func (t *lockTracker) demand() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int64(len(t.ops)) * lockWaitSamples * sampleBytes
+}
+
+// limitBytes sets the per-kind limit so that the samples of the kinds seen
+// so far fit in n bytes, dropping the oldest samples beyond it.
+func (t *lockTracker) limitBytes(n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.ops) == 0 {
+		return
+	}
+	limit := int(min(n/sampleBytes/int64(len(t.ops)), lockWaitSamples))
+	if limit == t.limit {
+		return
+	}
+	t.limit = limit
+	for _, w := range t.ops {
+		// Put the ring in order, oldest first, so it can be cut and can
+		// grow again by appending.
+		ordered := append(append([]time.Duration(nil), w.samples[w.next:]...), w.samples[:w.next]...)
+		if len(ordered) > t.limit {
+			ordered = ordered[len(ordered)-t.limit:]
+		}
+		w.samples, w.next = ordered, 0
+	}
+}
+
+func (t *lockTracker) snapshot() map[string]LockWaitStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make(map[string]LockWaitStats, len(t.ops))
+	for op, w := range t.ops {
+		sorted := append([]time.Duration(nil), w.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		stats[op] = LockWaitStats{
+			Acquisitions: w.count,
+			Total:        w.total,
+			Max:          w.max,
+			P50:          percentile(sorted, 0.50),
+			P99:          percentile(sorted, 0.99),
+		}
+	}
+	return stats
+}
+
+// percentile returns the p-th percentile of sorted, or zero if it is empty.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+// writeLock takes the write lock on behalf of an operation of kind op,
+// waiting first for the cache to be resumed if it is quiesced.
+func (l *LRU) writeLock(op string) {
+	if l.lockStats == nil {
+		l.quiesce.gate.RLock()
+		l.lock.Lock()
+		l.quiesce.gate.RUnlock()
+		return
+	}
+	start := time.Now()
+	l.quiesce.gate.RLock()
+	l.lock.Lock()
+	l.quiesce.gate.RUnlock()
+	l.lockStats.record(op, time.Since(start))
+}
+
+// tryWriteLock is writeLock for work that can be left for later: instead of
+// waiting for a quiesced cache to be resumed it returns false.
+func (l *LRU) tryWriteLock(op string) bool {
+	start := time.Now()
+	if !l.quiesce.gate.TryRLock() {
+		return false
+	}
+	l.lock.Lock()
+	l.quiesce.gate.RUnlock()
+	if l.lockStats != nil {
+		l.lockStats.record(op, time.Since(start))
+	}
+	return true
+}
+
+// readLock takes the read lock on behalf of an operation of kind op.
+func (l *LRU) readLock(op string) {
+	if l.lockStats == nil {
+		l.lock.RLock()
+		return
+	}
+	start := time.Now()
+	l.lock.RLock()
+	l.lockStats.record(op, time.Since(start))
+}
@@ -0,0 +1,58 @@
+package lrucache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockContentionTracking(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", TrackLockContention: true})
+	cache.Set("key1", "value1", time.Hour)
+
+	const hold = 50 * time.Millisecond
+	const readers = 4
+
+	cache.lock.Lock()
+	var started, done sync.WaitGroup
+	started.Add(readers)
+	done.Add(readers)
+	for i := 0; i < readers; i++ {
+		go func() {
+			defer done.Done()
+			started.Done()
+			cache.Get("key1")
+		}()
+	}
+	started.Wait()
+	time.Sleep(hold)
+	cache.lock.Unlock()
+	done.Wait()
+
+	waits := cache.Stats().LockWaits
+	get, ok := waits["get"]
+	if !ok {
+		t.Fatalf("Expected get waits to be recorded, got %v", waits)
+	}
+	if get.Acquisitions != readers {
+		t.Errorf("Expected %d get acquisitions, got %d", readers, get.Acquisitions)
+	}
+	// Readers reach the lock just after signalling, so allow for scheduling
+	// slack at the start of the hold.
+	if get.P50 < hold/2 || get.Max < hold/2 || get.Total < readers*hold/2 {
+		t.Errorf("Expected reader waits close to the %v hold, got %+v", hold, get)
+	}
+	if set := waits["set"]; set.Acquisitions != 1 {
+		t.Errorf("Expected 1 set acquisition, got %d", set.Acquisitions)
+	}
+}
+
+func TestLockContentionDisabled(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("key1", "value1", time.Hour)
+	cache.Get("key1")
+
+	if waits := cache.Stats().LockWaits; waits != nil {
+		t.Errorf("Expected no lock waits without TrackLockContention, got %v", waits)
+	}
+}
@@ -1,11 +1,14 @@
 package lrucache
 
 import (
+	"bytes"
 	"container/heap"
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/go-memdb"
@@ -14,23 +17,307 @@ import (
 // EvictCallback is a function that is called when an item is evicted from the cache.
 type EvictCallback func(key string, value interface{})
 
+// EvictReason tells a RemovalCallback why an entry left the cache.
+type EvictReason int
+
+const (
+	ReasonExpired    EvictReason = iota + 1 // the TTL elapsed
+	ReasonCapacity                          // evicted to stay within size
+	ReasonDependency                        // a key it was derived from changed
+	ReasonScheduled                         // a scheduled invalidation fired
+	ReasonConsumed                          // its last allowed read happened
+	ReasonGeneration                        // its generation was invalidated
+	ReasonDeleted                           // removed by DeleteMulti
+	ReasonCorrupt                           // its value failed to decode
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonDependency:
+		return "dependency"
+	case ReasonScheduled:
+		return "scheduled"
+	case ReasonConsumed:
+		return "consumed"
+	case ReasonGeneration:
+		return "generation"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonCorrupt:
+		return "corrupt"
+	default:
+		return fmt.Sprintf("EvictReason(%d)", int(r))
+	}
+}
+
+// RemovalCallback is called with the removed value and the reason whenever
+// the cache itself removes an entry.
+//
+// Both callbacks run synchronously, under the write lock, inside the call
+// that caused the removal: when a Set evicts entries to make room, their
+// callbacks have returned by the time Set returns, so nothing is left to
+// flush. For the same reason they must not call back into the cache.
+type RemovalCallback func(key string, value interface{}, reason EvictReason)
+
 type Options struct {
-	LogLevel      string // "debug", "info", "warn", "error"
-	EvictCallback EvictCallback
+	LogLevel        string // "debug", "info", "warn", "error"; empty disables logging
+	EvictCallback   EvictCallback
+	RemovalCallback RemovalCallback
+
+	// GetMiddleware wraps every Get; see Middleware for the order.
+	GetMiddleware []Middleware
+
+	// MaxDependencies caps how many keys one SetDerived entry may depend on.
+	// Defaults to 32.
+	MaxDependencies int
+
+	// AuditInterval enables a background consistency check between the
+	// expiration heap and the memdb table. Zero disables it.
+	AuditInterval time.Duration
+
+	// SetRateLimit bounds how often a single key may be written.
+	SetRateLimit SetRateLimit
+
+	// EvictVariantsSeparately makes capacity eviction drop a single variant
+	// stored with SetVariant instead of every variant of the same key.
+	EvictVariantsSeparately bool
+
+	// Preallocate, when positive, allocates CacheItems in blocks of this many
+	// and recycles the slots of removed items, which cuts the number of
+	// objects the garbage collector has to track in very large caches.
+	Preallocate int
+
+	// StrictErrors propagates internal failures that are otherwise only
+	// logged, such as a failed removal of an expired or evicted entry, to the
+	// calling operation. Background sweeps count them in Stats().SweepErrors
+	// and pass them to OnError.
+	StrictErrors bool
+	// OnError receives background failures when StrictErrors is set.
+	OnError func(err error)
+
+	// ClockJumpPolicy decides how stored deadlines follow a backward step of
+	// the system clock. Expiry itself always runs on the monotonic clock;
+	// forward steps are always re-anchored.
+	ClockJumpPolicy ClockJumpPolicy
+
+	// MinUsefulTTL makes writes whose TTL is below it skip the store. Set then
+	// returns an error wrapping ErrNotStored and SetEx reports stored=false.
+	MinUsefulTTL time.Duration
+
+	// DefaultTTL is the TTL of writes made with SetDefault. NoExpiration
+	// makes them never expire.
+	DefaultTTL time.Duration
+
+	// DebugPanics turns errors caused by misusing the cache into panics
+	// naming the key and call site, for development. These are the errors
+	// wrapping ErrInvalidArgument, such as an empty key or a TTL that is not
+	// positive, ErrClosed and ErrDependencyCycle; misses, storage failures
+	// and the rest are still returned.
+	DebugPanics bool
+
+	// Loader, when set, makes Get load and store keys it misses; see
+	// LoaderFunc. Concurrent misses of a key wait for a single call and
+	// share its result. NegativeTTL, when positive, caches loader errors for that
+	// long: Gets of the key return the same error without calling Loader
+	// again until it passes.
+	Loader      LoaderFunc
+	NegativeTTL time.Duration
+
+	// ReadRepairLimit caps how often within ReadRepairWindow a read may
+	// remove the entry of one key because its value fails to decode; past
+	// it the entry is left in place and the error returned. They default to
+	// 3 repairs a minute.
+	ReadRepairLimit  int
+	ReadRepairWindow time.Duration
+
+	// TombstoneRetention keeps the final values of expired and evicted
+	// entries retrievable through Tombstone for a while.
+	TombstoneRetention TombstoneRetention
+
+	// TrackLockContention measures how long operations wait for the cache
+	// lock and reports it in Stats().LockWaits.
+	TrackLockContention bool
+
+	// MissFilter, when sized, lets Get reject keys that were never stored
+	// without taking the lock or touching the table.
+	MissFilter MissFilter
+
+	// HitRatioAlert reports drops of the hit ratio below a threshold.
+	HitRatioAlert HitRatioAlert
+
+	// MaxDecodeDepth bounds the nesting of JSON values decoded on reads and
+	// defaults to 1000. MaxDecodeBytes, when positive, bounds the size of any
+	// decoded value. Values beyond either limit fail with ErrDeserialization.
+	MaxDecodeDepth int
+	MaxDecodeBytes int
+
+	// StatsKeyGrouper, when set, maps keys to groups, such as their first
+	// path segment, whose hits, misses, sets and evictions are reported in
+	// Stats().Groups. It runs on every Get and Set, so it must be cheap.
+	// MaxStatGroups bounds the number of groups and defaults to 64; keys of
+	// later groups are counted under OtherStatGroup.
+	StatsKeyGrouper func(key string) string
+	MaxStatGroups   int
+
+	// PreExpiryNotice announces entries shortly before they expire.
+	PreExpiryNotice PreExpiryNotice
+
+	// ObservabilityMaxBytes, when positive, bounds the combined memory of
+	// tombstones, lock wait samples and hit ratio samples. The oldest
+	// tombstones and samples are dropped to stay within it; current sizes
+	// are reported in Stats().ObservabilityBytes.
+	ObservabilityMaxBytes int64
+
+	// Watermarks report utilization crossing configured levels.
+	Watermarks []Watermark
+
+	// KeyNormalizer, when set, canonicalizes every key and key prefix
+	// passed to the cache before it is looked up or stored. See
+	// LowercaseKeys, FoldCaseKeys, TrimSpaceKeys and ChainKeyNormalizers.
+	KeyNormalizer KeyNormalizer
+
+	// KeyRedactor, when set, rewrites keys before they appear in logs and
+	// other observability output; see KeyRedactor and HashKeyRedactor.
+	KeyRedactor KeyRedactor
+
+	// FairnessGroups assigns keys to groups for capacity eviction, and
+	// FairnessShares reserves groups a fraction of the capacity: eviction
+	// skips entries of a group holding no more than its share, taking them
+	// from groups above theirs instead. Groups may use more than their share
+	// while there is room. Variants count toward the group of their base
+	// key, and groups missing from FairnessShares reserve nothing.
+	FairnessGroups func(key string) string
+	FairnessShares map[string]float64
+
+	// CardinalityGuard limits the admission of new keys while they arrive
+	// faster than a set rate.
+	CardinalityGuard CardinalityGuard
+	// DeletionLogSize is how many deletions are remembered for ExportDelta;
+	// 0 means 4096. A delta that would need older deletions fails with
+	// ErrDeltaUnavailable.
+	DeletionLogSize int
+
+	// MaxBytes, when positive, caps the combined size of the stored keys and
+	// serialized values. A write that would exceed it first evicts unpinned
+	// entries, closest to expiring first, so the cap holds at every point;
+	// if evicting all of them would not make room, the write is rejected
+	// with an error wrapping ErrNotStored.
+	MaxBytes int64
+
+	// IncrementResetsTTL makes Increment and Decrement of an existing counter
+	// reset its TTL to the one passed instead of keeping its deadline.
+	IncrementResetsTTL bool
+
+	// SharedSnapshot names a snapshot file shared with other caches on the
+	// same host.
+	SharedSnapshot SharedSnapshot
+
+	// DeleteMultiCallbacks makes DeleteMulti fire EvictCallback and
+	// RemovalCallback, with ReasonDeleted, for every entry it removes. By
+	// default bulk deletes are silent, like Delete.
+	DeleteMultiCallbacks bool
+
+	// SetManyAllOrNothing makes SetManyItems store nothing when any item
+	// fails, instead of storing the others.
+	SetManyAllOrNothing bool
+
+	// SweepSchedule caps the background sweep during a daily peak window.
+	SweepSchedule SweepSchedule
+
+	// SlidingTTL makes every successful Get, GetContext, GetWithExpiration
+	// and GetMulti move the entry's deadline out to SlidingWindow from now,
+	// so that entries live as long as they are read. Deadlines are never
+	// brought forward, and entries that never expire stay that way. Peek,
+	// Contains and the other reads leave deadlines alone.
+	SlidingTTL    bool
+	SlidingWindow time.Duration
+
+	// IndexValueHashes maintains an index of hashes of the stored values,
+	// which makes FindKeysByValue and DeleteByValue lookups instead of
+	// scans.
+	IndexValueHashes bool
+
+	// RequireWarmup starts the cache cold: until Preload or WarmFromKeyList
+	// completes or MarkWarm is called, Get handles misses as ColdBehavior
+	// says. ColdWaitTimeout bounds the wait of ColdWait and defaults to
+	// 100ms. WarmupTarget is the number of keys the warm-up is expected to
+	// load, reported in Stats; it defaults to the number of keys passed to
+	// Preload and WarmFromKeyList.
+	RequireWarmup   bool
+	ColdBehavior    ColdBehavior
+	ColdWaitTimeout time.Duration
+	WarmupTarget    int
 }
 
 type LRU struct {
-	db      *memdb.MemDB
-	size    int
-	opts    Options
-	lock    sync.RWMutex
-	expHeap *expirationHeap
+	db        *memdb.MemDB
+	size      int
+	opts      Options
+	lock      sync.RWMutex
+	expHeap   *expirationHeap
+	stats     cacheStats
+	limiter   *rateLimiter
+	negative  *negativeCache
+	loads     flightGroup // Options.Loader calls in flight
+	repairs   *repairLimiter
+	guard     *cardinalityGuard
+	arena     *itemArena
+	deps      dependencyGraph
+	gens      generations
+	drain     atomic.Int32 // drain mode, see EnterDrainMode
+	deletions deletionLog
+	usedBytes atomic.Int64 // size of the table, tracked while MaxBytes is set
+	sharedMu  sync.Mutex   // serializes SharedSnapshot saves and refreshes
+	sharedGen uint64       // shared snapshot generation last saved or merged
+	warmup    *warmupState // nil unless RequireWarmup is set
+
+	valueHashes *valueHashIndex // nil unless IndexValueHashes is set
+	quiesce     quiesceState
+
+	tombstones *tombstoneBuffer
+	schedules  scheduler
+	lockStats  *lockTracker
+	missFilter *missFilter
+	hitRatio   *hitRatioMonitor
+	groups     *statGroups
+	decisions  decisionLog
+	watermarks *watermarkTracker
+	pins       map[string]int // pin counts by key; guarded by lock
+	watches    watchRegistry
+
+	// done is closed by Close to stop the background goroutines.
+	done      chan struct{}
+	closeOnce sync.Once
+	closed    atomic.Bool
+
+	getChain GetFunc
+	now      func() time.Time
+
+	// epoch anchors the monotonic timeline returned by clock. elapsed, when
+	// set, replaces the monotonic reading of now in tests.
+	epoch     time.Time
+	elapsed   func() time.Duration
+	lastWall  time.Time
+	lastClock time.Time
+
+	// faultHook lets tests inject storage failures; nil outside tests.
+	faultHook func(op, key string) error
+	// mutationHook lets tests check the byte budget after every commit
+	// that changes it; nil outside tests.
+	mutationHook func()
 }
 
 func NewLRUWithTTL(size int, opts Options) (*LRU, error) {
 	if size <= 0 {
 		return nil, errors.New("cache size must be positive")
 	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
 
 	// Define the schema
 	schema := &memdb.DBSchema{
@@ -43,11 +330,36 @@ func NewLRUWithTTL(size int, opts Options) (*LRU, error) {
 						Unique:  true,
 						Indexer: &memdb.StringFieldIndex{Field: "Key"},
 					},
+					"variant": {
+						Name:         "variant",
+						Unique:       true,
+						AllowMissing: true,
+						Indexer: &memdb.CompoundIndex{
+							Indexes: []memdb.Indexer{
+								&memdb.StringFieldIndex{Field: "Base"},
+								&memdb.StringFieldIndex{Field: "Variant"},
+							},
+						},
+					},
+					"base": {
+						Name:         "base",
+						AllowMissing: true,
+						Indexer:      &memdb.StringFieldIndex{Field: "Base"},
+					},
 				},
 			},
 		},
 	}
 
+	var valueHashes *valueHashIndex
+	if opts.IndexValueHashes {
+		valueHashes = &valueHashIndex{hash: hashBytes}
+		schema.Tables["cache"].Indexes["value_hash"] = &memdb.IndexSchema{
+			Name:    "value_hash",
+			Indexer: valueHashes,
+		}
+	}
+
 	// Create a new database
 	db, err := memdb.NewMemDB(schema)
 	if err != nil {
@@ -55,126 +367,859 @@ func NewLRUWithTTL(size int, opts Options) (*LRU, error) {
 	}
 
 	lru := &LRU{
-		db:   db,
-		size: size,
-		opts: opts,
-		expHeap: &expirationHeap{
-			items:     make([]string, 0, size),
-			expiresAt: make(map[string]time.Time),
-		},
+		db:      db,
+		size:    size,
+		opts:    opts,
+		expHeap: newExpirationHeap(size),
+		now:     time.Now,
+		done:    make(chan struct{}),
+
+		valueHashes: valueHashes,
+	}
+	lru.epoch = time.Now()
+	lru.lastWall, lru.lastClock = lru.epoch, lru.epoch
+	if opts.PreExpiryNotice.Callback != nil {
+		lru.expHeap.notices = newNoticeSchedule(opts.PreExpiryNotice.Lead, lru.clock, size)
+	}
+	if opts.Preallocate > 0 {
+		lru.arena = &itemArena{blockSize: opts.Preallocate}
+	}
+	if opts.FairnessGroups != nil {
+		lru.expHeap.fair = newFairness(opts.FairnessGroups, opts.FairnessShares, size)
+	}
+	if len(opts.Watermarks) > 0 {
+		lru.watermarks = newWatermarkTracker(opts.Watermarks)
+	}
+	if opts.StatsKeyGrouper != nil {
+		lru.groups = newStatGroups(opts.StatsKeyGrouper, opts.MaxStatGroups)
+	}
+	if opts.HitRatioAlert.Callback != nil {
+		lru.hitRatio = &hitRatioMonitor{cfg: opts.HitRatioAlert}
+	}
+	if opts.MissFilter.ExpectedItems > 0 {
+		lru.missFilter = newMissFilter(opts.MissFilter)
+	}
+	if opts.TrackLockContention {
+		lru.lockStats = newLockTracker()
+	}
+	if opts.TombstoneRetention.Count > 0 {
+		lru.tombstones = newTombstoneBuffer(opts.TombstoneRetention)
+	}
+	lru.deletions = newDeletionLog(opts.DeletionLogSize)
+	if opts.RequireWarmup {
+		lru.warmup = newWarmupState(opts)
+	}
+	if opts.Loader != nil && opts.NegativeTTL > 0 {
+		lru.negative = newNegativeCache(size)
 	}
+	lru.repairs = newRepairLimiter(size, opts)
+	if opts.SetRateLimit.PerKeyPerSecond > 0 {
+		lru.limiter = newRateLimiter(opts.SetRateLimit)
+	}
+	if g := opts.CardinalityGuard; g.WindowKeys > 0 && g.MaxNewKeyRate > 0 {
+		lru.guard = newCardinalityGuard(g)
+	}
+	lru.getChain = chain(lru.lookup, opts.GetMiddleware)
 
 	go lru.expirationManager()
+	if opts.AuditInterval > 0 {
+		go lru.auditManager()
+	}
 	return lru, nil
 }
 
 func (l *LRU) expirationManager() {
 	ticker := time.NewTicker(1 * time.Minute)
-	for range ticker.C {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-l.done:
+			return
+		}
+		if l.Draining() {
+			continue
+		}
+		l.runSchedules()
 		l.removeExpiredItems()
+		l.firePreExpiry()
+		l.checkWatermarks()
+		l.checkHitRatio()
 	}
 }
 
 func (l *LRU) removeExpiredItems() {
-	l.lock.Lock()
+	l.writeLock("sweep")
 	defer l.lock.Unlock()
 
-	now := time.Now()
-	for l.expHeap.Len() > 0 && l.expHeap.expiresAt[l.expHeap.items[0]].Before(now) {
+	l.checkClock()
+	clock := l.clock()
+	budget := l.sweepBudget(l.now())
+	removed := 0
+	for l.expHeap.Len() > 0 && l.expHeap.deadlines[l.expHeap.items[0]].Before(clock) {
+		if budget.spent(removed, l.now) {
+			break
+		}
 		key := heap.Pop(l.expHeap).(string)
-		l.removeItem(key)
+		if decisionTracing {
+			l.decide("sweep", key, "expire", "deadline passed")
+		}
+		if err := l.removeItem(key, ReasonExpired); err != nil {
+			l.backgroundError(err)
+		}
+		removed++
+	}
+	if l.opts.SweepSchedule.enabled() {
+		l.stats.deferredExpiries.Store(int64(l.expiredBacklog(clock)))
 	}
+	l.reclaimGenerations()
+	l.maybeRebuildFilter()
+	l.enforceObservabilityBudget()
 }
 
 func (l *LRU) Set(key string, value interface{}, ttl time.Duration) error {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	if ttl <= 0 && ttl != NoExpiration {
+		return l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+	if err := l.checkUsefulTTL(ttl); err != nil {
+		return err
+	}
+
+	if l.limiter != nil && !l.limiter.allow(key, l.now()) {
+		if decisionTracing {
+			l.decide("set", key, "reject", "over the per-key rate limit")
+		}
+		l.stats.rateLimitedSets.Add(1)
+		if l.opts.SetRateLimit.RefreshTTL {
+			return l.refreshTTL(key, ttl)
+		}
+		return ErrRateLimited
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	return l.set(key, value, ttl, nil)
+}
+
+// SetEx is Set for callers that compute TTLs from upstream deadlines: a write
+// skipped because of Options.MinUsefulTTL reports stored=false with a nil
+// error instead of ErrNotStored.
+func (l *LRU) SetEx(key string, value interface{}, ttl time.Duration) (stored bool, err error) {
+	err = l.Set(key, value, ttl)
+	if errors.Is(err, ErrNotStored) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetDefault stores value under key like Set, with Options.DefaultTTL as
+// the TTL. Without a DefaultTTL it fails with ErrInvalidArgument.
+func (l *LRU) SetDefault(key string, value interface{}) error {
+	if l.opts.DefaultTTL == 0 {
+		return l.invalid(l.NormalizeKey(key), "SetDefault needs Options.DefaultTTL to be set")
+	}
+	return l.Set(key, value, l.opts.DefaultTTL)
+}
+
+// SetWithExpiry stores value under key like Set, but until the wall clock
+// time expiresAt instead of for a TTL; the entry's ExpiresAt is exactly
+// expiresAt. Like every deadline it follows Options.ClockJumpPolicy when the
+// clock is stepped. A time that is not in the future is rejected.
+func (l *LRU) SetWithExpiry(key string, value interface{}, expiresAt time.Time) error {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	ttl := expiresAt.Sub(l.now())
 	if ttl <= 0 {
-		return errors.New("ttl must be positive")
+		return l.invalid(key, fmt.Sprintf("expiresAt %v is not in the future", expiresAt))
+	}
+	if err := l.checkUsefulTTL(ttl); err != nil {
+		return err
 	}
 
-	l.lock.Lock()
+	data, err := serialize(value)
+	if err != nil {
+		return fmt.Errorf("failed to serialize value: %v", err)
+	}
+
+	l.writeLock("set")
 	defer l.lock.Unlock()
 
-	expiresAt := time.Now().Add(ttl)
+	item := l.newItem(key, data, ttl)
+	item.ExpiresAt, item.deadline = l.expiryAt(expiresAt)
+	return l.store(item, nil)
+}
+
+// GetOrSet returns the live value of key with loaded=true or, when there is
+// none, stores value with ttl and returns it with loaded=false, all under one
+// write lock, so concurrent callers agree on a single stored value. Finding
+// the entry counts as a hit and a read of a read-limited entry; storing
+// counts as a miss. When MinUsefulTTL or CardinalityGuard keeps value from
+// being stored, it is still returned with loaded=false and a nil error.
+func (l *LRU) GetOrSet(key string, value interface{}, ttl time.Duration) (actual interface{}, loaded bool, err error) {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	if ttl <= 0 && ttl != NoExpiration {
+		return nil, false, l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+
+	actual, loaded, err = l.getOrSet(key, value, ttl)
+	switch {
+	case loaded:
+		l.stats.hits.Add(1)
+		l.groups.hit(key)
+	case err == nil:
+		l.stats.misses.Add(1)
+		l.groups.miss(key)
+	}
+	return actual, loaded, err
+}
+
+// Add stores value under key only if key holds no live entry and reports
+// whether it did. An expired entry, or one whose read limit is used up, is
+// replaced; a live entry is left exactly as it is, without even counting as
+// a read. The check and the write happen under one write lock, so of
+// concurrent Adds of a key exactly one succeeds.
+func (l *LRU) Add(key string, value interface{}, ttl time.Duration) (bool, error) {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	if ttl <= 0 && ttl != NoExpiration {
+		return false, l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+	if err := l.checkUsefulTTL(ttl); err != nil {
+		return false, err
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw != nil {
+		item := raw.(*CacheItem)
+		if !item.expired(l.clock()) && (item.reads == nil || item.reads.Load() > 0) {
+			return false, nil
+		}
+	}
+	if err := l.set(key, value, ttl, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Replace stores value under key with a new ttl only if key holds a live
+// entry, returning ErrItemNotFound otherwise, so it never brings back an entry
+// that has expired or been removed. Like Add, the check and the write happen
+// under one write lock.
+func (l *LRU) Replace(key string, value interface{}, ttl time.Duration) error {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	if ttl <= 0 && ttl != NoExpiration {
+		return l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+	if err := l.checkUsefulTTL(ttl); err != nil {
+		return err
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		return ErrItemNotFound
+	}
+	if item := raw.(*CacheItem); item.expired(l.clock()) || (item.reads != nil && item.reads.Load() <= 0) {
+		return ErrItemNotFound
+	}
+	return l.set(key, value, ttl, nil)
+}
+
+// CompareAndSwap stores new under key with a new ttl only if key holds a live
+// entry whose value equals old, and reports whether it did. The values are
+// compared in their serialized form, so old must be of a type that
+// serializes the same way the stored value did: an int does not match an
+// int64, and a struct matches the map it was decoded into only if its
+// fields are in key order. Missing and expired keys report false.
+func (l *LRU) CompareAndSwap(key string, old, new interface{}, ttl time.Duration) (bool, error) {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	if ttl <= 0 && ttl != NoExpiration {
+		return false, l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+	if err := l.checkUsefulTTL(ttl); err != nil {
+		return false, err
+	}
+	want, err := serialize(old)
+	if err != nil {
+		return false, fmt.Errorf("failed to serialize value: %v", err)
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		return false, nil
+	}
+	item := raw.(*CacheItem)
+	if item.expired(l.clock()) || (item.reads != nil && item.reads.Load() <= 0) || !bytes.Equal(item.Value, want) {
+		return false, nil
+	}
+	if err := l.set(key, new, ttl, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getOrSet is GetOrSet without the hit and miss counting.
+func (l *LRU) getOrSet(key string, value interface{}, ttl time.Duration) (actual interface{}, loaded bool, err error) {
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw != nil && !raw.(*CacheItem).expired(l.clock()) {
+		item := raw.(*CacheItem)
+		existing, err := l.decode(item.Value)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to deserialize value: %w", err)
+		}
+		switch err := item.consumeRead(); err {
+		case nil, errLastRead:
+			item.recordAccess(l.now())
+			if err == errLastRead && !l.Draining() {
+				if rmErr := l.removeItem(key, ReasonConsumed); rmErr != nil && l.opts.StrictErrors {
+					return nil, false, fmt.Errorf("failed to remove consumed item: %w", rmErr)
+				}
+			}
+			return existing, true, nil
+		}
+	}
+
+	if err := l.checkUsefulTTL(ttl); err != nil {
+		return value, false, nil
+	}
+	if err := l.set(key, value, ttl, nil); err != nil {
+		if errors.Is(err, ErrNotStored) {
+			return value, false, nil
+		}
+		return nil, false, err
+	}
+	return value, false, nil
+}
+
+// checkUsefulTTL returns an error wrapping ErrNotStored, and counts the
+// skipped write, when ttl is below Options.MinUsefulTTL.
+func (l *LRU) checkUsefulTTL(ttl time.Duration) error {
+	if ttl >= l.opts.MinUsefulTTL || ttl == NoExpiration {
+		return nil
+	}
+	if decisionTracing {
+		l.decide("set", "", "reject", "ttl %v below MinUsefulTTL %v", ttl, l.opts.MinUsefulTTL)
+	}
+	l.stats.notStoredSets.Add(1)
+	return fmt.Errorf("%w: ttl %v is below the minimum useful ttl %v", ErrNotStored, ttl, l.opts.MinUsefulTTL)
+}
+
+// set stores value under key. Entries derived from key are invalidated and,
+// unless deps is given, key stops being a derived entry itself. The caller
+// must hold the write lock.
+func (l *LRU) set(key string, value interface{}, ttl time.Duration, deps []string) error {
 	data, err := serialize(value)
 	if err != nil {
 		return fmt.Errorf("failed to serialize value: %v", err)
 	}
+	return l.store(l.newItem(key, data, ttl), deps)
+}
 
-	item := &CacheItem{Key: key, Value: data, ExpiresAt: expiresAt}
+// store is set for an item built from an already serialized value.
+func (l *LRU) store(item *CacheItem, deps []string) error {
+	key := item.Key
+	if key == "" {
+		return l.invalid(key, "key must not be empty")
+	}
+	if err := l.checkWritable(key); err != nil {
+		return err
+	}
+	if err := l.admitKey(key); err != nil {
+		return err
+	}
+	if err := l.checkReplace(item); err != nil {
+		return err
+	}
+	if err := l.makeRoom(entryBytes(key, item.Value)-l.rowBytes(key), key); err != nil {
+		return err
+	}
+	l.filterAdd(key)
 
+	// Recounted, as making room can cascade to the entry being replaced.
+	growth := entryBytes(key, item.Value) - l.rowBytes(key)
 	txn := l.db.Txn(true)
+	l.stampModified(txn, item)
+	prev := l.previous(txn, key)
 	if err := txn.Insert("cache", item); err != nil {
 		txn.Abort()
 		return fmt.Errorf("failed to insert item: %v", err)
 	}
 	txn.Commit()
+	l.resized(growth)
+	l.retire(prev)
+	l.groups.set(key)
+	l.notifyWatchers(key, item.Value)
 
-	l.expHeap.expiresAt[key] = expiresAt
-	heap.Push(l.expHeap, key)
+	if decisionTracing {
+		l.decide("set", key, "admit", "stored with ttl %v", item.ttl())
+		if _, queued := l.expHeap.index[key]; queued {
+			l.decide("set", key, "heap", "fix existing entry")
+		} else {
+			l.decide("set", key, "heap", "push new entry")
+		}
+	}
+	l.expHeap.set(key, item.deadline)
+	l.deps.forget(key)
+	l.invalidateDependents(key)
+	if deps != nil {
+		l.deps.set(key, deps)
+	}
+	if err := l.evictOverCapacity(); err != nil && l.opts.StrictErrors {
+		return err
+	}
 
-	// Evict if over capacity
-	for l.expHeap.Len() > l.size {
-		evictKey := heap.Pop(l.expHeap).(string)
-		l.removeItem(evictKey)
+	l.log("debug", "Set key: %s, TTL: %v", l.redact(key), item.ttl())
+	return nil
+}
+
+// SetPreservingTTL replaces the value of a live entry while keeping its
+// deadline and heap position exactly as they are. It returns ErrItemNotFound
+// if the key is missing or already expired; it never creates an entry.
+func (l *LRU) SetPreservingTTL(key string, value interface{}) error {
+	key = l.NormalizeKey(key)
+	if err := l.checkWritable(key); err != nil {
+		return err
+	}
+	data, err := serialize(value)
+	if err != nil {
+		return fmt.Errorf("failed to serialize value: %v", err)
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil || raw.(*CacheItem).expired(l.clock()) {
+		return ErrItemNotFound
+	}
+	if err := l.checkMutable(raw.(*CacheItem)); err != nil {
+		return err
+	}
+	growth := int64(len(data) - len(raw.(*CacheItem).Value))
+	if err := l.makeRoom(growth, key); err != nil {
+		return err
+	}
+
+	txn := l.db.Txn(true)
+	// Making room can cascade to entries derived from the evicted ones.
+	if cur, err := txn.First("cache", "id", key); err != nil || cur != raw {
+		txn.Abort()
+		return ErrItemNotFound
 	}
+	item := l.copyItem(raw.(*CacheItem))
+	item.Value, item.LastModified = data, l.modifiedAfter(raw.(*CacheItem))
+	if err := txn.Insert("cache", item); err != nil {
+		txn.Abort()
+		return fmt.Errorf("failed to insert item: %v", err)
+	}
+	txn.Commit()
+	l.resized(growth)
+	l.retire(raw.(*CacheItem))
+	l.groups.set(key)
+	l.notifyWatchers(key, data)
+	l.invalidateDependents(key)
 
-	l.log("debug", "Set key: %s, TTL: %v", key, ttl)
+	l.log("debug", "Set key preserving TTL: %s", l.redact(key))
 	return nil
 }
 
 func (l *LRU) Get(key string) (interface{}, error) {
-	l.lock.RLock()
+	return l.GetContext(context.Background(), key)
+}
+
+// Peek returns the value stored under key like Get, but without side
+// effects: expired entries are reported with ErrItemExpired and left for the
+// sweep, reads are not recorded or counted in Stats, read limits are not
+// consumed and GetMiddleware does not run. It only takes the read lock.
+func (l *LRU) Peek(key string) (interface{}, error) {
+	return l.peek(l.NormalizeKey(key))
+}
+
+// peek is Peek for a normalized key.
+func (l *LRU) peek(key string) (interface{}, error) {
+	l.readLock("get")
 	defer l.lock.RUnlock()
 
-	txn := l.db.Txn(false)
-	raw, err := txn.First("cache", "id", key)
+	raw, err := l.db.Txn(false).First("cache", "id", key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve item: %v", err)
 	}
 	if raw == nil {
 		return nil, ErrItemNotFound
 	}
-
 	item := raw.(*CacheItem)
-	if time.Now().After(item.ExpiresAt) {
-		l.removeItem(key)
+	if item.expired(l.clock()) {
 		return nil, ErrItemExpired
 	}
+	if item.reads != nil && item.reads.Load() <= 0 {
+		return nil, ErrItemNotFound
+	}
+	value, err := l.decode(item.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize value: %w", err)
+	}
+	return value, nil
+}
+
+// Contains reports whether key holds a live entry. Like Peek it has no side
+// effects, and it never decodes the value.
+func (l *LRU) Contains(key string) bool {
+	key = l.NormalizeKey(key)
+	l.readLock("get")
+	defer l.lock.RUnlock()
 
-	value, err := deserialize(item.Value)
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil || raw == nil {
+		return false
+	}
+	item := raw.(*CacheItem)
+	return !item.expired(l.clock()) && (item.reads == nil || item.reads.Load() > 0)
+}
+
+// GetContext runs a Get through Options.GetMiddleware, falling back to
+// Options.Loader on a miss. A miss under WithMissCollector is recorded in
+// the collector instead of being loaded, and one under
+// WithSnapshotGeneration is returned as it is. While a cache created with
+// RequireWarmup is cold, misses follow Options.ColdBehavior: under
+// ColdReject and ColdWait a miss that remains one returns ErrColdStart
+// without calling Loader. An entry whose stored value fails to decode is
+// removed and the read treated as a miss, so that Loader, when set, stores
+// a fresh value in the same call; see Options.ReadRepairLimit.
+func (l *LRU) GetContext(ctx context.Context, key string) (interface{}, error) {
+	key = l.NormalizeKey(key)
+	cold := !l.Warmed() && l.warmup.behavior != ColdServe && snapshotGenerationFrom(ctx) == 0
+	if cold && l.warmup.behavior == ColdWait {
+		l.waitCold(ctx, key)
+	}
+	value, err := l.getChain(ctx, key)
+	if err != ErrItemNotFound && err != ErrItemExpired {
+		return value, err
+	}
+	if cold && !l.Warmed() {
+		return nil, ErrColdStart
+	}
+	if c := missCollectorFrom(ctx); c != nil && c.record(l, key) {
+		return value, err
+	}
+	if l.opts.Loader != nil && !l.Draining() && snapshotGenerationFrom(ctx) == 0 {
+		return l.readThrough(key)
+	}
+	return value, err
+}
+
+// GetWithExpiration returns the value stored under key together with the
+// entry's ExpiresAt, zero if it never expires, both taken from one locked
+// read. Under Options.SlidingTTL it is the ExpiresAt the read slid to.
+// Missing and expired entries are reported, counted and removed exactly as
+// Get does, but GetWithExpiration does not run GetMiddleware or
+// Options.Loader.
+func (l *LRU) GetWithExpiration(key string) (interface{}, time.Time, error) {
+	key = l.NormalizeKey(key)
+	r, err := l.lookupEntry(context.Background(), key, time.Time{})
+	return r.value, r.expiresAt, err
+}
+
+// readResult is what one locked read of an entry found.
+type readResult struct {
+	value        interface{}
+	expiresAt    time.Time
+	lastModified time.Time
+	// unchanged is set when the entry was not modified after the time the
+	// read was conditional on; value is then not decoded.
+	unchanged bool
+	// corrupt is the entry read when its value failed to decode for another
+	// reason than the decode limits.
+	corrupt *CacheItem
+}
+
+// lookup is the innermost GetFunc of the middleware chain.
+func (l *LRU) lookup(ctx context.Context, key string) (interface{}, error) {
+	r, err := l.lookupEntry(ctx, key, time.Time{})
+	return r.value, err
+}
+
+// lookupEntry is lookup returning everything the read found. A non-zero
+// modifiedSince makes the read conditional, as get describes.
+func (l *LRU) lookupEntry(ctx context.Context, key string, modifiedSince time.Time) (readResult, error) {
+	r, err := l.get(key, snapshotGenerationFrom(ctx), modifiedSince)
+	if r.corrupt != nil {
+		switch l.readRepair(key, r.corrupt, r.lastModified) {
+		case repairRemoved:
+			err = ErrItemNotFound
+		case repairRewritten:
+			// A write landed between the failed decode and the repair; read
+			// what it stored, once.
+			r, err = l.get(key, snapshotGenerationFrom(ctx), modifiedSince)
+		}
+	}
+	if err := l.finishRead(key, err); err != nil {
+		return readResult{value: r.value}, err
+	}
+	r.expiresAt = l.slide(key, r.expiresAt)
+	return r, nil
+}
+
+// finishRead does the bookkeeping of a read of key that ended with err: it
+// counts the hit or miss and removes the entry if the read used up its last
+// allowed read or found it expired. It returns nil for a read that used up
+// the last one, and err otherwise unless StrictErrors surfaces a failed
+// removal instead.
+func (l *LRU) finishRead(key string, err error) error {
+	if err == errLastRead {
+		if rmErr := l.removeConsumed(key); rmErr != nil && l.opts.StrictErrors {
+			return fmt.Errorf("failed to remove consumed item: %w", rmErr)
+		}
+		err = nil
+	}
+	switch err {
+	case nil:
+		l.stats.hits.Add(1)
+		l.groups.hit(key)
+	case ErrItemNotFound, ErrItemExpired:
+		l.stats.misses.Add(1)
+		l.groups.miss(key)
+	}
+	if err == ErrItemExpired {
+		if rmErr := l.removeExpired(key); rmErr != nil && l.opts.StrictErrors {
+			return fmt.Errorf("failed to remove expired item: %w", rmErr)
+		}
+	}
+	return err
+}
+
+// get looks key up. A positive since is a SnapshotGeneration token: entries
+// written, modified or deleted since then return ErrSnapshotStale. When
+// modifiedSince is not zero and the entry's LastModified is not after it,
+// the value is neither decoded nor counted against a read limit, and the
+// result is marked unchanged.
+func (l *LRU) get(key string, since uint64, modifiedSince time.Time) (readResult, error) {
+	// The filter cannot tell a key deleted since the snapshot from one that
+	// never existed, so snapshot reads look in the table.
+	if since == 0 && l.definitelyMissing(key) {
+		return readResult{}, ErrItemNotFound
+	}
+
+	l.readLock("get")
+	defer l.lock.RUnlock()
+
+	txn := l.db.Txn(false)
+	raw, err := txn.First("cache", "id", key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to deserialize value: %v", err)
+		return readResult{}, fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		if since > 0 && l.deletions.deletedSince(key, since) {
+			return readResult{}, ErrSnapshotStale
+		}
+		if l.missFilter != nil && since == 0 {
+			l.stats.filterFalsePositives.Add(1)
+		}
+		return readResult{}, ErrItemNotFound
 	}
 
-	l.log("debug", "Get key: %s", key)
-	return value, nil
+	item := raw.(*CacheItem)
+	if since > 0 && item.gen >= since {
+		return readResult{}, ErrSnapshotStale
+	}
+	if item.expired(l.clock()) && l.drain.Load() != drainStale {
+		return readResult{}, ErrItemExpired
+	}
+	r := readResult{expiresAt: item.ExpiresAt, lastModified: item.LastModified}
+	if !modifiedSince.IsZero() && !item.LastModified.After(modifiedSince) {
+		if item.reads != nil && item.reads.Load() <= 0 {
+			return readResult{}, ErrItemNotFound
+		}
+		item.recordAccess(l.now())
+		r.unchanged = true
+		return r, nil
+	}
+	item.recordAccess(l.now())
+
+	if r.value, err = l.decode(item.Value); err != nil {
+		if repairable(err) {
+			r.corrupt = item
+		}
+		return r, fmt.Errorf("failed to deserialize value: %w", err)
+	}
+	if err := item.consumeRead(); err != nil {
+		return r, err
+	}
+
+	l.log("debug", "Get key: %s", l.redact(key))
+	return r, nil
 }
 
+// Delete removes key and its variants. A key with neither returns
+// ErrItemNotFound.
 func (l *LRU) Delete(key string) error {
-	l.lock.Lock()
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+	l.writeLock("delete")
 	defer l.lock.Unlock()
+	return l.deleteKey(key)
+}
+
+// Pop returns the value stored under key and deletes the entry in one step
+// under the write lock, so among consumers racing each other and the sweep
+// exactly one receives it. Like Delete it also drops the variants of key. An
+// expired entry is removed as the sweep would remove it and ErrItemExpired
+// returned; a missing key, or one whose reads are used up, returns
+// ErrItemNotFound.
+func (l *LRU) Pop(key string) (interface{}, error) {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+	l.writeLock("delete")
+	defer l.lock.Unlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		return nil, ErrItemNotFound
+	}
+	item := raw.(*CacheItem)
+	if item.expired(l.clock()) {
+		if !l.Draining() {
+			reason := ReasonExpired
+			if item.gens.stale(item.gen) {
+				reason = ReasonGeneration
+			}
+			if err := l.removeItem(key, reason); err != nil && l.opts.StrictErrors {
+				return nil, err
+			}
+		}
+		return nil, ErrItemExpired
+	}
+	if item.reads != nil && item.reads.Load() <= 0 {
+		return nil, ErrItemNotFound
+	}
+
+	value, err := l.decode(item.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize value: %w", err)
+	}
+	if err := l.deleteKey(key); err != nil {
+		return nil, err
+	}
+	l.log("debug", "Popped key: %s", l.redact(key))
+	return value, nil
+}
 
+// deleteKey removes key and its variants without firing the removal
+// callbacks. The caller must hold the write lock.
+func (l *LRU) deleteKey(key string) error {
 	txn := l.db.Txn(true)
-	if _, err := txn.First("cache", "id", key); err != nil {
+	raw, err := txn.First("cache", "id", key)
+	if err != nil {
 		txn.Abort()
 		return fmt.Errorf("failed to find item: %v", err)
-	} else {
-		if err := txn.Delete("cache", &CacheItem{Key: key}); err != nil {
+	}
+
+	// Deleting a key also drops every variant stored under it.
+	var removed []string
+	if raw != nil {
+		removed = append(removed, key)
+	}
+	variants, err := variantKeys(txn, key)
+	if err != nil {
+		txn.Abort()
+		return err
+	}
+	removed = append(removed, variants...)
+	if len(removed) == 0 {
+		txn.Abort()
+		return ErrItemNotFound
+	}
+	var retired []*CacheItem
+	var freed int64
+	for _, k := range removed {
+		retired = append(retired, l.previous(txn, k))
+		freed += l.rowBytes(k)
+		if err := txn.Delete("cache", &CacheItem{Key: k}); err != nil {
 			txn.Abort()
 			return fmt.Errorf("failed to delete item: %v", err)
 		}
 	}
 	txn.Commit()
+	l.resized(-freed)
+	l.retire(retired...)
+	l.filterRemoved(len(removed))
 
-	delete(l.expHeap.expiresAt, key)
-	l.log("debug", "Deleted key: %s", key)
+	for _, k := range removed {
+		l.expHeap.remove(k)
+		l.closeWatchers(k)
+		l.recordDeletion(k)
+		l.deps.forget(k)
+		l.invalidateDependents(k)
+	}
+	l.log("debug", "Deleted key: %s", l.redact(key))
 	return nil
 }
 
+// ClearOptions controls which entries survive ClearWithOptions.
+type ClearOptions struct {
+	// KeepMatching, when set, retains every entry whose key it returns true for.
+	KeepMatching func(key string) bool
+	// KeepPinned retains every entry whose key is pinned with PinCtx.
+	KeepPinned bool
+}
+
 func (l *LRU) Clear() error {
-	l.lock.Lock()
+	return l.ClearWithOptions(ClearOptions{})
+}
+
+// ClearWithOptions removes every entry except those selected by opts. Retained
+// entries keep their value and deadline, and the expiration heap is rebuilt
+// from them, so retained entries that expire are still swept.
+func (l *LRU) ClearWithOptions(opts ClearOptions) error {
+	defer l.checkWatermarks()
+	l.writeLock("delete")
 	defer l.lock.Unlock()
 
+	return l.clear(opts)
+}
+
+// clear is ClearWithOptions for a caller that holds the write lock.
+func (l *LRU) clear(opts ClearOptions) error {
 	txn := l.db.Txn(true)
 	raw, err := txn.Get("cache", "id")
 	if err != nil {
@@ -182,53 +1227,387 @@ func (l *LRU) Clear() error {
 		return fmt.Errorf("failed to get all items: %v", err)
 	}
 
+	var kept, deleted []*CacheItem
+	var freed int64
 	for obj := raw.Next(); obj != nil; obj = raw.Next() {
 		item := obj.(*CacheItem)
+		if (opts.KeepMatching != nil && opts.KeepMatching(item.Key)) || (opts.KeepPinned && l.pins[item.Key] > 0) {
+			kept = append(kept, item)
+			continue
+		}
 		if err := txn.Delete("cache", item); err != nil {
 			txn.Abort()
 			return fmt.Errorf("failed to delete item: %v", err)
 		}
+		deleted = append(deleted, item)
+		freed += entryBytes(item.Key, item.Value)
 	}
 	txn.Commit()
+	l.resized(-freed)
 
-	l.expHeap.items = l.expHeap.items[:0]
-	l.expHeap.expiresAt = make(map[string]time.Time)
+	l.rebuildHeap(kept)
+	l.rebuildFilter()
+	for _, item := range deleted {
+		l.closeWatchers(item.Key)
+		l.recordDeletion(item.Key)
+		l.deps.forget(item.Key)
+	}
+	for _, item := range deleted {
+		l.invalidateDependents(item.Key)
+	}
+	l.retire(deleted...)
 
-	l.log("info", "Cache cleared")
+	l.log("info", "Cache cleared, %d entries kept", len(kept))
 	return nil
 }
 
 func (l *LRU) Len() int {
-	l.lock.RLock()
+	count, err := l.LenE()
+	if err != nil {
+		l.log("error", "Len failed: %v", err)
+		return 0
+	}
+	return count
+}
+
+// LenE is Len with the lookup failure returned instead of logged.
+func (l *LRU) LenE() (int, error) {
+	l.readLock("scan")
 	defer l.lock.RUnlock()
 
+	if err := l.fault("scan", ""); err != nil {
+		return 0, fmt.Errorf("failed to get cache size: %w", err)
+	}
 	txn := l.db.Txn(false)
 	it, err := txn.Get("cache", "id")
 	if err != nil {
-		l.log("error", "Failed to get cache size: %v", err)
-		return 0
+		return 0, fmt.Errorf("failed to get cache size: %w", err)
 	}
 	count := 0
 	for obj := it.Next(); obj != nil; obj = it.Next() {
 		count++
 	}
-	return count
+	return count, nil
 }
 
-func (l *LRU) removeItem(key string) {
+// Keys returns the keys of the live entries in key order. Expired entries
+// the sweep has not removed yet are left out, as are variants, which
+// Variants lists.
+func (l *LRU) Keys() []string {
+	l.readLock("scan")
+	defer l.lock.RUnlock()
+
+	it, err := l.db.Txn(false).Get("cache", "id")
+	if err != nil {
+		l.log("error", "Failed to list keys: %v", err)
+		return nil
+	}
+
+	clock := l.clock()
+	var keys []string
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		item := obj.(*CacheItem)
+		if item.Variant != "" || item.expired(clock) || (item.reads != nil && item.reads.Load() <= 0) {
+			continue
+		}
+		keys = append(keys, item.Key)
+	}
+	return keys
+}
+
+// CacheEntry is one entry of an Items snapshot.
+type CacheEntry struct {
+	Value        interface{}
+	ExpiresAt    time.Time // zero if the entry never expires
+	LastModified time.Time
+	Immutable    bool // stored with SetImmutable
+}
+
+// Items returns a copy of every live entry, read in one transaction so that
+// concurrent writes never show half applied. Values are decoded afresh and
+// the map belongs to the caller. Like Keys it leaves out expired entries and
+// variants; entries whose value fails to decode are logged and skipped.
+func (l *LRU) Items() map[string]CacheEntry {
+	l.readLock("scan")
+	defer l.lock.RUnlock()
+
+	it, err := l.db.Txn(false).Get("cache", "id")
+	if err != nil {
+		l.log("error", "Failed to list items: %v", err)
+		return nil
+	}
+
+	clock := l.clock()
+	items := make(map[string]CacheEntry)
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		item := obj.(*CacheItem)
+		if item.Variant != "" || item.expired(clock) || (item.reads != nil && item.reads.Load() <= 0) {
+			continue
+		}
+		value, err := l.decode(item.Value)
+		if err != nil {
+			l.log("error", "Failed to deserialize value of key %s: %v", l.redact(item.Key), err)
+			continue
+		}
+		items[item.Key] = CacheEntry{Value: value, ExpiresAt: item.ExpiresAt, LastModified: item.LastModified, Immutable: item.Immutable}
+	}
+	return items
+}
+
+// KV is one entry of a GetRange result.
+type KV struct {
+	Key   string
+	Value interface{}
+}
+
+// GetRange returns the live entries with keys in [fromKey, toKey) in key
+// order, at most limit of them unless limit is 0. An empty toKey means no
+// upper bound; both bounds are normalized like keys. The range is walked in
+// the key index in one transaction under the read lock, so it is a
+// consistent snapshot and never takes the write lock. Like Items it leaves
+// out expired entries and variants, and it does not count as reads of the
+// entries.
+func (l *LRU) GetRange(fromKey, toKey string, limit int) ([]KV, error) {
+	fromKey = l.NormalizeKey(fromKey)
+	if toKey != "" {
+		toKey = l.NormalizeKey(toKey)
+	}
+	return l.scanRange(fromKey, toKey, limit)
+}
+
+// KeysWithValues returns the live entries in key order, at most limit of
+// them unless limit is 0, read in one transaction, so unlike Keys followed
+// by Gets it never mixes in later changes. See SnapshotGeneration for
+// reading entries one at a time instead.
+func (l *LRU) KeysWithValues(limit int) ([]KV, error) {
+	return l.scanRange("", "", limit)
+}
+
+func (l *LRU) scanRange(fromKey, toKey string, limit int) ([]KV, error) {
+	if limit < 0 {
+		return nil, l.invalid(fromKey, "limit must not be negative")
+	}
+	l.readLock("scan")
+	defer l.lock.RUnlock()
+
+	it, err := l.db.Txn(false).LowerBound("cache", "id", fromKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan range: %v", err)
+	}
+
+	clock := l.clock()
+	var kvs []KV
+	for obj := it.Next(); obj != nil && (limit == 0 || len(kvs) < limit); obj = it.Next() {
+		item := obj.(*CacheItem)
+		if toKey != "" && item.Key >= toKey {
+			break
+		}
+		if item.Variant != "" || item.expired(clock) || (item.reads != nil && item.reads.Load() <= 0) {
+			continue
+		}
+		value, err := l.decode(item.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize value of key %s: %w", item.Key, err)
+		}
+		kvs = append(kvs, KV{Key: item.Key, Value: value})
+	}
+	return kvs, nil
+}
+
+// removeExpired removes key if it is still expired once the write lock is
+// held; a concurrent Set may have replaced it in the meantime.
+func (l *LRU) removeExpired(key string) error {
+	if l.Draining() {
+		return nil
+	}
+	defer l.checkWatermarks()
+	if !l.tryWriteLock("delete") {
+		return nil
+	}
+	defer l.lock.Unlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil || !raw.(*CacheItem).expired(l.clock()) {
+		return nil
+	}
+	if item := raw.(*CacheItem); item.gens.stale(item.gen) {
+		return l.removeItem(key, ReasonGeneration)
+	}
+	return l.removeItem(key, ReasonExpired)
+}
+
+// removeItem deletes key, fires the removal callbacks and invalidates the
+// entries derived from it. The caller must hold the write lock.
+func (l *LRU) removeItem(key string, reason EvictReason) error {
+	if err := l.fault("delete", key); err != nil {
+		l.log("error", "Failed to remove item: %v", err)
+		return err
+	}
 	txn := l.db.Txn(true)
-	if err := txn.Delete("cache", &CacheItem{Key: key}); err != nil {
+	raw, err := txn.First("cache", "id", key)
+	if err == nil && raw == nil {
+		err = memdb.ErrNotFound
+	}
+	if err == nil {
+		err = txn.Delete("cache", raw)
+	}
+	if err != nil {
 		txn.Abort()
 		l.log("error", "Failed to remove item: %v", err)
-		return
+		return fmt.Errorf("failed to remove item %s: %w", key, err)
 	}
 	txn.Commit()
 
-	delete(l.expHeap.expiresAt, key)
+	item := raw.(*CacheItem)
+	l.resized(-entryBytes(key, item.Value))
+	l.expHeap.remove(key)
+	l.filterRemoved(1)
+	l.groups.evicted(key)
 
 	if l.opts.EvictCallback != nil {
 		l.opts.EvictCallback(key, nil)
 	}
+	if l.opts.RemovalCallback != nil {
+		value, _ := l.decode(item.Value)
+		l.opts.RemovalCallback(key, value, reason)
+	}
+	if l.tombstones != nil {
+		l.tombstones.add(item, reason, l.now())
+		l.enforceObservabilityBudget()
+	}
+	l.retire(item)
+
+	l.closeWatchers(key)
+	l.recordDeletion(key)
+	l.deps.forget(key)
+	l.invalidateDependents(key)
+	return nil
+}
+
+// evictOverCapacity removes the unpinned entries closest to expiring until the
+// cache fits its size or only pinned entries are left. Unless
+// EvictVariantsSeparately is set, evicting one variant evicts all unpinned
+// variants of the same key. It keeps going past failed removals and returns
+// the first one. The caller must hold the lock.
+func (l *LRU) evictOverCapacity() error {
+	var firstErr error
+	var pinned []string
+	var held map[string]int // entries of pinned by fairness group
+	defer func() {
+		for _, key := range pinned {
+			l.expHeap.set(key, l.expHeap.deadlines[key])
+		}
+	}()
+	hold := func(reason string) {
+		key := heap.Pop(l.expHeap).(string)
+		if decisionTracing {
+			l.decide("set", key, "victim", "skipped, %s", reason)
+		}
+		if fair := l.expHeap.fair; fair != nil {
+			if held == nil {
+				held = make(map[string]int)
+			}
+			held[fair.groupOf(key)]++
+		}
+		pinned = append(pinned, key)
+	}
+	for l.expHeap.Len() > 0 && l.expHeap.Len()+len(pinned) > l.size {
+		if l.pins[l.expHeap.items[0]] > 0 {
+			hold("pinned")
+			continue
+		}
+		if l.expHeap.fair.protected(l.expHeap.items[0], held) {
+			hold("group at its reserved share")
+			continue
+		}
+		if decisionTracing {
+			l.decide("set", l.expHeap.items[0], "victim", "earliest deadline of %d entries, capacity %d", l.expHeap.Len()+len(pinned), l.size)
+		}
+		evictKey := heap.Pop(l.expHeap).(string)
+
+		var siblings []string
+		if !l.opts.EvictVariantsSeparately {
+			txn := l.db.Txn(false)
+			if raw, err := txn.First("cache", "id", evictKey); err == nil && raw != nil && raw.(*CacheItem).Base != "" {
+				siblings, _ = variantKeys(txn, raw.(*CacheItem).Base)
+			}
+		}
+
+		if err := l.removeItem(evictKey, ReasonCapacity); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to evict: %w", err)
+		}
+		for _, sibling := range siblings {
+			if sibling == evictKey || l.pins[sibling] > 0 {
+				continue
+			}
+			if decisionTracing {
+				l.decide("set", sibling, "victim", "variant of evicted %s", l.redact(evictKey))
+			}
+			if err := l.removeItem(sibling, ReasonCapacity); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to evict: %w", err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// rebuildHeap replaces the expiration heap with exactly one entry per item.
+func (l *LRU) rebuildHeap(items []*CacheItem) {
+	keys := make([]string, len(items))
+	deadlines := make(map[string]time.Time, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+		deadlines[item.Key] = item.deadline
+	}
+	l.installHeap(heapOf(l.size, keys, deadlines))
+}
+
+// heapOf returns a heap of keys ordered by deadlines, which it takes over. It
+// touches nothing else, so it can be built without the lock.
+func heapOf(size int, keys []string, deadlines map[string]time.Time) *expirationHeap {
+	h := newExpirationHeap(size)
+	h.deadlines = deadlines
+	for i, key := range keys {
+		h.items = append(h.items, key)
+		h.index[key] = i
+	}
+	heap.Init(h)
+	return h
+}
+
+// installHeap replaces the expiration heap with h, keeping the notice
+// schedule and recounting the fairness groups. The caller must hold the
+// write lock.
+func (l *LRU) installHeap(h *expirationHeap) {
+	h.notices, h.fair = l.expHeap.notices, l.expHeap.fair
+	h.fair.reset()
+	for _, key := range h.items {
+		h.fair.add(key)
+	}
+	l.expHeap = h
+}
+
+// backgroundError records a failure from a background pass. Outside strict
+// mode it has already been logged and nothing else happens.
+func (l *LRU) backgroundError(err error) {
+	if !l.opts.StrictErrors {
+		return
+	}
+	l.stats.sweepErrors.Add(1)
+	if l.opts.OnError != nil {
+		l.opts.OnError(err)
+	}
+}
+
+// fault returns the failure injected by a test for op, if any.
+func (l *LRU) fault(op, key string) error {
+	if l.faultHook == nil {
+		return nil
+	}
+	return l.faultHook(op, key)
 }
 
 func (l *LRU) log(level, format string, v ...interface{}) {
@@ -1,7 +1,11 @@
 package lrucache
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -167,3 +171,941 @@ func TestLRUOptions(t *testing.T) {
 		t.Errorf("EvictCallback not called for key1")
 	}
 }
+
+func TestLRUClearWithOptions(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	cache.Set("cfg:short", "a", 100*time.Millisecond)
+	cache.Set("cfg:long", "b", 1*time.Hour)
+	cache.Set("tmp:1", "c", 1*time.Hour)
+	cache.Set("tmp:2", "d", 1*time.Hour)
+
+	err := cache.ClearWithOptions(ClearOptions{
+		KeepMatching: func(key string) bool { return strings.HasPrefix(key, "cfg:") },
+	})
+	if err != nil {
+		t.Fatalf("ClearWithOptions failed: %v", err)
+	}
+
+	if l := cache.Len(); l != 2 {
+		t.Errorf("Expected len 2 after clear, got %d", l)
+	}
+	if _, err := cache.Get("tmp:1"); err != ErrItemNotFound {
+		t.Errorf("Expected tmp:1 to be cleared, got %v", err)
+	}
+	if v, err := cache.Get("cfg:long"); err != nil || v.(string) != "b" {
+		t.Errorf("Expected cfg:long to survive, got %v, %v", v, err)
+	}
+
+	// Survivors are still tracked for expiry.
+	time.Sleep(150 * time.Millisecond)
+	cache.removeExpiredItems()
+	if l := cache.Len(); l != 1 {
+		t.Errorf("Expected expired survivor to be swept, got len %d", l)
+	}
+}
+
+func TestLRUClearKeepPinned(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("pinned:long", 1, time.Hour)
+	cache.Set("pinned:short", 2, time.Minute)
+	cache.Set("unpinned", 3, time.Hour)
+	cache.Set("cfg", 4, time.Hour)
+	for _, key := range []string{"pinned:long", "pinned:short"} {
+		unpin, err := cache.PinCtx(context.Background(), key)
+		if err != nil {
+			t.Fatalf("PinCtx(%s) failed: %v", key, err)
+		}
+		defer unpin()
+	}
+
+	err := cache.ClearWithOptions(ClearOptions{
+		KeepPinned:   true,
+		KeepMatching: func(key string) bool { return key == "cfg" },
+	})
+	if err != nil {
+		t.Fatalf("ClearWithOptions failed: %v", err)
+	}
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"cfg", "pinned:long", "pinned:short"}) {
+		t.Errorf("Expected the pinned and matching entries to survive, got %v", keys)
+	}
+
+	// Pins do not stop expiry, and the rebuilt heap still tracks survivors.
+	now = now.Add(2 * time.Minute)
+	cache.removeExpiredItems()
+	if l := cache.Len(); l != 2 {
+		t.Errorf("Expected the expired pinned entry to be swept, got len %d", l)
+	}
+	if _, err := cache.Get("pinned:long"); err != nil {
+		t.Errorf("Expected pinned:long to survive, got %v", err)
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if l := cache.Len(); l != 0 {
+		t.Errorf("Expected Clear to drop pinned entries too, got len %d", l)
+	}
+}
+
+func TestLRUSetPreservingTTL(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	cache.Set("key1", "old", 1*time.Hour)
+	cache.Set("key2", "other", 2*time.Hour)
+
+	expiresAt := func(key string) time.Time {
+		raw, _ := cache.db.Txn(false).First("cache", "id", key)
+		return raw.(*CacheItem).ExpiresAt
+	}
+	before := expiresAt("key1")
+	pos, deadline := cache.expHeap.index["key1"], cache.expHeap.deadlines["key1"]
+
+	if err := cache.SetPreservingTTL("key1", "new"); err != nil {
+		t.Fatalf("SetPreservingTTL failed: %v", err)
+	}
+
+	if v, _ := cache.Get("key1"); v.(string) != "new" {
+		t.Errorf("Expected value to change, got %v", v)
+	}
+	if after := expiresAt("key1"); after != before {
+		t.Errorf("Expected deadline %v to be unchanged, got %v", before, after)
+	}
+	if cache.expHeap.index["key1"] != pos || cache.expHeap.deadlines["key1"] != deadline {
+		t.Errorf("Expected heap entry to be untouched")
+	}
+
+	if err := cache.SetPreservingTTL("missing", "x"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound for missing key, got %v", err)
+	}
+	if _, err := cache.Get("missing"); err != ErrItemNotFound {
+		t.Errorf("Expected missing key not to be created, got %v", err)
+	}
+
+	cache.Set("short", "v", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if err := cache.SetPreservingTTL("short", "x"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound for expired key, got %v", err)
+	}
+}
+
+func TestLRUMinUsefulTTL(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", MinUsefulTTL: time.Second})
+
+	if err := cache.Set("short", "value", time.Second-time.Nanosecond); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Expected ErrNotStored below the minimum, got %v", err)
+	}
+	if _, err := cache.Get("short"); err != ErrItemNotFound {
+		t.Errorf("Expected skipped write to leave no entry, got %v", err)
+	}
+	if err := cache.Set("exact", "value", time.Second); err != nil {
+		t.Errorf("Expected TTL equal to the minimum to be stored, got %v", err)
+	}
+
+	stored, err := cache.SetEx("short", "value", time.Millisecond)
+	if stored || err != nil {
+		t.Errorf("Expected SetEx to report stored=false with nil error, got %v, %v", stored, err)
+	}
+	stored, err = cache.SetEx("long", "value", time.Minute)
+	if !stored || err != nil {
+		t.Errorf("Expected SetEx to store, got %v, %v", stored, err)
+	}
+	if _, err := cache.SetEx("bad", "value", 0); err == nil {
+		t.Error("Expected SetEx to keep rejecting non-positive TTLs")
+	}
+
+	if skipped := cache.Stats().NotStoredSets; skipped != 2 {
+		t.Errorf("Expected 2 skipped writes, got %d", skipped)
+	}
+}
+
+func TestLRUSetDefault(t *testing.T) {
+	cache, _ := NewLRUWithTTL(2, Options{LogLevel: "error", DefaultTTL: 15 * time.Minute})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	if err := cache.SetDefault("a", 1); err != nil {
+		t.Fatalf("SetDefault failed: %v", err)
+	}
+	if got := cache.Items()["a"].ExpiresAt; !got.Equal(now.Add(15 * time.Minute)) {
+		t.Errorf("Expected the default TTL, got ExpiresAt %v", got)
+	}
+
+	// Capacity eviction treats default TTL entries like any other: the
+	// earliest deadline goes first.
+	cache.Set("short", 2, 5*time.Minute)
+	now = now.Add(time.Minute)
+	cache.SetDefault("b", 3)
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("Expected short to be evicted, got %v", keys)
+	}
+	cache.SetDefault("c", 4)
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"b", "c"}) {
+		t.Errorf("Expected a to be evicted next, got %v", keys)
+	}
+
+	plain, _ := NewLRUWithTTL(2, Options{LogLevel: "error"})
+	err := plain.SetDefault("a", 1)
+	if !errors.Is(err, ErrInvalidArgument) || !strings.Contains(err.Error(), "DefaultTTL") {
+		t.Errorf("Expected an error naming DefaultTTL, got %v", err)
+	}
+	if plain.Len() != 0 {
+		t.Errorf("Expected nothing to be stored")
+	}
+}
+
+func TestLRUSetNoExpiration(t *testing.T) {
+	cache, _ := NewLRUWithTTL(3, Options{LogLevel: "error", MinUsefulTTL: time.Minute})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	if err := cache.Set("p", "permanent", NoExpiration); err != nil {
+		t.Fatalf("Set with NoExpiration failed: %v", err)
+	}
+	if ttl, _ := cache.TTL("p"); ttl != NoExpiration {
+		t.Errorf("Expected TTL NoExpiration, got %v", ttl)
+	}
+	if got := cache.Items()["p"].ExpiresAt; !got.IsZero() {
+		t.Errorf("Expected a zero ExpiresAt, got %v", got)
+	}
+	if err := cache.Set("bad", 1, -time.Second); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected other negative TTLs to be rejected, got %v", err)
+	}
+
+	// Expiring entries are evicted for capacity before the permanent one.
+	cache.Set("a", 1, time.Hour)
+	cache.Set("b", 2, 2*time.Hour)
+	cache.Set("c", 3, 3*time.Hour)
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"b", "c", "p"}) {
+		t.Errorf("Expected a to be evicted, got %v", keys)
+	}
+
+	// The sweep removes what expired and leaves the permanent entry.
+	now = now.Add(100 * 365 * 24 * time.Hour)
+	cache.removeExpiredItems()
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"p"}) {
+		t.Errorf("Expected only p to survive the sweep, got %v", keys)
+	}
+	if v, err := cache.Get("p"); err != nil || v != "permanent" {
+		t.Errorf("Expected p to still be readable, got %v, %v", v, err)
+	}
+
+	// Permanent entries count toward the limit and are evicted once only
+	// permanent entries are left.
+	cache.Set("q", 1, NoExpiration)
+	cache.Set("e", 2, time.Minute)
+	cache.Set("r", 3, NoExpiration)
+	if keys := cache.Keys(); cache.Len() != 3 || !reflect.DeepEqual(keys, []string{"p", "q", "r"}) {
+		t.Errorf("Expected e to be evicted before any permanent entry, got %v", keys)
+	}
+	cache.Set("s", 4, NoExpiration)
+	if _, err := cache.Get("s"); err != nil || cache.Len() != 3 {
+		t.Errorf("Expected a permanent entry to make room for s, got %v with %d entries", err, cache.Len())
+	}
+
+	if n, err := cache.Increment("n", 1, NoExpiration); err != nil || n != 1 {
+		t.Errorf("Increment with NoExpiration failed: %v, %v", n, err)
+	}
+	if ttl, _ := cache.TTL("n"); ttl != NoExpiration {
+		t.Errorf("Expected the counter never to expire, got %v", ttl)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent cache, got %v", err)
+	}
+}
+
+func TestLRUSetWithExpiry(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	midnight := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	if err := cache.SetWithExpiry("daily", "v", midnight); err != nil {
+		t.Fatalf("SetWithExpiry failed: %v", err)
+	}
+	cache.Set("later", "v", 2*time.Hour)
+	if got := cache.Items()["daily"].ExpiresAt; !got.Equal(midnight) {
+		t.Errorf("Expected ExpiresAt %v, got %v", midnight, got)
+	}
+	if order := expirationOrder(cache); !reflect.DeepEqual(order, []string{"daily", "later"}) {
+		t.Errorf("Expected daily to expire first, got %v", order)
+	}
+
+	err := cache.SetWithExpiry("past", "v", now.Add(-time.Second))
+	if !errors.Is(err, ErrInvalidArgument) || !strings.Contains(err.Error(), "not in the future") {
+		t.Errorf("Expected a past expiry to be rejected, got %v", err)
+	}
+
+	now = midnight.Add(-time.Second)
+	if _, err := cache.Get("daily"); err != nil {
+		t.Errorf("Expected daily to live until midnight, got %v", err)
+	}
+	now = midnight.Add(time.Second)
+	if _, err := cache.Get("daily"); err != ErrItemExpired {
+		t.Errorf("Expected daily to expire at midnight, got %v", err)
+	}
+}
+
+func TestLRUGetWithExpiration(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", "x", time.Minute)
+	cache.Set("forever", "y", time.Minute)
+	cache.Persist("forever")
+
+	value, expiresAt, err := cache.GetWithExpiration("a")
+	if err != nil || value != "x" {
+		t.Fatalf("Expected x, got %v, %v", value, err)
+	}
+	if want := now.Add(time.Minute); !expiresAt.Equal(want) {
+		t.Errorf("Expected ExpiresAt %v, got %v", want, expiresAt)
+	}
+	if _, expiresAt, err := cache.GetWithExpiration("forever"); err != nil || !expiresAt.IsZero() {
+		t.Errorf("Expected a zero ExpiresAt for a persisted entry, got %v, %v", expiresAt, err)
+	}
+	if _, _, err := cache.GetWithExpiration("missing"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, expiresAt, err := cache.GetWithExpiration("a"); err != ErrItemExpired || !expiresAt.IsZero() {
+		t.Errorf("Expected ErrItemExpired, got %v, %v", expiresAt, err)
+	}
+	if cache.Contains("a") {
+		t.Errorf("Expected the expired entry to be removed")
+	}
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Expected 2 hits and 2 misses, got %d and %d", stats.Hits, stats.Misses)
+	}
+}
+
+func TestLRUItems(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", "x", time.Minute)
+	cache.Set("obj", map[string]interface{}{"n": 1}, time.Hour)
+	cache.Set("old", "v", time.Second)
+	now = now.Add(2 * time.Second)
+
+	items := cache.Items()
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 live items, got %v", items)
+	}
+	if e := items["a"]; e.Value != "x" || !e.ExpiresAt.Equal(time.Unix(1700000060, 0)) {
+		t.Errorf("Unexpected entry for a: %+v", e)
+	}
+
+	items["obj"].Value.(map[string]interface{})["n"] = 2
+	delete(items, "a")
+	if v, _ := cache.Get("obj"); v.(map[string]interface{})["n"] != float64(1) {
+		t.Errorf("Expected the snapshot to be a copy, cache holds %v", v)
+	}
+	if len(cache.Items()) != 2 {
+		t.Error("Expected deleting from the snapshot to leave the cache alone")
+	}
+}
+
+func TestLRUItemsDuringSets(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{LogLevel: "error"})
+
+	// Each value records the TTL it was written with, so an entry is
+	// consistent when its expiry is its write time plus that TTL.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			ttl := time.Duration(i%7+1) * time.Minute // rotates for every key
+			cache.Set(fmt.Sprintf("k%d", i%100), map[string]interface{}{
+				"ttl":     ttl.String(),
+				"written": time.Now().Format(time.RFC3339Nano),
+			}, ttl)
+		}
+	}()
+
+	for round := 0; round < 50; round++ {
+		for key, e := range cache.Items() {
+			v := e.Value.(map[string]interface{})
+			ttl, _ := time.ParseDuration(v["ttl"].(string))
+			written, _ := time.Parse(time.RFC3339Nano, v["written"].(string))
+			if d := e.ExpiresAt.Sub(written) - ttl; d < -time.Second || d > time.Second {
+				t.Fatalf("Torn entry for %s: value written %v with ttl %v, expires %v", key, written, ttl, e.ExpiresAt)
+			}
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestLRUGetOrSet(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	v, loaded, err := cache.GetOrSet("k", "first", time.Minute)
+	if err != nil || loaded || v != "first" {
+		t.Fatalf("Expected to store first, got %v, %v, %v", v, loaded, err)
+	}
+	v, loaded, err = cache.GetOrSet("k", "second", time.Minute)
+	if err != nil || !loaded || v != "first" {
+		t.Fatalf("Expected to load first, got %v, %v, %v", v, loaded, err)
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %d and %d", stats.Hits, stats.Misses)
+	}
+
+	cache.SetWithReadLimit("once", "v", time.Minute, 1)
+	if v, loaded, _ := cache.GetOrSet("once", "new", time.Minute); !loaded || v != "v" {
+		t.Errorf("Expected the read-limited value, got %v, %v", v, loaded)
+	}
+	if v, loaded, _ := cache.GetOrSet("once", "new", time.Minute); loaded || v != "new" {
+		t.Errorf("Expected the consumed entry to be replaced, got %v, %v", v, loaded)
+	}
+}
+
+func TestLRUGetOrSetConcurrent(t *testing.T) {
+	var inserts sync.Map
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 50)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, loaded, err := cache.GetOrSet("k", i, time.Minute)
+			if err != nil {
+				t.Errorf("GetOrSet failed: %v", err)
+			}
+			if !loaded {
+				inserts.Store(i, true)
+			}
+			results[i] = v
+		}(i)
+	}
+	wg.Wait()
+
+	count := 0
+	inserts.Range(func(_, _ interface{}) bool { count++; return true })
+	if count != 1 {
+		t.Fatalf("Expected exactly one insert, got %d", count)
+	}
+	for i, v := range results {
+		if v != results[0] {
+			t.Errorf("Caller %d got %v, caller 0 got %v", i, v, results[0])
+		}
+	}
+	if n := cache.expHeap.Len(); n != 1 {
+		t.Errorf("Expected one heap entry, got %d", n)
+	}
+}
+
+func TestLRUGetOrSetNotStored(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", MinUsefulTTL: time.Second})
+
+	v, loaded, err := cache.GetOrSet("k", "v", time.Millisecond)
+	if err != nil || loaded || v != "v" {
+		t.Fatalf("Expected the value passed through, got %v, %v, %v", v, loaded, err)
+	}
+	if cache.Contains("k") {
+		t.Error("Expected a TTL below MinUsefulTTL not to be stored")
+	}
+	if n := cache.Stats().NotStoredSets; n != 1 {
+		t.Errorf("Expected 1 skipped write, got %d", n)
+	}
+}
+
+func TestLRUAdd(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	if added, err := cache.Add("k", "first", time.Second); err != nil || !added {
+		t.Fatalf("Expected Add of a new key to succeed, got %v, %v", added, err)
+	}
+	before := expiresAt(t, cache, "k")
+	if added, err := cache.Add("k", "second", time.Hour); err != nil || added {
+		t.Fatalf("Expected Add of a live key to fail, got %v, %v", added, err)
+	}
+	if v, _ := cache.Peek("k"); v != "first" || !expiresAt(t, cache, "k").Equal(before) {
+		t.Errorf("Expected the live entry untouched, got %v", v)
+	}
+
+	now = now.Add(2 * time.Second)
+	if added, err := cache.Add("k", "third", time.Minute); err != nil || !added {
+		t.Fatalf("Expected Add to replace an expired entry, got %v, %v", added, err)
+	}
+	if v, _ := cache.Get("k"); v != "third" {
+		t.Errorf("Expected the replacement, got %v", v)
+	}
+	if n := cache.expHeap.Len(); n != 1 {
+		t.Errorf("Expected one heap entry, got %d", n)
+	}
+}
+
+func TestLRUAddConcurrent(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%5 == 0 {
+				cache.Set("other", i, time.Minute)
+			}
+			added, err := cache.Add("lock", i, time.Minute)
+			if err != nil {
+				t.Errorf("Add failed: %v", err)
+			}
+			if added {
+				mu.Lock()
+				winners++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if winners != 1 {
+		t.Errorf("Expected exactly one Add to win, got %d", winners)
+	}
+}
+
+func TestLRUReplace(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	if err := cache.Replace("session", "data", time.Minute); err != ErrItemNotFound {
+		t.Fatalf("Expected ErrItemNotFound for a missing key, got %v", err)
+	}
+	if cache.Contains("session") {
+		t.Fatal("Expected Replace not to create the key")
+	}
+
+	cache.Set("session", "old", time.Minute)
+	if err := cache.Replace("session", "new", time.Hour); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	if v, _ := cache.Get("session"); v != "new" {
+		t.Errorf("Expected the new value, got %v", v)
+	}
+	if got := expiresAt(t, cache, "session"); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected the new TTL, got %v", got)
+	}
+	if n := cache.expHeap.Len(); n != 1 || !cache.expHeap.deadlines["session"].Equal(cache.clock().Add(time.Hour)) {
+		t.Errorf("Expected one heap entry at the new deadline, got %d", n)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := cache.Replace("session", "again", time.Hour); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound for an expired key, got %v", err)
+	}
+	cache.removeExpiredItems()
+	if err := cache.Replace("session", "again", time.Hour); err != ErrItemNotFound || cache.Contains("session") {
+		t.Errorf("Expected a swept key to stay gone, got %v", err)
+	}
+}
+
+func TestLRUCompareAndSwap(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	if swapped, err := cache.CompareAndSwap("state", "idle", "running", time.Minute); swapped || err != nil {
+		t.Fatalf("Expected no swap for a missing key, got %v, %v", swapped, err)
+	}
+
+	cache.Set("state", "idle", time.Minute)
+	if swapped, err := cache.CompareAndSwap("state", "running", "done", time.Minute); swapped || err != nil {
+		t.Errorf("Expected no swap for a different value, got %v, %v", swapped, err)
+	}
+	if swapped, err := cache.CompareAndSwap("state", "idle", "running", time.Hour); !swapped || err != nil {
+		t.Fatalf("Expected a swap, got %v, %v", swapped, err)
+	}
+	if v, _ := cache.Get("state"); v != "running" {
+		t.Errorf("Expected the new value, got %v", v)
+	}
+	if got := expiresAt(t, cache, "state"); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected the new TTL, got %v", got)
+	}
+
+	// Objects compare by their encoding, and numbers by their exact type.
+	cache.Set("user", map[string]interface{}{"name": "a", "age": 3}, time.Minute)
+	if swapped, _ := cache.CompareAndSwap("user", map[string]interface{}{"age": 3, "name": "a"}, "x", time.Minute); !swapped {
+		t.Error("Expected an equal object to swap")
+	}
+	cache.Set("count", 1, time.Minute)
+	if swapped, _ := cache.CompareAndSwap("count", int64(1), 2, time.Minute); swapped {
+		t.Error("Expected an int64 not to match a stored int")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if swapped, err := cache.CompareAndSwap("state", "running", "done", time.Minute); swapped || err != nil {
+		t.Errorf("Expected no swap for an expired key, got %v, %v", swapped, err)
+	}
+}
+
+func TestLRUCompareAndSwapConcurrent(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("counter", 0, time.Hour)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; {
+				v, err := cache.Get("counter")
+				if err != nil {
+					t.Errorf("Get failed: %v", err)
+					return
+				}
+				n := v.(int)
+				if swapped, _ := cache.CompareAndSwap("counter", n, n+1, time.Hour); swapped {
+					i++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := cache.Get("counter"); v != 400 {
+		t.Errorf("Expected every increment to land, got %v", v)
+	}
+}
+
+func TestLRUPop(t *testing.T) {
+	var removed []EvictReason
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:        "error",
+		RemovalCallback: func(key string, value interface{}, reason EvictReason) { removed = append(removed, reason) },
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	if _, err := cache.Pop("job"); err != ErrItemNotFound {
+		t.Fatalf("Expected ErrItemNotFound for a missing key, got %v", err)
+	}
+
+	cache.Set("job", map[string]interface{}{"id": "7"}, time.Minute)
+	v, err := cache.Pop("job")
+	if err != nil {
+		t.Fatalf("Pop failed: %v", err)
+	}
+	if v.(map[string]interface{})["id"] != "7" {
+		t.Errorf("Expected the stored value, got %v", v)
+	}
+	if cache.Contains("job") || cache.expHeap.Len() != 0 {
+		t.Errorf("Expected Pop to remove the entry from the table and the heap, heap has %d", cache.expHeap.Len())
+	}
+	if _, err := cache.Pop("job"); err != ErrItemNotFound {
+		t.Errorf("Expected a popped key to be gone, got %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("Expected Pop not to fire the removal callback, got %v", removed)
+	}
+
+	cache.Set("job", "v", time.Minute)
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.Pop("job"); err != ErrItemExpired {
+		t.Errorf("Expected ErrItemExpired, got %v", err)
+	}
+	if cache.Len() != 0 || cache.expHeap.Len() != 0 {
+		t.Errorf("Expected the expired entry to be removed")
+	}
+	if len(removed) != 1 || removed[0] != ReasonExpired {
+		t.Errorf("Expected the expired entry to be removed as expired, got %v", removed)
+	}
+	if _, err := cache.Pop("job"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound after the expired entry was removed, got %v", err)
+	}
+}
+
+func TestLRUPopConcurrent(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{LogLevel: "error"})
+	for i := 0; i < 50; i++ {
+		cache.Set(fmt.Sprintf("job%d", i), i, time.Hour)
+	}
+
+	var received sync.Map
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if v, err := cache.Pop(fmt.Sprintf("job%d", i)); err == nil {
+					if _, dup := received.LoadOrStore(v, true); dup {
+						t.Errorf("Value %v popped twice", v)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	n := 0
+	received.Range(func(any, any) bool { n++; return true })
+	if n != 50 || cache.Len() != 0 {
+		t.Errorf("Expected all 50 values popped once, got %d with %d left", n, cache.Len())
+	}
+}
+
+func TestLRUSetRunsEvictionCallbacksBeforeReturning(t *testing.T) {
+	var mu sync.Mutex
+	var evicted []string
+	cache, _ := NewLRUWithTTL(3, Options{
+		LogLevel: "error",
+		RemovalCallback: func(key string, value interface{}, reason EvictReason) {
+			mu.Lock()
+			evicted = append(evicted, key)
+			mu.Unlock()
+		},
+	})
+
+	for i := 0; i < 500; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i, time.Duration(i+1)*time.Second)
+		mu.Lock()
+		n := len(evicted)
+		mu.Unlock()
+		if want := max(0, i-2); n != want {
+			t.Fatalf("After Set %d: expected %d eviction callbacks, got %d", i, want, n)
+		}
+	}
+	for i, key := range evicted {
+		if want := fmt.Sprintf("key%d", i); key != want {
+			t.Fatalf("Expected eviction %d to be %s, got %s", i, want, key)
+		}
+	}
+}
+
+func TestLRUGetRange(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	for hour := 9; hour <= 12; hour++ {
+		for _, minute := range []string{"00", "15", "30", "45"} {
+			key := fmt.Sprintf("metrics:2024-06-01T%02d:%s", hour, minute)
+			ttl := time.Hour
+			if minute == "15" {
+				ttl = time.Minute
+			}
+			cache.Set(key, minute, ttl)
+		}
+	}
+	cache.SetVariant("metrics:2024-06-01T10:30", "gzip", []byte("z"), time.Hour)
+	cache.Set("other", "v", time.Hour)
+	now = now.Add(2 * time.Minute)
+
+	kvs, err := cache.GetRange("metrics:2024-06-01T10:", "metrics:2024-06-01T11:", 0)
+	if err != nil {
+		t.Fatalf("GetRange failed: %v", err)
+	}
+	want := []KV{
+		{"metrics:2024-06-01T10:00", "00"},
+		{"metrics:2024-06-01T10:30", "30"},
+		{"metrics:2024-06-01T10:45", "45"},
+	}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Errorf("Expected %v, got %v", want, kvs)
+	}
+
+	kvs, _ = cache.GetRange("metrics:2024-06-01T11:30", "", 3)
+	var keys []string
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+	if want := []string{"metrics:2024-06-01T11:30", "metrics:2024-06-01T11:45", "metrics:2024-06-01T12:00"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Expected %v with a limit, got %v", want, keys)
+	}
+
+	for _, r := range [][2]string{{"a", "a"}, {"metrics:2024-06-02", "metrics:2024-06-03"}, {"z", "a"}} {
+		if kvs, err := cache.GetRange(r[0], r[1], 0); len(kvs) != 0 || err != nil {
+			t.Errorf("Expected [%s, %s) to be empty, got %v, %v", r[0], r[1], kvs, err)
+		}
+	}
+}
+
+func TestLRUConcurrentSetsKeepSideStateTogether(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	// Every write lands on the same instant, as writes within one clock
+	// tick do.
+	cache.now = func() time.Time { return now }
+
+	const iterations = 10000
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			cache.Set("key", "a", time.Minute)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			cache.SetWithReadLimit("key", "b", 2*time.Minute, 1<<30)
+		}
+	}()
+
+	check := func() error {
+		cache.readLock("get")
+		defer cache.lock.RUnlock()
+
+		raw, err := cache.db.Txn(false).First("cache", "id", "key")
+		if err != nil || raw == nil {
+			return err
+		}
+		item := raw.(*CacheItem)
+		value, _ := deserialize(item.Value)
+		ttl := item.ExpiresAt.Sub(item.CreatedAt)
+		switch {
+		case value == "a" && (ttl != time.Minute || item.reads != nil):
+			return fmt.Errorf("value a with ttl %v and read limit %v", ttl, item.reads != nil)
+		case value == "b" && (ttl != 2*time.Minute || item.reads == nil):
+			return fmt.Errorf("value b with ttl %v and read limit %v", ttl, item.reads != nil)
+		case !cache.expHeap.deadlines["key"].Equal(item.deadline):
+			return fmt.Errorf("heap deadline %v for a row expiring at %v", cache.expHeap.deadlines["key"], item.deadline)
+		}
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		if err := check(); err != nil {
+			t.Fatalf("Side state belongs to another write: %v", err)
+		}
+		select {
+		case <-done:
+			if err := check(); err != nil {
+				t.Fatalf("Side state belongs to another write: %v", err)
+			}
+			if err := cache.Validate(); err != nil {
+				t.Fatalf("Validate failed: %v", err)
+			}
+			return
+		default:
+		}
+	}
+}
+
+func TestLRUPeek(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("key", map[string]interface{}{"a": 1}, time.Minute)
+	cache.Set("short", "v", time.Second)
+	before := expiresAt(t, cache, "key")
+
+	v, err := cache.Peek("key")
+	if err != nil || v.(map[string]interface{})["a"] != float64(1) {
+		t.Fatalf("Expected the stored value, got %v, %v", v, err)
+	}
+	if _, err := cache.Peek("missing"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, err := cache.Peek("short"); err != ErrItemExpired {
+		t.Errorf("Expected ErrItemExpired, got %v", err)
+	}
+	if got := liveKeys(cache); len(got) != 2 {
+		t.Errorf("Expected Peek to leave the expired entry in place, got %v", got)
+	}
+	if cache.expHeap.Len() != 2 || !expiresAt(t, cache, "key").Equal(before) {
+		t.Error("Expected Peek to leave the expiration heap alone")
+	}
+
+	s := cache.Stats()
+	if s.Hits != 0 || s.Misses != 0 {
+		t.Errorf("Expected Peek not to count hits or misses, got %d and %d", s.Hits, s.Misses)
+	}
+	raw, _ := cache.db.Txn(false).First("cache", "id", "key")
+	if hits := raw.(*CacheItem).hits(); hits != 0 {
+		t.Errorf("Expected Peek not to record reads, got %d", hits)
+	}
+}
+
+func TestLRUPeekKeepsReadLimit(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.SetWithReadLimit("token", "secret", time.Minute, 1)
+
+	for i := 0; i < 3; i++ {
+		if v, err := cache.Peek("token"); err != nil || v != "secret" {
+			t.Fatalf("Expected Peek to see the value, got %v, %v", v, err)
+		}
+	}
+	if v, err := cache.Get("token"); err != nil || v != "secret" {
+		t.Fatalf("Expected the single read to remain for Get, got %v, %v", v, err)
+	}
+	if _, err := cache.Peek("token"); err != ErrItemNotFound {
+		t.Errorf("Expected the consumed entry to be gone, got %v", err)
+	}
+}
+
+func TestLRUContains(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", MaxDecodeBytes: 8})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	// Too large to decode, which Contains never tries.
+	cache.Set("big", strings.Repeat("x", 100), time.Minute)
+	cache.Set("short", "v", time.Second)
+
+	if !cache.Contains("big") || !cache.Contains("short") {
+		t.Fatal("Expected both entries to be present")
+	}
+	if cache.Contains("missing") {
+		t.Error("Expected a missing key not to be present")
+	}
+
+	now = now.Add(2 * time.Second)
+	if cache.Contains("short") {
+		t.Error("Expected an expired entry not to be present")
+	}
+	if got := liveKeys(cache); len(got) != 2 {
+		t.Errorf("Expected the expired entry to wait for the sweeper, got %v", got)
+	}
+}
+
+func TestLRUKeys(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	if got := cache.Keys(); len(got) != 0 {
+		t.Fatalf("Expected no keys in an empty cache, got %v", got)
+	}
+	cache.Set("b", "v", time.Minute)
+	cache.Set("a", "v", time.Minute)
+	cache.Set("old", "v", time.Second)
+	cache.SetVariant("page", "gzip", []byte("v"), time.Minute)
+
+	now = now.Add(2 * time.Second)
+	if got, want := cache.Keys(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected keys %v, got %v", want, got)
+	}
+	if _, err := cache.Get("old"); err == nil {
+		t.Error("Expected the unlisted key to be gone for Get too")
+	}
+}
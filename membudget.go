@@ -0,0 +1,120 @@
+package lrucache
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// entryBytes is what an entry counts against Options.MaxBytes: its key and
+// serialized value.
+func entryBytes(key string, value []byte) int64 {
+	return int64(len(key) + len(value))
+}
+
+// rowBytes returns the size of the committed row for key, or 0 if there is
+// none or MaxBytes is not set.
+func (l *LRU) rowBytes(key string) int64 {
+	if l.opts.MaxBytes <= 0 {
+		return 0
+	}
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil || raw == nil {
+		return 0
+	}
+	return entryBytes(key, raw.(*CacheItem).Value)
+}
+
+// batchGrowth returns how many bytes writing items would add to the table,
+// later items replacing earlier ones with the same key, and the keys they
+// write. It returns nothing when MaxBytes is not set.
+func (l *LRU) batchGrowth(items []*CacheItem) (int64, []string) {
+	if l.opts.MaxBytes <= 0 {
+		return 0, nil
+	}
+	sizes := make(map[string]int64, len(items))
+	for _, item := range items {
+		sizes[item.Key] = entryBytes(item.Key, item.Value)
+	}
+	var growth int64
+	keys := make([]string, 0, len(sizes))
+	for key, size := range sizes {
+		growth += size - l.rowBytes(key)
+		keys = append(keys, key)
+	}
+	return growth, keys
+}
+
+// resized records that a commit changed the size of the table by delta
+// bytes. The caller must hold the write lock.
+func (l *LRU) resized(delta int64) {
+	if l.opts.MaxBytes <= 0 {
+		return
+	}
+	l.usedBytes.Add(delta)
+	if l.mutationHook != nil {
+		l.mutationHook()
+	}
+}
+
+// recountBytes sets the byte counter to bytes, the size of the table counted
+// row by row. The caller must hold the write lock.
+func (l *LRU) recountBytes(bytes int64) {
+	if l.opts.MaxBytes <= 0 {
+		return
+	}
+	if used := l.usedBytes.Swap(bytes); used != bytes {
+		l.log("warn", "Byte counter was %d, table holds %d bytes", used, bytes)
+	}
+}
+
+// makeRoom evicts the unpinned entries closest to expiring until the table
+// can grow by growth bytes within MaxBytes, never choosing a key in keep. If
+// evicting all of them would not be enough, it evicts nothing and returns an
+// error wrapping ErrNotStored. The caller must hold the write lock and have
+// no write transaction open.
+func (l *LRU) makeRoom(growth int64, keep ...string) error {
+	limit := l.opts.MaxBytes
+	if limit <= 0 {
+		return nil
+	}
+	over := l.usedBytes.Load() + growth - limit
+	if over <= 0 {
+		return nil
+	}
+
+	kept := make(map[string]bool, len(keep))
+	for _, key := range keep {
+		kept[key] = true
+	}
+	var victims, held []string
+	defer func() {
+		for _, key := range held {
+			l.expHeap.set(key, l.expHeap.deadlines[key])
+		}
+	}()
+	var freed int64
+	for freed < over && l.expHeap.Len() > 0 {
+		key := heap.Pop(l.expHeap).(string)
+		if l.pins[key] > 0 || kept[key] {
+			held = append(held, key)
+			continue
+		}
+		victims = append(victims, key)
+		freed += l.rowBytes(key)
+	}
+	if freed < over {
+		held = append(held, victims...)
+		return fmt.Errorf("%w: %d bytes do not fit within MaxBytes %d", ErrNotStored, growth, limit)
+	}
+
+	for _, key := range victims {
+		if decisionTracing {
+			l.decide("set", key, "victim", "%d bytes over MaxBytes %d", over, limit)
+		}
+		if err := l.removeItem(key, ReasonCapacity); err != nil {
+			return fmt.Errorf("failed to evict: %w", err)
+		}
+		l.stats.emergencyEvictions.Add(1)
+	}
+	return nil
+}
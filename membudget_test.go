@@ -0,0 +1,160 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// budgeted returns a cache whose byte total is checked against MaxBytes and
+// against the table itself after every commit that changes it.
+func budgeted(t *testing.T, size int, maxBytes int64) *LRU {
+	t.Helper()
+	cache, _ := NewLRUWithTTL(size, Options{LogLevel: "error", MaxBytes: maxBytes})
+	cache.mutationHook = func() {
+		used := cache.usedBytes.Load()
+		if used > maxBytes {
+			t.Errorf("Table holds %d bytes, over MaxBytes %d", used, maxBytes)
+		}
+		var actual int64
+		it, _ := cache.db.Txn(false).Get("cache", "id")
+		for obj := it.Next(); obj != nil; obj = it.Next() {
+			actual += entryBytes(obj.(*CacheItem).Key, obj.(*CacheItem).Value)
+		}
+		if used != actual {
+			t.Errorf("Tracked %d bytes, table holds %d", used, actual)
+		}
+	}
+	return cache
+}
+
+func TestLRUMaxBytesEvictsBeforeInsert(t *testing.T) {
+	cache := budgeted(t, 100, 100)
+	value := strings.Repeat("x", 20) // 22 bytes with the key and type tag
+
+	for i, key := range []string{"a", "b", "c", "d"} {
+		cache.Set(key, value, time.Duration(i+1)*time.Minute)
+	}
+	if got, want := cache.usedBytes.Load(), int64(4*22); got != want {
+		t.Fatalf("Expected %d bytes, got %d", want, got)
+	}
+
+	// The next entry only fits once the one expiring first is gone.
+	if err := cache.Set("e", value, time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if cache.Contains("a") || !cache.Contains("b") || !cache.Contains("e") {
+		t.Errorf("Expected only the earliest expiring entry to be evicted, got %v", cache.Keys())
+	}
+	if n := cache.Stats().EmergencyEvictions; n != 1 {
+		t.Errorf("Expected 1 emergency eviction, got %d", n)
+	}
+
+	// A large write evicts as many entries as it needs, all before inserting.
+	if err := cache.Set("big", strings.Repeat("y", 60), time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if n := cache.Stats().EmergencyEvictions; n != 4 {
+		t.Errorf("Expected 4 emergency evictions, got %d", n)
+	}
+}
+
+func TestLRUMaxBytesRejectsWhatCannotFit(t *testing.T) {
+	cache := budgeted(t, 100, 100)
+	cache.Set("a", "v", time.Minute)
+	cache.Set("pinned", strings.Repeat("x", 50), time.Minute)
+	unpin, err := cache.PinCtx(context.Background(), "pinned")
+	if err != nil {
+		t.Fatalf("PinCtx failed: %v", err)
+	}
+	defer unpin()
+
+	err = cache.Set("big", strings.Repeat("y", 60), time.Hour)
+	if !errors.Is(err, ErrNotStored) {
+		t.Fatalf("Expected ErrNotStored, got %v", err)
+	}
+	if !cache.Contains("a") || !cache.Contains("pinned") || cache.Contains("big") {
+		t.Errorf("Expected a rejected write to evict nothing, got %v", cache.Keys())
+	}
+	if n := cache.Stats().EmergencyEvictions; n != 0 {
+		t.Errorf("Expected no emergency evictions, got %d", n)
+	}
+	if err := cache.Set("huge", strings.Repeat("z", 200), time.Hour); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Expected ErrNotStored for a value over MaxBytes, got %v", err)
+	}
+}
+
+func TestLRUMaxBytesTracksEveryWrite(t *testing.T) {
+	cache := budgeted(t, 100, 200)
+	cache.Set("user", map[string]interface{}{"name": "a"}, time.Hour)
+	cache.Set("note", "short", time.Minute)
+
+	// Growing a value in place makes room like a Set does.
+	if err := cache.SetField("user", "bio", strings.Repeat("b", 120)); err != nil {
+		t.Fatalf("SetField failed: %v", err)
+	}
+	if err := cache.SetPreservingTTL("note", strings.Repeat("n", 40)); err != nil {
+		t.Fatalf("SetPreservingTTL failed: %v", err)
+	}
+	if _, err := cache.Append("note", []byte("!!")); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := cache.SetVariant("page", "gzip", []byte(strings.Repeat("g", 30)), time.Hour); err != nil {
+		t.Fatalf("SetVariant failed: %v", err)
+	}
+	err := cache.RangeMutate(func(key string, value interface{}) (interface{}, RangeAction) {
+		if key == "note" {
+			return strings.Repeat("m", 60), ActionUpdate
+		}
+		return nil, ActionKeep
+	})
+	if err != nil {
+		t.Fatalf("RangeMutate failed: %v", err)
+	}
+	if n := cache.Stats().EmergencyEvictions; n == 0 {
+		t.Error("Expected growing writes to evict")
+	}
+
+	cache.Delete("page")
+	cache.Clear()
+	if n := cache.usedBytes.Load(); n != 0 {
+		t.Errorf("Expected an empty cache to hold 0 bytes, got %d", n)
+	}
+}
+
+func TestLRUMaxBytesRecountedFromTable(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{LogLevel: "error", MaxBytes: 100})
+	cache.Set("a", strings.Repeat("a", 30), time.Minute)
+	cache.Set("b", strings.Repeat("b", 30), time.Hour)
+	want := cache.usedBytes.Load()
+
+	// A counter that drifted from the table fails validation, and
+	// RebuildIndexes and the audit both recount it.
+	for _, repair := range []func(){
+		func() { cache.RebuildIndexes() },
+		func() { cache.audit() },
+	} {
+		cache.usedBytes.Store(want + 50)
+		if err := cache.Validate(); err == nil {
+			t.Fatal("Expected a drifted byte counter to fail validation")
+		}
+		repair()
+		if err := cache.Validate(); err != nil {
+			t.Errorf("Expected the byte counter to be recounted, got %v", err)
+		}
+		if n := cache.usedBytes.Load(); n != want {
+			t.Errorf("Expected %d bytes, got %d", want, n)
+		}
+	}
+
+	// The recounted total leaves room for a write the drift would have
+	// evicted for.
+	if err := cache.Set("c", strings.Repeat("c", 30), time.Hour); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if n := cache.Stats().EmergencyEvictions; n != 0 {
+		t.Errorf("Expected no evictions, got %d", n)
+	}
+}
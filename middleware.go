@@ -0,0 +1,66 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// GetFunc looks up a key.
+type GetFunc func(ctx context.Context, key string) (interface{}, error)
+
+// Middleware wraps a GetFunc with cross-cutting behavior. In
+// Options.GetMiddleware the first middleware is the outermost: it sees the
+// call first and the result last. The innermost GetFunc is the cache lookup
+// itself, including lazy removal of expired entries.
+type Middleware func(next GetFunc) GetFunc
+
+func chain(core GetFunc, middleware []Middleware) GetFunc {
+	get := core
+	for i := len(middleware) - 1; i >= 0; i-- {
+		get = middleware[i](get)
+	}
+	return get
+}
+
+// GetMetrics counts the outcomes of the Gets seen by MetricsMiddleware.
+type GetMetrics struct {
+	Hits   atomic.Uint64
+	Misses atomic.Uint64 // ErrItemNotFound and ErrItemExpired
+	Errors atomic.Uint64 // any other error
+}
+
+// MetricsMiddleware counts hits, misses and errors into m.
+func MetricsMiddleware(m *GetMetrics) Middleware {
+	return func(next GetFunc) GetFunc {
+		return func(ctx context.Context, key string) (interface{}, error) {
+			value, err := next(ctx, key)
+			switch {
+			case err == nil:
+				m.Hits.Add(1)
+			case isMiss(err):
+				m.Misses.Add(1)
+			default:
+				m.Errors.Add(1)
+			}
+			return value, err
+		}
+	}
+}
+
+// DefaultValueMiddleware answers misses with value instead of an error.
+func DefaultValueMiddleware(value interface{}) Middleware {
+	return func(next GetFunc) GetFunc {
+		return func(ctx context.Context, key string) (interface{}, error) {
+			v, err := next(ctx, key)
+			if isMiss(err) {
+				return value, nil
+			}
+			return v, err
+		}
+	}
+}
+
+func isMiss(err error) bool {
+	return errors.Is(err, ErrItemNotFound) || errors.Is(err, ErrItemExpired)
+}
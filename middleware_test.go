@@ -0,0 +1,90 @@
+package lrucache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetMiddlewareOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next GetFunc) GetFunc {
+			return func(ctx context.Context, key string) (interface{}, error) {
+				calls = append(calls, name+" before")
+				v, err := next(ctx, key)
+				calls = append(calls, name+" after")
+				return v, err
+			}
+		}
+	}
+
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:      "error",
+		GetMiddleware: []Middleware{trace("outer"), trace("inner")},
+	})
+	cache.Set("key", 1, time.Hour)
+
+	if v, err := cache.Get("key"); err != nil || v.(int) != 1 {
+		t.Fatalf("Get failed. Got %v, %v", v, err)
+	}
+	want := []string{"outer before", "inner before", "inner after", "outer after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected %v, got %v", want, calls)
+	}
+}
+
+func TestGetMiddlewareShortCircuit(t *testing.T) {
+	var reachedCore bool
+	deny := func(next GetFunc) GetFunc {
+		return func(ctx context.Context, key string) (interface{}, error) {
+			if key == "blocked" {
+				return nil, ErrItemNotFound
+			}
+			reachedCore = true
+			return next(ctx, key)
+		}
+	}
+
+	var metrics GetMetrics
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:      "error",
+		GetMiddleware: []Middleware{MetricsMiddleware(&metrics), DefaultValueMiddleware("fallback"), deny},
+	})
+	cache.Set("blocked", "secret", time.Hour)
+	cache.Set("open", "value", time.Hour)
+
+	if v, err := cache.Get("blocked"); err != nil || v.(string) != "fallback" {
+		t.Errorf("Expected the default value for a short-circuited Get, got %v, %v", v, err)
+	}
+	if reachedCore {
+		t.Errorf("Expected the chain to stop before the lookup")
+	}
+	if v, err := cache.GetContext(context.Background(), "open"); err != nil || v.(string) != "value" {
+		t.Errorf("Expected the stored value, got %v, %v", v, err)
+	}
+	if v, err := cache.Get("missing"); err != nil || v.(string) != "fallback" {
+		t.Errorf("Expected the default value for a miss, got %v, %v", v, err)
+	}
+
+	// The metrics sit outside the default value, so every call was a hit.
+	if h, m := metrics.Hits.Load(), metrics.Misses.Load(); h != 3 || m != 0 {
+		t.Errorf("Expected 3 hits and 0 misses, got %d and %d", h, m)
+	}
+}
+
+func TestMetricsMiddlewareCountsMisses(t *testing.T) {
+	var metrics GetMetrics
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:      "error",
+		GetMiddleware: []Middleware{MetricsMiddleware(&metrics)},
+	})
+	cache.Set("key", 1, time.Hour)
+
+	cache.Get("key")
+	cache.Get("missing")
+	if h, m := metrics.Hits.Load(), metrics.Misses.Load(); h != 1 || m != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %d and %d", h, m)
+	}
+}
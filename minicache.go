@@ -0,0 +1,126 @@
+package lrucache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MiniCache is a small map-backed TTL cache for per-request or
+// per-connection use. It stores values as-is, runs no background goroutine
+// and expires entries lazily. The zero value is ready to use; a MiniCache
+// must not be copied after first use.
+type MiniCache struct {
+	// Size caps the number of entries; zero means unbounded. When full, the
+	// entry closest to expiring is evicted. Entries set with NoExpiration
+	// never expire and are evicted last.
+	Size int
+	// EvictCallback is called when an entry expires or is evicted for capacity.
+	EvictCallback EvictCallback
+
+	mu    sync.Mutex
+	items map[string]miniItem
+}
+
+type miniItem struct {
+	value     interface{}
+	expiresAt time.Time // zero if the entry never expires
+}
+
+func (i miniItem) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && now.After(i.expiresAt)
+}
+
+// expiresBefore reports whether i expires before other, entries that never
+// expire sorting last.
+func (i miniItem) expiresBefore(other miniItem) bool {
+	if i.expiresAt.IsZero() || other.expiresAt.IsZero() {
+		return !i.expiresAt.IsZero() && other.expiresAt.IsZero()
+	}
+	return i.expiresAt.Before(other.expiresAt)
+}
+
+func (c *MiniCache) Set(key string, value interface{}, ttl time.Duration) error {
+	if ttl <= 0 && ttl != NoExpiration {
+		return fmt.Errorf("%w: ttl must be positive or NoExpiration", ErrInvalidArgument)
+	}
+	if key == "" {
+		return fmt.Errorf("%w: key must not be empty", ErrInvalidArgument)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.items == nil {
+		c.items = make(map[string]miniItem)
+	}
+	now := time.Now()
+	if _, ok := c.items[key]; !ok && c.Size > 0 && len(c.items) >= c.Size {
+		c.makeRoom(now)
+	}
+	item := miniItem{value: value}
+	if ttl != NoExpiration {
+		item.expiresAt = now.Add(ttl)
+	}
+	c.items[key] = item
+	return nil
+}
+
+func (c *MiniCache) Get(key string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[key]
+	if !ok {
+		return nil, ErrItemNotFound
+	}
+	if item.expired(time.Now()) {
+		c.evict(key, item)
+		return nil, ErrItemExpired
+	}
+	return item.value, nil
+}
+
+func (c *MiniCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; !ok {
+		return ErrItemNotFound
+	}
+	delete(c.items, key)
+	return nil
+}
+
+func (c *MiniCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.items)
+}
+
+// makeRoom drops expired entries and, if the cache is still full, the entry
+// closest to expiring, entries that never expire going last.
+func (c *MiniCache) makeRoom(now time.Time) {
+	var victim string
+	var soonest miniItem
+	for key, item := range c.items {
+		if item.expired(now) {
+			c.evict(key, item)
+			continue
+		}
+		if victim == "" || item.expiresBefore(soonest) {
+			victim, soonest = key, item
+		}
+	}
+	if len(c.items) >= c.Size {
+		c.evict(victim, c.items[victim])
+	}
+}
+
+func (c *MiniCache) evict(key string, item miniItem) {
+	delete(c.items, key)
+	if c.EvictCallback != nil {
+		c.EvictCallback(key, item.value)
+	}
+}
@@ -0,0 +1,171 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMiniCacheBasicOperations(t *testing.T) {
+	var cache MiniCache
+
+	if err := cache.Set("key1", "value1", 1*time.Hour); err != nil {
+		t.Errorf("Failed to set key1: %v", err)
+	}
+	if err := cache.Set("key2", 42, 1*time.Hour); err != nil {
+		t.Errorf("Failed to set key2: %v", err)
+	}
+
+	v, err := cache.Get("key1")
+	if err != nil || v.(string) != "value1" {
+		t.Errorf("Get key1 failed. Got %v, %v", v, err)
+	}
+
+	v, err = cache.Get("key2")
+	if err != nil || v.(int) != 42 {
+		t.Errorf("Get key2 failed. Got %v, %v", v, err)
+	}
+
+	if err := cache.Delete("key1"); err != nil {
+		t.Errorf("Delete key1 failed: %v", err)
+	}
+
+	if _, err := cache.Get("key1"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+
+	if l := cache.Len(); l != 1 {
+		t.Errorf("Expected len 1, got %d", l)
+	}
+
+	if err := cache.Set("key3", 1, 0); err == nil {
+		t.Errorf("Expected error for zero ttl")
+	}
+}
+
+func TestMiniCacheExpiration(t *testing.T) {
+	evicted := make(map[string]interface{})
+	cache := MiniCache{EvictCallback: func(key string, value interface{}) {
+		evicted[key] = value
+	}}
+
+	cache.Set("key1", "value1", 100*time.Millisecond)
+	cache.Set("key2", "value2", 200*time.Millisecond)
+
+	time.Sleep(150 * time.Millisecond)
+
+	if _, err := cache.Get("key1"); err != ErrItemExpired {
+		t.Errorf("Expected key1 to be expired, got %v", err)
+	}
+	if _, err := cache.Get("key2"); err != nil {
+		t.Errorf("key2 should not be expired yet, got %v", err)
+	}
+	if evicted["key1"] != "value1" {
+		t.Errorf("Expected EvictCallback for key1 with its value, got %v", evicted)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := cache.Get("key2"); err != ErrItemExpired {
+		t.Errorf("Expected key2 to be expired, got %v", err)
+	}
+}
+
+func TestMiniCacheEviction(t *testing.T) {
+	cache := MiniCache{Size: 3}
+
+	cache.Set("key1", 1, 1*time.Hour)
+	cache.Set("key2", 2, 2*time.Hour)
+	cache.Set("key3", 3, 3*time.Hour)
+	cache.Set("key4", 4, 4*time.Hour) // should evict key1
+
+	if _, err := cache.Get("key1"); err != ErrItemNotFound {
+		t.Errorf("key1 should have been evicted, got %v", err)
+	}
+	if l := cache.Len(); l != 3 {
+		t.Errorf("Expected len 3, got %d", l)
+	}
+}
+
+func TestMiniCacheNoExpiration(t *testing.T) {
+	cache := MiniCache{Size: 2}
+
+	if err := cache.Set("forever", 1, NoExpiration); err != nil {
+		t.Fatalf("Set with NoExpiration failed: %v", err)
+	}
+	if err := cache.Set("bad", 1, -time.Second); err == nil {
+		t.Errorf("Expected other negative TTLs to be rejected")
+	}
+	cache.Set("short", 2, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if v, err := cache.Get("forever"); err != nil || v != 1 {
+		t.Errorf("Expected the entry never to expire, got %v, %v", v, err)
+	}
+
+	// Expiring entries are evicted before ones that never expire.
+	cache.Set("a", 3, time.Hour)
+	cache.Set("b", 4, time.Hour)
+	if _, err := cache.Get("forever"); err != nil {
+		t.Errorf("Expected the non-expiring entry to be evicted last, got %v", err)
+	}
+	cache.Set("c", 5, NoExpiration)
+	cache.Set("d", 6, NoExpiration)
+	if l := cache.Len(); l != 2 {
+		t.Errorf("Expected len 2, got %d", l)
+	}
+	if _, err := cache.Get("d"); err != nil {
+		t.Errorf("Expected d to be stored once only non-expiring entries are left, got %v", err)
+	}
+}
+
+func TestCacherErrors(t *testing.T) {
+	lru, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	for name, cache := range map[string]Cacher{"LRU": lru, "MiniCache": &MiniCache{}} {
+		t.Run(name, func(t *testing.T) {
+			if err := cache.Set("k", 1, 0); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for a zero TTL, got %v", err)
+			}
+			if err := cache.Set("", 1, time.Hour); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for an empty key, got %v", err)
+			}
+			if _, err := cache.Get("missing"); err != ErrItemNotFound {
+				t.Errorf("Expected ErrItemNotFound from Get, got %v", err)
+			}
+			if err := cache.Delete("missing"); err != ErrItemNotFound {
+				t.Errorf("Expected ErrItemNotFound from Delete, got %v", err)
+			}
+			cache.Set("k", 1, time.Hour)
+			if err := cache.Delete("k"); err != nil {
+				t.Errorf("Delete failed: %v", err)
+			}
+		})
+	}
+}
+
+func benchmarkCacher(b *testing.B, cache Cacher) {
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		cache.Set(keys[i], i, 1*time.Hour)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[i%len(keys)]
+		if i%10 == 0 {
+			cache.Set(key, i, 1*time.Hour)
+		} else {
+			cache.Get(key)
+		}
+	}
+}
+
+func BenchmarkMiniCache100(b *testing.B) {
+	benchmarkCacher(b, &MiniCache{Size: 100})
+}
+
+func BenchmarkLRU100(b *testing.B) {
+	cache, _ := NewLRUWithTTL(100, Options{LogLevel: "error"})
+	benchmarkCacher(b, cache)
+}
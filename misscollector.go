@@ -0,0 +1,145 @@
+package lrucache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Loaded is one result of a BatchLoader. A TTL of NoExpiration stores the
+// value to never expire; any other TTL that is not positive returns the value
+// without storing it.
+type Loaded struct {
+	Value interface{}
+	TTL   time.Duration
+}
+
+// BatchLoader loads several missed keys at once. Keys left out of the result
+// resolve to ErrItemNotFound; an error resolves every key to it.
+type BatchLoader func(ctx context.Context, keys []string) (map[string]Loaded, error)
+
+// MissCollector gathers the misses of one request so they can be loaded in
+// a single batch. It serves the first LRU that records a miss in it; Gets on
+// other caches pass it by.
+type MissCollector struct {
+	mu      sync.Mutex
+	cache   *LRU
+	pending []string
+	seen    map[string]bool
+	results map[string]collected
+}
+
+type collected struct {
+	value interface{}
+	err   error
+}
+
+type missCollectorKey struct{}
+
+// WithMissCollector returns a context whose Gets record their misses in the
+// returned collector instead of calling Options.Loader. The handler then
+// loads them all with LoadAll and reads the results back with Resolve.
+func WithMissCollector(ctx context.Context) (context.Context, *MissCollector) {
+	c := &MissCollector{seen: make(map[string]bool), results: make(map[string]collected)}
+	return context.WithValue(ctx, missCollectorKey{}, c), c
+}
+
+func missCollectorFrom(ctx context.Context) *MissCollector {
+	c, _ := ctx.Value(missCollectorKey{}).(*MissCollector)
+	return c
+}
+
+// record notes a miss of key in l and reports whether the collector took it.
+func (c *MissCollector) record(l *LRU, key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cache == nil {
+		c.cache = l
+	}
+	if c.cache != l {
+		return false
+	}
+	if !c.seen[key] {
+		c.seen[key] = true
+		c.pending = append(c.pending, key)
+	}
+	return true
+}
+
+// Misses returns the keys recorded since the last LoadAll, in the order they
+// were first missed.
+func (c *MissCollector) Misses() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.pending...)
+}
+
+// LoadAll passes the recorded misses to load in one call and stores what it
+// returns, keeping any value stored in the meantime instead. It returns the
+// error of load, which every key then resolves to. With nothing recorded
+// load is not called; while the cache is draining it is not called either
+// and LoadAll returns ErrDraining.
+func (c *MissCollector) LoadAll(ctx context.Context, load BatchLoader) error {
+	c.mu.Lock()
+	l, keys := c.cache, c.pending
+	c.pending, c.seen = nil, make(map[string]bool)
+	c.mu.Unlock()
+	if len(keys) == 0 {
+		return nil
+	}
+	if l.Draining() {
+		return ErrDraining
+	}
+
+	loaded, err := load(ctx, keys)
+	results := make(map[string]collected, len(keys))
+	for _, key := range keys {
+		r, ok := loaded[key]
+		switch {
+		case err != nil:
+			results[key] = collected{err: err}
+		case !ok:
+			results[key] = collected{err: ErrItemNotFound}
+		case r.TTL <= 0 && r.TTL != NoExpiration:
+			results[key] = collected{value: r.Value}
+		default:
+			results[key] = c.store(l, key, r)
+		}
+	}
+
+	c.mu.Lock()
+	for key, r := range results {
+		c.results[key] = r
+	}
+	c.mu.Unlock()
+	return err
+}
+
+func (c *MissCollector) store(l *LRU, key string, r Loaded) collected {
+	defer l.checkWatermarks()
+	actual, _, err := l.getOrSet(key, r.Value, r.TTL)
+	if err != nil {
+		// The value is good even if it could not be stored.
+		return collected{value: r.Value}
+	}
+	return collected{value: actual}
+}
+
+// Resolve returns the result LoadAll got for key. Keys it did not load are
+// read from the cache with Get.
+func (c *MissCollector) Resolve(key string) (interface{}, error) {
+	c.mu.Lock()
+	l := c.cache
+	if l != nil {
+		key = l.NormalizeKey(key)
+	}
+	r, ok := c.results[key]
+	c.mu.Unlock()
+	switch {
+	case ok:
+		return r.value, r.err
+	case l == nil:
+		return nil, ErrItemNotFound
+	}
+	return l.Get(key)
+}
@@ -0,0 +1,112 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMissCollectorBatchesMisses(t *testing.T) {
+	loaderCalls := 0
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		Loader: func(string) (interface{}, time.Duration, error) {
+			loaderCalls++
+			return nil, 0, errors.New("unexpected")
+		},
+	})
+	cache.Set("user:1", "alice", time.Minute)
+	cache.Set("user:3", "carol", time.Minute)
+
+	ctx, collector := WithMissCollector(context.Background())
+	keys := []string{"user:1", "user:2", "user:3", "user:4", "user:2", "user:5"}
+	for _, key := range keys {
+		cache.GetContext(ctx, key)
+	}
+	if want := []string{"user:2", "user:4", "user:5"}; !reflect.DeepEqual(collector.Misses(), want) {
+		t.Fatalf("Expected misses %v, got %v", want, collector.Misses())
+	}
+
+	var batches [][]string
+	err := collector.LoadAll(ctx, func(ctx context.Context, keys []string) (map[string]Loaded, error) {
+		batches = append(batches, keys)
+		return map[string]Loaded{
+			"user:2": {Value: "bob", TTL: time.Minute},
+			"user:4": {Value: "dave", TTL: 0},
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	if len(batches) != 1 || !reflect.DeepEqual(batches[0], []string{"user:2", "user:4", "user:5"}) {
+		t.Errorf("Expected one batch of the missed keys, got %v", batches)
+	}
+	if loaderCalls != 0 {
+		t.Errorf("Expected Options.Loader not to run, got %d calls", loaderCalls)
+	}
+
+	want := map[string]interface{}{"user:1": "alice", "user:2": "bob", "user:3": "carol", "user:4": "dave"}
+	for key, v := range want {
+		if got, err := collector.Resolve(key); err != nil || got != v {
+			t.Errorf("Resolve(%s) = %v, %v, want %v", key, got, err, v)
+		}
+	}
+	if _, err := collector.Resolve("user:5"); err != ErrItemNotFound {
+		t.Errorf("Expected a key the batch left out to be not found, got %v", err)
+	}
+	if v, _ := cache.Get("user:2"); v != "bob" {
+		t.Errorf("Expected the batch result to be stored, got %v", v)
+	}
+	if cache.Contains("user:4") {
+		t.Error("Expected a result without a TTL not to be stored")
+	}
+
+	// Nothing is pending any more.
+	if err := collector.LoadAll(ctx, func(context.Context, []string) (map[string]Loaded, error) {
+		t.Error("Expected no second batch")
+		return nil, nil
+	}); err != nil {
+		t.Errorf("Expected an empty LoadAll to succeed, got %v", err)
+	}
+}
+
+func TestMissCollectorBatchError(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	ctx, collector := WithMissCollector(context.Background())
+	cache.GetContext(ctx, "a")
+	cache.GetContext(ctx, "b")
+
+	boom := errors.New("boom")
+	if err := collector.LoadAll(ctx, func(context.Context, []string) (map[string]Loaded, error) {
+		return nil, boom
+	}); err != boom {
+		t.Fatalf("Expected the batch error, got %v", err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if _, err := collector.Resolve(key); err != boom {
+			t.Errorf("Expected %s to resolve to the batch error, got %v", key, err)
+		}
+	}
+	if cache.Len() != 0 {
+		t.Error("Expected nothing stored after a failed batch")
+	}
+}
+
+func TestMissCollectorOneCache(t *testing.T) {
+	first, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	second, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		Loader:   func(key string) (interface{}, time.Duration, error) { return "loaded", time.Minute, nil },
+	})
+	ctx, collector := WithMissCollector(context.Background())
+
+	first.GetContext(ctx, "a")
+	if v, err := second.GetContext(ctx, "b"); err != nil || v != "loaded" {
+		t.Errorf("Expected another cache to load as usual, got %v, %v", v, err)
+	}
+	if got := collector.Misses(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Expected only the first cache's misses, got %v", got)
+	}
+}
@@ -0,0 +1,145 @@
+package lrucache
+
+import (
+	"hash/maphash"
+	"math"
+	"sync/atomic"
+)
+
+// MissFilter sizes a bloom filter that lets Get answer for keys that were
+// never stored without taking the lock. A zero ExpectedItems disables it.
+type MissFilter struct {
+	ExpectedItems     int     // keys the filter is sized for
+	FalsePositiveRate float64 // target rate at ExpectedItems; defaults to 0.01
+}
+
+const defaultFalsePositiveRate = 0.01
+
+// bloomFilter is a standard bloom filter whose bits can be read without a
+// lock. Keys are only ever added; removed keys stay set until the filter is
+// rebuilt from the table, so it never reports a stored key as missing.
+type bloomFilter struct {
+	seed   maphash.Seed
+	bits   []atomic.Uint64
+	hashes uint64
+}
+
+func newBloomFilter(cfg MissFilter) *bloomFilter {
+	p := cfg.FalsePositiveRate
+	if p <= 0 || p >= 1 {
+		p = defaultFalsePositiveRate
+	}
+	n := float64(cfg.ExpectedItems)
+	m := math.Ceil(-n * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/n*math.Ln2))
+	return &bloomFilter{
+		seed:   maphash.MakeSeed(),
+		bits:   make([]atomic.Uint64, (uint64(m)+63)/64),
+		hashes: uint64(k),
+	}
+}
+
+// positions derives the filter bits of key by double hashing.
+func (f *bloomFilter) positions(key string, visit func(word, bit uint64) bool) bool {
+	h := maphash.String(f.seed, key)
+	h1, h2 := h&math.MaxUint32, h>>32|1
+	size := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < f.hashes; i++ {
+		pos := (h1 + i*h2) % size
+		if !visit(pos/64, pos%64) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) add(key string) {
+	f.positions(key, func(word, bit uint64) bool {
+		for {
+			old := f.bits[word].Load()
+			if old&(1<<bit) != 0 || f.bits[word].CompareAndSwap(old, old|1<<bit) {
+				return true
+			}
+		}
+	})
+}
+
+func (f *bloomFilter) mayContain(key string) bool {
+	return f.positions(key, func(word, bit uint64) bool {
+		return f.bits[word].Load()&(1<<bit) != 0
+	})
+}
+
+// missFilter keeps the live bloom filter and counts how stale it has become.
+type missFilter struct {
+	cfg     MissFilter
+	current atomic.Pointer[bloomFilter]
+	removed int // keys removed since the last rebuild; guarded by the cache lock
+}
+
+func newMissFilter(cfg MissFilter) *missFilter {
+	f := &missFilter{cfg: cfg}
+	f.current.Store(newBloomFilter(cfg))
+	return f
+}
+
+// filterAdd records key in the miss filter. Writers call it before the key
+// becomes visible; the caller must hold the write lock.
+func (l *LRU) filterAdd(key string) {
+	if l.missFilter != nil {
+		l.missFilter.current.Load().add(key)
+	}
+}
+
+// filterRemoved notes that n keys left the table. The caller must hold the
+// write lock.
+func (l *LRU) filterRemoved(n int) {
+	if l.missFilter != nil {
+		l.missFilter.removed += n
+	}
+}
+
+// definitelyMissing reports whether the miss filter rules key out.
+func (l *LRU) definitelyMissing(key string) bool {
+	if l.missFilter == nil || l.missFilter.current.Load().mayContain(key) {
+		return false
+	}
+	if decisionTracing {
+		l.decide("get", key, "filter", "ruled out by the miss filter")
+	}
+	l.stats.filterShortCircuits.Add(1)
+	return true
+}
+
+// maybeRebuildFilter rebuilds the miss filter once a tenth of its capacity has
+// been removed since the last rebuild. The caller must hold the write lock.
+func (l *LRU) maybeRebuildFilter() {
+	if l.missFilter != nil && l.missFilter.removed*10 >= l.missFilter.cfg.ExpectedItems {
+		l.rebuildFilter()
+	}
+}
+
+// rebuildFilter replaces the miss filter with one holding exactly the keys in
+// the table. The caller must hold the write lock.
+func (l *LRU) rebuildFilter() {
+	if l.missFilter == nil {
+		return
+	}
+	it, err := l.db.Txn(false).Get("cache", "id")
+	if err != nil {
+		l.log("error", "Failed to rebuild miss filter: %v", err)
+		return
+	}
+	f := newBloomFilter(l.missFilter.cfg)
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		f.add(obj.(*CacheItem).Key)
+	}
+	l.installFilter(f)
+}
+
+// installFilter replaces the miss filter with f, built from every key in the
+// table. The caller must hold the write lock.
+func (l *LRU) installFilter(f *bloomFilter) {
+	l.missFilter.current.Store(f)
+	l.missFilter.removed = 0
+}
@@ -0,0 +1,85 @@
+package lrucache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestMissFilterShortCircuits(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{LogLevel: "error", MissFilter: MissFilter{ExpectedItems: 100}})
+
+	cache.Set("key1", "value1", time.Hour)
+	if v, err := cache.Get("key1"); err != nil || v != "value1" {
+		t.Errorf("Expected value1, got %v, %v", v, err)
+	}
+	for i := 0; i < 1000; i++ {
+		if _, err := cache.Get(fmt.Sprintf("cold%d", i)); err != ErrItemNotFound {
+			t.Fatalf("Expected ErrItemNotFound, got %v", err)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.MissFilterShortCircuits+stats.MissFilterFalsePositives != 1000 {
+		t.Errorf("Expected every miss to be counted, got %+v", stats)
+	}
+	if stats.MissFilterFalsePositiveRate > 0.05 {
+		t.Errorf("Expected a false positive rate near 1%%, got %v", stats.MissFilterFalsePositiveRate)
+	}
+}
+
+func TestMissFilterNoFalseNegatives(t *testing.T) {
+	cache, _ := NewLRUWithTTL(200, Options{LogLevel: "error", MissFilter: MissFilter{ExpectedItems: 200}})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	rng := rand.New(rand.NewSource(1))
+
+	for step := 0; step < 5000; step++ {
+		key := fmt.Sprintf("key%d", rng.Intn(300))
+		switch rng.Intn(10) {
+		case 0:
+			cache.Delete(key)
+		case 1:
+			now = now.Add(time.Duration(rng.Intn(30)) * time.Second)
+			cache.removeExpiredItems()
+		case 2:
+			if step%500 == 0 {
+				cache.ClearWithOptions(ClearOptions{KeepMatching: func(k string) bool { return len(k)%2 == 0 }})
+			}
+		default:
+			cache.Set(key, step, time.Duration(1+rng.Intn(120))*time.Second)
+		}
+
+		it, _ := cache.db.Txn(false).Get("cache", "id")
+		for obj := it.Next(); obj != nil; obj = it.Next() {
+			if k := obj.(*CacheItem).Key; !cache.missFilter.current.Load().mayContain(k) {
+				t.Fatalf("Step %d: filter reports stored key %s as missing", step, k)
+			}
+		}
+	}
+}
+
+func benchmarkMisses(b *testing.B, opts Options) {
+	cache, _ := NewLRUWithTTL(10000, opts)
+	for i := 0; i < 10000; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i, time.Hour)
+	}
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("cold%d", i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Get(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkGetMissWithoutFilter(b *testing.B) {
+	benchmarkMisses(b, Options{LogLevel: "error"})
+}
+
+func BenchmarkGetMissWithFilter(b *testing.B) {
+	benchmarkMisses(b, Options{LogLevel: "error", MissFilter: MissFilter{ExpectedItems: 10000}})
+}
@@ -0,0 +1,49 @@
+package lrucache
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// GetIfModifiedSince answers a conditional read, such as one for an HTTP
+// If-Modified-Since header. If the entry's LastModified is after since it
+// returns the value with modified set, as Get would; otherwise it returns a
+// nil value without decoding it and without using up a read of an entry
+// stored with SetWithReadLimit. lastModified is returned either way and,
+// being strictly later for every write of the key, can be passed back as
+// since to tell writes made within the same clock tick apart. A zero since
+// always counts as modified. Missing and expired entries are reported,
+// counted and removed as Get does; like GetMulti, GetIfModifiedSince does
+// not run GetMiddleware or Options.Loader.
+func (l *LRU) GetIfModifiedSince(key string, since time.Time) (value interface{}, modified bool, lastModified time.Time, err error) {
+	key = l.NormalizeKey(key)
+	r, err := l.lookupEntry(context.Background(), key, since)
+	if err != nil {
+		return nil, false, time.Time{}, err
+	}
+	return r.value, !r.unchanged, r.lastModified, nil
+}
+
+// modifiedAfter returns the LastModified of a new value replacing prev:
+// now, or just after prev's if the clock has not moved past it.
+func (l *LRU) modifiedAfter(prev *CacheItem) time.Time {
+	now := l.now()
+	if next := prev.LastModified.Add(time.Nanosecond); now.Before(next) {
+		return next
+	}
+	return now
+}
+
+// stampModified moves the LastModified of item, about to be inserted in
+// txn, past that of the row it replaces, if any.
+func (l *LRU) stampModified(txn *memdb.Txn, item *CacheItem) {
+	raw, err := txn.First("cache", "id", item.Key)
+	if err != nil || raw == nil {
+		return
+	}
+	if prev := raw.(*CacheItem); !item.LastModified.After(prev.LastModified) {
+		item.LastModified = l.modifiedAfter(prev)
+	}
+}
@@ -0,0 +1,140 @@
+package lrucache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func lastModified(t *testing.T, cache *LRU, key string) time.Time {
+	t.Helper()
+	entry, ok := cache.Items()[key]
+	if !ok {
+		t.Fatalf("Expected %s to be cached", key)
+	}
+	return entry.LastModified
+}
+
+func TestLastModified(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", "v1", time.Hour)
+	if lm := lastModified(t, cache, "a"); !lm.Equal(now) {
+		t.Errorf("Expected LastModified %v, got %v", now, lm)
+	}
+	written := now
+
+	now = now.Add(time.Minute)
+	cache.Touch("a", 2*time.Hour)
+	cache.ExpireAt("a", now.Add(3*time.Hour))
+	if lm := lastModified(t, cache, "a"); !lm.Equal(written) {
+		t.Errorf("Expected TTL changes to keep LastModified %v, got %v", written, lm)
+	}
+
+	cache.SetPreservingTTL("a", "v2")
+	if lm := lastModified(t, cache, "a"); !lm.Equal(now) {
+		t.Errorf("Expected a value change to bump LastModified to %v, got %v", now, lm)
+	}
+
+	now = now.Add(time.Minute)
+	cache.SetMulti(map[string]interface{}{"a": "v3"}, time.Hour)
+	if lm := lastModified(t, cache, "a"); !lm.Equal(now) {
+		t.Errorf("Expected a bulk write to bump LastModified to %v, got %v", now, lm)
+	}
+}
+
+func TestLastModifiedSameInstant(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", "v1", time.Hour)
+	first := lastModified(t, cache, "a")
+	cache.Set("a", "v2", time.Hour)
+	second := lastModified(t, cache, "a")
+	cache.Append("a", []byte("!"))
+	third := lastModified(t, cache, "a")
+	if !second.After(first) || !third.After(second) {
+		t.Errorf("Expected strictly increasing LastModified within one instant, got %v, %v, %v", first, second, third)
+	}
+
+	v, modified, lm, err := cache.GetIfModifiedSince("a", first)
+	if err != nil || !modified || v == nil || !lm.Equal(third) {
+		t.Errorf("Expected the same-instant write to count as modified, got %v, %v, %v, %v", v, modified, lm, err)
+	}
+	if _, modified, _, _ := cache.GetIfModifiedSince("a", third); modified {
+		t.Errorf("Expected no change since the last write")
+	}
+}
+
+func TestGetIfModifiedSince(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("page", "<html>", time.Hour)
+	_, modified, lm, err := cache.GetIfModifiedSince("page", time.Time{})
+	if err != nil || !modified {
+		t.Fatalf("Expected a zero since to count as modified, got %v, %v", modified, err)
+	}
+
+	now = now.Add(time.Minute)
+	cache.Touch("page", time.Hour)
+	v, modified, lm2, err := cache.GetIfModifiedSince("page", lm)
+	if err != nil || modified || v != nil || !lm2.Equal(lm) {
+		t.Errorf("Expected not modified after a TTL refresh, got %v, %v, %v, %v", v, modified, lm2, err)
+	}
+	if _, modified, _, _ := cache.GetIfModifiedSince("page", lm.Add(-time.Second)); !modified {
+		t.Errorf("Expected an earlier since to count as modified")
+	}
+
+	cache.Set("page", "<html>v2", time.Hour)
+	v, modified, _, err = cache.GetIfModifiedSince("page", lm)
+	if err != nil || !modified || v != "<html>v2" {
+		t.Errorf("Expected the new value, got %v, %v, %v", v, modified, err)
+	}
+
+	if _, _, _, err := cache.GetIfModifiedSince("missing", lm); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, _, _, err := cache.GetIfModifiedSince("page", lm); err != ErrItemExpired {
+		t.Errorf("Expected ErrItemExpired, got %v", err)
+	}
+	if stats := cache.Stats(); stats.Hits != 4 || stats.Misses != 2 {
+		t.Errorf("Expected 4 hits and 2 misses, got %d and %d", stats.Hits, stats.Misses)
+	}
+}
+
+func TestGetIfModifiedSinceKeepsReadLimit(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.SetWithReadLimit("token", "t", time.Hour, 1)
+	lm := lastModified(t, cache, "token")
+
+	if _, modified, _, err := cache.GetIfModifiedSince("token", lm); err != nil || modified {
+		t.Errorf("Expected not modified, got %v, %v", modified, err)
+	}
+	if v, err := cache.Get("token"); err != nil || v != "t" {
+		t.Errorf("Expected the unchanged answer to leave the read, got %v, %v", v, err)
+	}
+}
+
+func TestLastModifiedSurvivesExport(t *testing.T) {
+	src, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	src.Set("a", 1, time.Hour)
+	want := lastModified(t, src, "a")
+
+	var buf bytes.Buffer
+	if err := src.ExportDelta(&buf, 0); err != nil {
+		t.Fatalf("ExportDelta failed: %v", err)
+	}
+	dst, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	if err := dst.ApplyDelta(&buf); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	if got := lastModified(t, dst, "a"); !got.Equal(want) {
+		t.Errorf("Expected LastModified %v after import, got %v", want, got)
+	}
+}
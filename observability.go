@@ -0,0 +1,72 @@
+package lrucache
+
+import "unsafe"
+
+// Names of the side structures in Stats().ObservabilityBytes.
+const (
+	sideTombstones = "tombstones"
+	sideLockWaits  = "lock_waits"
+	sideHitRatio   = "hit_ratio"
+)
+
+func (m *hitRatioMonitor) bytes() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(cap(m.samples)) * int64(unsafe.Sizeof(hitSample{}))
+}
+
+// observabilityBytes returns the approximate memory of each enabled side
+// structure. The caller must hold the lock.
+func (l *LRU) observabilityBytes() map[string]int64 {
+	sizes := make(map[string]int64)
+	if l.tombstones != nil {
+		sizes[sideTombstones] = l.tombstones.size
+	}
+	if l.lockStats != nil {
+		sizes[sideLockWaits] = l.lockStats.bytes()
+	}
+	if l.hitRatio != nil {
+		sizes[sideHitRatio] = l.hitRatio.bytes()
+	}
+	return sizes
+}
+
+// enforceObservabilityBudget keeps the combined memory of the side
+// structures within Options.ObservabilityMaxBytes. What the hit ratio samples
+// leave of the budget is split between tombstones and lock wait samples in
+// proportion to what each would hold without a budget, and each drops its
+// oldest records to fit its share. Hit ratio samples are only counted, since
+// trimming them would shorten the alert window. The caller must hold the
+// write lock.
+func (l *LRU) enforceObservabilityBudget() {
+	budget := l.opts.ObservabilityMaxBytes
+	if budget <= 0 {
+		return
+	}
+	if l.hitRatio != nil {
+		budget -= l.hitRatio.bytes()
+	}
+	budget = max(budget, 0)
+
+	var tombDemand, lockDemand int64
+	if l.tombstones != nil {
+		tombDemand = l.tombstones.demand(l.opts.TombstoneRetention.Count)
+	}
+	if l.lockStats != nil {
+		lockDemand = l.lockStats.demand()
+	}
+	if tombDemand+lockDemand <= budget {
+		if l.lockStats != nil {
+			l.lockStats.limitBytes(lockDemand)
+		}
+		return
+	}
+
+	tombShare := budget * tombDemand / (tombDemand + lockDemand)
+	if l.tombstones != nil {
+		l.tombstones.limitBytes(tombShare)
+	}
+	if l.lockStats != nil {
+		l.lockStats.limitBytes(budget - tombShare)
+	}
+}
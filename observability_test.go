@@ -0,0 +1,85 @@
+package lrucache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObservabilityBudget(t *testing.T) {
+	const budget = 8 << 10
+	cache, _ := NewLRUWithTTL(1000, Options{
+		LogLevel:              "error",
+		TombstoneRetention:    TombstoneRetention{Count: 1000},
+		TrackLockContention:   true,
+		ObservabilityMaxBytes: budget,
+	})
+	value := strings.Repeat("x", 64)
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("key%03d", i)
+		cache.Set(key, value, time.Minute)
+		cache.Get(key)
+		cache.Delete(key)
+		cache.Set(key, value, time.Minute)
+		cache.removeItem(key, ReasonExpired)
+	}
+	cache.removeExpiredItems()
+
+	sizes := cache.Stats().ObservabilityBytes
+	var total int64
+	for _, n := range sizes {
+		total += n
+	}
+	if total > budget {
+		t.Fatalf("Expected at most %d bytes, got %d (%v)", budget, total, sizes)
+	}
+	if sizes[sideTombstones] == 0 || sizes[sideLockWaits] == 0 {
+		t.Errorf("Expected both structures to keep some data, got %v", sizes)
+	}
+	if _, _, _, ok := cache.Tombstone("key499"); !ok {
+		t.Error("Expected the newest tombstone to survive")
+	}
+	if _, _, _, ok := cache.Tombstone("key000"); ok {
+		t.Error("Expected the oldest tombstone to be trimmed")
+	}
+	if waits := cache.Stats().LockWaits["set"]; waits.Acquisitions == 0 {
+		t.Error("Expected lock waits to still be counted")
+	}
+}
+
+func TestObservabilityBytesUnbounded(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", TombstoneRetention: TombstoneRetention{Count: 3}})
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("key%d", i)
+		cache.Set(key, "v", time.Minute)
+		cache.removeItem(key, ReasonExpired)
+	}
+	data, _ := serialize("v")
+	want := int64(3 * (len("key0") + len(data) + tombstoneOverhead))
+	if got := cache.Stats().ObservabilityBytes[sideTombstones]; got != want {
+		t.Errorf("Expected %d tombstone bytes, got %d", want, got)
+	}
+}
+
+func TestLockTrackerLimitKeepsNewest(t *testing.T) {
+	tr := newLockTracker()
+	for i := 1; i <= 1500; i++ {
+		tr.record("get", time.Duration(i))
+	}
+	tr.limitBytes(10 * sampleBytes)
+	w := tr.ops["get"]
+	if len(w.samples) != 10 || w.samples[0] != 1491 || w.samples[9] != 1500 {
+		t.Fatalf("Expected the ten newest samples, got %v", w.samples)
+	}
+	tr.record("get", 1501)
+	if w.samples[0] != 1501 || len(w.samples) != 10 {
+		t.Errorf("Expected the oldest sample to be replaced, got %v", w.samples)
+	}
+
+	tr.limitBytes(12 * sampleBytes)
+	tr.record("get", 1502)
+	if len(w.samples) != 11 || w.samples[0] != 1492 || w.samples[10] != 1502 {
+		t.Errorf("Expected the ring to grow in order, got %v", w.samples)
+	}
+}
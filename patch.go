@@ -0,0 +1,298 @@
+package lrucache
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ApplyMergePatch applies an RFC 7386 merge patch to the JSON object stored
+// under key: members of the patch replace those of the value, objects merge
+// recursively and nulls delete members. A positive ttl or NoExpiration also
+// resets the TTL; zero keeps it. A stored value that is not an object returns
+// ErrNotAnObject.
+func (l *LRU) ApplyMergePatch(key string, patch []byte, ttl time.Duration) error {
+	key = l.NormalizeKey(key)
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return fmt.Errorf("invalid merge patch: %v", err)
+	}
+	return l.updateValue(key, ttl, func(value interface{}) (interface{}, error) {
+		if _, ok := value.(map[string]interface{}); !ok {
+			return nil, ErrNotAnObject
+		}
+		return mergePatch(value, p), nil
+	})
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ApplyJSONPatch applies the RFC 6902 operations in patch to the JSON value
+// stored under key, keeping its TTL. Either every operation applies or the
+// entry is left unchanged; a failing operation, including a failed test,
+// returns an error wrapping ErrPatchConflict.
+func (l *LRU) ApplyJSONPatch(key string, patch []byte) error {
+	key = l.NormalizeKey(key)
+	var ops []patchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return fmt.Errorf("invalid json patch: %v", err)
+	}
+	return l.updateValue(key, 0, func(value interface{}) (interface{}, error) {
+		for i, op := range ops {
+			var err error
+			if value, err = applyOperation(value, op); err != nil {
+				return nil, fmt.Errorf("%w: operation %d (%s %s): %v", ErrPatchConflict, i, op.Op, op.Path, err)
+			}
+		}
+		return value, nil
+	})
+}
+
+func applyOperation(doc interface{}, op patchOperation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("invalid value: %v", err)
+		}
+		switch op.Op {
+		case "add":
+			return pointerAdd(doc, path, value)
+		case "replace":
+			return pointerReplace(doc, path, value)
+		}
+		got, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(got, value) {
+			return nil, fmt.Errorf("test failed")
+		}
+		return doc, nil
+	case "remove":
+		return pointerRemove(doc, path)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := pointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return pointerAdd(doc, path, deepCopy(value))
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("cannot move a value into itself")
+		}
+		if doc, err = pointerRemove(doc, from); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, value)
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into unescaped tokens. The
+// empty pointer refers to the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		var err error
+		if doc, err = pointerChild(doc, token); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func pointerChild(node interface{}, token string) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		v, ok := n[token]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", token)
+		}
+		return v, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		return n[i], nil
+	default:
+		return nil, fmt.Errorf("cannot reference %q in a scalar", token)
+	}
+}
+
+// arrayIndex parses token as an array index no greater than max.
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+// pointerUpdate applies fn to the container holding the last token of path and
+// returns the document with that container replaced by fn's result.
+func pointerUpdate(doc interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	next, err := pointerChild(doc, path[0])
+	if err != nil {
+		return nil, err
+	}
+	if next, err = pointerUpdate(next, path[1:], fn); err != nil {
+		return nil, err
+	}
+	switch n := doc.(type) {
+	case map[string]interface{}:
+		n[path[0]] = next
+	case []interface{}:
+		i, _ := arrayIndex(path[0], len(n)-1)
+		n[i] = next
+	}
+	return doc, nil
+}
+
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[token] = value
+			return p, nil
+		case []interface{}:
+			if token == "-" {
+				return append(p, value), nil
+			}
+			i, err := arrayIndex(token, len(p))
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+		default:
+			return nil, fmt.Errorf("cannot add %q to a scalar", token)
+		}
+	})
+}
+
+func pointerRemove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot remove the whole document")
+	}
+	return pointerUpdate(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		if _, err := pointerChild(parent, token); err != nil {
+			return nil, err
+		}
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			delete(p, token)
+			return p, nil
+		default:
+			a := p.([]interface{})
+			i, _ := arrayIndex(token, len(a)-1)
+			return append(a[:i], a[i+1:]...), nil
+		}
+	})
+}
+
+func pointerReplace(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		if _, err := pointerChild(parent, token); err != nil {
+			return nil, err
+		}
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[token] = value
+			return p, nil
+		default:
+			a := p.([]interface{})
+			i, _ := arrayIndex(token, len(a)-1)
+			a[i] = value
+			return a, nil
+		}
+	})
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = deepCopy(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = deepCopy(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,143 @@
+package lrucache
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func decodeJSON(t *testing.T, doc string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatalf("invalid test document %s: %v", doc, err)
+	}
+	return v
+}
+
+func TestApplyMergePatchRFCExample(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("doc", decodeJSON(t, `{
+		"title": "Goodbye!",
+		"author": {"givenName": "John", "familyName": "Doe"},
+		"tags": ["example", "sample"],
+		"content": "This will be unchanged"
+	}`), time.Hour)
+
+	patch := `{
+		"title": "Hello!",
+		"phoneNumber": "+01-123-456-7890",
+		"author": {"familyName": null},
+		"tags": ["example"]
+	}`
+	if err := cache.ApplyMergePatch("doc", []byte(patch), 0); err != nil {
+		t.Fatalf("ApplyMergePatch failed: %v", err)
+	}
+
+	want := decodeJSON(t, `{
+		"title": "Hello!",
+		"author": {"givenName": "John"},
+		"tags": ["example"],
+		"content": "This will be unchanged",
+		"phoneNumber": "+01-123-456-7890"
+	}`)
+	if v, _ := cache.Get("doc"); !reflect.DeepEqual(v, want) {
+		t.Errorf("Expected %v, got %v", want, v)
+	}
+}
+
+func TestApplyMergePatchErrors(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	cache.Set("list", []interface{}{"a"}, time.Hour)
+	cache.Set("short", map[string]interface{}{}, time.Minute)
+
+	if err := cache.ApplyMergePatch("list", []byte(`{"a":1}`), 0); !errors.Is(err, ErrNotAnObject) {
+		t.Errorf("Expected ErrNotAnObject for an array value, got %v", err)
+	}
+	if err := cache.ApplyMergePatch("missing", []byte(`{"a":1}`), 0); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+	if err := cache.ApplyMergePatch("short", []byte(`{`), 0); err == nil {
+		t.Error("Expected an invalid patch to be rejected")
+	}
+
+	if err := cache.ApplyMergePatch("short", []byte(`{"a":1}`), time.Hour); err != nil {
+		t.Fatalf("ApplyMergePatch with ttl failed: %v", err)
+	}
+	now = now.Add(30 * time.Minute)
+	if _, err := cache.Get("short"); err != nil {
+		t.Errorf("Expected the ttl to be reset, got %v", err)
+	}
+	now = now.Add(time.Hour)
+	if err := cache.ApplyMergePatch("short", []byte(`{"a":2}`), 0); err != ErrItemExpired {
+		t.Errorf("Expected ErrItemExpired, got %v", err)
+	}
+}
+
+func TestApplyJSONPatchRFCExamples(t *testing.T) {
+	cases := []struct {
+		name, doc, patch, want string
+	}{
+		{"add object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"remove object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"move value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"test success", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"add nested member", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{"escape ordering", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+		{"add array value", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{"copy value", `{"foo":{"a":1}}`, `[{"op":"copy","from":"/foo","path":"/bar"},{"op":"replace","path":"/bar/a","value":2}]`, `{"foo":{"a":1},"bar":{"a":2}}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+			cache.Set("doc", decodeJSON(t, c.doc), time.Hour)
+			if err := cache.ApplyJSONPatch("doc", []byte(c.patch)); err != nil {
+				t.Fatalf("ApplyJSONPatch failed: %v", err)
+			}
+			if v, _ := cache.Get("doc"); !reflect.DeepEqual(v, decodeJSON(t, c.want)) {
+				t.Errorf("Expected %s, got %v", c.want, v)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatchConflicts(t *testing.T) {
+	cases := []struct {
+		name, patch string
+	}{
+		{"test failure", `[{"op":"test","path":"/baz","value":"bar"}]`},
+		{"add to nonexistent target", `[{"op":"add","path":"/missing/bat","value":"qux"}]`},
+		{"path through a scalar", `[{"op":"add","path":"/baz/x","value":1}]`},
+		{"remove missing member", `[{"op":"remove","path":"/missing"}]`},
+		{"array index out of range", `[{"op":"replace","path":"/list/5","value":1}]`},
+		{"unknown operation", `[{"op":"frobnicate","path":"/baz"}]`},
+		{"later operation fails", `[{"op":"replace","path":"/baz","value":"new"},{"op":"test","path":"/baz","value":"qux"}]`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+			cache.Set("doc", decodeJSON(t, `{"baz":"qux","list":[1]}`), time.Hour)
+			if err := cache.ApplyJSONPatch("doc", []byte(c.patch)); !errors.Is(err, ErrPatchConflict) {
+				t.Fatalf("Expected ErrPatchConflict, got %v", err)
+			}
+			if v, _ := cache.Get("doc"); !reflect.DeepEqual(v, decodeJSON(t, `{"baz":"qux","list":[1]}`)) {
+				t.Errorf("Expected a failed patch to leave the value unchanged, got %v", v)
+			}
+		})
+	}
+
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	if err := cache.ApplyJSONPatch("missing", []byte(`[]`)); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+}
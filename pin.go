@@ -0,0 +1,66 @@
+package lrucache
+
+import (
+	"context"
+	"sync"
+)
+
+// PinCtx exempts key from capacity eviction until the returned unpin is
+// called or ctx is done, whichever comes first. Pins are counted, so a key
+// pinned by several callers stays pinned until all of them have let go;
+// calling unpin more than once is harmless. Pinned entries still expire.
+// Pins belong to the key rather than to the entry, so a key that is
+// rewritten while pinned stays pinned. When every entry is pinned a new one
+// can push the cache over its size; it shrinks back as pins are released.
+//
+// The key must hold a live entry. Cancellation is watched with
+// context.AfterFunc, which costs no goroutine until ctx is done.
+func (l *LRU) PinCtx(ctx context.Context, key string) (unpin func(), err error) {
+	key = l.NormalizeKey(key)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	l.writeLock("set")
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	switch {
+	case err != nil:
+		l.lock.Unlock()
+		return nil, err
+	case raw == nil:
+		l.lock.Unlock()
+		return nil, ErrItemNotFound
+	case raw.(*CacheItem).expired(l.clock()):
+		l.lock.Unlock()
+		return nil, ErrItemExpired
+	}
+	if l.pins == nil {
+		l.pins = make(map[string]int)
+	}
+	l.pins[key]++
+	l.lock.Unlock()
+
+	var once sync.Once
+	release := func() { once.Do(func() { l.release(key) }) }
+	stop := context.AfterFunc(ctx, release)
+	return func() {
+		stop()
+		release()
+	}, nil
+}
+
+// release drops one pin of key and evicts whatever the pin was keeping over
+// capacity once the last one is gone.
+func (l *LRU) release(key string) {
+	defer l.checkWatermarks()
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	if l.pins[key]--; l.pins[key] > 0 {
+		return
+	}
+	delete(l.pins, key)
+	if err := l.evictOverCapacity(); err != nil {
+		l.backgroundError(err)
+	}
+}
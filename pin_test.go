@@ -0,0 +1,106 @@
+package lrucache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPinCtxCounted(t *testing.T) {
+	cache, _ := NewLRUWithTTL(2, Options{LogLevel: "error"})
+	cache.Set("a", 1, time.Minute)
+	cache.Set("b", 2, 2*time.Minute)
+
+	unpin1, err := cache.PinCtx(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("PinCtx failed: %v", err)
+	}
+	unpin2, _ := cache.PinCtx(context.Background(), "a")
+
+	// a expires first, so it would be the victim; b goes instead.
+	cache.Set("c", 3, 3*time.Minute)
+	if got, want := liveKeys(cache), []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected the pinned entry to survive, got %v", got)
+	}
+
+	unpin1()
+	unpin1()
+	cache.Set("d", 4, 4*time.Minute)
+	if got, want := liveKeys(cache), []string{"a", "d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected a to stay pinned by the second pin, got %v", got)
+	}
+
+	unpin2()
+	cache.Set("e", 5, 5*time.Minute)
+	if got, want := liveKeys(cache), []string{"d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected a to be evictable once unpinned, got %v", got)
+	}
+}
+
+func TestPinCtxCancellation(t *testing.T) {
+	cache, _ := NewLRUWithTTL(1, Options{LogLevel: "error"})
+	cache.Set("a", 1, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := cache.PinCtx(ctx, "a"); err != nil {
+		t.Fatalf("PinCtx failed: %v", err)
+	}
+	// The new entry is the only one that can go.
+	cache.Set("b", 2, 2*time.Minute)
+	if got, want := liveKeys(cache), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected only the pinned entry to remain, got %v", got)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for {
+		cache.lock.RLock()
+		pinned := cache.pins["a"]
+		cache.lock.RUnlock()
+		if pinned == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected cancellation to release the pin")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cache.Set("c", 3, 3*time.Minute)
+	if got, want := liveKeys(cache), []string{"c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the released entry to be evicted, got %v", got)
+	}
+}
+
+func TestPinCtxReleaseShrinksCache(t *testing.T) {
+	cache, _ := NewLRUWithTTL(1, Options{LogLevel: "error"})
+	cache.Set("a", 1, time.Minute)
+	unpinA, _ := cache.PinCtx(context.Background(), "a")
+	// The pin stays with the key while it is deleted and written again.
+	cache.Delete("a")
+	cache.Set("b", 2, 2*time.Minute)
+	unpinB, _ := cache.PinCtx(context.Background(), "b")
+	cache.Set("a", 1, time.Minute)
+	if got := liveKeys(cache); len(got) != 2 {
+		t.Fatalf("Expected both pinned entries to remain, got %v", got)
+	}
+
+	unpinA()
+	if got, want := liveKeys(cache), []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected releasing a to bring the cache back to its size, got %v", got)
+	}
+	unpinB()
+}
+
+func TestPinCtxErrors(t *testing.T) {
+	cache, _ := NewLRUWithTTL(1, Options{LogLevel: "error"})
+	if _, err := cache.PinCtx(context.Background(), "missing"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+	cache.Set("a", 1, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.PinCtx(ctx, "a"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
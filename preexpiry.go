@@ -0,0 +1,80 @@
+package lrucache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// PreExpiryNotice calls Callback once for every entry, Lead before it
+// expires. Notices are delivered by the background sweep, so they can be
+// late by up to its interval. Entries removed before their notice get none,
+// entries whose deadline moves re-arm theirs, and entries whose TTL is
+// shorter than Lead are never announced.
+type PreExpiryNotice struct {
+	Lead     time.Duration
+	Callback func(key string, expiresAt time.Time)
+}
+
+// noticeSchedule keeps the notice time of every armed entry in a heap of its
+// own, maintained alongside the expiration heap.
+type noticeSchedule struct {
+	lead  time.Duration
+	clock func() time.Time
+	due   *expirationHeap
+}
+
+func newNoticeSchedule(lead time.Duration, clock func() time.Time, size int) *noticeSchedule {
+	return &noticeSchedule{lead: lead, clock: clock, due: newExpirationHeap(size)}
+}
+
+// arm schedules the notice for an entry with the given deadline, replacing
+// any earlier one. A notice that would already be due is dropped.
+func (s *noticeSchedule) arm(key string, deadline time.Time) {
+	if s == nil {
+		return
+	}
+	at := deadline.Add(-s.lead)
+	if at.Before(s.clock()) {
+		s.due.remove(key)
+		return
+	}
+	s.due.set(key, at)
+}
+
+func (s *noticeSchedule) disarm(key string) {
+	if s != nil {
+		s.due.remove(key)
+	}
+}
+
+// firePreExpiry delivers the notices that have come due. The callbacks run
+// after the lock is released, so they may use the cache.
+func (l *LRU) firePreExpiry() {
+	if l.opts.PreExpiryNotice.Callback == nil {
+		return
+	}
+	type notice struct {
+		key       string
+		expiresAt time.Time
+	}
+	var due []notice
+
+	l.writeLock("sweep")
+	s := l.expHeap.notices
+	clock := l.clock()
+	txn := l.db.Txn(false)
+	for s.due.Len() > 0 && !s.due.deadlines[s.due.items[0]].After(clock) {
+		key := heap.Pop(s.due).(string)
+		delete(s.due.deadlines, key)
+		raw, err := txn.First("cache", "id", key)
+		if err != nil || raw == nil || raw.(*CacheItem).expired(clock) {
+			continue
+		}
+		due = append(due, notice{key, raw.(*CacheItem).ExpiresAt})
+	}
+	l.lock.Unlock()
+
+	for _, n := range due {
+		l.opts.PreExpiryNotice.Callback(n.key, n.expiresAt)
+	}
+}
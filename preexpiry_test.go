@@ -0,0 +1,94 @@
+package lrucache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newNoticeCache(t *testing.T, lead time.Duration) (*LRU, *time.Time, *[]string) {
+	var notified []string
+	cache, err := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		PreExpiryNotice: PreExpiryNotice{
+			Lead:     lead,
+			Callback: func(key string, expiresAt time.Time) { notified = append(notified, key) },
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewLRUWithTTL failed: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	return cache, &now, &notified
+}
+
+func TestPreExpiryNoticeOnce(t *testing.T) {
+	var gotExpiry time.Time
+	cache, now, notified := newNoticeCache(t, 10*time.Second)
+	cb := cache.opts.PreExpiryNotice.Callback
+	cache.opts.PreExpiryNotice.Callback = func(key string, expiresAt time.Time) {
+		gotExpiry = expiresAt
+		cb(key, expiresAt)
+	}
+	cache.Set("key", "v", time.Minute)
+	want := now.Add(time.Minute)
+
+	*now = now.Add(49 * time.Second)
+	cache.firePreExpiry()
+	if len(*notified) != 0 {
+		t.Fatalf("Expected no notice before the lead time, got %v", *notified)
+	}
+
+	*now = now.Add(time.Second)
+	cache.firePreExpiry()
+	*now = now.Add(5 * time.Second)
+	cache.firePreExpiry()
+	if !reflect.DeepEqual(*notified, []string{"key"}) {
+		t.Fatalf("Expected exactly one notice, got %v", *notified)
+	}
+	if !gotExpiry.Equal(want) {
+		t.Errorf("Expected expiresAt %v, got %v", want, gotExpiry)
+	}
+}
+
+func TestPreExpiryNoticeRearmsAfterTouch(t *testing.T) {
+	cache, now, notified := newNoticeCache(t, 10*time.Second)
+	cache.Set("key", "v", time.Minute)
+
+	*now = now.Add(50 * time.Second)
+	cache.firePreExpiry()
+	if len(*notified) != 1 {
+		t.Fatalf("Expected the first notice, got %v", *notified)
+	}
+
+	if _, _, err := cache.TouchMany([]string{"key"}, time.Minute); err != nil {
+		t.Fatalf("TouchMany failed: %v", err)
+	}
+	*now = now.Add(45 * time.Second)
+	cache.firePreExpiry()
+	if len(*notified) != 1 {
+		t.Fatalf("Expected no notice before the new lead time, got %v", *notified)
+	}
+	*now = now.Add(5 * time.Second)
+	cache.firePreExpiry()
+	if !reflect.DeepEqual(*notified, []string{"key", "key"}) {
+		t.Errorf("Expected a second notice after the touch, got %v", *notified)
+	}
+}
+
+func TestPreExpiryNoticeSuppressed(t *testing.T) {
+	cache, now, notified := newNoticeCache(t, 10*time.Second)
+	cache.Set("deleted", "v", time.Minute)
+	cache.Set("short", "v", 5*time.Second)
+	cache.Set("touched", "v", time.Minute)
+
+	cache.Delete("deleted")
+	cache.TouchMany([]string{"touched"}, 2*time.Minute)
+
+	*now = now.Add(time.Minute)
+	cache.firePreExpiry()
+	if len(*notified) != 0 {
+		t.Errorf("Expected no notices, got %v", *notified)
+	}
+}
@@ -0,0 +1,96 @@
+package lrucache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// quiesceState holds mutations back while the cache is quiesced. Every
+// writer passes through gate on its way to the write lock, and Quiesce
+// holds gate exclusively, so readers, which never touch gate, keep going.
+type quiesceState struct {
+	gate   sync.RWMutex
+	active atomic.Bool
+	since  atomic.Int64 // UnixNano the current quiesce started at
+	total  atomic.Int64 // nanoseconds spent quiesced, past quiesces only
+}
+
+// Quiesce is QuiesceCtx without a deadline.
+func (l *LRU) Quiesce() (resume func(), err error) {
+	return l.QuiesceCtx(context.Background())
+}
+
+// QuiesceCtx freezes the cache's internal structures, for example so that
+// the process can be snapshotted from outside. It stops new mutations,
+// waits for the write transaction in flight, if any, to commit, checks the
+// frozen state with Validate and returns a function that lets mutations
+// through again. Removal callbacks and background sweeps run under the
+// write lock, so none is running once QuiesceCtx returns.
+//
+// Reads keep being served while the cache is quiesced. Expired and used up
+// entries they find are left in place for later, as in drain mode, but a
+// read that has to write, such as a Get that loads through Options.Loader,
+// waits for resume like every mutation. Calling resume more than once is
+// harmless; mutating the cache from the goroutine that holds it quiesced
+// deadlocks.
+//
+// If ctx is done first, QuiesceCtx gives up with ctx.Err(); mutations that
+// queued up meanwhile go ahead as soon as the one in flight finishes. If the
+// state does not validate, the cache is resumed and the error returned.
+func (l *LRU) QuiesceCtx(ctx context.Context) (resume func(), err error) {
+	acquired := make(chan struct{})
+	go func() {
+		l.quiesce.gate.Lock()
+		l.lock.Lock()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+	case <-ctx.Done():
+		go func() {
+			<-acquired
+			l.lock.Unlock()
+			l.quiesce.gate.Unlock()
+		}()
+		return nil, ctx.Err()
+	}
+
+	l.quiesce.since.Store(time.Now().UnixNano())
+	l.quiesce.active.Store(true)
+	l.lock.Unlock()
+	l.log("info", "Quiesced")
+
+	var once sync.Once
+	resume = func() {
+		once.Do(func() {
+			elapsed := time.Now().UnixNano() - l.quiesce.since.Load()
+			l.quiesce.active.Store(false)
+			l.quiesce.total.Add(elapsed)
+			l.quiesce.gate.Unlock()
+			l.log("info", "Resumed after %v quiesced", time.Duration(elapsed))
+		})
+	}
+	if err := l.Validate(); err != nil {
+		resume()
+		return nil, err
+	}
+	return resume, nil
+}
+
+// Quiesced reports whether the cache is quiesced.
+func (l *LRU) Quiesced() bool {
+	return l.quiesce.active.Load()
+}
+
+// quiescedTime returns the time spent quiesced, including the quiesce in
+// progress.
+func (l *LRU) quiescedTime() time.Duration {
+	total := l.quiesce.total.Load()
+	if l.Quiesced() {
+		total += time.Now().UnixNano() - l.quiesce.since.Load()
+	}
+	return time.Duration(total)
+}
@@ -0,0 +1,199 @@
+package lrucache
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQuiesceBlocksMutations(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("a", 1, time.Hour)
+
+	resume, err := cache.Quiesce()
+	if err != nil {
+		t.Fatalf("Quiesce failed: %v", err)
+	}
+	if !cache.Stats().Quiesced {
+		t.Errorf("Expected Stats to report the cache quiesced")
+	}
+
+	set := make(chan error, 1)
+	go func() { set <- cache.Set("b", 2, time.Hour) }()
+	select {
+	case err := <-set:
+		t.Fatalf("Expected Set to wait for resume, it returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if v, err := cache.Get("a"); err != nil || v != 1 {
+		t.Errorf("Expected reads to go on, got %v, %v", v, err)
+	}
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Errorf("Expected only a while quiesced, got %v", keys)
+	}
+
+	resume()
+	resume()
+	if err := <-set; err != nil {
+		t.Fatalf("Set failed after resume: %v", err)
+	}
+	if _, err := cache.Get("b"); err != nil {
+		t.Errorf("Expected b after resume, got %v", err)
+	}
+	stats := cache.Stats()
+	if stats.Quiesced || stats.QuiescedTime < 50*time.Millisecond {
+		t.Errorf("Expected at least 50ms quiesced and no longer quiesced, got %v, %v", stats.QuiescedTime, stats.Quiesced)
+	}
+}
+
+func TestQuiesceUnderLoad(t *testing.T) {
+	cache, _ := NewLRUWithTTL(50, Options{LogLevel: "error", AuditInterval: time.Millisecond})
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; !stop.Load(); i++ {
+				key := fmt.Sprintf("k%d", (w*31+i)%100)
+				switch i % 3 {
+				case 0, 1:
+					cache.Set(key, i, time.Duration(1+i%5)*time.Millisecond)
+				case 2:
+					cache.Delete(key)
+				}
+			}
+		}(w)
+	}
+	var reads atomic.Int64
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for !stop.Load() {
+			cache.Get(fmt.Sprintf("k%d", reads.Add(1)%100))
+		}
+	}()
+
+	for round := 0; round < 5; round++ {
+		time.Sleep(5 * time.Millisecond)
+		resume, err := cache.Quiesce()
+		if err != nil {
+			t.Fatalf("Quiesce failed: %v", err)
+		}
+		before, readsBefore := rowKeys(cache), reads.Load()
+		time.Sleep(10 * time.Millisecond)
+		if after := rowKeys(cache); !reflect.DeepEqual(before, after) {
+			t.Errorf("Expected the rows to stay put while quiesced, got %v then %v", before, after)
+		}
+		if reads.Load() == readsBefore {
+			t.Errorf("Expected reads to go on while quiesced")
+		}
+		if err := cache.Validate(); err != nil {
+			t.Errorf("Expected a consistent state while quiesced, got %v", err)
+		}
+		resume()
+	}
+
+	stop.Store(true)
+	wg.Wait()
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent state after resuming, got %v", err)
+	}
+}
+
+// rowKeys lists the keys of every row, expired or not.
+func rowKeys(cache *LRU) []string {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	it, _ := cache.db.Txn(false).Get("cache", "id")
+	var keys []string
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		keys = append(keys, obj.(*CacheItem).Key)
+	}
+	return keys
+}
+
+func TestQuiesceLeavesExpiredEntries(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	cache.Set("a", 1, time.Minute)
+	now = now.Add(2 * time.Minute)
+
+	resume, err := cache.Quiesce()
+	if err != nil {
+		t.Fatalf("Quiesce failed: %v", err)
+	}
+	got := make(chan error, 1)
+	go func() {
+		_, err := cache.Get("a")
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		if err != ErrItemExpired {
+			t.Errorf("Expected ErrItemExpired, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a read of an expired entry not to wait for resume")
+	}
+	if n := cache.Len(); n != 1 {
+		t.Errorf("Expected the expired entry to stay while quiesced, got %d entries", n)
+	}
+	resume()
+
+	cache.Get("a")
+	if n := cache.Len(); n != 0 {
+		t.Errorf("Expected the expired entry to be removed after resume, got %d entries", n)
+	}
+}
+
+func TestQuiesceCtxGivesUp(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	// Hold the write lock as a long transaction would.
+	cache.lock.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cache.QuiesceCtx(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	cache.lock.Unlock()
+
+	if err := cache.Set("a", 1, time.Hour); err != nil {
+		t.Fatalf("Expected Set to go ahead after giving up, got %v", err)
+	}
+	if cache.Quiesced() {
+		t.Errorf("Expected the cache not to be quiesced")
+	}
+	resume, err := cache.Quiesce()
+	if err != nil {
+		t.Fatalf("Quiesce failed: %v", err)
+	}
+	resume()
+}
+
+func TestQuiesceRejectsInconsistentState(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("a", 1, time.Hour)
+	cache.lock.Lock()
+	heap.Push(cache.expHeap, "ghost")
+	cache.lock.Unlock()
+
+	if _, err := cache.Quiesce(); err == nil {
+		t.Fatal("Expected Quiesce to report the inconsistency")
+	}
+	if cache.Quiesced() {
+		t.Errorf("Expected the cache to be resumed")
+	}
+	if err := cache.Set("b", 2, time.Hour); err != nil {
+		t.Errorf("Expected Set to go ahead, got %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package lrucache
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// RangeAction tells RangeMutate what to do with the entry it passed to fn.
+type RangeAction int
+
+const (
+	ActionKeep   RangeAction = iota // leave the entry as it is
+	ActionUpdate                    // replace the value, keeping the TTL
+	ActionDelete                    // delete the entry and its variants
+)
+
+// rangeMutateBatch is how many entries RangeMutate reads, and then applies
+// changes to, under one lock acquisition.
+const rangeMutateBatch = 256
+
+// rangeEntry is an entry handed to fn together with what identifies the
+// version of it that was read.
+type rangeEntry struct {
+	key    string
+	value  interface{}
+	access *accessStats
+}
+
+// RangeMutate calls fn for every live entry in key order and applies the
+// returned action: ActionKeep, ActionUpdate with the returned value, or
+// ActionDelete. Entries are read and changed in batches, each under a
+// single lock acquisition, and fn runs with no lock held, so the whole
+// cache can be transformed without copying it and while other callers keep
+// going.
+//
+// Every entry that exists when RangeMutate starts and is not deleted in the
+// meantime is visited exactly once. Entries inserted while it runs are
+// visited if their key sorts after the current position, and not otherwise.
+// An entry that is deleted or rewritten by someone else between being read
+// and its batch being applied keeps the concurrent change: the action fn
+// returned for it is dropped. Updates keep the TTL and invalidate derived
+// entries as SetPreservingTTL does, and an update of an entry stored with
+// SetImmutable fails its batch with ErrImmutable; deletes behave as Delete
+// does for a single key, dropping the variants stored under it too.
+func (l *LRU) RangeMutate(fn func(key string, value interface{}) (newValue interface{}, action RangeAction)) error {
+	defer l.checkWatermarks()
+
+	var cursor string
+	for first := true; ; first = false {
+		batch, err := l.rangeBatch(cursor, !first)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		var updates []rangeUpdate
+		var deletes []rangeEntry
+		for _, e := range batch {
+			newValue, action := fn(e.key, e.value)
+			switch action {
+			case ActionUpdate:
+				data, err := serialize(newValue)
+				if err != nil {
+					return fmt.Errorf("failed to serialize value of key %s: %v", e.key, err)
+				}
+				updates = append(updates, rangeUpdate{e, data})
+			case ActionDelete:
+				deletes = append(deletes, e)
+			}
+		}
+		if err := l.applyRangeBatch(updates, deletes); err != nil {
+			return err
+		}
+		cursor = batch[len(batch)-1].key
+	}
+}
+
+type rangeUpdate struct {
+	rangeEntry
+	data []byte
+}
+
+// rangeBatch reads up to rangeMutateBatch live entries starting at cursor,
+// or right after it when after is set.
+func (l *LRU) rangeBatch(cursor string, after bool) ([]rangeEntry, error) {
+	l.readLock("scan")
+	defer l.lock.RUnlock()
+
+	it, err := l.db.Txn(false).LowerBound("cache", "id", cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get items: %v", err)
+	}
+	clock := l.clock()
+	var batch []rangeEntry
+	for obj := it.Next(); obj != nil && len(batch) < rangeMutateBatch; obj = it.Next() {
+		item := obj.(*CacheItem)
+		if (after && item.Key == cursor) || item.expired(clock) {
+			continue
+		}
+		value, err := l.decode(item.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize value of key %s: %w", item.Key, err)
+		}
+		batch = append(batch, rangeEntry{key: item.Key, value: value, access: item.access})
+	}
+	return batch, nil
+}
+
+// applyRangeBatch writes the outcome of one batch. Entries whose row has
+// been replaced or removed since the batch was read are skipped; rows that
+// were only touched or had their value patched in place share the access
+// statistics of the row that was read and still count as the same entry.
+func (l *LRU) applyRangeBatch(updates []rangeUpdate, deletes []rangeEntry) error {
+	if len(updates) == 0 && len(deletes) == 0 {
+		return nil
+	}
+	if len(updates) > 0 {
+		if err := l.checkWritable(""); err != nil {
+			return err
+		}
+	}
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	clock := l.clock()
+	current := func(txn *memdb.Txn, e rangeEntry) (*CacheItem, error) {
+		raw, err := txn.First("cache", "id", e.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve item: %v", err)
+		}
+		if raw == nil || raw.(*CacheItem).access != e.access || raw.(*CacheItem).expired(clock) {
+			return nil, nil
+		}
+		return raw.(*CacheItem), nil
+	}
+
+	read := l.db.Txn(false)
+	for _, u := range updates {
+		if prev, err := current(read, u.rangeEntry); err == nil {
+			if err := l.checkMutable(prev); err != nil {
+				return err
+			}
+		}
+	}
+	if l.opts.MaxBytes > 0 {
+		var growth int64
+		keys := make([]string, 0, len(updates))
+		for _, u := range updates {
+			if prev, err := current(read, u.rangeEntry); err == nil && prev != nil {
+				growth += int64(len(u.data) - len(prev.Value))
+				keys = append(keys, u.key)
+			}
+		}
+		for _, d := range deletes {
+			if prev, err := current(read, d); err == nil && prev != nil {
+				growth -= entryBytes(prev.Key, prev.Value)
+				keys = append(keys, d.key)
+			}
+		}
+		if err := l.makeRoom(growth, keys...); err != nil {
+			return err
+		}
+	}
+
+	txn := l.db.Txn(true)
+	var retired []*CacheItem
+	var changed []rangeUpdate
+	var removed []string
+	var growth int64
+	for _, u := range updates {
+		prev, err := current(txn, u.rangeEntry)
+		if err != nil {
+			txn.Abort()
+			return err
+		}
+		if prev == nil {
+			continue
+		}
+		item := l.copyItem(prev)
+		item.Value, item.LastModified = u.data, l.modifiedAfter(prev)
+		if err := txn.Insert("cache", item); err != nil {
+			txn.Abort()
+			return fmt.Errorf("failed to insert item: %v", err)
+		}
+		retired = append(retired, prev)
+		changed = append(changed, u)
+		growth += int64(len(u.data) - len(prev.Value))
+	}
+	for _, d := range deletes {
+		prev, err := current(txn, d)
+		if err != nil {
+			txn.Abort()
+			return err
+		}
+		if prev == nil {
+			continue
+		}
+		// Like Delete, deleting a key also drops every variant stored under it.
+		variants, err := variantKeys(txn, d.key)
+		if err != nil {
+			txn.Abort()
+			return err
+		}
+		gone := []*CacheItem{prev}
+		for _, k := range variants {
+			raw, err := txn.First("cache", "id", k)
+			if err != nil {
+				txn.Abort()
+				return fmt.Errorf("failed to retrieve item: %v", err)
+			}
+			gone = append(gone, raw.(*CacheItem))
+		}
+		for _, item := range gone {
+			if err := txn.Delete("cache", item); err != nil {
+				txn.Abort()
+				return fmt.Errorf("failed to delete item: %v", err)
+			}
+			retired = append(retired, item)
+			removed = append(removed, item.Key)
+			growth -= entryBytes(item.Key, item.Value)
+		}
+	}
+	txn.Commit()
+	l.resized(growth)
+	l.retire(retired...)
+	l.filterRemoved(len(removed))
+
+	for _, u := range changed {
+		l.groups.set(u.key)
+		l.notifyWatchers(u.key, u.data)
+		l.invalidateDependents(u.key)
+	}
+	for _, key := range removed {
+		l.expHeap.remove(key)
+		l.closeWatchers(key)
+		l.recordDeletion(key)
+		l.deps.forget(key)
+		l.invalidateDependents(key)
+	}
+	l.log("debug", "Range updated %d and deleted %d keys", len(changed), len(removed))
+	return nil
+}
@@ -0,0 +1,171 @@
+package lrucache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRangeMutateMigrates(t *testing.T) {
+	const n = 50000
+	cache, _ := NewLRUWithTTL(2*n, Options{LogLevel: "error"})
+	for i := 0; i < n; i++ {
+		cache.Set(fmt.Sprintf("doc:%05d", i), map[string]interface{}{"v": 1}, time.Hour)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			// Stay within capacity so none of the documents is evicted.
+			cache.Set(fmt.Sprintf("new:%05d", i%(n/10)), map[string]interface{}{"v": 1}, time.Hour)
+		}
+	}()
+
+	visits := make(map[string]int)
+	err := cache.RangeMutate(func(key string, value interface{}) (interface{}, RangeAction) {
+		visits[key]++
+		if !strings.HasPrefix(key, "doc:") {
+			return nil, ActionKeep
+		}
+		doc := value.(map[string]interface{})
+		doc["v"] = 2
+		return doc, ActionUpdate
+	})
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("RangeMutate failed: %v", err)
+	}
+
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("doc:%05d", i)
+		if visits[key] != 1 {
+			t.Fatalf("Expected %s to be visited once, got %d", key, visits[key])
+		}
+	}
+	for key, count := range visits {
+		if count != 1 {
+			t.Fatalf("Expected %s to be visited at most once, got %d", key, count)
+		}
+	}
+	for _, i := range []int{0, n / 2, n - 1} {
+		v, err := cache.Get(fmt.Sprintf("doc:%05d", i))
+		if err != nil || v.(map[string]interface{})["v"] != float64(2) {
+			t.Errorf("Expected doc:%05d to be migrated, got %v, %v", i, v, err)
+		}
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+}
+
+func TestRangeMutateDeleteAndKeep(t *testing.T) {
+	var removed []string
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:        "error",
+		RemovalCallback: func(key string, value interface{}, reason EvictReason) { removed = append(removed, key) },
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+	cache.Set("a", "1", time.Minute)
+	cache.Set("b", "2", time.Minute)
+	cache.Set("c", "3", time.Second)
+	cache.SetDerived("d", "4", time.Minute, []string{"a"})
+	before := expiresAt(t, cache, "b")
+	now = now.Add(2 * time.Second)
+
+	var seen []string
+	err := cache.RangeMutate(func(key string, value interface{}) (interface{}, RangeAction) {
+		seen = append(seen, key)
+		switch key {
+		case "a":
+			return nil, ActionDelete
+		case "b":
+			return "two", ActionUpdate
+		}
+		return nil, ActionKeep
+	})
+	if err != nil {
+		t.Fatalf("RangeMutate failed: %v", err)
+	}
+
+	if strings.Join(seen, ",") != "a,b,d" {
+		t.Errorf("Expected the live entries in key order, got %v", seen)
+	}
+	if _, err := cache.Get("a"); err != ErrItemNotFound {
+		t.Errorf("Expected a to be deleted, got %v", err)
+	}
+	if _, err := cache.Get("d"); err != ErrItemNotFound {
+		t.Errorf("Expected d to be invalidated with a, got %v", err)
+	}
+	if v, _ := cache.Get("b"); v != "two" {
+		t.Errorf("Expected b to be updated, got %v", v)
+	}
+	if after := expiresAt(t, cache, "b"); !after.Equal(before) {
+		t.Errorf("Expected the update to keep the TTL, got %v, want %v", after, before)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+}
+
+func TestRangeMutateSkipsConcurrentChanges(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("a", "old", time.Minute)
+	cache.Set("b", "old", time.Minute)
+
+	err := cache.RangeMutate(func(key string, value interface{}) (interface{}, RangeAction) {
+		// Both entries are read in the same batch before fn runs.
+		if key == "a" {
+			cache.Set("a", "concurrent", time.Minute)
+			cache.Delete("b")
+		}
+		return "migrated", ActionUpdate
+	})
+	if err != nil {
+		t.Fatalf("RangeMutate failed: %v", err)
+	}
+	if v, _ := cache.Get("a"); v != "concurrent" {
+		t.Errorf("Expected the concurrent write to win, got %v", v)
+	}
+	if _, err := cache.Get("b"); err != ErrItemNotFound {
+		t.Errorf("Expected the concurrently deleted entry to stay deleted, got %v", err)
+	}
+}
+
+func TestRangeMutateDeleteDropsVariants(t *testing.T) {
+	cache := budgeted(t, 10, 1000)
+	cache.Set("page", "identity", time.Hour)
+	cache.SetVariant("page", "gzip", []byte("z"), time.Hour)
+	cache.SetVariant("page", "br", []byte("b"), time.Hour)
+	cache.Set("other", "v", time.Hour)
+
+	err := cache.RangeMutate(func(key string, value interface{}) (interface{}, RangeAction) {
+		if key == "page" {
+			return nil, ActionDelete
+		}
+		return nil, ActionKeep
+	})
+	if err != nil {
+		t.Fatalf("RangeMutate failed: %v", err)
+	}
+	if variants := cache.Variants("page"); len(variants) != 0 {
+		t.Errorf("Expected the variants to be deleted with page, got %v", variants)
+	}
+	if keys := cache.Keys(); len(keys) != 1 || keys[0] != "other" {
+		t.Errorf("Expected only other to be left, got %v", keys)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package lrucache
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+const (
+	defaultRateLimitKeys = 1024
+	hottestLimitedKeys   = 10
+)
+
+// SetRateLimit bounds how often a single key may be written. A zero
+// PerKeyPerSecond disables limiting.
+type SetRateLimit struct {
+	PerKeyPerSecond float64 // sustained Sets per key per second
+	Burst           int     // Sets allowed back to back; defaults to 1
+
+	// RefreshTTL makes a limited Set extend the TTL of the stored entry
+	// instead of failing with ErrRateLimited. The value is not replaced.
+	RefreshTTL bool
+
+	// MaxKeys bounds the number of per-key buckets kept; the least recently
+	// written keys lose their state first. Defaults to 1024.
+	MaxKeys int
+}
+
+type tokenBucket struct {
+	tokens  float64
+	last    time.Time
+	limited uint64
+}
+
+// rateLimiter keeps a token bucket per recently written key.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets *simplelru.LRU
+}
+
+func newRateLimiter(cfg SetRateLimit) *rateLimiter {
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	maxKeys := cfg.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = defaultRateLimitKeys
+	}
+	buckets, _ := simplelru.NewLRU(maxKeys, nil)
+	return &rateLimiter{rate: cfg.PerKeyPerSecond, burst: float64(burst), buckets: buckets}
+}
+
+// allow takes a token from key's bucket and reports whether the Set may go
+// ahead.
+func (r *rateLimiter) allow(key string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b *tokenBucket
+	if v, ok := r.buckets.Get(key); ok {
+		b = v.(*tokenBucket)
+		b.tokens = math.Min(r.burst, b.tokens+now.Sub(b.last).Seconds()*r.rate)
+	} else {
+		b = &tokenBucket{tokens: r.burst}
+		r.buckets.Add(key, b)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+	b.limited++
+	return false
+}
+
+// hottest returns up to n tracked keys that have been limited, most limited
+// first.
+func (r *rateLimiter) hottest(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type entry struct {
+		key     string
+		limited uint64
+	}
+	var entries []entry
+	for _, k := range r.buckets.Keys() {
+		v, _ := r.buckets.Peek(k)
+		if b := v.(*tokenBucket); b.limited > 0 {
+			entries = append(entries, entry{k.(string), b.limited})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].limited != entries[j].limited {
+			return entries[i].limited > entries[j].limited
+		}
+		return entries[i].key < entries[j].key
+	})
+
+	keys := make([]string, 0, n)
+	for i := 0; i < len(entries) && i < n; i++ {
+		keys = append(keys, entries[i].key)
+	}
+	return keys
+}
+
+// refreshTTL moves the deadline of a live entry without replacing its value.
+func (l *LRU) refreshTTL(key string, ttl time.Duration) error {
+	if err := l.checkWritable(key); err != nil {
+		return err
+	}
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	txn := l.db.Txn(true)
+	raw, err := txn.First("cache", "id", key)
+	if err != nil {
+		txn.Abort()
+		return fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil || raw.(*CacheItem).expired(l.clock()) {
+		txn.Abort()
+		return ErrRateLimited
+	}
+
+	item := l.copyItem(raw.(*CacheItem))
+	item.ExpiresAt, item.deadline = l.expiry(ttl)
+	if err := txn.Insert("cache", item); err != nil {
+		txn.Abort()
+		return fmt.Errorf("failed to insert item: %v", err)
+	}
+	txn.Commit()
+	l.retire(raw.(*CacheItem))
+
+	l.expHeap.set(key, item.deadline)
+	l.log("debug", "Rate limited set refreshed TTL for key: %s", l.redact(key))
+	return nil
+}
@@ -0,0 +1,96 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetRateLimit(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:     "error",
+		SetRateLimit: SetRateLimit{PerKeyPerSecond: 2, Burst: 2},
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	// The burst is allowed, the next Set in the same instant is not.
+	want := []error{nil, nil, ErrRateLimited}
+	for i, w := range want {
+		if err := cache.Set("hot", i, time.Hour); err != w {
+			t.Errorf("Set %d: expected %v, got %v", i, w, err)
+		}
+	}
+
+	// Half a second refills exactly one token at two per second.
+	now = now.Add(500 * time.Millisecond)
+	if err := cache.Set("hot", 3, time.Hour); err != nil {
+		t.Errorf("Expected refilled Set to pass, got %v", err)
+	}
+	if err := cache.Set("hot", 4, time.Hour); err != ErrRateLimited {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+
+	// Other keys have their own budget.
+	if err := cache.Set("cold", 1, time.Hour); err != nil {
+		t.Errorf("Expected Set on another key to pass, got %v", err)
+	}
+
+	if v, _ := cache.Get("hot"); v.(int) != 3 {
+		t.Errorf("Expected last allowed value 3, got %v", v)
+	}
+
+	stats := cache.Stats()
+	if stats.RateLimitedSets != 2 {
+		t.Errorf("Expected 2 rate limited sets, got %d", stats.RateLimitedSets)
+	}
+	if len(stats.HottestLimitedKeys) != 1 || stats.HottestLimitedKeys[0] != "hot" {
+		t.Errorf("Expected hot to be the hottest limited key, got %v", stats.HottestLimitedKeys)
+	}
+}
+
+func TestSetRateLimitRefreshTTL(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:     "error",
+		SetRateLimit: SetRateLimit{PerKeyPerSecond: 1, RefreshTTL: true},
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("key", "first", time.Minute)
+	now = now.Add(100 * time.Millisecond)
+	if err := cache.Set("key", "second", time.Hour); err != nil {
+		t.Fatalf("Expected limited Set to refresh the TTL, got %v", err)
+	}
+
+	if v, _ := cache.Get("key"); v.(string) != "first" {
+		t.Errorf("Expected the value to be kept, got %v", v)
+	}
+	now = now.Add(30 * time.Minute)
+	if _, err := cache.Get("key"); err != nil {
+		t.Errorf("Expected the refreshed TTL to apply, got %v", err)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent heap, got %v", err)
+	}
+}
+
+func TestSetRateLimitEvictsStaleBuckets(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:     "error",
+		SetRateLimit: SetRateLimit{PerKeyPerSecond: 1, MaxKeys: 2},
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", 1, time.Hour)
+	if err := cache.Set("a", 1, time.Hour); err != ErrRateLimited {
+		t.Fatalf("Expected ErrRateLimited, got %v", err)
+	}
+
+	// Two other keys push a's bucket out, so it starts over with a full burst.
+	cache.Set("b", 1, time.Hour)
+	cache.Set("c", 1, time.Hour)
+	if err := cache.Set("a", 2, time.Hour); err != nil {
+		t.Errorf("Expected a fresh bucket for a, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// errLastRead is returned by get together with the value when the read used
+// up the last read of an entry stored with SetWithReadLimit.
+var errLastRead = errors.New("last read")
+
+// SetWithReadLimit stores value under key like Set, but the entry can only
+// be read maxReads times. The read that uses up the limit still returns the
+// value and then removes the entry with ReasonConsumed; reads racing for the
+// last one are decided atomically, so exactly maxReads of them succeed. The
+// entry also expires after ttl as usual.
+func (l *LRU) SetWithReadLimit(key string, value interface{}, ttl time.Duration, maxReads int) error {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	if ttl <= 0 && ttl != NoExpiration {
+		return l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+	if maxReads <= 0 {
+		return l.invalid(key, "maxReads must be positive")
+	}
+	if err := l.checkUsefulTTL(ttl); err != nil {
+		return err
+	}
+
+	data, err := serialize(value)
+	if err != nil {
+		return fmt.Errorf("failed to serialize value: %v", err)
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	item := l.newItem(key, data, ttl)
+	item.reads = &atomic.Int64{}
+	item.reads.Store(int64(maxReads))
+	return l.store(item, nil)
+}
+
+// consumeRead takes one read from a read limited item. It returns
+// errLastRead when that was the last read and ErrItemNotFound when none were
+// left, in which case the entry is already on its way out.
+func (i *CacheItem) consumeRead() error {
+	if i.reads == nil {
+		return nil
+	}
+	switch left := i.reads.Add(-1); {
+	case left < 0:
+		return ErrItemNotFound
+	case left == 0:
+		return errLastRead
+	}
+	return nil
+}
+
+// removeConsumed removes key once its last read has been taken. A newer
+// entry stored under key in the meantime is left alone.
+func (l *LRU) removeConsumed(key string) error {
+	if l.Draining() {
+		return nil
+	}
+	defer l.checkWatermarks()
+	if !l.tryWriteLock("delete") {
+		return nil
+	}
+	defer l.lock.Unlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		return nil
+	}
+	if reads := raw.(*CacheItem).reads; reads == nil || reads.Load() > 0 {
+		return nil
+	}
+	return l.removeItem(key, ReasonConsumed)
+}
@@ -0,0 +1,108 @@
+package lrucache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetWithReadLimitRace(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	if err := cache.SetWithReadLimit("token", "secret", time.Minute, 1); err != nil {
+		t.Fatalf("SetWithReadLimit failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var got atomic.Int32
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := cache.Get("token"); err == nil && v == "secret" {
+				got.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := got.Load(); n != 1 {
+		t.Fatalf("Expected exactly one read to succeed, got %d", n)
+	}
+	if keys := liveKeys(cache); len(keys) != 0 {
+		t.Errorf("Expected the consumed entry to be removed, got %v", keys)
+	}
+}
+
+func TestSetWithReadLimitConsumed(t *testing.T) {
+	var reasons []EvictReason
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		RemovalCallback: func(key string, value interface{}, reason EvictReason) {
+			reasons = append(reasons, reason)
+		},
+	})
+	cache.SetWithReadLimit("key", "v", time.Minute, 3)
+
+	for i := 0; i < 3; i++ {
+		if v, err := cache.Get("key"); err != nil || v != "v" {
+			t.Fatalf("Read %d: expected v, got %v, %v", i+1, v, err)
+		}
+	}
+	if _, err := cache.Get("key"); err != ErrItemNotFound {
+		t.Fatalf("Expected ErrItemNotFound after the last read, got %v", err)
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonConsumed {
+		t.Errorf("Expected one removal with ReasonConsumed, got %v", reasons)
+	}
+	if ReasonConsumed.String() != "consumed" {
+		t.Errorf("Expected \"consumed\", got %q", ReasonConsumed.String())
+	}
+}
+
+func TestSetWithReadLimitExpiresFirst(t *testing.T) {
+	var reasons []EvictReason
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		RemovalCallback: func(key string, value interface{}, reason EvictReason) {
+			reasons = append(reasons, reason)
+		},
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.SetWithReadLimit("key", "v", time.Second, 5)
+	if _, err := cache.Get("key"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, err := cache.Get("key"); err != ErrItemExpired {
+		t.Fatalf("Expected ErrItemExpired, got %v", err)
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonExpired {
+		t.Errorf("Expected one removal with ReasonExpired, got %v", reasons)
+	}
+}
+
+func TestSetWithReadLimitReplaced(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.SetWithReadLimit("key", "old", time.Minute, 1)
+	cache.Set("key", "new", time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if v, err := cache.Get("key"); err != nil || v != "new" {
+			t.Fatalf("Expected the replacement to have no read limit, got %v, %v", v, err)
+		}
+	}
+}
+
+func TestSetWithReadLimitInvalid(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	if err := cache.SetWithReadLimit("key", "v", time.Minute, 0); err == nil {
+		t.Error("Expected an error for maxReads 0")
+	}
+	if err := cache.SetWithReadLimit("key", "v", 0, 1); err == nil {
+		t.Error("Expected an error for a zero ttl")
+	}
+}
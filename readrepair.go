@@ -0,0 +1,114 @@
+package lrucache
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+const (
+	defaultReadRepairLimit  = 3
+	defaultReadRepairWindow = time.Minute
+)
+
+// repairWindow counts the repairs of one key since start.
+type repairWindow struct {
+	start time.Time
+	n     int
+}
+
+// repairLimiter caps the read repairs of each key within a fixed window. It
+// tracks at most as many keys as the cache itself.
+type repairLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	windows *simplelru.LRU
+}
+
+func newRepairLimiter(size int, opts Options) *repairLimiter {
+	limit, window := opts.ReadRepairLimit, opts.ReadRepairWindow
+	if limit <= 0 {
+		limit = defaultReadRepairLimit
+	}
+	if window <= 0 {
+		window = defaultReadRepairWindow
+	}
+	windows, _ := simplelru.NewLRU(size, nil)
+	return &repairLimiter{limit: limit, window: window, windows: windows}
+}
+
+// allow counts a repair of key at now and reports whether it is within the
+// limit.
+func (r *repairLimiter) allow(key string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v, ok := r.windows.Get(key)
+	if !ok || now.Sub(v.(*repairWindow).start) >= r.window {
+		r.windows.Add(key, &repairWindow{start: now, n: 1})
+		return true
+	}
+	w := v.(*repairWindow)
+	if w.n >= r.limit {
+		return false
+	}
+	w.n++
+	return true
+}
+
+// repairable reports whether a decode failure means the stored bytes are
+// bad. Values rejected by the decode limits are intact and are left alone.
+func repairable(err error) bool {
+	return !errors.Is(err, ErrDeserialization)
+}
+
+// repairOutcome tells a read what readRepair did with the entry it failed
+// to decode.
+type repairOutcome int
+
+const (
+	repairKept      repairOutcome = iota // left in place; the read fails
+	repairRemoved                        // gone; the read is a miss
+	repairRewritten                      // replaced since; the read retries
+)
+
+// readRepair removes bad, the entry under key whose value a read failed to
+// decode. It leaves the entry while draining or quiesced and once the key
+// is over Options.ReadRepairLimit. Like update, it only touches the row the
+// read saw: the read's lastModified tells bad from a later write of the key
+// that reused its arena slot.
+func (l *LRU) readRepair(key string, bad *CacheItem, lastModified time.Time) repairOutcome {
+	if l.Draining() {
+		return repairKept
+	}
+	defer l.checkWatermarks()
+	if !l.tryWriteLock("delete") {
+		return repairKept
+	}
+	defer l.lock.Unlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return repairKept
+	}
+	if raw == nil {
+		// Repaired by a concurrent read.
+		return repairRemoved
+	}
+	if item := raw.(*CacheItem); item != bad || !item.LastModified.Equal(lastModified) {
+		return repairRewritten
+	}
+	if !l.repairs.allow(key, l.clock()) {
+		l.log("warn", "Not repairing key %s again: over the read repair limit", l.redact(key))
+		return repairKept
+	}
+	if err := l.removeItem(key, ReasonCorrupt); err != nil {
+		return repairKept
+	}
+	l.stats.readRepairs.Add(1)
+	l.log("warn", "Removed undecodable entry for key %s", l.redact(key))
+	return repairRemoved
+}
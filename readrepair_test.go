@@ -0,0 +1,176 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// corrupt overwrites the stored bytes of key with ones that fail to decode.
+func corrupt(t *testing.T, cache *LRU, key string) {
+	t.Helper()
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	raw, err := cache.db.Txn(false).First("cache", "id", key)
+	if err != nil || raw == nil {
+		t.Fatalf("Expected %s to be cached, got %v", key, err)
+	}
+	raw.(*CacheItem).Value = []byte{0xff, '1'}
+}
+
+func TestReadRepairWithLoader(t *testing.T) {
+	var loads atomic.Int32
+	var reasons []EvictReason
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		Loader: func(key string) (interface{}, time.Duration, error) {
+			loads.Add(1)
+			return "fresh " + key, time.Hour, nil
+		},
+		RemovalCallback: func(_ string, _ interface{}, reason EvictReason) { reasons = append(reasons, reason) },
+	})
+	cache.Set("k", "stale", time.Hour)
+	corrupt(t, cache, "k")
+
+	if v, err := cache.Get("k"); err != nil || v != "fresh k" {
+		t.Fatalf("Expected the repaired value from the same Get, got %v, %v", v, err)
+	}
+	if v, err := cache.Get("k"); err != nil || v != "fresh k" {
+		t.Errorf("Expected the repaired value to be stored, got %v, %v", v, err)
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("Expected one load, got %d", n)
+	}
+	if stats := cache.Stats(); stats.ReadRepairs != 1 || stats.Misses != 1 || stats.Hits != 1 {
+		t.Errorf("Expected 1 repair, 1 miss and 1 hit, got %d, %d and %d", stats.ReadRepairs, stats.Misses, stats.Hits)
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonCorrupt {
+		t.Errorf("Expected one removal with ReasonCorrupt, got %v", reasons)
+	}
+}
+
+func TestReadRepairWithoutLoader(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", MaxDecodeBytes: 64})
+	cache.Set("k", "v", time.Hour)
+	cache.Set("big", string(make([]byte, 100)), time.Hour)
+	corrupt(t, cache, "k")
+
+	if _, err := cache.Get("k"); err != ErrItemNotFound {
+		t.Errorf("Expected the bad entry to read as a miss, got %v", err)
+	}
+	if _, err := cache.Get("big"); !errors.Is(err, ErrDeserialization) {
+		t.Errorf("Expected a value over the decode limits to keep failing, got %v", err)
+	}
+	if keys := cache.Keys(); len(keys) != 1 || keys[0] != "big" {
+		t.Errorf("Expected only the bad entry to be removed, got %v", keys)
+	}
+	if n := cache.Stats().ReadRepairs; n != 1 {
+		t.Errorf("Expected 1 repair, got %d", n)
+	}
+}
+
+func TestReadRepairLimit(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", ReadRepairLimit: 2, ReadRepairWindow: time.Minute})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		cache.Set("k", "v", time.Hour)
+		corrupt(t, cache, "k")
+		if _, err := cache.Get("k"); err != ErrItemNotFound {
+			t.Fatalf("Expected repair %d to go ahead, got %v", i+1, err)
+		}
+	}
+	cache.Set("k", "v", time.Hour)
+	corrupt(t, cache, "k")
+	if _, err := cache.Get("k"); err == nil || err == ErrItemNotFound {
+		t.Errorf("Expected the decode error past the limit, got %v", err)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Expected the entry to be left in place past the limit")
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := cache.Get("k"); err != ErrItemNotFound {
+		t.Errorf("Expected a new window to allow a repair, got %v", err)
+	}
+	if n := cache.Stats().ReadRepairs; n != 3 {
+		t.Errorf("Expected 3 repairs, got %d", n)
+	}
+}
+
+func TestReadRepairConcurrent(t *testing.T) {
+	var loads atomic.Int32
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		Loader: func(key string) (interface{}, time.Duration, error) {
+			loads.Add(1)
+			time.Sleep(10 * time.Millisecond)
+			return "fresh", time.Hour, nil
+		},
+	})
+	cache.Set("k", "stale", time.Hour)
+	corrupt(t, cache, "k")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := cache.Get("k"); err != nil || v != "fresh" {
+				t.Errorf("Expected every reader to get the repaired value, got %v, %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := loads.Load(); n != 1 {
+		t.Errorf("Expected the repair loads to be coalesced, got %d", n)
+	}
+	if n := cache.Stats().ReadRepairs; n != 1 {
+		t.Errorf("Expected 1 repair, got %d", n)
+	}
+}
+
+func TestReadRepairSkipsRewrittenEntry(t *testing.T) {
+	for _, prealloc := range []int{0, 4} {
+		t.Run(fmt.Sprintf("Preallocate=%d", prealloc), func(t *testing.T) {
+			var removed []string
+			cache, _ := NewLRUWithTTL(10, Options{
+				LogLevel:        "error",
+				Preallocate:     prealloc,
+				RemovalCallback: func(key string, _ interface{}, _ EvictReason) { removed = append(removed, key) },
+			})
+			cache.Set("k", "stale", time.Hour)
+			corrupt(t, cache, "k")
+
+			// The read fails to decode, then writes land before the repair
+			// takes the write lock. With the arena, the second one reuses the
+			// slot the first retired: that of the bad entry.
+			r, err := cache.get("k", 0, time.Time{})
+			if err == nil || r.corrupt == nil {
+				t.Fatalf("Expected a failed decode, got %v", err)
+			}
+			cache.Set("k", "interim", time.Hour)
+			cache.Set("k", "good", time.Hour)
+			if prealloc > 0 {
+				raw, _ := cache.db.Txn(false).First("cache", "id", "k")
+				if raw.(*CacheItem) != r.corrupt {
+					t.Fatalf("Expected the arena to reuse the slot of the bad entry")
+				}
+			}
+
+			if got := cache.readRepair("k", r.corrupt, r.lastModified); got != repairRewritten {
+				t.Errorf("Expected the rewrite to be noticed, got %v", got)
+			}
+			if v, err := cache.Get("k"); err != nil || v != "good" {
+				t.Errorf("Expected the new value to survive, got %v, %v", v, err)
+			}
+			if n := cache.Stats().ReadRepairs; n != 0 || len(removed) != 0 {
+				t.Errorf("Expected no repair, got %d repairs and removals %v", n, removed)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package lrucache
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// KeyRedactor maps a key to the form it takes in logs, decision traces,
+// audit reports, DumpTable, Stats().Groups and DebugPanics messages. It is
+// never applied to what the cache returns to callers, such as Keys or the
+// key passed to callbacks. Key prefixes, such as those of TouchPrefix or a
+// scheduled invalidation, are redacted like keys.
+type KeyRedactor func(key string) string
+
+// redactSalt keys the hashes of HashKeyRedactor. It is drawn once per
+// process, so the same key redacts alike in every cache of the process and
+// differently after a restart.
+var redactSalt = func() []byte {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		panic("lrucache: failed to draw the redaction salt: " + err.Error())
+	}
+	return salt
+}()
+
+// HashKeyRedactor returns a KeyRedactor that replaces keys with a salted
+// hash. The part of the key up to and including the first sep is kept in
+// the clear, so that "user:42" becomes "user:" followed by the hash of "42";
+// an empty sep, or a key without it, is hashed whole. Equal keys redact
+// alike within the process, so redacted logs can still be correlated.
+func HashKeyRedactor(sep string) KeyRedactor {
+	return func(key string) string {
+		var prefix string
+		if sep != "" {
+			if i := strings.Index(key, sep); i >= 0 {
+				prefix, key = key[:i+len(sep)], key[i+len(sep):]
+			}
+		}
+		mac := hmac.New(sha256.New, redactSalt)
+		mac.Write([]byte(key))
+		return prefix + hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+}
+
+// redact returns key as Options.KeyRedactor has it shown.
+func (l *LRU) redact(key string) string {
+	if l.opts.KeyRedactor == nil || key == "" {
+		return key
+	}
+	return l.opts.KeyRedactor(key)
+}
+
+// redactAll is redact for a list of keys.
+func (l *LRU) redactAll(keys []string) []string {
+	if l.opts.KeyRedactor == nil || len(keys) == 0 {
+		return keys
+	}
+	out := make([]string, len(keys))
+	for i, key := range keys {
+		out[i] = l.redact(key)
+	}
+	return out
+}
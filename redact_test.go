@@ -0,0 +1,124 @@
+package lrucache
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashKeyRedactor(t *testing.T) {
+	redact := HashKeyRedactor(":")
+	a := redact("user:42")
+	if want := "user:" + saltedHash("42"); a != want {
+		t.Errorf("Expected the prefix kept and the id hashed to %q, got %q", want, a)
+	}
+	if b := redact("user:42"); a != b {
+		t.Errorf("Expected equal keys to redact alike, got %q and %q", a, b)
+	}
+	if b := redact("user:43"); a == b {
+		t.Errorf("Expected distinct keys to redact apart, both gave %q", a)
+	}
+	if whole := redact("session-42"); whole != saltedHash("session-42") {
+		t.Errorf("Expected a key without the separator to be hashed whole, got %q", whole)
+	}
+	if whole := HashKeyRedactor("")("user:42"); whole != saltedHash("user:42") {
+		t.Errorf("Expected an empty separator to hash the whole key, got %q", whole)
+	}
+}
+
+// saltedHash is what HashKeyRedactor replaces s with.
+func saltedHash(s string) string {
+	mac := hmac.New(sha256.New, redactSalt)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// TestKeyRedactorSurfaces goes through every output meant for people or
+// monitoring and checks that the secret part of the key never shows up in
+// it, while the functional API keeps returning the key itself.
+func TestKeyRedactorSurfaces(t *testing.T) {
+	const key = "user:secret42"
+	redact := HashKeyRedactor(":")
+	want := redact(key)
+
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	var evicted []string
+	cache, _ := NewLRUWithTTL(1, Options{
+		LogLevel:        "debug",
+		KeyRedactor:     redact,
+		DebugPanics:     true,
+		StatsKeyGrouper: func(key string) string { return key },
+		EvictCallback:   func(key string, _ interface{}) { evicted = append(evicted, key) },
+	})
+	cache.Set(key, "v", time.Minute)
+	cache.Get(key)
+	cache.Touch(key, time.Hour)
+
+	check := func(surface, out string) {
+		t.Helper()
+		if strings.Contains(out, "secret42") {
+			t.Errorf("%s leaks the key: %s", surface, out)
+		}
+		if !strings.Contains(out, want) {
+			t.Errorf("%s does not show the redacted key %q: %s", surface, want, out)
+		}
+	}
+
+	check("log", logs.String())
+
+	var table bytes.Buffer
+	if err := cache.DumpTable(&table, DumpOptions{MaxKeyWidth: 100}); err != nil {
+		t.Fatalf("DumpTable failed: %v", err)
+	}
+	check("DumpTable", table.String())
+
+	var groups []string
+	for name := range cache.Stats().Groups {
+		groups = append(groups, name)
+	}
+	check("Stats().Groups", strings.Join(groups, " "))
+
+	if decisionTracing {
+		var trace []string
+		for _, d := range cache.LastDecisions(10) {
+			trace = append(trace, d.String())
+		}
+		check("LastDecisions", strings.Join(trace, "\n"))
+	}
+
+	if err := panics(func() { cache.Set(key, 1, 0) }); err == nil {
+		t.Errorf("Expected a DebugPanics panic")
+	} else {
+		check("DebugPanics", err.Error())
+	}
+
+	cache.lock.Lock()
+	heap.Push(cache.expHeap, "user:orphan7")
+	cache.lock.Unlock()
+	if err := cache.Validate(); err == nil || strings.Contains(err.Error(), "orphan7") {
+		t.Errorf("Expected Validate to report a redacted orphan, got %v", err)
+	}
+	logs.Reset()
+	cache.audit()
+	if out := logs.String(); !strings.Contains(out, "Audit repaired") || strings.Contains(out, "orphan7") {
+		t.Errorf("Expected the audit log to redact the orphan, got %s", out)
+	}
+
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{key}) {
+		t.Errorf("Expected Keys to return the key itself, got %v", keys)
+	}
+	cache.Set("user:other", "w", 2*time.Hour)
+	if !reflect.DeepEqual(evicted, []string{key}) {
+		t.Errorf("Expected callbacks to get the key itself, got %v", evicted)
+	}
+}
@@ -0,0 +1,158 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schedule says when a scheduled invalidation fires. Build one with DailyAt
+// or Every.
+type Schedule struct {
+	daily        bool
+	hour, minute int
+	loc          *time.Location
+	every        time.Duration
+}
+
+// DailyAt fires once a day at hour:minute in loc, or in UTC if loc is nil.
+func DailyAt(hour, minute int, loc *time.Location) Schedule {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return Schedule{daily: true, hour: hour, minute: minute, loc: loc}
+}
+
+// Every fires repeatedly, interval apart, starting interval after the rule
+// is scheduled.
+func Every(interval time.Duration) Schedule {
+	return Schedule{every: interval}
+}
+
+func (s Schedule) validate() error {
+	switch {
+	case s.daily:
+		if s.hour < 0 || s.hour > 23 || s.minute < 0 || s.minute > 59 {
+			return fmt.Errorf("invalid time of day %02d:%02d", s.hour, s.minute)
+		}
+	case s.every <= 0:
+		return errors.New("schedule must be DailyAt or a positive Every")
+	}
+	return nil
+}
+
+// next returns the first time the schedule fires after t.
+func (s Schedule) next(t time.Time) time.Time {
+	if !s.daily {
+		return t.Add(s.every)
+	}
+	local := t.In(s.loc)
+	at := time.Date(local.Year(), local.Month(), local.Day(), s.hour, s.minute, 0, 0, s.loc)
+	if !at.After(local) {
+		at = time.Date(local.Year(), local.Month(), local.Day()+1, s.hour, s.minute, 0, 0, s.loc)
+	}
+	return at
+}
+
+type scheduledRule struct {
+	schedule Schedule
+	prefix   string
+	next     time.Time
+}
+
+type scheduler struct {
+	lastID int
+	rules  map[string]*scheduledRule
+}
+
+// ScheduleInvalidation removes every key starting with prefix each time spec
+// fires, regardless of TTLs. Rules are checked by the background sweep, so
+// they fire within a sweep interval of their scheduled time. The returned id
+// cancels the rule.
+func (l *LRU) ScheduleInvalidation(spec Schedule, prefix string) (id string, err error) {
+	prefix = l.NormalizeKey(prefix)
+	if err := spec.validate(); err != nil {
+		return "", err
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	if l.schedules.rules == nil {
+		l.schedules.rules = make(map[string]*scheduledRule)
+	}
+	l.schedules.lastID++
+	id = fmt.Sprintf("invalidation-%d", l.schedules.lastID)
+	l.schedules.rules[id] = &scheduledRule{schedule: spec, prefix: prefix, next: spec.next(l.now())}
+	return id, nil
+}
+
+// CancelScheduledInvalidation stops the rule with the given id and reports
+// whether it existed.
+func (l *LRU) CancelScheduledInvalidation(id string) bool {
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	if _, ok := l.schedules.rules[id]; !ok {
+		return false
+	}
+	delete(l.schedules.rules, id)
+	return true
+}
+
+// runSchedules fires every rule that is due. Rules that missed several
+// firings fire once.
+func (l *LRU) runSchedules() {
+	l.writeLock("sweep")
+	defer l.lock.Unlock()
+
+	ids := make([]string, 0, len(l.schedules.rules))
+	for id := range l.schedules.rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	now := l.now()
+	for _, id := range ids {
+		rule := l.schedules.rules[id]
+		if now.Before(rule.next) {
+			continue
+		}
+		removed := l.invalidatePrefix(rule.prefix)
+		rule.next = rule.schedule.next(now)
+		l.stats.scheduledFired.Add(1)
+		l.log("info", "Scheduled invalidation %s removed %d keys with prefix: %s", id, removed, l.redact(rule.prefix))
+	}
+}
+
+// invalidatePrefix removes every key starting with prefix and returns how
+// many were removed. The caller must hold the write lock.
+func (l *LRU) invalidatePrefix(prefix string) int {
+	it, err := l.db.Txn(false).Get("cache", "id_prefix", prefix)
+	if err != nil {
+		l.log("error", "Failed to get items: %v", err)
+		return 0
+	}
+	var keys []string
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		if key := obj.(*CacheItem).Key; strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	removed := 0
+	for _, key := range keys {
+		// Removing a key can already have invalidated keys derived from it.
+		if raw, err := l.db.Txn(false).First("cache", "id", key); err == nil && raw == nil {
+			continue
+		}
+		if err := l.removeItem(key, ReasonScheduled); err != nil {
+			l.backgroundError(err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
@@ -0,0 +1,110 @@
+package lrucache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func liveKeys(cache *LRU) []string {
+	var keys []string
+	it, _ := cache.db.Txn(false).Get("cache", "id")
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		keys = append(keys, obj.(*CacheItem).Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestScheduleInvalidationDaily(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Date(2024, 3, 1, 23, 50, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	if _, err := cache.ScheduleInvalidation(DailyAt(0, 5, nil), "price:"); err != nil {
+		t.Fatalf("ScheduleInvalidation failed: %v", err)
+	}
+	cache.Set("price:apple", 1, 48*time.Hour)
+	cache.Set("price:pear", 2, 48*time.Hour)
+	cache.Set("stock:apple", 3, 48*time.Hour)
+
+	now = time.Date(2024, 3, 2, 0, 4, 59, 0, time.UTC)
+	cache.runSchedules()
+	if got := liveKeys(cache); len(got) != 3 {
+		t.Fatalf("Expected nothing removed before 00:05, got %v", got)
+	}
+
+	now = time.Date(2024, 3, 2, 0, 5, 0, 0, time.UTC)
+	cache.runSchedules()
+	if got, want := liveKeys(cache), []string{"stock:apple"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v after firing, got %v", want, got)
+	}
+	if fired := cache.Stats().ScheduledInvalidations; fired != 1 {
+		t.Errorf("Expected 1 firing, got %d", fired)
+	}
+
+	// The next firing is a day later.
+	cache.Set("price:apple", 1, 48*time.Hour)
+	now = now.Add(23 * time.Hour)
+	cache.runSchedules()
+	if _, err := cache.Get("price:apple"); err != nil {
+		t.Errorf("Expected price:apple to survive until the next day, got %v", err)
+	}
+	now = now.Add(time.Hour)
+	cache.runSchedules()
+	if _, err := cache.Get("price:apple"); err != ErrItemNotFound {
+		t.Errorf("Expected price:apple to be removed on the next day, got %v", err)
+	}
+}
+
+func TestScheduleInvalidationEveryAndCancel(t *testing.T) {
+	var reasons []EvictReason
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		RemovalCallback: func(key string, value interface{}, reason EvictReason) {
+			reasons = append(reasons, reason)
+		},
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	id, err := cache.ScheduleInvalidation(Every(10*time.Minute), "session:")
+	if err != nil {
+		t.Fatalf("ScheduleInvalidation failed: %v", err)
+	}
+	cache.Set("session:1", "a", time.Hour)
+	cache.Set("user:1", "b", time.Hour)
+
+	now = now.Add(10 * time.Minute)
+	cache.runSchedules()
+	if got, want := liveKeys(cache), []string{"user:1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v after firing, got %v", want, got)
+	}
+	if !reflect.DeepEqual(reasons, []EvictReason{ReasonScheduled}) {
+		t.Errorf("Expected one scheduled removal, got %v", reasons)
+	}
+
+	if !cache.CancelScheduledInvalidation(id) {
+		t.Error("Expected cancel to find the rule")
+	}
+	if cache.CancelScheduledInvalidation(id) {
+		t.Error("Expected second cancel to report a missing rule")
+	}
+	cache.Set("session:2", "c", time.Hour)
+	now = now.Add(time.Hour)
+	cache.runSchedules()
+	if _, err := cache.Get("session:2"); err != nil {
+		t.Errorf("Expected cancelled rule not to fire, got %v", err)
+	}
+}
+
+func TestScheduleInvalidationRejectsInvalidSpecs(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	for _, spec := range []Schedule{{}, Every(0), DailyAt(24, 0, nil), DailyAt(0, 60, nil)} {
+		if _, err := cache.ScheduleInvalidation(spec, "x"); err == nil {
+			t.Errorf("Expected %+v to be rejected", spec)
+		}
+	}
+}
@@ -1,56 +1,223 @@
 package lrucache
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 )
 
+// Every serialized value starts with a one byte type tag so that deserialize
+// can hand back exactly the Go type that was stored.
+const (
+	tagString byte = iota + 1
+	tagBytes
+	tagInt
+	tagInt8
+	tagInt16
+	tagInt32
+	tagInt64
+	tagUint
+	tagUint8
+	tagUint16
+	tagUint32
+	tagUint64
+	tagFloat32
+	tagFloat64
+	tagBool
+	tagJSON
+)
+
+func tagged(tag byte, payload string) []byte {
+	data := make([]byte, 0, len(payload)+1)
+	data = append(data, tag)
+	return append(data, payload...)
+}
+
+// maxNumberLen is the longest text strconv produces for any number the
+// serializer stores, so numbers are encoded with a single allocation.
+const maxNumberLen = 24
+
+func numeric(tag byte) []byte {
+	data := make([]byte, 1, 1+maxNumberLen)
+	data[0] = tag
+	return data
+}
+
+const maxPooledJSONBuffer = 64 << 10
+
+// jsonBuffers holds the scratch buffers JSON values are encoded into
+// before being copied out at their exact size.
+var jsonBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
 func serialize(value interface{}) ([]byte, error) {
 	switch v := value.(type) {
 	case string:
-		return []byte(v), nil
+		return tagged(tagString, v), nil
 	case []byte:
-		return v, nil
+		return tagged(tagBytes, string(v)), nil
 	case int:
-		return []byte(fmt.Sprintf("%d", v)), nil
+		return strconv.AppendInt(numeric(tagInt), int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(numeric(tagInt8), int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(numeric(tagInt16), int64(v), 10), nil
 	case int32:
-		return []byte(fmt.Sprintf("%d", v)), nil
+		return strconv.AppendInt(numeric(tagInt32), int64(v), 10), nil
 	case int64:
-		return []byte(fmt.Sprintf("%d", v)), nil
+		return strconv.AppendInt(numeric(tagInt64), v, 10), nil
+	case uint:
+		return strconv.AppendUint(numeric(tagUint), uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(numeric(tagUint8), uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(numeric(tagUint16), uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(numeric(tagUint32), uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(numeric(tagUint64), v, 10), nil
 	case float32:
-		return []byte(fmt.Sprintf("%f", v)), nil
+		return strconv.AppendFloat(numeric(tagFloat32), float64(v), 'g', -1, 32), nil
 	case float64:
-		return []byte(fmt.Sprintf("%f", v)), nil
+		return strconv.AppendFloat(numeric(tagFloat64), v, 'g', -1, 64), nil
 	case bool:
-		return []byte(fmt.Sprintf("%t", v)), nil
+		return strconv.AppendBool(numeric(tagBool), v), nil
 	default:
-		return json.Marshal(v)
+		return serializeJSON(v)
 	}
 }
 
-func deserialize(data []byte) (interface{}, error) {
-	// Try to parse as int
-	if i, err := strconv.Atoi(string(data)); err == nil {
-		return i, nil
+// serializeJSON encodes v as json.Marshal would, through a pooled buffer.
+func serializeJSON(v interface{}) ([]byte, error) {
+	buf := jsonBuffers.Get().(*bytes.Buffer)
+	defer func() {
+		// Keep exceptionally large values from pinning their buffer.
+		if buf.Cap() <= maxPooledJSONBuffer {
+			jsonBuffers.Put(buf)
+		}
+	}()
+	buf.Reset()
+
+	buf.WriteByte(tagJSON)
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	// Encode ends the document with a newline that Marshal does not.
+	encoded := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	return append([]byte(nil), encoded...), nil
+}
+
+// defaultMaxDecodeDepth bounds the nesting of stored JSON values when
+// Options.MaxDecodeDepth is zero.
+const defaultMaxDecodeDepth = 1000
+
+// decode deserializes a stored value within the limits set in Options.
+func (l *LRU) decode(data []byte) (interface{}, error) {
+	return deserializeLimited(data, l.opts.MaxDecodeDepth, l.opts.MaxDecodeBytes)
+}
+
+// deserializeLimited is deserialize for values that may be untrusted. Values
+// longer than maxBytes, when positive, and JSON nested deeper than maxDepth
+// are rejected with ErrDeserialization before any decoding work is done.
+func deserializeLimited(data []byte, maxDepth, maxBytes int) (interface{}, error) {
+	if maxBytes > 0 && len(data) > maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes, at most %d allowed", ErrDeserialization, len(data), maxBytes)
+	}
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxDecodeDepth
 	}
+	if len(data) > 0 && data[0] == tagJSON && exceedsDepth(data[1:], maxDepth) {
+		return nil, fmt.Errorf("%w: nested deeper than %d", ErrDeserialization, maxDepth)
+	}
+	return deserialize(data)
+}
 
-	// Try to parse as float
-	if f, err := strconv.ParseFloat(string(data), 64); err == nil {
-		return f, nil
+// exceedsDepth reports whether the arrays and objects in the JSON document
+// nest deeper than max. It stops at the first level past max.
+func exceedsDepth(doc []byte, max int) bool {
+	depth := 0
+	inString, escaped := false, false
+	for _, b := range doc {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+			continue
+		}
+		switch b {
+		case '"':
+			inString = true
+		case '[', '{':
+			if depth++; depth > max {
+				return true
+			}
+		case ']', '}':
+			depth--
+		}
 	}
+	return false
+}
 
-	// Try to parse as bool
-	if b, err := strconv.ParseBool(string(data)); err == nil {
-		return b, nil
+func deserialize(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, errors.New("missing type tag")
 	}
 
-	// If not a simple type, try JSON
-	var value interface{}
-	if err := json.Unmarshal(data, &value); err == nil {
+	payload := string(data[1:])
+	switch data[0] {
+	case tagString:
+		return payload, nil
+	case tagBytes:
+		return []byte(payload), nil
+	case tagInt:
+		i, err := strconv.ParseInt(payload, 10, strconv.IntSize)
+		return int(i), err
+	case tagInt8:
+		i, err := strconv.ParseInt(payload, 10, 8)
+		return int8(i), err
+	case tagInt16:
+		i, err := strconv.ParseInt(payload, 10, 16)
+		return int16(i), err
+	case tagInt32:
+		i, err := strconv.ParseInt(payload, 10, 32)
+		return int32(i), err
+	case tagInt64:
+		return strconv.ParseInt(payload, 10, 64)
+	case tagUint:
+		u, err := strconv.ParseUint(payload, 10, strconv.IntSize)
+		return uint(u), err
+	case tagUint8:
+		u, err := strconv.ParseUint(payload, 10, 8)
+		return uint8(u), err
+	case tagUint16:
+		u, err := strconv.ParseUint(payload, 10, 16)
+		return uint16(u), err
+	case tagUint32:
+		u, err := strconv.ParseUint(payload, 10, 32)
+		return uint32(u), err
+	case tagUint64:
+		return strconv.ParseUint(payload, 10, 64)
+	case tagFloat32:
+		f, err := strconv.ParseFloat(payload, 32)
+		return float32(f), err
+	case tagFloat64:
+		return strconv.ParseFloat(payload, 64)
+	case tagBool:
+		return strconv.ParseBool(payload)
+	case tagJSON:
+		var value interface{}
+		if err := json.Unmarshal(data[1:], &value); err != nil {
+			return nil, err
+		}
 		return value, nil
+	default:
+		return nil, fmt.Errorf("unknown type tag %d", data[0])
 	}
-
-	// Treat as string
-	return string(data), nil
 }
@@ -0,0 +1,230 @@
+package lrucache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSerdeNumericRoundTrip(t *testing.T) {
+	cases := []struct {
+		name  string
+		value interface{}
+	}{
+		{"int min", int(math.MinInt)},
+		{"int max", int(math.MaxInt)},
+		{"int8 min", int8(math.MinInt8)},
+		{"int8 max", int8(math.MaxInt8)},
+		{"int16 min", int16(math.MinInt16)},
+		{"int16 max", int16(math.MaxInt16)},
+		{"int32 min", int32(math.MinInt32)},
+		{"int32 max", int32(math.MaxInt32)},
+		{"int64 min", int64(math.MinInt64)},
+		{"int64 max", int64(math.MaxInt64)},
+		{"uint zero", uint(0)},
+		{"uint max", uint(math.MaxUint)},
+		{"uint8 max", uint8(math.MaxUint8)},
+		{"uint16 max", uint16(math.MaxUint16)},
+		{"uint32 max", uint32(math.MaxUint32)},
+		{"uint64 above int64", uint64(math.MaxInt64) + 1},
+		{"uint64 max", uint64(math.MaxUint64)},
+		{"float32 max", float32(math.MaxFloat32)},
+		{"float32 smallest", float32(math.SmallestNonzeroFloat32)},
+		{"float32 negative", float32(-1.5)},
+		{"float64 max", math.MaxFloat64},
+		{"float64 smallest", math.SmallestNonzeroFloat64},
+		{"float64 negative", -math.Pi},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, err := serialize(c.value)
+			if err != nil {
+				t.Fatalf("serialize failed: %v", err)
+			}
+			got, err := deserialize(data)
+			if err != nil {
+				t.Fatalf("deserialize failed: %v", err)
+			}
+			if reflect.TypeOf(got) != reflect.TypeOf(c.value) {
+				t.Fatalf("Expected type %T, got %T", c.value, got)
+			}
+			if got != c.value {
+				t.Errorf("Expected %v, got %v", c.value, got)
+			}
+		})
+	}
+}
+
+func TestSerdePreservesTypes(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	cache.Set("numeric string", "42", 1*time.Hour)
+	cache.Set("bytes", []byte("raw"), 1*time.Hour)
+	cache.Set("int32", int32(7), 1*time.Hour)
+	cache.Set("float32", float32(0.1), 1*time.Hour)
+
+	if v, _ := cache.Get("numeric string"); v != "42" {
+		t.Errorf("Expected string \"42\", got %T %v", v, v)
+	}
+	if v, _ := cache.Get("bytes"); !reflect.DeepEqual(v, []byte("raw")) {
+		t.Errorf("Expected []byte raw, got %T %v", v, v)
+	}
+	if v, _ := cache.Get("int32"); v != int32(7) {
+		t.Errorf("Expected int32 7, got %T %v", v, v)
+	}
+	if v, _ := cache.Get("float32"); v != float32(0.1) {
+		t.Errorf("Expected float32 0.1, got %T %v", v, v)
+	}
+}
+
+func TestDeserializeRejectsUnknownTag(t *testing.T) {
+	if _, err := deserialize(nil); err == nil {
+		t.Error("Expected error for empty data")
+	}
+	if _, err := deserialize([]byte{0xff, '1'}); err == nil {
+		t.Error("Expected error for unknown tag")
+	}
+}
+
+func TestDeserializeRejectsDeepNesting(t *testing.T) {
+	doc := strings.Repeat("[", 10000) + strings.Repeat("]", 10000)
+	data := tagged(tagJSON, doc)
+
+	start := time.Now()
+	_, err := deserializeLimited(data, 0, 0)
+	if !errors.Is(err, ErrDeserialization) {
+		t.Fatalf("Expected ErrDeserialization, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected a fast rejection, took %v", elapsed)
+	}
+
+	// Brackets inside strings do not count towards the depth.
+	if _, err := deserializeLimited(tagged(tagJSON, `["[[[[", "\"{{{{"]`), 2, 0); err != nil {
+		t.Errorf("Expected brackets in strings to be ignored, got %v", err)
+	}
+	if _, err := deserializeLimited(tagged(tagJSON, `[[[1]]]`), 2, 0); !errors.Is(err, ErrDeserialization) {
+		t.Errorf("Expected depth 3 to exceed a limit of 2, got %v", err)
+	}
+}
+
+func TestDecodeLimitsOnGet(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", MaxDecodeDepth: 3, MaxDecodeBytes: 64})
+
+	cache.Set("shallow", map[string]interface{}{"a": []interface{}{1}}, time.Hour)
+	cache.Set("deep", []interface{}{[]interface{}{[]interface{}{[]interface{}{}}}}, time.Hour)
+	cache.Set("big", strings.Repeat("x", 100), time.Hour)
+
+	if _, err := cache.Get("shallow"); err != nil {
+		t.Errorf("Expected a value within the limits to decode, got %v", err)
+	}
+	if _, err := cache.Get("deep"); !errors.Is(err, ErrDeserialization) {
+		t.Errorf("Expected ErrDeserialization for a deep value, got %v", err)
+	}
+	if _, err := cache.Get("big"); !errors.Is(err, ErrDeserialization) {
+		t.Errorf("Expected ErrDeserialization for a large value, got %v", err)
+	}
+}
+
+// legacySerialize is the encoding stored values were written with before
+// serialize moved to strconv; both must produce the same bytes.
+func legacySerialize(value interface{}) []byte {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		data, _ := serialize(v)
+		return tagged(data[0], fmt.Sprintf("%d", v))
+	case float32:
+		return tagged(tagFloat32, strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		return tagged(tagFloat64, strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		return tagged(tagBool, fmt.Sprintf("%t", v))
+	default:
+		data, _ := json.Marshal(v)
+		return tagged(tagJSON, string(data))
+	}
+}
+
+func TestSerializeMatchesLegacyEncoding(t *testing.T) {
+	values := []interface{}{
+		0, -1, math.MaxInt, math.MinInt,
+		int8(math.MinInt8), int16(math.MaxInt16), int32(math.MinInt32), int64(math.MinInt64),
+		uint(math.MaxUint), uint8(255), uint16(0), uint32(math.MaxUint32), uint64(math.MaxUint64),
+		float32(-1.17549435e-38), float32(3.5), -2.2250738585072014e-308, math.Inf(1), math.NaN(), 1e21,
+		true, false,
+		map[string]interface{}{"a": []interface{}{1, "<b>", nil}, "c": 1.5},
+		[]int{1, 2, 3}, struct{ Name string }{"x&y"}, nil,
+	}
+	for _, v := range values {
+		got, err := serialize(v)
+		if err != nil {
+			t.Fatalf("serialize(%v) failed: %v", v, err)
+		}
+		if want := legacySerialize(v); string(got) != string(want) {
+			t.Errorf("serialize(%#v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestSerializeNumericAllocations(t *testing.T) {
+	cases := map[string]interface{}{"int": math.MinInt64, "uint64": uint64(math.MaxUint64), "float64": -2.2250738585072014e-308, "bool": true}
+	for name, v := range cases {
+		if allocs := testing.AllocsPerRun(100, func() { serialize(v) }); allocs > 1 {
+			t.Errorf("serialize(%s) made %.0f allocations, want at most 1", name, allocs)
+		}
+	}
+}
+
+func BenchmarkSerializeInt(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		serialize(i)
+	}
+}
+
+func BenchmarkSerializeJSON(b *testing.B) {
+	value := map[string]interface{}{"name": "widget", "tags": []interface{}{"a", "b"}, "price": 9.5}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		serialize(value)
+	}
+}
+
+func BenchmarkSetInt(b *testing.B) {
+	cache, _ := NewLRUWithTTL(1000, Options{LogLevel: "error"})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cache.Set("counter", i, time.Minute)
+	}
+}
+
+func FuzzDeserialize(f *testing.F) {
+	for _, v := range []interface{}{"text", []byte{0, 1}, -7, uint8(3), 1.5, true, map[string]interface{}{"a": []interface{}{1, "b"}}} {
+		data, _ := serialize(v)
+		f.Add(data)
+	}
+	f.Add([]byte{tagJSON, '[', '[', '"', '\\', '"', ']'})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		value, err := deserializeLimited(data, 32, 1<<16)
+		if err != nil {
+			return
+		}
+		// Whatever decodes must encode and decode to the same value again.
+		again, err := serialize(value)
+		if err != nil {
+			t.Fatalf("serialize of decoded %#v failed: %v", value, err)
+		}
+		if _, err := deserialize(again); err != nil {
+			t.Fatalf("round trip of %#v failed: %v", value, err)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package lrucache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// slide moves the deadline of the entry under key, just read with the given
+// ExpiresAt, out to Options.SlidingWindow from now, and returns the entry's
+// ExpiresAt afterwards. The read is done under the read lock and the slide
+// under a short write lock of its own, so an entry rewritten in between is
+// left alone. Sliding is skipped while draining or quiesced.
+func (l *LRU) slide(key string, expiresAt time.Time) time.Time {
+	if !l.opts.SlidingTTL || expiresAt.IsZero() || l.Draining() {
+		return expiresAt
+	}
+	if !l.tryWriteLock("set") {
+		return expiresAt
+	}
+	defer l.lock.Unlock()
+
+	txn := l.db.Txn(true)
+	old, slid, deadline, err := l.slideItem(txn, key, expiresAt)
+	if err != nil || old == nil {
+		txn.Abort()
+		if err != nil {
+			l.log("error", "Failed to slide deadline of key %s: %v", l.redact(key), err)
+		}
+		return expiresAt
+	}
+	txn.Commit()
+	l.retire(old)
+	l.expHeap.set(key, deadline)
+	return slid
+}
+
+// slideMany is slide for the entries GetMulti read, given with their
+// ExpiresAt, in one write transaction.
+func (l *LRU) slideMany(read map[string]time.Time) {
+	if !l.opts.SlidingTTL || len(read) == 0 || l.Draining() {
+		return
+	}
+	if !l.tryWriteLock("set") {
+		return
+	}
+	defer l.lock.Unlock()
+
+	deadlines := make(map[string]time.Time, len(read))
+	var retired []*CacheItem
+	txn := l.db.Txn(true)
+	for key, expiresAt := range read {
+		if expiresAt.IsZero() {
+			continue
+		}
+		old, _, deadline, err := l.slideItem(txn, key, expiresAt)
+		if err != nil {
+			txn.Abort()
+			l.log("error", "Failed to slide deadline of key %s: %v", l.redact(key), err)
+			return
+		}
+		if old != nil {
+			retired = append(retired, old)
+			deadlines[key] = deadline
+		}
+	}
+	txn.Commit()
+	l.retire(retired...)
+	l.expHeap.setMany(deadlines)
+}
+
+// slideItem rewrites the entry under key with a deadline SlidingWindow from
+// now, unless it changed since it was read with expiresAt, has expired or
+// would not live any longer. It returns the entry it replaced, nil if it
+// left it alone, and the new deadlines. The caller must hold the write lock
+// and retire the replaced entry after committing.
+func (l *LRU) slideItem(txn *memdb.Txn, key string, expiresAt time.Time) (old *CacheItem, slid, deadline time.Time, err error) {
+	raw, err := txn.First("cache", "id", key)
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		return nil, time.Time{}, time.Time{}, nil
+	}
+	item := raw.(*CacheItem)
+	if !item.ExpiresAt.Equal(expiresAt) || item.expired(l.clock()) {
+		return nil, time.Time{}, time.Time{}, nil
+	}
+	slid, deadline = l.expiry(l.opts.SlidingWindow)
+	if !deadline.After(item.deadline) {
+		return nil, time.Time{}, time.Time{}, nil
+	}
+	if err := l.touchItem(txn, item, slid, deadline); err != nil {
+		return nil, time.Time{}, time.Time{}, err
+	}
+	return item, slid, deadline, nil
+}
@@ -0,0 +1,123 @@
+package lrucache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSlidingTTL(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", SlidingTTL: true, SlidingWindow: 10 * time.Minute})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("session", "s", 10*time.Minute)
+	cache.Set("other", "o", 15*time.Minute)
+	for i := 0; i < 3; i++ {
+		now = now.Add(8 * time.Minute)
+		if _, err := cache.Get("session"); err != nil {
+			t.Fatalf("Expected the session to stay alive while read, got %v", err)
+		}
+	}
+	if _, expiresAt, err := cache.GetWithExpiration("session"); err != nil || !expiresAt.Equal(now.Add(10*time.Minute)) {
+		t.Errorf("Expected ExpiresAt %v, got %v, %v", now.Add(10*time.Minute), expiresAt, err)
+	}
+	if ttl, _ := cache.TTL("session"); ttl != 10*time.Minute {
+		t.Errorf("Expected 10m left, got %v", ttl)
+	}
+	if _, err := cache.Get("other"); err != ErrItemExpired {
+		t.Errorf("Expected the unread entry to expire, got %v", err)
+	}
+
+	now = now.Add(11 * time.Minute)
+	if _, err := cache.Get("session"); err != ErrItemExpired {
+		t.Errorf("Expected the session to expire once no longer read, got %v", err)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected the heap to follow the slides, got %v", err)
+	}
+}
+
+func TestSlidingTTLNeverShortens(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", SlidingTTL: true, SlidingWindow: time.Minute})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("long", "v", time.Hour)
+	cache.Set("forever", "v", time.Minute)
+	cache.Persist("forever")
+	cache.Get("long")
+	cache.Get("forever")
+
+	if ttl, _ := cache.TTL("long"); ttl != time.Hour {
+		t.Errorf("Expected the longer TTL to be kept, got %v", ttl)
+	}
+	if ttl, _ := cache.TTL("forever"); ttl != NoExpiration {
+		t.Errorf("Expected the persisted entry to keep never expiring, got %v", ttl)
+	}
+}
+
+func TestSlidingTTLOff(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", "v", 10*time.Minute)
+	now = now.Add(8 * time.Minute)
+	cache.Get("a")
+	if ttl, _ := cache.TTL("a"); ttl != 2*time.Minute {
+		t.Errorf("Expected Get to leave the deadline alone, got %v left", ttl)
+	}
+}
+
+func TestSlidingTTLGetMulti(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", SlidingTTL: true, SlidingWindow: 10 * time.Minute})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", 1, 5*time.Minute)
+	cache.Set("b", 2, 6*time.Minute)
+	cache.Set("c", 3, 7*time.Minute)
+	now = now.Add(4 * time.Minute)
+	if _, missing, err := cache.GetMulti([]string{"a", "b", "missing"}); err != nil || len(missing) != 1 {
+		t.Fatalf("GetMulti failed: %v, missing %v", err, missing)
+	}
+	for _, key := range []string{"a", "b"} {
+		if ttl, _ := cache.TTL(key); ttl != 10*time.Minute {
+			t.Errorf("Expected %s to slide to 10m, got %v", key, ttl)
+		}
+	}
+	cache.lock.Lock()
+	order := expirationOrder(cache)
+	cache.lock.Unlock()
+	if len(order) != 3 || order[0] != "c" {
+		t.Errorf("Expected c to expire first now, got %v", order)
+	}
+}
+
+func TestSlidingTTLConcurrent(t *testing.T) {
+	cache, _ := NewLRUWithTTL(20, Options{LogLevel: "error", SlidingTTL: true, SlidingWindow: time.Minute})
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("k%d", (w+i)%40)
+				switch i % 4 {
+				case 0:
+					cache.Set(key, i, time.Duration(1+i%3)*time.Second)
+				case 1:
+					cache.GetMulti([]string{key, "k0", "k1"})
+				default:
+					cache.Get(key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent cache, got %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package lrucache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SharedSnapshot lets caches on one host, typically in separate processes,
+// share their entries through a snapshot file; see SaveSharedSnapshot and
+// RefreshFromSharedSnapshot.
+type SharedSnapshot struct {
+	// Path is the snapshot file. Access to it is serialized with an OS file
+	// lock on Path+".lock".
+	Path string
+	// LockTimeout bounds how long a save or refresh waits for the lock.
+	// Zero waits as long as it takes.
+	LockTimeout time.Duration
+}
+
+const lockPollInterval = 10 * time.Millisecond
+
+// sharedFileHeader is the first line of a shared snapshot file. Generation
+// counts the saves of the file; Size and Checksum cover the export that
+// follows, so a torn or corrupt file is detected before anything is merged.
+type sharedFileHeader struct {
+	Generation uint64 `json:"generation"`
+	Size       int    `json:"size"`
+	Checksum   uint32 `json:"checksum"`
+}
+
+// SaveSharedSnapshot writes a full export of the cache to the shared
+// snapshot file under an exclusive lock. A newer generation written by
+// another cache is merged in first, as RefreshFromSharedSnapshot would, so
+// saves never drop each other's entries. The file is replaced by a rename,
+// so readers see either the old or the new snapshot; a corrupt file is
+// logged and overwritten.
+func (l *LRU) SaveSharedSnapshot() error {
+	path := l.opts.SharedSnapshot.Path
+	if path == "" {
+		return l.invalid("", "SharedSnapshot.Path is not set")
+	}
+	l.sharedMu.Lock()
+	defer l.sharedMu.Unlock()
+	unlock, err := l.lockSharedSnapshot(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	header, body, err := readSharedSnapshot(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case errors.Is(err, ErrSnapshotCorrupt):
+		l.log("warn", "Overwriting shared snapshot: %v", err)
+	case err != nil:
+		return err
+	case header.Generation > l.sharedGen:
+		if err := l.mergeExport(body); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := l.ExportDelta(&buf, 0); err != nil {
+		return err
+	}
+	gen := max(header.Generation, l.sharedGen) + 1
+	if err := writeSharedSnapshot(path, gen, buf.Bytes()); err != nil {
+		return err
+	}
+	l.sharedGen = gen
+	l.log("debug", "Saved shared snapshot generation %d", gen)
+	return nil
+}
+
+// RefreshFromSharedSnapshot merges the shared snapshot file into the cache
+// if another cache has saved a newer generation of it than this one last
+// saved or merged, and reports whether it did. Entries from the file are
+// stored unless the cache holds a live entry for the key that was written
+// at the same time or later; nothing is deleted. A torn or corrupt file
+// fails with ErrSnapshotCorrupt and leaves the cache as it is.
+func (l *LRU) RefreshFromSharedSnapshot() (bool, error) {
+	path := l.opts.SharedSnapshot.Path
+	if path == "" {
+		return false, l.invalid("", "SharedSnapshot.Path is not set")
+	}
+	l.sharedMu.Lock()
+	defer l.sharedMu.Unlock()
+	unlock, err := l.lockSharedSnapshot(false)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	header, body, err := readSharedSnapshot(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if header.Generation <= l.sharedGen {
+		return false, nil
+	}
+	if err := l.mergeExport(body); err != nil {
+		return false, err
+	}
+	l.sharedGen = header.Generation
+	l.log("debug", "Merged shared snapshot generation %d", header.Generation)
+	return true, nil
+}
+
+// SharedSnapshotGeneration returns the generation of the shared snapshot
+// this cache last saved or merged, or 0 if it has done neither.
+func (l *LRU) SharedSnapshotGeneration() uint64 {
+	l.sharedMu.Lock()
+	defer l.sharedMu.Unlock()
+	return l.sharedGen
+}
+
+// lockSharedSnapshot takes the lock on the snapshot's lock file, exclusive
+// for writing, and returns its release.
+func (l *LRU) lockSharedSnapshot(exclusive bool) (func(), error) {
+	opts := l.opts.SharedSnapshot
+	f, err := os.OpenFile(opts.Path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot lock: %v", err)
+	}
+	deadline := time.Now().Add(opts.LockTimeout)
+	for {
+		locked, err := tryLockFile(f, exclusive)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %v", f.Name(), err)
+		}
+		if locked {
+			return func() {
+				unlockFile(f)
+				f.Close()
+			}, nil
+		}
+		if opts.LockTimeout > 0 && time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out after %v waiting for the lock on %s", opts.LockTimeout, f.Name())
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// readSharedSnapshot reads and verifies a shared snapshot file.
+func readSharedSnapshot(path string) (sharedFileHeader, []byte, error) {
+	var header sharedFileHeader
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return header, nil, err
+	}
+	line, body, ok := bytes.Cut(data, []byte("\n"))
+	if !ok || json.Unmarshal(line, &header) != nil {
+		return sharedFileHeader{}, nil, fmt.Errorf("%w: %s: bad header", ErrSnapshotCorrupt, path)
+	}
+	if len(body) != header.Size || crc32.ChecksumIEEE(body) != header.Checksum {
+		return sharedFileHeader{}, nil, fmt.Errorf("%w: %s: checksum mismatch", ErrSnapshotCorrupt, path)
+	}
+	return header, body, nil
+}
+
+// writeSharedSnapshot replaces the file at path with a new generation of
+// the snapshot by writing it beside the file and renaming it into place.
+func writeSharedSnapshot(path string, gen uint64, body []byte) error {
+	line, err := json.Marshal(sharedFileHeader{Generation: gen, Size: len(body), Checksum: crc32.ChecksumIEEE(body)})
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot header: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	data := append(append(line, '\n'), body...)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %v", err)
+	}
+	return nil
+}
+
+// mergeExport stores the entries of an export that are newer than the
+// cache's own entries for the same keys.
+func (l *LRU) mergeExport(body []byte) error {
+	defer l.checkWatermarks()
+	_, records, err := readExport(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	clock, now := l.clock(), l.now()
+	var items []*CacheItem
+	for _, rec := range records {
+		if rec.Delete != "" {
+			continue
+		}
+		raw, err := l.db.Txn(false).First("cache", "id", rec.Key)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve item: %v", err)
+		}
+		if raw != nil && !raw.(*CacheItem).expired(clock) && !raw.(*CacheItem).CreatedAt.Before(rec.CreatedAt) {
+			continue
+		}
+		if item := l.itemFromRecord(rec, now); item != nil {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return l.storeMany(items)
+}
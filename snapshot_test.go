@@ -0,0 +1,160 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sharedPair(t *testing.T) (a, b *LRU, path string) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "cache.snapshot")
+	opts := Options{LogLevel: "error", SharedSnapshot: SharedSnapshot{Path: path, LockTimeout: 5 * time.Second}}
+	a, _ = NewLRUWithTTL(1000, opts)
+	b, _ = NewLRUWithTTL(1000, opts)
+	return a, b, path
+}
+
+func sortedKeys(c *LRU) []string {
+	keys := c.Keys()
+	sort.Strings(keys)
+	return keys
+}
+
+func TestLRUSharedSnapshot(t *testing.T) {
+	a, b, _ := sharedPair(t)
+
+	if merged, err := b.RefreshFromSharedSnapshot(); merged || err != nil {
+		t.Fatalf("Expected nothing to refresh before the first save, got %v, %v", merged, err)
+	}
+	a.Set("x", "from a", time.Hour)
+	if err := a.SaveSharedSnapshot(); err != nil {
+		t.Fatalf("SaveSharedSnapshot failed: %v", err)
+	}
+	if merged, err := b.RefreshFromSharedSnapshot(); !merged || err != nil {
+		t.Fatalf("Expected a refresh, got %v, %v", merged, err)
+	}
+	if v, _ := b.Get("x"); v != "from a" {
+		t.Errorf("Expected the entry from a, got %v", v)
+	}
+	if merged, _ := b.RefreshFromSharedSnapshot(); merged {
+		t.Error("Expected no refresh without a newer generation")
+	}
+
+	// b saves without refreshing first; a's entries survive the save.
+	a.Set("y", "from a", time.Hour)
+	a.SaveSharedSnapshot()
+	b.Set("z", "from b", time.Hour)
+	if err := b.SaveSharedSnapshot(); err != nil {
+		t.Fatalf("SaveSharedSnapshot failed: %v", err)
+	}
+	a.RefreshFromSharedSnapshot()
+	if want := []string{"x", "y", "z"}; !reflect.DeepEqual(sortedKeys(a), want) || !reflect.DeepEqual(sortedKeys(b), want) {
+		t.Errorf("Expected both caches to hold %v, got %v and %v", want, sortedKeys(a), sortedKeys(b))
+	}
+	if a.SharedSnapshotGeneration() != b.SharedSnapshotGeneration() {
+		t.Errorf("Expected both caches at the same generation, got %d and %d", a.SharedSnapshotGeneration(), b.SharedSnapshotGeneration())
+	}
+
+	// The later write wins in either direction.
+	a.Set("x", "newer in a", time.Hour)
+	b.RefreshFromSharedSnapshot()
+	time.Sleep(time.Millisecond)
+	b.Set("y", "newer in b", time.Hour)
+	a.SaveSharedSnapshot()
+	b.SaveSharedSnapshot()
+	a.RefreshFromSharedSnapshot()
+	for _, c := range []*LRU{a, b} {
+		x, _ := c.Get("x")
+		y, _ := c.Get("y")
+		if x != "newer in a" || y != "newer in b" {
+			t.Errorf("Expected the newest writes to win, got x=%v y=%v", x, y)
+		}
+	}
+}
+
+func TestLRUSharedSnapshotDetectsCorruption(t *testing.T) {
+	a, b, path := sharedPair(t)
+	a.Set("x", "v", time.Hour)
+	a.SaveSharedSnapshot()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	flipped := append([]byte(nil), data...)
+	flipped[len(flipped)-5] ^= 0xff
+	for name, corrupt := range map[string][]byte{
+		"flipped":   flipped,
+		"torn":      data[:len(data)/2],
+		"no header": []byte("garbage"),
+	} {
+		os.WriteFile(path, corrupt, 0o644)
+		if merged, err := b.RefreshFromSharedSnapshot(); merged || !errors.Is(err, ErrSnapshotCorrupt) {
+			t.Errorf("%s: expected ErrSnapshotCorrupt, got %v, %v", name, merged, err)
+		}
+		if b.Len() != 0 {
+			t.Errorf("%s: expected a corrupt snapshot to leave the cache alone", name)
+		}
+	}
+
+	// A save replaces the corrupt file.
+	if err := a.SaveSharedSnapshot(); err != nil {
+		t.Fatalf("SaveSharedSnapshot failed: %v", err)
+	}
+	if merged, err := b.RefreshFromSharedSnapshot(); !merged || err != nil || !b.Contains("x") {
+		t.Errorf("Expected a refresh after the file was rewritten, got %v, %v", merged, err)
+	}
+}
+
+func TestLRUSharedSnapshotConcurrent(t *testing.T) {
+	a, b, _ := sharedPair(t)
+	var wg sync.WaitGroup
+	for name, c := range map[string]*LRU{"a": a, "b": b} {
+		wg.Add(1)
+		go func(name string, c *LRU) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				c.Set(fmt.Sprintf("%s%d", name, i), i, time.Hour)
+				if err := c.SaveSharedSnapshot(); err != nil {
+					t.Errorf("SaveSharedSnapshot failed: %v", err)
+				}
+				if _, err := c.RefreshFromSharedSnapshot(); err != nil {
+					t.Errorf("RefreshFromSharedSnapshot failed: %v", err)
+				}
+			}
+		}(name, c)
+	}
+	wg.Wait()
+
+	a.SaveSharedSnapshot()
+	b.SaveSharedSnapshot()
+	a.RefreshFromSharedSnapshot()
+	if len(sortedKeys(a)) != 40 || !reflect.DeepEqual(sortedKeys(a), sortedKeys(b)) {
+		t.Errorf("Expected both caches to converge on 40 keys, got %d and %d", len(sortedKeys(a)), len(sortedKeys(b)))
+	}
+}
+
+func TestLRUSharedSnapshotLockTimeout(t *testing.T) {
+	a, _, path := sharedPair(t)
+	a.opts.SharedSnapshot.LockTimeout = 50 * time.Millisecond
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer f.Close()
+	if ok, err := tryLockFile(f, true); !ok || err != nil {
+		t.Fatalf("Failed to take the lock: %v", err)
+	}
+	defer unlockFile(f)
+
+	if err := a.SaveSharedSnapshot(); err == nil {
+		t.Error("Expected a save to time out while another holder has the lock")
+	}
+}
@@ -0,0 +1,244 @@
+// Package sqlcache caches the results of single-row database/sql queries in
+// an lrucache.Cacher, cache-aside: hits are served from the cache, misses
+// run the query and store the row.
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shammianand/lrucache"
+)
+
+const keyPrefix = "sqlcache:"
+
+// Key returns the cache key CachedQueryRow stores the row of query and args
+// under. Arguments are told apart by type as well as value, so 1 and "1" get
+// different keys.
+func Key(query string, args ...interface{}) string {
+	var b strings.Builder
+	b.WriteString(keyPrefix)
+	b.WriteString(strconv.Quote(query))
+	for _, arg := range args {
+		b.WriteByte(',')
+		b.WriteString(strconv.Quote(fmt.Sprintf("%T:%v", arg, arg)))
+	}
+	return b.String()
+}
+
+// CachedQueryRow scans the first row of query into dest, a pointer to a
+// struct, serving it from c when cached. On a miss it runs the query on db
+// and stores the row for ttl; concurrent misses of the same query and
+// arguments on the same cache wait for a single query and share its row.
+// The shared query runs until every caller waiting for it has given up;
+// a caller whose ctx ends first returns ctx.Err() without cancelling it
+// for the others.
+//
+// Columns are matched to exported fields by their `db:"name"` tag, or else by
+// the field name ignoring case; a tag of "-" skips the field and columns
+// without a field are discarded. A NULL column scans as it would with
+// Rows.Scan, so it needs a pointer or sql.Null* field. Cached rows are stored
+// as the JSON encoding of the struct, so every field must round-trip through
+// encoding/json. A query without rows returns sql.ErrNoRows and is not
+// cached; a failed store is ignored, as the row is still good.
+func CachedQueryRow(ctx context.Context, c lrucache.Cacher, db *sql.DB, ttl time.Duration, dest interface{}, query string, args ...interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("sqlcache: dest must be a non-nil pointer to a struct, got %T", dest)
+	}
+	key := Key(query, args...)
+	if data, ok := cached(c, key); ok {
+		if err := json.Unmarshal(data, dest); err == nil {
+			return nil
+		}
+	}
+
+	v, err := flights.do(ctx, c, key, func(ctx context.Context) (interface{}, error) {
+		// An earlier flight may have stored the row since the miss.
+		if data, ok := cached(c, key); ok {
+			return data, nil
+		}
+		row := reflect.New(rv.Elem().Type())
+		if err := queryRow(ctx, db, row, query, args); err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(row.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("sqlcache: failed to encode row: %v", err)
+		}
+		_ = c.Set(key, data, ttl)
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(v.([]byte), dest); err != nil {
+		return fmt.Errorf("sqlcache: failed to decode row: %v", err)
+	}
+	return nil
+}
+
+// Invalidate removes the cached row of query and args from c. A row that is
+// not cached returns lrucache.ErrItemNotFound.
+func Invalidate(c lrucache.Cacher, query string, args ...interface{}) error {
+	return c.Delete(Key(query, args...))
+}
+
+func cached(c lrucache.Cacher, key string) ([]byte, bool) {
+	v, err := c.Get(key)
+	if err != nil {
+		return nil, false
+	}
+	data, ok := v.([]byte)
+	return data, ok
+}
+
+// queryRow runs query and scans its first row into the struct dest points to.
+func queryRow(ctx context.Context, db *sql.DB, dest reflect.Value, query string, args []interface{}) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	fields := fieldsByColumn(dest.Elem().Type())
+	targets := make([]interface{}, len(columns))
+	for i, column := range columns {
+		if index, ok := fields[strings.ToLower(column)]; ok {
+			targets[i] = dest.Elem().Field(index).Addr().Interface()
+		} else {
+			targets[i] = new(interface{})
+		}
+	}
+	if err := rows.Scan(targets...); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// fieldsByColumn maps lower-cased column names to the indexes of the fields
+// of t they scan into. Tagged fields win over fields matched by name.
+func fieldsByColumn(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	tagged := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("db")
+		switch {
+		case tag == "-":
+		case tag != "":
+			fields[strings.ToLower(tag)] = i
+			tagged[strings.ToLower(tag)] = true
+		case !tagged[strings.ToLower(f.Name)]:
+			fields[strings.ToLower(f.Name)] = i
+		}
+	}
+	return fields
+}
+
+// flights coalesces the queries of every cache. Calls are only held while
+// in flight, so nothing keeps a cache reachable once its queries are done.
+var flights flightGroup
+
+// flightKey identifies a query against one cache, so the same query
+// against two caches runs once for each.
+type flightKey struct {
+	c   lrucache.Cacher
+	key string
+}
+
+// flightGroup runs one call per key at a time and hands its result to every
+// caller that asked for the key in the meantime.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[flightKey]*flightCall
+}
+
+type flightCall struct {
+	done    chan struct{}
+	value   interface{}
+	err     error
+	waiters int                // callers still waiting; guarded by the group
+	cancel  context.CancelFunc // stops fn once no caller is waiting
+}
+
+var errFlightPanicked = errors.New("sqlcache: query panicked")
+
+// do runs fn for the key of c once for all concurrent callers and waits for
+// its result or for ctx to end. fn gets a context that carries the values of
+// the first caller's ctx and is cancelled only when every waiting caller has
+// given up; a later caller then starts a new call. A cache that cannot be
+// used as a map key, such as a struct holding a map, is not coalesced.
+func (g *flightGroup) do(ctx context.Context, c lrucache.Cacher, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if !reflect.ValueOf(c).Comparable() {
+		return fn(ctx)
+	}
+	k := flightKey{c, key}
+
+	g.mu.Lock()
+	call, ok := g.calls[k]
+	if !ok {
+		if g.calls == nil {
+			g.calls = make(map[flightKey]*flightCall)
+		}
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &flightCall{done: make(chan struct{}), err: errFlightPanicked, cancel: cancel}
+		g.calls[k] = call
+		go g.run(callCtx, k, call, fn)
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		if call.waiters--; call.waiters == 0 {
+			call.cancel()
+			if g.calls[k] == call {
+				delete(g.calls, k)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// run runs fn for call and publishes its result.
+func (g *flightGroup) run(ctx context.Context, k flightKey, call *flightCall, fn func(ctx context.Context) (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.value, call.err = nil, fmt.Errorf("%w: %v", errFlightPanicked, r)
+		}
+		g.mu.Lock()
+		if g.calls[k] == call {
+			delete(g.calls, k)
+		}
+		g.mu.Unlock()
+		call.cancel()
+		close(call.done)
+	}()
+	call.value, call.err = fn(ctx)
+}
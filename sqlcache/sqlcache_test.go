@@ -0,0 +1,307 @@
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shammianand/lrucache"
+)
+
+// fakeDriver serves a fixed table of users for any query, looked up by the
+// first argument, and counts the queries it runs.
+type fakeDriver struct {
+	queries atomic.Int32
+	gate    chan struct{} // when set, queries wait for it to be closed
+}
+
+var users = map[int64][]driver.Value{
+	1: {int64(1), "alice", "alice@example.com", nil},
+	2: {int64(2), "bob", nil, "bobby"},
+}
+
+var fake = &fakeDriver{}
+
+func init() { sql.Register("sqlcache-fake", fake) }
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.d}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct{ d *fakeDriver }
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return 1 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.queries.Add(1)
+	if s.d.gate != nil {
+		<-s.d.gate
+	}
+	rows := &fakeRows{}
+	if row, ok := users[args[0].(int64)]; ok {
+		rows.rows = [][]driver.Value{row}
+	}
+	return rows, nil
+}
+
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string { return []string{"id", "name", "email", "nick"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+type user struct {
+	ID       int64  `db:"id"`
+	Name     string // matched by name
+	Email    *string
+	Nickname sql.NullString `db:"nick"`
+	Ignored  string         `db:"-"`
+}
+
+const query = "SELECT id, name, email, nick FROM users WHERE id = ?"
+
+func setup(t *testing.T) (*lrucache.LRU, *sql.DB) {
+	t.Helper()
+	fake.queries.Store(0)
+	fake.gate = nil
+	cache, _ := lrucache.NewLRUWithTTL(10, lrucache.Options{LogLevel: "error"})
+	db, err := sql.Open("sqlcache-fake", "")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return cache, db
+}
+
+func TestCachedQueryRow(t *testing.T) {
+	cache, db := setup(t)
+	ctx := context.Background()
+
+	var u user
+	if err := CachedQueryRow(ctx, cache, db, time.Minute, &u, query, int64(1)); err != nil {
+		t.Fatalf("CachedQueryRow failed: %v", err)
+	}
+	if u.ID != 1 || u.Name != "alice" || u.Email == nil || *u.Email != "alice@example.com" || u.Nickname.Valid {
+		t.Errorf("Unexpected row on a miss: %+v", u)
+	}
+
+	var hit user
+	if err := CachedQueryRow(ctx, cache, db, time.Minute, &hit, query, int64(1)); err != nil {
+		t.Fatalf("CachedQueryRow failed: %v", err)
+	}
+	if n := fake.queries.Load(); n != 1 {
+		t.Errorf("Expected the hit to skip the database, got %d queries", n)
+	}
+	if hit.Name != "alice" || *hit.Email != "alice@example.com" || hit.Nickname.Valid {
+		t.Errorf("Unexpected row on a hit: %+v", hit)
+	}
+
+	if err := Invalidate(cache, query, int64(1)); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	for _, c := range []lrucache.Cacher{cache, &lrucache.MiniCache{}} {
+		if err := Invalidate(c, query, int64(1)); err != lrucache.ErrItemNotFound {
+			t.Errorf("Expected ErrItemNotFound for a row that is not cached in %T, got %v", c, err)
+		}
+	}
+	if err := CachedQueryRow(ctx, cache, db, time.Minute, &hit, query, int64(1)); err != nil || fake.queries.Load() != 2 {
+		t.Errorf("Expected an invalidated row to be queried again, got %v", err)
+	}
+}
+
+func TestCachedQueryRowNulls(t *testing.T) {
+	cache, db := setup(t)
+	for i := 0; i < 2; i++ {
+		var u user
+		if err := CachedQueryRow(context.Background(), cache, db, time.Minute, &u, query, int64(2)); err != nil {
+			t.Fatalf("CachedQueryRow failed: %v", err)
+		}
+		if u.Email != nil || !u.Nickname.Valid || u.Nickname.String != "bobby" {
+			t.Errorf("Expected NULL email and a nickname, got %+v", u)
+		}
+	}
+}
+
+func TestCachedQueryRowNoRows(t *testing.T) {
+	cache, db := setup(t)
+	var u user
+	for i := 0; i < 2; i++ {
+		if err := CachedQueryRow(context.Background(), cache, db, time.Minute, &u, query, int64(3)); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("Expected sql.ErrNoRows, got %v", err)
+		}
+	}
+	if n := fake.queries.Load(); n != 2 {
+		t.Errorf("Expected missing rows not to be cached, got %d queries", n)
+	}
+	if err := CachedQueryRow(context.Background(), cache, db, time.Minute, u, query, int64(1)); err == nil {
+		t.Error("Expected an error for a dest that is not a pointer")
+	}
+}
+
+func TestCachedQueryRowCoalesces(t *testing.T) {
+	cache, db := setup(t)
+	fake.gate = make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]user, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := CachedQueryRow(context.Background(), cache, db, time.Minute, &results[i], query, int64(1)); err != nil {
+				t.Errorf("CachedQueryRow failed: %v", err)
+			}
+		}(i)
+	}
+	for fake.queries.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(fake.gate)
+	wg.Wait()
+
+	if n := fake.queries.Load(); n != 1 {
+		t.Errorf("Expected one query for concurrent misses, got %d", n)
+	}
+	for _, u := range results {
+		if u.Name != "alice" {
+			t.Errorf("Expected every caller to get the row, got %+v", u)
+		}
+	}
+	if n := inFlight(); n != 0 {
+		t.Errorf("Expected finished flights to be dropped, got %d", n)
+	}
+}
+
+func inFlight() int {
+	flights.mu.Lock()
+	defer flights.mu.Unlock()
+	return len(flights.calls)
+}
+
+func waiters(c lrucache.Cacher, key string) int {
+	flights.mu.Lock()
+	defer flights.mu.Unlock()
+	if call, ok := flights.calls[flightKey{c, key}]; ok {
+		return call.waiters
+	}
+	return 0
+}
+
+func TestCachedQueryRowCallerGivesUp(t *testing.T) {
+	cache, db := setup(t)
+	fake.gate = make(chan struct{})
+	key := Key(query, int64(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		var u user
+		first <- CachedQueryRow(ctx, cache, db, time.Minute, &u, query, int64(1))
+	}()
+	second := make(chan user, 1)
+	go func() {
+		var u user
+		if err := CachedQueryRow(context.Background(), cache, db, time.Minute, &u, query, int64(1)); err != nil {
+			t.Errorf("Expected the remaining caller to get the row, got %v", err)
+		}
+		second <- u
+	}()
+	for waiters(cache, key) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the caller that gave up to get its ctx error, got %v", err)
+	}
+	close(fake.gate)
+	if u := <-second; u.Name != "alice" {
+		t.Errorf("Expected the row, got %+v", u)
+	}
+	if n := fake.queries.Load(); n != 1 {
+		t.Errorf("Expected one shared query, got %d", n)
+	}
+}
+
+func TestCachedQueryRowAllCallersGiveUp(t *testing.T) {
+	cache, db := setup(t)
+	fake.gate = make(chan struct{})
+	key := Key(query, int64(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		var u user
+		done <- CachedQueryRow(ctx, cache, db, time.Minute, &u, query, int64(1))
+	}()
+	for waiters(cache, key) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	flights.mu.Lock()
+	call := flights.calls[flightKey{cache, key}]
+	flights.mu.Unlock()
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if n := inFlight(); n != 0 {
+		t.Errorf("Expected an abandoned flight to be dropped so later callers start afresh, got %d", n)
+	}
+	close(fake.gate)
+	<-call.done
+}
+
+// mapCacher is a Cacher that cannot be used as a map key.
+type mapCacher struct {
+	*lrucache.LRU
+	labels map[string]string
+}
+
+func TestCachedQueryRowUncomparableCacher(t *testing.T) {
+	cache, db := setup(t)
+	c := mapCacher{cache, map[string]string{"tenant": "a"}}
+
+	var u user
+	if err := CachedQueryRow(context.Background(), c, db, time.Minute, &u, query, int64(1)); err != nil || u.Name != "alice" {
+		t.Fatalf("Expected the row, got %+v, %v", u, err)
+	}
+	if err := CachedQueryRow(context.Background(), c, db, time.Minute, &u, query, int64(1)); err != nil {
+		t.Fatalf("CachedQueryRow failed: %v", err)
+	}
+	if n := fake.queries.Load(); n != 1 {
+		t.Errorf("Expected the second call to be a hit, got %d queries", n)
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key(query, 1) == Key(query, "1") {
+		t.Error("Expected arguments of different types to get different keys")
+	}
+	if Key(query, 1, 2) == Key(query, 12) {
+		t.Error("Expected argument boundaries to be kept")
+	}
+}
@@ -0,0 +1,138 @@
+package lrucache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OtherStatGroup collects the keys of groups beyond Options.MaxStatGroups.
+const OtherStatGroup = "other"
+
+const defaultMaxStatGroups = 64
+
+// GroupStats holds the counters of one StatsKeyGrouper group.
+type GroupStats struct {
+	Hits      uint64
+	Misses    uint64
+	Sets      uint64
+	Evictions uint64
+}
+
+type groupCounters struct {
+	hits, misses, sets, evictions atomic.Uint64
+}
+
+// statGroups keeps per-group counters for at most max groups; keys of any
+// further group are counted under OtherStatGroup.
+type statGroups struct {
+	grouper func(key string) string
+	max     int
+
+	mu     sync.RWMutex
+	groups map[string]*groupCounters
+	other  groupCounters
+}
+
+func newStatGroups(grouper func(key string) string, max int) *statGroups {
+	if max <= 0 {
+		max = defaultMaxStatGroups
+	}
+	return &statGroups{grouper: grouper, max: max, groups: make(map[string]*groupCounters)}
+}
+
+// counters returns the counters for key's group, creating them while there
+// is room. A nil receiver means grouping is off and returns nil.
+func (g *statGroups) counters(key string) *groupCounters {
+	if g == nil {
+		return nil
+	}
+	name := g.grouper(key)
+
+	g.mu.RLock()
+	c, ok := g.groups[name]
+	g.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.groups[name]; ok {
+		return c
+	}
+	if name == OtherStatGroup || len(g.groups) >= g.max {
+		return &g.other
+	}
+	c = &groupCounters{}
+	g.groups[name] = c
+	return c
+}
+
+func (g *statGroups) hit(key string) {
+	if c := g.counters(key); c != nil {
+		c.hits.Add(1)
+	}
+}
+
+func (g *statGroups) miss(key string) {
+	if c := g.counters(key); c != nil {
+		c.misses.Add(1)
+	}
+}
+
+func (g *statGroups) set(key string) {
+	if c := g.counters(key); c != nil {
+		c.sets.Add(1)
+	}
+}
+
+func (g *statGroups) evicted(key string) {
+	if c := g.counters(key); c != nil {
+		c.evictions.Add(1)
+	}
+}
+
+func (c *groupCounters) snapshot() GroupStats {
+	return GroupStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Sets:      c.sets.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+func (g *statGroups) snapshot() map[string]GroupStats {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	out := make(map[string]GroupStats, len(g.groups)+1)
+	for name, c := range g.groups {
+		out[name] = c.snapshot()
+	}
+	if other := g.other.snapshot(); other != (GroupStats{}) {
+		out[OtherStatGroup] = other
+	}
+	return out
+}
+
+// redactGroups passes the group names of a snapshot through
+// Options.KeyRedactor, adding up the counters of names it merges.
+// OtherStatGroup keeps its name.
+func (l *LRU) redactGroups(groups map[string]GroupStats) map[string]GroupStats {
+	if l.opts.KeyRedactor == nil {
+		return groups
+	}
+	out := make(map[string]GroupStats, len(groups))
+	for name, g := range groups {
+		if name != OtherStatGroup {
+			name = l.redact(name)
+		}
+		sum := out[name]
+		sum.Hits += g.Hits
+		sum.Misses += g.Misses
+		sum.Sets += g.Sets
+		sum.Evictions += g.Evictions
+		out[name] = sum
+	}
+	return out
+}
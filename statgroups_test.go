@@ -0,0 +1,76 @@
+package lrucache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func firstSegment(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+func TestStatsGroups(t *testing.T) {
+	cache, _ := NewLRUWithTTL(2, Options{LogLevel: "error", StatsKeyGrouper: firstSegment})
+
+	cache.Set("user:1", "a", time.Minute)
+	cache.Get("user:1")
+	cache.Get("user:1")
+	cache.Get("user:2")
+	cache.Set("feed:1", "b", time.Minute)
+	cache.Get("feed:1")
+	cache.Get("feed:2")
+	cache.Get("feed:3")
+	cache.Get("price:1")
+	// A third entry evicts the one closest to expiring, user:1.
+	cache.Set("price:1", "c", 2*time.Minute)
+
+	want := map[string]GroupStats{
+		"user":  {Hits: 2, Misses: 1, Sets: 1, Evictions: 1},
+		"feed":  {Hits: 1, Misses: 2, Sets: 1},
+		"price": {Misses: 1, Sets: 1},
+	}
+	got := cache.Stats().Groups
+	if len(got) != len(want) {
+		t.Fatalf("Expected groups %v, got %v", want, got)
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("Group %s: expected %+v, got %+v", name, w, got[name])
+		}
+	}
+}
+
+func TestStatsGroupsOverflow(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", StatsKeyGrouper: firstSegment, MaxStatGroups: 2})
+
+	cache.Set("a:1", 1, time.Minute)
+	cache.Set("b:1", 1, time.Minute)
+	cache.Set("c:1", 1, time.Minute)
+	cache.Set("d:1", 1, time.Minute)
+	cache.Get("c:1")
+	cache.Get("a:1")
+
+	got := cache.Stats().Groups
+	if len(got) != 3 {
+		t.Fatalf("Expected two groups plus %q, got %v", OtherStatGroup, got)
+	}
+	if got["a"].Sets != 1 || got["a"].Hits != 1 || got["b"].Sets != 1 {
+		t.Errorf("Unexpected counters for the first groups: %v", got)
+	}
+	if other := got[OtherStatGroup]; other.Sets != 2 || other.Hits != 1 {
+		t.Errorf("Expected two sets and one hit in %q, got %+v", OtherStatGroup, other)
+	}
+}
+
+func TestStatsGroupsDisabled(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("a:1", 1, time.Minute)
+	cache.Get("a:1")
+	if groups := cache.Stats().Groups; groups != nil {
+		t.Errorf("Expected no groups without a grouper, got %v", groups)
+	}
+}
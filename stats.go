@@ -0,0 +1,162 @@
+package lrucache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a point-in-time copy of the cache counters.
+type Stats struct {
+	// Hits and Misses count Gets that found a live entry and Gets that did
+	// not.
+	Hits   uint64
+	Misses uint64
+
+	// InconsistenciesFound counts heap/table disagreements repaired by the audit.
+	InconsistenciesFound uint64
+
+	// RateLimitedSets counts Sets that exceeded their per-key rate limit.
+	RateLimitedSets uint64
+	// HottestLimitedKeys lists the tracked keys with the most limited Sets,
+	// most limited first.
+	HottestLimitedKeys []string
+
+	// SweepErrors counts background failures recorded in strict mode.
+	SweepErrors uint64
+
+	// ClockJumps counts detected steps of the wall clock.
+	ClockJumps uint64
+
+	// NotStoredSets counts writes skipped for a TTL below MinUsefulTTL.
+	NotStoredSets uint64
+
+	// ReadRepairs counts entries removed by reads because their value
+	// failed to decode.
+	ReadRepairs uint64
+
+	// LockWaits maps operation kinds (get, scan, set, delete and sweep) to
+	// their lock wait times. It is nil unless TrackLockContention is set.
+	LockWaits map[string]LockWaitStats
+
+	// ScheduledInvalidations counts fired ScheduleInvalidation rules.
+	ScheduledInvalidations uint64
+
+	// MissFilterShortCircuits counts Gets answered by the miss filter alone,
+	// and MissFilterFalsePositives Gets it let through for missing keys.
+	MissFilterShortCircuits  uint64
+	MissFilterFalsePositives uint64
+	// MissFilterFalsePositiveRate is the observed share of misses the filter
+	// failed to rule out.
+	MissFilterFalsePositiveRate float64
+
+	// Groups maps StatsKeyGrouper groups, named through Options.KeyRedactor,
+	// to their counters. It is nil unless StatsKeyGrouper is set.
+	Groups map[string]GroupStats
+
+	// GuardedSets counts writes of new keys refused by CardinalityGuard,
+	// and CardinalityGuardActive reports whether it is refusing them now.
+	GuardedSets            uint64
+	CardinalityGuardActive bool
+
+	// Occupancy maps FairnessGroups groups to their number of entries. It
+	// is nil unless FairnessGroups is set.
+	Occupancy map[string]int
+
+	// Draining reports whether the cache is in drain mode.
+	Draining bool
+
+	// EmergencyEvictions counts entries evicted to make room for a write
+	// within MaxBytes.
+	EmergencyEvictions uint64
+
+	// DeferredExpiries counts the expired entries the last sweep left in
+	// place because of Options.SweepSchedule.
+	DeferredExpiries int64
+
+	// Warmed reports whether the warm-up of a cache created with
+	// RequireWarmup has ended, and WarmupLoaded and WarmupTarget how many
+	// keys it loaded out of how many expected.
+	Warmed       bool
+	WarmupLoaded int64
+	WarmupTarget int64
+
+	// Quiesced reports whether the cache is quiesced, and QuiescedTime how
+	// long it has been quiesced in all, the current quiesce included.
+	Quiesced     bool
+	QuiescedTime time.Duration
+
+	// ObservabilityBytes maps the enabled side structures ("tombstones",
+	// "lock_waits" and "hit_ratio") to their approximate memory in bytes.
+	ObservabilityBytes map[string]int64
+}
+
+type cacheStats struct {
+	hits                 atomic.Uint64
+	misses               atomic.Uint64
+	inconsistenciesFound atomic.Uint64
+	rateLimitedSets      atomic.Uint64
+	sweepErrors          atomic.Uint64
+	clockJumps           atomic.Uint64
+	notStoredSets        atomic.Uint64
+	readRepairs          atomic.Uint64
+	scheduledFired       atomic.Uint64
+	filterShortCircuits  atomic.Uint64
+	filterFalsePositives atomic.Uint64
+	guardedSets          atomic.Uint64
+	emergencyEvictions   atomic.Uint64
+	deferredExpiries     atomic.Int64
+}
+
+// Stats returns a snapshot of the cache counters.
+func (l *LRU) Stats() Stats {
+	s := Stats{
+		Hits:                     l.stats.hits.Load(),
+		Misses:                   l.stats.misses.Load(),
+		InconsistenciesFound:     l.stats.inconsistenciesFound.Load(),
+		RateLimitedSets:          l.stats.rateLimitedSets.Load(),
+		SweepErrors:              l.stats.sweepErrors.Load(),
+		ClockJumps:               l.stats.clockJumps.Load(),
+		NotStoredSets:            l.stats.notStoredSets.Load(),
+		ReadRepairs:              l.stats.readRepairs.Load(),
+		ScheduledInvalidations:   l.stats.scheduledFired.Load(),
+		MissFilterShortCircuits:  l.stats.filterShortCircuits.Load(),
+		MissFilterFalsePositives: l.stats.filterFalsePositives.Load(),
+		GuardedSets:              l.stats.guardedSets.Load(),
+		Draining:                 l.Draining(),
+		EmergencyEvictions:       l.stats.emergencyEvictions.Load(),
+		DeferredExpiries:         l.stats.deferredExpiries.Load(),
+		Warmed:                   l.Warmed(),
+		Quiesced:                 l.Quiesced(),
+		QuiescedTime:             l.quiescedTime(),
+	}
+	if misses := s.MissFilterShortCircuits + s.MissFilterFalsePositives; misses > 0 {
+		s.MissFilterFalsePositiveRate = float64(s.MissFilterFalsePositives) / float64(misses)
+	}
+	if l.warmup != nil {
+		s.WarmupLoaded = l.warmup.loaded.Load()
+		s.WarmupTarget = l.warmup.target.Load()
+	}
+	if l.guard != nil {
+		s.CardinalityGuardActive = l.guard.active.Load()
+	}
+	if l.groups != nil {
+		s.Groups = l.redactGroups(l.groups.snapshot())
+	}
+	if l.opts.FairnessGroups != nil {
+		l.readLock("scan")
+		s.Occupancy = l.expHeap.fair.snapshot()
+		l.lock.RUnlock()
+	}
+	if l.tombstones != nil || l.lockStats != nil || l.hitRatio != nil {
+		l.readLock("scan")
+		s.ObservabilityBytes = l.observabilityBytes()
+		l.lock.RUnlock()
+	}
+	if l.lockStats != nil {
+		s.LockWaits = l.lockStats.snapshot()
+	}
+	if l.limiter != nil {
+		s.HottestLimitedKeys = l.limiter.hottest(hottestLimitedKeys)
+	}
+	return s
+}
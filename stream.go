@@ -0,0 +1,95 @@
+package lrucache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// SetReader stores exactly size bytes read from r under key, as Set would
+// store a []byte. The bytes are read straight into the stored buffer, so the
+// value is never held twice, and nothing past size is read from r.
+func (l *LRU) SetReader(key string, r io.Reader, size int64, ttl time.Duration) error {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	if ttl <= 0 && ttl != NoExpiration {
+		return l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+	if size < 0 {
+		return l.invalid(key, "size must not be negative")
+	}
+	if err := l.checkUsefulTTL(ttl); err != nil {
+		return err
+	}
+
+	data := make([]byte, size+1)
+	data[0] = tagBytes
+	if _, err := io.ReadFull(r, data[1:]); err != nil {
+		return fmt.Errorf("failed to read value: %v", err)
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	return l.store(l.newItem(key, data, ttl), nil)
+}
+
+// GetReader streams the []byte or string value stored under key without
+// copying it and returns its length. Stored buffers are never modified, so
+// the reader stays valid until Close even if the entry is replaced, evicted
+// or expires in the meantime. Hits and misses are counted in Stats, and
+// expired entries removed, as Get does.
+func (l *LRU) GetReader(key string) (io.ReadCloser, int64, error) {
+	key = l.NormalizeKey(key)
+	r, size, err := l.openReader(key)
+	if err := l.finishRead(key, err); err != nil {
+		return nil, 0, err
+	}
+	return r, size, nil
+}
+
+// openReader is GetReader without the bookkeeping of finishRead. Like get,
+// it returns errLastRead, along with the reader, for the last allowed read.
+func (l *LRU) openReader(key string) (io.ReadCloser, int64, error) {
+	if l.definitelyMissing(key) {
+		return nil, 0, ErrItemNotFound
+	}
+
+	l.readLock("get")
+	defer l.lock.RUnlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		return nil, 0, ErrItemNotFound
+	}
+	item := raw.(*CacheItem)
+	if item.expired(l.clock()) {
+		return nil, 0, ErrItemExpired
+	}
+	if tag := item.Value[0]; tag != tagBytes && tag != tagString {
+		return nil, 0, fmt.Errorf("value of key %s is not bytes or a string", key)
+	}
+	err = item.consumeRead()
+	if err != nil && err != errLastRead {
+		return nil, 0, err
+	}
+	item.recordAccess(l.now())
+
+	payload := item.Value[1:]
+	return &valueReader{Reader: bytes.NewReader(payload)}, int64(len(payload)), err
+}
+
+// valueReader holds on to a stored buffer until it is closed.
+type valueReader struct {
+	*bytes.Reader
+}
+
+func (r *valueReader) Close() error {
+	r.Reader = bytes.NewReader(nil)
+	return nil
+}
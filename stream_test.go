@@ -0,0 +1,116 @@
+package lrucache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamLargeValue(t *testing.T) {
+	const size = 64 << 20
+	cache, _ := NewLRUWithTTL(1, Options{LogLevel: "error"})
+
+	written := sha256.New()
+	payload := io.TeeReader(io.LimitReader(rand.New(rand.NewSource(1)), size), written)
+	if err := cache.SetReader("blob", payload, size, time.Hour); err != nil {
+		t.Fatalf("SetReader failed: %v", err)
+	}
+
+	r, n, err := cache.GetReader("blob")
+	if err != nil {
+		t.Fatalf("GetReader failed: %v", err)
+	}
+	if n != size {
+		t.Fatalf("Expected size %d, got %d", size, n)
+	}
+	read := sha256.New()
+	if _, err := io.CopyN(read, r, size/2); err != nil {
+		t.Fatalf("First half failed: %v", err)
+	}
+
+	// Evicting the entry halfway through must not disturb the reader.
+	cache.Set("other", "value", time.Hour)
+	if _, err := cache.Get("blob"); err != ErrItemNotFound {
+		t.Fatalf("Expected blob to be evicted, got %v", err)
+	}
+	if _, err := io.Copy(read, r); err != nil {
+		t.Fatalf("Second half failed: %v", err)
+	}
+	r.Close()
+
+	if !bytes.Equal(read.Sum(nil), written.Sum(nil)) {
+		t.Error("Expected streamed value to match what was written")
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	if err := cache.SetReader("short", strings.NewReader("abc"), 10, time.Hour); err == nil {
+		t.Error("Expected a reader shorter than size to fail")
+	}
+	if _, err := cache.Get("short"); err != ErrItemNotFound {
+		t.Errorf("Expected a failed SetReader to store nothing, got %v", err)
+	}
+
+	src := strings.NewReader("abcdef")
+	if err := cache.SetReader("capped", src, 3, time.Hour); err != nil {
+		t.Fatalf("SetReader failed: %v", err)
+	}
+	if v, _ := cache.Get("capped"); !bytes.Equal(v.([]byte), []byte("abc")) {
+		t.Errorf("Expected only size bytes to be stored, got %q", v)
+	}
+	if src.Len() != 3 {
+		t.Errorf("Expected nothing past size to be read, %d bytes left", src.Len())
+	}
+
+	cache.Set("text", "hello", time.Hour)
+	r, n, err := cache.GetReader("text")
+	if err != nil || n != 5 {
+		t.Fatalf("Expected to stream a string value, got %d, %v", n, err)
+	}
+	if data, _ := io.ReadAll(r); string(data) != "hello" {
+		t.Errorf("Expected hello, got %q", data)
+	}
+
+	cache.Set("number", 42, time.Hour)
+	if _, _, err := cache.GetReader("number"); err == nil {
+		t.Error("Expected GetReader to reject non-byte values")
+	}
+	if _, _, err := cache.GetReader("missing"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+}
+
+func TestGetReaderStats(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", StatsKeyGrouper: func(string) string { return "blobs" }})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.SetReader("blob", strings.NewReader("abc"), 3, time.Minute)
+	cache.SetWithReadLimit("once", []byte("x"), time.Hour, 1)
+	for _, key := range []string{"blob", "once", "once", "missing"} {
+		if r, _, err := cache.GetReader(key); err == nil {
+			r.Close()
+		}
+	}
+	now = now.Add(2 * time.Minute)
+	if _, _, err := cache.GetReader("blob"); err != ErrItemExpired {
+		t.Fatalf("Expected ErrItemExpired, got %v", err)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 3 {
+		t.Errorf("Expected 2 hits and 3 misses, got %d and %d", stats.Hits, stats.Misses)
+	}
+	if g := stats.Groups["blobs"]; g.Hits != 2 || g.Misses != 3 {
+		t.Errorf("Expected the group to count them too, got %+v", g)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected the consumed and expired entries to be removed, got %v", cache.Keys())
+	}
+}
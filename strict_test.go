@@ -0,0 +1,98 @@
+package lrucache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errInjected = errors.New("injected failure")
+
+func failing(op string) func(string, string) error {
+	return func(o, key string) error {
+		if o == op {
+			return errInjected
+		}
+		return nil
+	}
+}
+
+func TestStrictErrorsGet(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", StrictErrors: strict})
+		now := time.Unix(1700000000, 0)
+		cache.now = func() time.Time { return now }
+
+		cache.Set("key", 1, time.Second)
+		now = now.Add(2 * time.Second)
+		cache.faultHook = failing("delete")
+
+		_, err := cache.Get("key")
+		if strict && !errors.Is(err, errInjected) {
+			t.Errorf("strict: expected the removal failure, got %v", err)
+		}
+		if !strict && err != ErrItemExpired {
+			t.Errorf("lenient: expected ErrItemExpired, got %v", err)
+		}
+	}
+}
+
+func TestStrictErrorsEviction(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		cache, _ := NewLRUWithTTL(1, Options{LogLevel: "error", StrictErrors: strict})
+
+		cache.Set("key1", 1, time.Hour)
+		cache.faultHook = failing("delete")
+
+		err := cache.Set("key2", 2, 2*time.Hour)
+		if strict && !errors.Is(err, errInjected) {
+			t.Errorf("strict: expected the eviction failure, got %v", err)
+		}
+		if !strict && err != nil {
+			t.Errorf("lenient: expected Set to succeed, got %v", err)
+		}
+	}
+}
+
+func TestStrictErrorsLen(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	cache.Set("key", 1, time.Hour)
+	cache.faultHook = failing("scan")
+
+	if _, err := cache.LenE(); !errors.Is(err, errInjected) {
+		t.Errorf("Expected LenE to return the failure, got %v", err)
+	}
+	if l := cache.Len(); l != 0 {
+		t.Errorf("Expected Len to stay lenient and return 0, got %d", l)
+	}
+}
+
+func TestStrictErrorsSweeper(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		var reported []error
+		cache, _ := NewLRUWithTTL(10, Options{
+			LogLevel:     "error",
+			StrictErrors: strict,
+			OnError:      func(err error) { reported = append(reported, err) },
+		})
+		now := time.Unix(1700000000, 0)
+		cache.now = func() time.Time { return now }
+
+		cache.Set("key1", 1, time.Second)
+		cache.Set("key2", 2, time.Second)
+		now = now.Add(2 * time.Second)
+		cache.faultHook = failing("delete")
+		cache.removeExpiredItems()
+
+		want := 0
+		if strict {
+			want = 2
+		}
+		if n := cache.Stats().SweepErrors; n != uint64(want) {
+			t.Errorf("strict=%v: expected %d sweep errors, got %d", strict, want, n)
+		}
+		if len(reported) != want {
+			t.Errorf("strict=%v: expected %d OnError calls, got %d", strict, want, len(reported))
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package lrucache
+
+import "time"
+
+// SweepSchedule limits the work of the background sweep during a daily peak
+// window, so that mass expirations do not add latency at peak traffic.
+// Inside the window each sweep removes at most PeakMaxEntries entries and
+// stops after PeakMaxDuration; outside it the sweep removes everything that
+// has expired. Entries left over are still treated as expired by every read
+// and are reclaimed by later sweeps, so only memory, not correctness, is
+// deferred. A zero cap means no cap of that kind.
+type SweepSchedule struct {
+	// PeakStart and PeakEnd are the times of day the window starts and
+	// ends, as offsets from midnight in Location, or UTC if Location is nil.
+	// A window ending before it starts spans midnight; equal offsets
+	// disable the schedule.
+	PeakStart, PeakEnd time.Duration
+	Location           *time.Location
+
+	PeakMaxEntries  int
+	PeakMaxDuration time.Duration
+}
+
+func (s SweepSchedule) enabled() bool {
+	return s.PeakStart != s.PeakEnd
+}
+
+// inPeak reports whether t falls inside the peak window.
+func (s SweepSchedule) inPeak(t time.Time) bool {
+	if !s.enabled() {
+		return false
+	}
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	offset := t.Sub(midnight)
+	if s.PeakStart < s.PeakEnd {
+		return offset >= s.PeakStart && offset < s.PeakEnd
+	}
+	return offset >= s.PeakStart || offset < s.PeakEnd
+}
+
+// sweepBudget bounds one run of the sweep.
+type sweepBudget struct {
+	entries  int       // zero means no cap
+	deadline time.Time // zero means no cap
+}
+
+// sweepBudget returns the limits the schedule sets for a sweep starting at
+// now.
+func (l *LRU) sweepBudget(now time.Time) sweepBudget {
+	s := l.opts.SweepSchedule
+	if !s.inPeak(now) {
+		return sweepBudget{}
+	}
+	b := sweepBudget{entries: s.PeakMaxEntries}
+	if s.PeakMaxDuration > 0 {
+		b.deadline = now.Add(s.PeakMaxDuration)
+	}
+	return b
+}
+
+// spent reports whether a sweep that has removed removed entries has used up
+// its budget.
+func (b sweepBudget) spent(removed int, now func() time.Time) bool {
+	if b.entries > 0 && removed >= b.entries {
+		return true
+	}
+	return !b.deadline.IsZero() && !now().Before(b.deadline)
+}
+
+// expiredBacklog counts the entries on the heap whose deadline has passed.
+// The caller must hold the lock.
+func (l *LRU) expiredBacklog(clock time.Time) int {
+	n := 0
+	for _, deadline := range l.expHeap.deadlines {
+		if deadline.Before(clock) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package lrucache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSweepScheduleWindow(t *testing.T) {
+	day := SweepSchedule{PeakStart: 9 * time.Hour, PeakEnd: 21 * time.Hour}
+	night := SweepSchedule{PeakStart: 22 * time.Hour, PeakEnd: 2 * time.Hour}
+	for _, tc := range []struct {
+		s    SweepSchedule
+		hour int
+		want bool
+	}{
+		{day, 8, false},
+		{day, 9, true},
+		{day, 20, true},
+		{day, 21, false},
+		{night, 23, true},
+		{night, 1, true},
+		{night, 2, false},
+		{night, 12, false},
+		{SweepSchedule{}, 12, false},
+	} {
+		at := time.Date(2024, 3, 1, tc.hour, 0, 0, 0, time.UTC)
+		if got := tc.s.inPeak(at); got != tc.want {
+			t.Errorf("%+v at %02d:00: expected %v, got %v", tc.s, tc.hour, tc.want, got)
+		}
+	}
+
+	tokyo := time.FixedZone("JST", 9*3600)
+	local := SweepSchedule{PeakStart: 9 * time.Hour, PeakEnd: 21 * time.Hour, Location: tokyo}
+	if !local.inPeak(time.Date(2024, 3, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 01:00 UTC to be 10:00 in the window's zone")
+	}
+}
+
+func TestSweepScheduleCapsPeakSweeps(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{
+		LogLevel:      "error",
+		SweepSchedule: SweepSchedule{PeakStart: 9 * time.Hour, PeakEnd: 21 * time.Hour, PeakMaxEntries: 3},
+	})
+	now := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i, time.Second)
+	}
+	cache.Set("live", 0, 24*time.Hour)
+	now = now.Add(2 * time.Second)
+
+	cache.removeExpiredItems()
+	if n := cache.Len(); n != 8 {
+		t.Errorf("Expected a peak sweep to remove 3 entries, got %d left", n)
+	}
+	if s := cache.Stats(); s.DeferredExpiries != 7 {
+		t.Errorf("Expected 7 deferred expiries, got %d", s.DeferredExpiries)
+	}
+	for i := 0; i < 10; i++ {
+		if cache.Contains(fmt.Sprintf("key%d", i)) {
+			t.Errorf("Expected key%d to read as expired", i)
+		}
+	}
+
+	// Past 21:00 the backlog drains in one sweep.
+	now = now.Add(time.Hour)
+	cache.removeExpiredItems()
+	if n := cache.Len(); n != 1 {
+		t.Errorf("Expected only the live entry to remain, got %d entries", n)
+	}
+	if s := cache.Stats(); s.DeferredExpiries != 0 {
+		t.Errorf("Expected no deferred expiries, got %d", s.DeferredExpiries)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent heap, got %v", err)
+	}
+}
+
+func TestSweepBudgetDuration(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	b := sweepBudget{deadline: start.Add(time.Second)}
+	if b.spent(100, func() time.Time { return now }) {
+		t.Errorf("Expected the budget to last until its deadline")
+	}
+	now = now.Add(time.Second)
+	if !b.spent(0, func() time.Time { return now }) {
+		t.Errorf("Expected the budget to be spent at its deadline")
+	}
+}
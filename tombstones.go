@@ -0,0 +1,122 @@
+package lrucache
+
+import (
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// TombstoneRetention keeps the final value of expired and evicted entries
+// around for a while after their removal. A zero Count disables it.
+type TombstoneRetention struct {
+	Count  int           // tombstones kept; the oldest are dropped first
+	MaxAge time.Duration // how long a tombstone stays retrievable; zero keeps it until pushed out
+}
+
+type tombstone struct {
+	value     []byte
+	removedAt time.Time
+	reason    EvictReason
+}
+
+// tombstoneBuffer holds the tombstones of the most recently removed keys. It
+// is sized on its own and never counts against the cache size.
+type tombstoneBuffer struct {
+	maxAge  time.Duration
+	entries *simplelru.LRU
+	size    int64 // bytes held, as counted by tombstoneBytes
+}
+
+// tombstoneOverhead approximates the bookkeeping bytes of one tombstone
+// besides its key and value.
+const tombstoneOverhead = 96
+
+func tombstoneBytes(key string, t *tombstone) int64 {
+	return int64(len(key) + len(t.value) + tombstoneOverhead)
+}
+
+func newTombstoneBuffer(cfg TombstoneRetention) *tombstoneBuffer {
+	b := &tombstoneBuffer{maxAge: cfg.MaxAge}
+	b.entries, _ = simplelru.NewLRU(cfg.Count, func(key, value interface{}) {
+		b.size -= tombstoneBytes(key.(string), value.(*tombstone))
+	})
+	return b
+}
+
+// add records the removal of item and drops tombstones that have aged out.
+// The caller must hold the write lock.
+func (b *tombstoneBuffer) add(item *CacheItem, reason EvictReason, now time.Time) {
+	if old, ok := b.entries.Peek(item.Key); ok {
+		b.size -= tombstoneBytes(item.Key, old.(*tombstone))
+	}
+	t := &tombstone{value: item.Value, removedAt: now, reason: reason}
+	b.size += tombstoneBytes(item.Key, t)
+	b.entries.Add(item.Key, t)
+	if b.maxAge <= 0 {
+		return
+	}
+	for {
+		_, v, ok := b.entries.GetOldest()
+		if !ok || now.Sub(v.(*tombstone).removedAt) <= b.maxAge {
+			return
+		}
+		b.entries.RemoveOldest()
+	}
+}
+
+// demand estimates the bytes the buffer takes when full, from the average
+// size of the tombstones it holds.
+func (b *tombstoneBuffer) demand(count int) int64 {
+	if n := b.entries.Len(); n > 0 {
+		return b.size / int64(n) * int64(count)
+	}
+	return 0
+}
+
+// limitBytes drops the oldest tombstones until the rest fit in n bytes. The
+// caller must hold the write lock.
+func (b *tombstoneBuffer) limitBytes(n int64) {
+	for b.size > n {
+		if _, _, ok := b.entries.RemoveOldest(); !ok {
+			return
+		}
+	}
+}
+
+// get returns the tombstone of key if it is still within the retention
+// window.
+func (b *tombstoneBuffer) get(key string, now time.Time) (*tombstone, bool) {
+	v, ok := b.entries.Peek(key)
+	if !ok {
+		return nil, false
+	}
+	t := v.(*tombstone)
+	if b.maxAge > 0 && now.Sub(t.removedAt) > b.maxAge {
+		return nil, false
+	}
+	return t, true
+}
+
+// Tombstone returns the last value of key before it expired or was evicted,
+// when it was removed and why. ok is false if TombstoneRetention is disabled
+// or key has no tombstone within the retention window.
+func (l *LRU) Tombstone(key string) (value interface{}, removedAt time.Time, reason EvictReason, ok bool) {
+	key = l.NormalizeKey(key)
+	if l.tombstones == nil {
+		return nil, time.Time{}, 0, false
+	}
+
+	l.readLock("get")
+	defer l.lock.RUnlock()
+
+	t, ok := l.tombstones.get(key, l.now())
+	if !ok {
+		return nil, time.Time{}, 0, false
+	}
+	value, err := l.decode(t.value)
+	if err != nil {
+		l.log("error", "Failed to deserialize tombstone of key %s: %v", l.redact(key), err)
+		return nil, time.Time{}, 0, false
+	}
+	return value, t.removedAt, t.reason, true
+}
@@ -0,0 +1,79 @@
+package lrucache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTombstoneRetention(t *testing.T) {
+	cache, _ := NewLRUWithTTL(2, Options{
+		LogLevel:           "error",
+		TombstoneRetention: TombstoneRetention{Count: 10, MaxAge: 5 * time.Minute},
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("key1", "old", time.Minute)
+	now = now.Add(2 * time.Minute)
+	cache.removeExpiredItems()
+	removed := now
+
+	value, removedAt, reason, ok := cache.Tombstone("key1")
+	if !ok || value != "old" || !removedAt.Equal(removed) || reason != ReasonExpired {
+		t.Errorf("Expected expired tombstone, got %v %v %v %v", value, removedAt, reason, ok)
+	}
+
+	cache.Set("key2", 2, time.Hour)
+	cache.Set("key3", 3, 2*time.Hour)
+	cache.Set("key4", 4, 3*time.Hour)
+	if value, _, reason, ok := cache.Tombstone("key2"); !ok || value != 2 || reason != ReasonCapacity {
+		t.Errorf("Expected evicted tombstone, got %v %v %v", value, reason, ok)
+	}
+	if _, _, _, ok := cache.Tombstone("key3"); ok {
+		t.Error("Expected no tombstone for a live key")
+	}
+
+	now = now.Add(6 * time.Minute)
+	if _, _, _, ok := cache.Tombstone("key1"); ok {
+		t.Error("Expected tombstone to age out after MaxAge")
+	}
+}
+
+func TestTombstoneBufferBound(t *testing.T) {
+	cache, _ := NewLRUWithTTL(1000, Options{
+		LogLevel:           "error",
+		TombstoneRetention: TombstoneRetention{Count: 5},
+	})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key%03d", i), i, time.Minute+time.Duration(i)*time.Second)
+	}
+	now = now.Add(time.Hour)
+	cache.removeExpiredItems()
+
+	if n := cache.tombstones.entries.Len(); n != 5 {
+		t.Errorf("Expected 5 tombstones, got %d", n)
+	}
+	if _, _, _, ok := cache.Tombstone("key000"); ok {
+		t.Error("Expected the oldest tombstones to be dropped")
+	}
+	if value, _, _, ok := cache.Tombstone("key099"); !ok || value != 99 {
+		t.Errorf("Expected the latest tombstone to be kept, got %v %v", value, ok)
+	}
+	if l := cache.Len(); l != 0 {
+		t.Errorf("Expected tombstones not to count against the cache, got len %d", l)
+	}
+}
+
+func TestTombstoneDisabled(t *testing.T) {
+	cache, _ := NewLRUWithTTL(1, Options{LogLevel: "error"})
+
+	cache.Set("key1", "value1", time.Hour)
+	cache.Set("key2", "value2", time.Hour)
+	if _, _, _, ok := cache.Tombstone("key1"); ok {
+		t.Error("Expected no tombstones without TombstoneRetention")
+	}
+}
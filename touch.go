@@ -0,0 +1,210 @@
+package lrucache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// Touch moves the deadline of a live entry to ttl from now without
+// rewriting its value. The entry's place on the expiration heap is fixed in
+// place. Absent keys return ErrItemNotFound and expired ones ErrItemExpired;
+// neither is resurrected.
+func (l *LRU) Touch(key string, ttl time.Duration) error {
+	key = l.NormalizeKey(key)
+	if ttl <= 0 {
+		return l.invalid(key, "ttl must be positive")
+	}
+	return l.retime(key, func() (time.Time, time.Time) { return l.expiry(ttl) })
+}
+
+// ExpireAt is Touch with the wall clock time the entry should expire at,
+// which becomes its ExpiresAt. A time that is not in the future is rejected.
+func (l *LRU) ExpireAt(key string, t time.Time) error {
+	key = l.NormalizeKey(key)
+	if !t.After(l.now()) {
+		return l.invalid(key, fmt.Sprintf("expiry time %v is not in the future", t))
+	}
+	return l.retime(key, func() (time.Time, time.Time) { return l.expiryAt(t) })
+}
+
+// Persist removes the TTL of a live entry without rewriting its value, so
+// it never expires. It is still subject to capacity eviction, where entries
+// that never expire go last, and a later write of the key gives it a TTL
+// again. Its ExpiresAt becomes zero. Absent keys return ErrItemNotFound and
+// expired ones ErrItemExpired.
+func (l *LRU) Persist(key string) error {
+	key = l.NormalizeKey(key)
+	return l.retime(key, func() (time.Time, time.Time) { return time.Time{}, neverDeadline })
+}
+
+// retime gives the live entry under key the deadlines returned by expiry,
+// which runs under the write lock.
+func (l *LRU) retime(key string, expiry func() (expiresAt, deadline time.Time)) error {
+	if err := l.checkWritable(key); err != nil {
+		return err
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	txn := l.db.Txn(true)
+	raw, err := txn.First("cache", "id", key)
+	if err != nil {
+		txn.Abort()
+		return fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		txn.Abort()
+		return ErrItemNotFound
+	}
+	if raw.(*CacheItem).expired(l.clock()) {
+		txn.Abort()
+		return ErrItemExpired
+	}
+	expiresAt, deadline := expiry()
+	if err := l.touchItem(txn, raw.(*CacheItem), expiresAt, deadline); err != nil {
+		txn.Abort()
+		return err
+	}
+	txn.Commit()
+	l.retire(raw.(*CacheItem))
+
+	l.expHeap.set(key, deadline)
+	l.log("debug", "Touched key: %s, expires at: %v", l.redact(key), expiresAt)
+	return nil
+}
+
+// TTL returns how long the entry under key has left to live, or
+// NoExpiration if it never expires. It is read from the entry itself, on
+// the same monotonic clock expiry uses, rather than from the expiration
+// heap. Absent keys, and entries whose reads are used up, return
+// ErrItemNotFound; expired ones return ErrItemExpired.
+func (l *LRU) TTL(key string) (time.Duration, error) {
+	key = l.NormalizeKey(key)
+	l.readLock("get")
+	defer l.lock.RUnlock()
+
+	raw, err := l.db.Txn(false).First("cache", "id", key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		return 0, ErrItemNotFound
+	}
+	item := raw.(*CacheItem)
+	clock := l.clock()
+	if item.expired(clock) {
+		return 0, ErrItemExpired
+	}
+	if item.reads != nil && item.reads.Load() <= 0 {
+		return 0, ErrItemNotFound
+	}
+	if item.persistent() {
+		return NoExpiration, nil
+	}
+	return item.deadline.Sub(clock), nil
+}
+
+// TouchMany extends the TTL of every live key in keys within a single write
+// transaction. Keys that are absent or already expired are returned in
+// missing rather than being resurrected.
+func (l *LRU) TouchMany(keys []string, ttl time.Duration) (touched int, missing []string, err error) {
+	if ttl <= 0 {
+		return 0, nil, l.invalid("", "ttl must be positive")
+	}
+	if err := l.checkWritable(""); err != nil {
+		return 0, nil, err
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	clock := l.clock()
+	expiresAt, deadline := l.expiry(ttl)
+	deadlines := make(map[string]time.Time, len(keys))
+	var retired []*CacheItem
+
+	txn := l.db.Txn(true)
+	for _, key := range keys {
+		raw, err := txn.First("cache", "id", l.NormalizeKey(key))
+		if err != nil {
+			txn.Abort()
+			return 0, nil, fmt.Errorf("failed to retrieve item: %v", err)
+		}
+		if raw == nil || raw.(*CacheItem).expired(clock) {
+			missing = append(missing, key)
+			continue
+		}
+		if err := l.touchItem(txn, raw.(*CacheItem), expiresAt, deadline); err != nil {
+			txn.Abort()
+			return 0, nil, err
+		}
+		retired = append(retired, raw.(*CacheItem))
+		deadlines[raw.(*CacheItem).Key] = deadline
+	}
+	txn.Commit()
+	l.retire(retired...)
+
+	l.expHeap.setMany(deadlines)
+	l.log("debug", "Touched %d keys, %d missing, TTL: %v", len(deadlines), len(missing), ttl)
+	return len(deadlines), missing, nil
+}
+
+// TouchByPrefix extends the TTL of every live key starting with prefix
+// within a single write transaction and returns how many were touched.
+func (l *LRU) TouchByPrefix(prefix string, ttl time.Duration) (int, error) {
+	prefix = l.NormalizeKey(prefix)
+	if ttl <= 0 {
+		return 0, l.invalid(prefix, "ttl must be positive")
+	}
+	if err := l.checkWritable(prefix); err != nil {
+		return 0, err
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	clock := l.clock()
+	expiresAt, deadline := l.expiry(ttl)
+	deadlines := make(map[string]time.Time)
+	var retired []*CacheItem
+
+	txn := l.db.Txn(true)
+	it, err := txn.Get("cache", "id_prefix", prefix)
+	if err != nil {
+		txn.Abort()
+		return 0, fmt.Errorf("failed to get items: %v", err)
+	}
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		item := obj.(*CacheItem)
+		if !strings.HasPrefix(item.Key, prefix) || item.expired(clock) {
+			continue
+		}
+		if err := l.touchItem(txn, item, expiresAt, deadline); err != nil {
+			txn.Abort()
+			return 0, err
+		}
+		retired = append(retired, item)
+		deadlines[item.Key] = deadline
+	}
+	txn.Commit()
+	l.retire(retired...)
+
+	l.expHeap.setMany(deadlines)
+	l.log("debug", "Touched %d keys with prefix: %s, TTL: %v", len(deadlines), l.redact(prefix), ttl)
+	return len(deadlines), nil
+}
+
+// touchItem stores a copy of item with a new deadline.
+func (l *LRU) touchItem(txn *memdb.Txn, item *CacheItem, expiresAt, deadline time.Time) error {
+	touched := l.copyItem(item)
+	touched.ExpiresAt = expiresAt
+	touched.deadline = deadline
+	if err := txn.Insert("cache", touched); err != nil {
+		return fmt.Errorf("failed to insert item: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package lrucache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTouch(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("session", "v", time.Minute)
+	cache.Set("other", "v", 10*time.Minute)
+	cache.Set("stale", "v", time.Second)
+	now = now.Add(2 * time.Second)
+
+	if err := cache.Touch("session", time.Hour); err != nil {
+		t.Fatalf("Touch failed: %v", err)
+	}
+	if err := cache.Touch("absent", time.Hour); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+	if err := cache.Touch("stale", time.Hour); err != ErrItemExpired {
+		t.Errorf("Expected ErrItemExpired, got %v", err)
+	}
+	if n := cache.expHeap.Len(); n != 3 {
+		t.Errorf("Expected the heap entry to be moved, not duplicated, got %d entries", n)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent heap, got %v", err)
+	}
+	if order := expirationOrder(cache); !reflect.DeepEqual(order, []string{"stale", "other", "session"}) {
+		t.Errorf("Expected session to expire last, got %v", order)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if v, err := cache.Get("session"); err != nil || v != "v" {
+		t.Errorf("Expected session to be extended with its value, got %v, %v", v, err)
+	}
+}
+
+func TestExpireAt(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", 1, time.Minute)
+	cache.Set("b", 2, time.Hour)
+	at := now.Add(2 * time.Hour)
+	if err := cache.ExpireAt("a", at); err != nil {
+		t.Fatalf("ExpireAt failed: %v", err)
+	}
+	if got := cache.Items()["a"].ExpiresAt; !got.Equal(at) {
+		t.Errorf("Expected ExpiresAt %v, got %v", at, got)
+	}
+	if ttl, _ := cache.TTL("a"); ttl != 2*time.Hour {
+		t.Errorf("Expected 2h left, got %v", ttl)
+	}
+	if err := cache.ExpireAt("a", now); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected a past time to be rejected, got %v", err)
+	}
+	if err := cache.ExpireAt("absent", at); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent heap, got %v", err)
+	}
+	if order := expirationOrder(cache); !reflect.DeepEqual(order, []string{"b", "a"}) {
+		t.Errorf("Expected a to expire after b, got %v", order)
+	}
+}
+
+func TestPersist(t *testing.T) {
+	cache, _ := NewLRUWithTTL(3, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("flag", true, time.Minute)
+	cache.Set("b", 2, time.Hour)
+	if err := cache.Persist("flag"); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	if err := cache.Persist("absent"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+	if ttl, err := cache.TTL("flag"); err != nil || ttl != NoExpiration {
+		t.Errorf("Expected NoExpiration, got %v, %v", ttl, err)
+	}
+	if e := cache.Items()["flag"]; !e.ExpiresAt.IsZero() {
+		t.Errorf("Expected a zero ExpiresAt, got %v", e.ExpiresAt)
+	}
+
+	now = now.Add(2 * time.Hour)
+	cache.removeExpiredItems()
+	if v, err := cache.Get("flag"); err != nil || v != true {
+		t.Errorf("Expected the persisted entry to outlive its TTL, got %v, %v", v, err)
+	}
+	if cache.Contains("b") {
+		t.Errorf("Expected b to expire")
+	}
+
+	// Persisted entries are evicted for capacity, after all others.
+	cache.Set("c", 3, time.Hour)
+	cache.Set("d", 4, 2*time.Hour)
+	cache.Set("e", 5, 3*time.Hour)
+	if cache.Contains("c") || !cache.Contains("flag") {
+		t.Errorf("Expected c to be evicted before the persisted entry, got %v", cache.Keys())
+	}
+	cache.Persist("d")
+	cache.Persist("e")
+	cache.Set("f", 6, time.Hour)
+	if cache.Contains("f") || cache.Len() != 3 {
+		t.Errorf("Expected the only expiring entry to be evicted, got %v", cache.Keys())
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent heap, got %v", err)
+	}
+
+	// A later write gives the key a TTL again.
+	cache.Set("d", 7, time.Minute)
+	if ttl, _ := cache.TTL("d"); ttl != time.Minute {
+		t.Errorf("Expected d to expire again, got %v", ttl)
+	}
+}
+
+func TestPersistSurvivesExport(t *testing.T) {
+	src, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	src.Set("flag", "on", time.Minute)
+	src.Persist("flag")
+
+	var buf bytes.Buffer
+	if err := src.ExportDelta(&buf, 0); err != nil {
+		t.Fatalf("ExportDelta failed: %v", err)
+	}
+	dst, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	if err := dst.ApplyDelta(&buf); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	if ttl, err := dst.TTL("flag"); err != nil || ttl != NoExpiration {
+		t.Errorf("Expected the imported entry never to expire, got %v, %v", ttl, err)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", 1, time.Hour)
+	cache.Set("stale", 2, time.Second)
+	cache.SetWithReadLimit("once", 3, time.Hour, 1)
+	now = now.Add(10 * time.Second)
+
+	if ttl, err := cache.TTL("a"); err != nil || ttl != time.Hour-10*time.Second {
+		t.Errorf("Expected 59m50s left, got %v, %v", ttl, err)
+	}
+	if _, err := cache.TTL("stale"); err != ErrItemExpired {
+		t.Errorf("Expected ErrItemExpired, got %v", err)
+	}
+	if _, err := cache.TTL("absent"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound, got %v", err)
+	}
+	cache.Get("once")
+	if _, err := cache.TTL("once"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound for a used up entry, got %v", err)
+	}
+
+	// The heap's copy of the deadline does not matter.
+	cache.expHeap.deadlines["a"] = now
+	if ttl, err := cache.TTL("a"); err != nil || ttl != time.Hour-10*time.Second {
+		t.Errorf("Expected the TTL to come from the entry, got %v, %v", ttl, err)
+	}
+}
+
+func TestTouchMany(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("live1", 1, time.Minute)
+	cache.Set("live2", 2, time.Minute)
+	cache.Set("stale", 3, time.Second)
+	now = now.Add(2 * time.Second)
+
+	touched, missing, err := cache.TouchMany([]string{"live1", "stale", "live2", "absent"}, time.Hour)
+	if err != nil {
+		t.Fatalf("TouchMany failed: %v", err)
+	}
+	if touched != 2 {
+		t.Errorf("Expected 2 touched, got %d", touched)
+	}
+	if !reflect.DeepEqual(missing, []string{"stale", "absent"}) {
+		t.Errorf("Expected stale and absent to be missing, got %v", missing)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent heap, got %v", err)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if _, err := cache.Get("live1"); err != nil {
+		t.Errorf("Expected live1 to be extended, got %v", err)
+	}
+	if _, err := cache.Get("stale"); err != ErrItemExpired {
+		t.Errorf("Expected stale to stay expired, got %v", err)
+	}
+}
+
+func TestTouchByPrefix(t *testing.T) {
+	cache, _ := NewLRUWithTTL(100, Options{LogLevel: "error"})
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 20; i++ {
+		cache.Set(fmt.Sprintf("session:alice:%d", i), i, time.Duration(i+1)*time.Minute)
+		cache.Set(fmt.Sprintf("session:bob:%d", i), i, time.Duration(i+1)*time.Minute)
+	}
+	cache.Set("session:alice:expired", 0, time.Second)
+	now = now.Add(2 * time.Second)
+
+	n, err := cache.TouchByPrefix("session:alice:", time.Hour)
+	if err != nil {
+		t.Fatalf("TouchByPrefix failed: %v", err)
+	}
+	if n != 20 {
+		t.Errorf("Expected 20 touched, got %d", n)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent heap, got %v", err)
+	}
+
+	// Bob's sessions kept their deadlines and now expire first.
+	now = now.Add(10 * time.Minute)
+	cache.removeExpiredItems()
+	if l := cache.Len(); l != 20+10 {
+		t.Errorf("Expected alice's 20 and bob's 10 longest sessions to remain, got %d", l)
+	}
+	if _, err := cache.Get("session:alice:0"); err != nil {
+		t.Errorf("Expected alice's session to be extended, got %v", err)
+	}
+}
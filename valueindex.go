@@ -0,0 +1,141 @@
+package lrucache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// valueHashIndex indexes entries by a hash of their serialized value. It
+// hashes Value itself on every insert, so no write path has to keep a hash
+// field up to date.
+type valueHashIndex struct {
+	// hash lets tests force collisions; it is hashBytes otherwise.
+	hash func(data []byte) uint64
+}
+
+func (x *valueHashIndex) FromObject(raw interface{}) (bool, []byte, error) {
+	item, ok := raw.(*CacheItem)
+	if !ok {
+		return false, nil, fmt.Errorf("unexpected object %T", raw)
+	}
+	return true, binary.BigEndian.AppendUint64(nil, x.hash(item.Value)), nil
+}
+
+func (x *valueHashIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("must provide only a single argument")
+	}
+	h, ok := args[0].(uint64)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a uint64: %#v", args[0])
+	}
+	return binary.BigEndian.AppendUint64(nil, h), nil
+}
+
+func hashBytes(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+func (l *LRU) hashValue(data []byte) uint64 {
+	if l.valueHashes != nil {
+		return l.valueHashes.hash(data)
+	}
+	return hashBytes(data)
+}
+
+// ValueHash returns the hash FindKeysByValueHash looks up for value, which
+// is taken over its serialized form.
+func (l *LRU) ValueHash(value interface{}) (uint64, error) {
+	data, err := serialize(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to serialize value: %v", err)
+	}
+	return l.hashValue(data), nil
+}
+
+// FindKeysByValue returns the keys of the live entries whose value
+// serializes to the same bytes as value, in key order. With
+// Options.IndexValueHashes set the lookup goes through an index of value
+// hashes, and candidates are compared byte for byte, so hash collisions are
+// never reported; without it every entry is compared. Like Keys it leaves
+// out variants.
+func (l *LRU) FindKeysByValue(value interface{}) ([]string, error) {
+	data, err := serialize(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize value: %v", err)
+	}
+	l.readLock("scan")
+	defer l.lock.RUnlock()
+	return l.findByValue(l.hashValue(data), data)
+}
+
+// FindKeysByValueHash returns the keys of the live entries whose value
+// hashes to hash, as ValueHash computes it, in key order. Unlike
+// FindKeysByValue it cannot rule out collisions.
+func (l *LRU) FindKeysByValueHash(hash uint64) ([]string, error) {
+	l.readLock("scan")
+	defer l.lock.RUnlock()
+	return l.findByValue(hash, nil)
+}
+
+// DeleteByValue removes every entry FindKeysByValue would report for value,
+// as DeleteMulti does, and returns how many were removed.
+func (l *LRU) DeleteByValue(value interface{}) (int, error) {
+	defer l.checkWatermarks()
+	data, err := serialize(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to serialize value: %v", err)
+	}
+
+	l.writeLock("delete")
+	defer l.lock.Unlock()
+
+	keys, err := l.findByValue(l.hashValue(data), data)
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+	return l.deleteMany(keys)
+}
+
+// findByValue returns the keys of live entries whose value hashes to hash
+// and, unless data is nil, equals data. The caller must hold the lock.
+func (l *LRU) findByValue(hash uint64, data []byte) ([]string, error) {
+	txn := l.db.Txn(false)
+	var it memdb.ResultIterator
+	var err error
+	if l.valueHashes != nil {
+		it, err = txn.Get("cache", "value_hash", hash)
+	} else {
+		it, err = txn.Get("cache", "id")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get items: %v", err)
+	}
+
+	clock := l.clock()
+	var keys []string
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		item := obj.(*CacheItem)
+		if item.Variant != "" || item.expired(clock) || (item.reads != nil && item.reads.Load() <= 0) {
+			continue
+		}
+		switch {
+		case data != nil:
+			if !bytes.Equal(item.Value, data) {
+				continue
+			}
+		case l.valueHashes == nil:
+			if l.hashValue(item.Value) != hash {
+				continue
+			}
+		}
+		keys = append(keys, item.Key)
+	}
+	return keys, nil
+}
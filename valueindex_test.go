@@ -0,0 +1,87 @@
+package lrucache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLRUFindKeysByValue(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", IndexValueHashes: indexed})
+		now := time.Unix(1700000000, 0)
+		cache.now = func() time.Time { return now }
+
+		doc := map[string]interface{}{"secret": "hunter2"}
+		cache.Set("c", doc, time.Hour)
+		cache.Set("a", doc, time.Hour)
+		cache.Set("b", "other", time.Hour)
+		cache.Set("old", doc, time.Minute)
+		cache.Set("d", "other", time.Hour)
+		cache.SetPreservingTTL("d", doc)
+		now = now.Add(2 * time.Minute)
+
+		keys, err := cache.FindKeysByValue(doc)
+		if err != nil {
+			t.Fatalf("FindKeysByValue failed: %v", err)
+		}
+		if want := []string{"a", "c", "d"}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("indexed %v: expected %v, got %v", indexed, want, keys)
+		}
+
+		h, _ := cache.ValueHash("other")
+		if keys, _ := cache.FindKeysByValueHash(h); !reflect.DeepEqual(keys, []string{"b"}) {
+			t.Errorf("indexed %v: expected b by hash, got %v", indexed, keys)
+		}
+		if keys, _ := cache.FindKeysByValue("missing"); len(keys) != 0 {
+			t.Errorf("indexed %v: expected no keys, got %v", indexed, keys)
+		}
+	}
+}
+
+func TestLRUFindKeysByValueCollisions(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", IndexValueHashes: true})
+	cache.valueHashes.hash = func([]byte) uint64 { return 7 }
+
+	cache.Set("a", "one", time.Hour)
+	cache.Set("b", "two", time.Hour)
+	cache.Set("c", "one", time.Hour)
+
+	if keys, _ := cache.FindKeysByValue("one"); !reflect.DeepEqual(keys, []string{"a", "c"}) {
+		t.Errorf("Expected colliding values to be told apart, got %v", keys)
+	}
+	if keys, _ := cache.FindKeysByValueHash(7); len(keys) != 3 {
+		t.Errorf("Expected every entry under the colliding hash, got %v", keys)
+	}
+	if n, err := cache.DeleteByValue("two"); err != nil || n != 1 {
+		t.Errorf("Expected one deletion, got %d, %v", n, err)
+	}
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"a", "c"}) {
+		t.Errorf("Expected only b to be deleted, got %v", keys)
+	}
+}
+
+func TestLRUDeleteByValue(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", IndexValueHashes: true})
+	cache.Set("a", []byte("leak"), time.Hour)
+	cache.Set("b", []byte("leak"), time.Hour)
+	cache.Set("c", "leak", time.Hour)
+
+	n, err := cache.DeleteByValue([]byte("leak"))
+	if err != nil {
+		t.Fatalf("DeleteByValue failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 deletions, got %d", n)
+	}
+	// A string and a []byte serialize differently.
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"c"}) {
+		t.Errorf("Expected c to remain, got %v", keys)
+	}
+	if keys, _ := cache.FindKeysByValue([]byte("leak")); len(keys) != 0 {
+		t.Errorf("Expected the index to forget deleted entries, got %v", keys)
+	}
+	if n, _ := cache.DeleteByValue("nothing"); n != 0 {
+		t.Errorf("Expected no deletions, got %d", n)
+	}
+}
@@ -0,0 +1,136 @@
+package lrucache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// variantSep joins a base key and a variant name into the entry's own key.
+const variantSep = "\x00"
+
+func variantKey(key, variant string) string {
+	return key + variantSep + variant
+}
+
+// SetVariant stores one representation of key, for example a gzip and an
+// identity encoding of the same HTTP body. Each variant has its own TTL and
+// occupies its own slot, and Delete(key) removes all variants at once.
+func (l *LRU) SetVariant(key, variant string, value []byte, ttl time.Duration) error {
+	key = l.NormalizeKey(key)
+	defer l.checkWatermarks()
+
+	if ttl <= 0 && ttl != NoExpiration {
+		return l.invalid(key, "ttl must be positive or NoExpiration")
+	}
+	if key == "" {
+		return l.invalid(key, "key must not be empty")
+	}
+	if variant == "" {
+		return l.invalid(key, "variant must not be empty")
+	}
+	if err := l.checkWritable(key); err != nil {
+		return err
+	}
+
+	l.writeLock("set")
+	defer l.lock.Unlock()
+
+	data, err := serialize(value)
+	if err != nil {
+		return fmt.Errorf("failed to serialize value: %v", err)
+	}
+
+	item := l.newItem(variantKey(key, variant), data, ttl)
+	item.Base = key
+	item.Variant = variant
+	if err := l.makeRoom(entryBytes(item.Key, data)-l.rowBytes(item.Key), item.Key); err != nil {
+		return err
+	}
+	l.filterAdd(item.Key)
+
+	growth := entryBytes(item.Key, data) - l.rowBytes(item.Key)
+	txn := l.db.Txn(true)
+	prev := l.previous(txn, item.Key)
+	if err := txn.Insert("cache", item); err != nil {
+		txn.Abort()
+		return fmt.Errorf("failed to insert item: %v", err)
+	}
+	txn.Commit()
+	l.resized(growth)
+	l.retire(prev)
+	l.groups.set(item.Key)
+
+	l.expHeap.set(item.Key, item.deadline)
+	if err := l.evictOverCapacity(); err != nil && l.opts.StrictErrors {
+		return err
+	}
+
+	l.log("debug", "Set key: %s, variant: %s, TTL: %v", l.redact(key), variant, ttl)
+	return nil
+}
+
+// GetVariant returns the bytes stored for one variant of key.
+func (l *LRU) GetVariant(key, variant string) ([]byte, error) {
+	key = l.NormalizeKey(key)
+	l.readLock("get")
+	defer l.lock.RUnlock()
+
+	txn := l.db.Txn(false)
+	raw, err := txn.First("cache", "variant", key, variant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve item: %v", err)
+	}
+	if raw == nil {
+		return nil, ErrItemNotFound
+	}
+
+	item := raw.(*CacheItem)
+	if item.expired(l.clock()) {
+		return nil, ErrItemExpired
+	}
+	item.recordAccess(l.now())
+
+	value, err := l.decode(item.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize value: %w", err)
+	}
+	return value.([]byte), nil
+}
+
+// Variants lists the live variants stored for key in lexicographic order.
+func (l *LRU) Variants(key string) []string {
+	key = l.NormalizeKey(key)
+	l.readLock("scan")
+	defer l.lock.RUnlock()
+
+	txn := l.db.Txn(false)
+	it, err := txn.Get("cache", "base", key)
+	if err != nil {
+		l.log("error", "Failed to list variants: %v", err)
+		return nil
+	}
+
+	clock := l.clock()
+	var variants []string
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		if item := obj.(*CacheItem); !item.expired(clock) {
+			variants = append(variants, item.Variant)
+		}
+	}
+	return variants
+}
+
+// variantKeys returns the entry keys of every variant stored under key.
+func variantKeys(txn *memdb.Txn, key string) ([]string, error) {
+	it, err := txn.Get("cache", "base", key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get variants: %v", err)
+	}
+	var keys []string
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		keys = append(keys, obj.(*CacheItem).Key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,74 @@
+package lrucache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVariants(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	cache.Set("/index.html", "plain entry", time.Hour)
+	if err := cache.SetVariant("/index.html", "identity", []byte("<html>"), time.Hour); err != nil {
+		t.Fatalf("SetVariant failed: %v", err)
+	}
+	if err := cache.SetVariant("/index.html", "gzip", []byte{0x1f, 0x8b}, time.Hour); err != nil {
+		t.Fatalf("SetVariant failed: %v", err)
+	}
+	cache.SetVariant("/other.html", "gzip", []byte{0x1f}, time.Hour)
+
+	if v, err := cache.GetVariant("/index.html", "gzip"); err != nil || !reflect.DeepEqual(v, []byte{0x1f, 0x8b}) {
+		t.Errorf("GetVariant gzip failed. Got %v, %v", v, err)
+	}
+	if v, err := cache.GetVariant("/index.html", "identity"); err != nil || string(v) != "<html>" {
+		t.Errorf("GetVariant identity failed. Got %v, %v", v, err)
+	}
+	if _, err := cache.GetVariant("/index.html", "br"); err != ErrItemNotFound {
+		t.Errorf("Expected ErrItemNotFound for missing variant, got %v", err)
+	}
+	if got := cache.Variants("/index.html"); !reflect.DeepEqual(got, []string{"gzip", "identity"}) {
+		t.Errorf("Expected gzip and identity variants, got %v", got)
+	}
+
+	if err := cache.Delete("/index.html"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := cache.GetVariant("/index.html", "gzip"); err != ErrItemNotFound {
+		t.Errorf("Expected gzip variant to be gone, got %v", err)
+	}
+	if _, err := cache.GetVariant("/index.html", "identity"); err != ErrItemNotFound {
+		t.Errorf("Expected identity variant to be gone, got %v", err)
+	}
+	if _, err := cache.Get("/index.html"); err != ErrItemNotFound {
+		t.Errorf("Expected plain entry to be gone, got %v", err)
+	}
+	if _, err := cache.GetVariant("/other.html", "gzip"); err != nil {
+		t.Errorf("Expected other resource to survive, got %v", err)
+	}
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Expected a consistent heap, got %v", err)
+	}
+}
+
+func TestVariantsEvictedAsGroup(t *testing.T) {
+	for _, separately := range []bool{false, true} {
+		cache, _ := NewLRUWithTTL(3, Options{LogLevel: "error", EvictVariantsSeparately: separately})
+
+		cache.SetVariant("a", "gzip", []byte("1"), 1*time.Hour)
+		cache.SetVariant("a", "identity", []byte("2"), 2*time.Hour)
+		cache.Set("b", 3, 3*time.Hour)
+		cache.Set("c", 4, 4*time.Hour) // evicts a/gzip, and a/identity when grouped
+
+		want := 2
+		if separately {
+			want = 3
+		}
+		if l := cache.Len(); l != want {
+			t.Errorf("separately=%v: expected len %d, got %d", separately, want, l)
+		}
+		if _, err := cache.GetVariant("a", "gzip"); err != ErrItemNotFound {
+			t.Errorf("separately=%v: expected a/gzip to be evicted, got %v", separately, err)
+		}
+	}
+}
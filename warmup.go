@@ -0,0 +1,150 @@
+package lrucache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ColdBehavior decides how Get treats misses while a cache created with
+// Options.RequireWarmup is still warming up.
+type ColdBehavior int
+
+const (
+	ColdServe  ColdBehavior = iota // handle misses as usual
+	ColdReject                     // misses return ErrColdStart
+	ColdWait                       // misses wait up to ColdWaitTimeout for the key
+)
+
+const defaultColdWaitTimeout = 100 * time.Millisecond
+
+// warmupState tracks the warm-up of a cache created with RequireWarmup.
+type warmupState struct {
+	warmed    chan struct{} // closed by MarkWarm
+	once      sync.Once
+	loaded    atomic.Int64
+	target    atomic.Int64
+	fixed     bool // target comes from Options.WarmupTarget
+	behavior  ColdBehavior
+	waitLimit time.Duration
+}
+
+func newWarmupState(opts Options) *warmupState {
+	w := &warmupState{
+		warmed:    make(chan struct{}),
+		fixed:     opts.WarmupTarget > 0,
+		behavior:  opts.ColdBehavior,
+		waitLimit: opts.ColdWaitTimeout,
+	}
+	if w.waitLimit <= 0 {
+		w.waitLimit = defaultColdWaitTimeout
+	}
+	w.target.Store(int64(opts.WarmupTarget))
+	return w
+}
+
+// progress counts n keys loaded out of expected ones, which only extend the
+// target when no WarmupTarget was configured.
+func (w *warmupState) progress(n, expected int) {
+	if w == nil {
+		return
+	}
+	w.loaded.Add(int64(n))
+	if !w.fixed {
+		w.target.Add(int64(expected))
+	}
+}
+
+// Warmed reports whether the cache has finished warming up. Caches created
+// without Options.RequireWarmup are always warm.
+func (l *LRU) Warmed() bool {
+	if l.warmup == nil {
+		return true
+	}
+	select {
+	case <-l.warmup.warmed:
+		return true
+	default:
+		return false
+	}
+}
+
+// MarkWarm ends the warm-up, after which Get handles misses as usual.
+// Preload and WarmFromKeyList call it when they complete; calling it again
+// does nothing.
+func (l *LRU) MarkWarm() {
+	if l.warmup == nil {
+		return
+	}
+	l.warmup.once.Do(func() {
+		close(l.warmup.warmed)
+		l.log("info", "Warm-up complete, %d keys loaded", l.warmup.loaded.Load())
+	})
+}
+
+// Preload stores items as SetMultiTTL does and then marks the cache warm.
+// If the batch fails the cache stays cold.
+func (l *LRU) Preload(items map[string]TTLValue) error {
+	if err := l.SetMultiTTL(items); err != nil {
+		return err
+	}
+	l.warmup.progress(len(items), len(items))
+	l.MarkWarm()
+	return nil
+}
+
+// WarmFromKeyList loads every key in keys with Options.Loader, skipping keys
+// already cached, and then marks the cache warm. Keys that fail to load do
+// not stop the warm-up; the first failure is returned once it completes. If
+// ctx ends first, WarmFromKeyList returns its error and the cache stays
+// cold.
+func (l *LRU) WarmFromKeyList(ctx context.Context, keys []string) error {
+	if l.opts.Loader == nil {
+		return l.misuse("", fmt.Errorf("%w: WarmFromKeyList needs Options.Loader", ErrInvalidArgument))
+	}
+
+	var firstErr error
+	loaded := 0
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			l.warmup.progress(loaded, len(keys))
+			return err
+		}
+		key = l.NormalizeKey(key)
+		if _, err := l.readThrough(key); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to warm key %s: %w", key, err)
+			}
+			continue
+		}
+		loaded++
+	}
+	l.warmup.progress(loaded, len(keys))
+	l.MarkWarm()
+	return firstErr
+}
+
+// waitCold holds a Get of a missing key under ColdWait until the key is
+// stored, the warm-up ends, ctx is done or ColdWaitTimeout passes.
+func (l *LRU) waitCold(ctx context.Context, key string) {
+	if l.Contains(key) {
+		return
+	}
+	stored, cancel := l.WatchKey(key, 1)
+	defer cancel()
+	// The key may have been stored before the watch began.
+	if l.Contains(key) {
+		return
+	}
+
+	timer := time.NewTimer(l.warmup.waitLimit)
+	defer timer.Stop()
+	select {
+	case <-stored:
+	case <-l.warmup.warmed:
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,135 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLRUColdBehavior(t *testing.T) {
+	loader := func(key string) (interface{}, time.Duration, error) { return "loaded " + key, time.Hour, nil }
+
+	t.Run("serve", func(t *testing.T) {
+		cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", RequireWarmup: true, Loader: loader})
+		if v, err := cache.Get("a"); err != nil || v != "loaded a" {
+			t.Errorf("Expected a cold cache to load misses as usual, got %v, %v", v, err)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", RequireWarmup: true, ColdBehavior: ColdReject, Loader: loader})
+		cache.Set("hit", 1, time.Hour)
+		if v, err := cache.Get("hit"); err != nil || v != 1 {
+			t.Errorf("Expected hits to be served while cold, got %v, %v", v, err)
+		}
+		if _, err := cache.Get("a"); !errors.Is(err, ErrColdStart) {
+			t.Errorf("Expected ErrColdStart, got %v", err)
+		}
+		if cache.Contains("a") {
+			t.Errorf("Expected the loader not to run while cold")
+		}
+
+		cache.MarkWarm()
+		if v, err := cache.Get("a"); err != nil || v != "loaded a" {
+			t.Errorf("Expected misses to load once warm, got %v, %v", v, err)
+		}
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		cache, _ := NewLRUWithTTL(10, Options{
+			LogLevel:        "error",
+			RequireWarmup:   true,
+			ColdBehavior:    ColdWait,
+			ColdWaitTimeout: 20 * time.Millisecond,
+		})
+		if _, err := cache.Get("a"); !errors.Is(err, ErrColdStart) {
+			t.Errorf("Expected ErrColdStart after the wait, got %v", err)
+		}
+
+		go func() {
+			time.Sleep(5 * time.Millisecond)
+			cache.Set("b", 2, time.Hour)
+		}()
+		cache.warmup.waitLimit = 5 * time.Second
+		if v, err := cache.Get("b"); err != nil || v != 2 {
+			t.Errorf("Expected the Get to wait for the key, got %v, %v", v, err)
+		}
+	})
+}
+
+func TestLRUWarmupEndsMidRequest(t *testing.T) {
+	loader := func(key string) (interface{}, time.Duration, error) { return "loaded", time.Hour, nil }
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel:        "error",
+		RequireWarmup:   true,
+		ColdBehavior:    ColdWait,
+		ColdWaitTimeout: 5 * time.Second,
+		Loader:          loader,
+	})
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cache.Preload(map[string]TTLValue{"other": {Value: 1, TTL: time.Hour}})
+	}()
+	start := time.Now()
+	if v, err := cache.Get("a"); err != nil || v != "loaded" {
+		t.Errorf("Expected the waiting Get to load once warm, got %v, %v", v, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Expected the end of the warm-up to release the wait")
+	}
+	if !cache.Warmed() {
+		t.Errorf("Expected Preload to mark the cache warm")
+	}
+}
+
+func TestLRUWarmFromKeyList(t *testing.T) {
+	fail := errors.New("origin down")
+	loader := func(key string) (interface{}, time.Duration, error) {
+		if key == "bad" {
+			return nil, 0, fail
+		}
+		return key, time.Hour, nil
+	}
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", RequireWarmup: true, ColdBehavior: ColdReject, Loader: loader})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cache.WarmFromKeyList(ctx, []string{"a"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if cache.Warmed() {
+		t.Errorf("Expected a cancelled warm-up to leave the cache cold")
+	}
+
+	err := cache.WarmFromKeyList(context.Background(), []string{"a", "bad", "c"})
+	if !errors.Is(err, fail) {
+		t.Errorf("Expected the loader error, got %v", err)
+	}
+	if !cache.Warmed() || !cache.Contains("a") || !cache.Contains("c") {
+		t.Errorf("Expected the other keys to load and the cache to be warm")
+	}
+	s := cache.Stats()
+	if !s.Warmed || s.WarmupLoaded != 2 || s.WarmupTarget != 4 {
+		t.Errorf("Expected 2 of 4 keys loaded, got %d of %d", s.WarmupLoaded, s.WarmupTarget)
+	}
+}
+
+func TestLRUWarmupTarget(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", RequireWarmup: true, WarmupTarget: 5})
+	if s := cache.Stats(); s.Warmed || s.WarmupTarget != 5 {
+		t.Errorf("Expected a cold cache with target 5, got %+v", s)
+	}
+	if err := cache.Preload(map[string]TTLValue{"a": {Value: 1, TTL: time.Hour}, "b": {Value: 2, TTL: time.Hour}}); err != nil {
+		t.Fatalf("Preload failed: %v", err)
+	}
+	if s := cache.Stats(); !s.Warmed || s.WarmupLoaded != 2 || s.WarmupTarget != 5 {
+		t.Errorf("Expected 2 of 5 keys loaded, got %d of %d", s.WarmupLoaded, s.WarmupTarget)
+	}
+
+	plain, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	if !plain.Warmed() {
+		t.Errorf("Expected a cache without RequireWarmup to be warm")
+	}
+}
@@ -0,0 +1,121 @@
+package lrucache
+
+import "sync"
+
+// keyWatch is one WatchKey subscription.
+type keyWatch struct {
+	ch chan interface{}
+}
+
+// watchRegistry holds the WatchKey subscriptions by key. It has its own
+// mutex so that cancelling a watch never waits for the cache lock.
+type watchRegistry struct {
+	mu     sync.Mutex
+	byKey  map[string]map[*keyWatch]struct{}
+	closed bool
+}
+
+// WatchKey subscribes to the values stored under key. Every write of the
+// key, by Set and its variants, SetPreservingTTL or an in-place update such
+// as SetField, sends the new value; when the entry is deleted, expires or is
+// evicted the channel is closed. Each watch buffers up to buffer values,
+// at least one, and drops the oldest one when a new value finds it full.
+// Every watch receives its own decoded copy of the value.
+//
+// The returned cancel ends the watch and closes the channel; so does Close.
+func (l *LRU) WatchKey(key string, buffer int) (<-chan interface{}, func()) {
+	key = l.NormalizeKey(key)
+	w := &keyWatch{ch: make(chan interface{}, max(buffer, 1))}
+
+	r := &l.watches
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		close(w.ch)
+		return w.ch, func() {}
+	}
+	if r.byKey == nil {
+		r.byKey = make(map[string]map[*keyWatch]struct{})
+	}
+	if r.byKey[key] == nil {
+		r.byKey[key] = make(map[*keyWatch]struct{})
+	}
+	r.byKey[key][w] = struct{}{}
+
+	return w.ch, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if _, ok := r.byKey[key][w]; ok {
+			delete(r.byKey[key], w)
+			if len(r.byKey[key]) == 0 {
+				delete(r.byKey, key)
+			}
+			close(w.ch)
+		}
+	}
+}
+
+// notifyWatchers sends the value just stored under key to its watches. The
+// caller must hold the write lock, which orders the notifications of
+// concurrent writes.
+func (l *LRU) notifyWatchers(key string, data []byte) {
+	r := &l.watches
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for w := range r.byKey[key] {
+		value, err := l.decode(data)
+		if err != nil {
+			l.log("error", "Failed to deserialize value of watched key %s: %v", l.redact(key), err)
+			return
+		}
+		select {
+		case w.ch <- value:
+			continue
+		default:
+		}
+		// Full: make room by dropping the oldest value.
+		select {
+		case <-w.ch:
+		default:
+		}
+		select {
+		case w.ch <- value:
+		default:
+		}
+	}
+}
+
+// closeWatchers ends the watches of a key that has left the cache.
+func (l *LRU) closeWatchers(key string) {
+	r := &l.watches
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for w := range r.byKey[key] {
+		close(w.ch)
+	}
+	delete(r.byKey, key)
+}
+
+// Close stops the background sweep and audit and ends every watch. Reads
+// and deletes keep working, but writes fail with ErrClosed and nothing
+// expires in the background any more.
+func (l *LRU) Close() error {
+	l.closeOnce.Do(func() {
+		l.closed.Store(true)
+		close(l.done)
+	})
+
+	r := &l.watches
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, watches := range r.byKey {
+		for w := range watches {
+			close(w.ch)
+		}
+	}
+	r.byKey = nil
+	r.closed = true
+	return nil
+}
@@ -0,0 +1,154 @@
+package lrucache
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, ch <-chan interface{}) (interface{}, bool) {
+	t.Helper()
+	select {
+	case v, ok := <-ch:
+		return v, ok
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for a watch")
+		return nil, false
+	}
+}
+
+func TestWatchKeyTwoWatchers(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	defer cache.Close()
+
+	a, cancelA := cache.WatchKey("k", 4)
+	defer cancelA()
+	b, cancelB := cache.WatchKey("k", 4)
+	defer cancelB()
+
+	cache.Set("k", "one", time.Minute)
+	cache.SetPreservingTTL("k", "two")
+	cache.Set("other", "x", time.Minute)
+
+	for _, ch := range []<-chan interface{}{a, b} {
+		for _, want := range []string{"one", "two"} {
+			if v, ok := receive(t, ch); !ok || v != want {
+				t.Errorf("Expected %q, got %v (open %v)", want, v, ok)
+			}
+		}
+		select {
+		case v := <-ch:
+			t.Errorf("Expected no value for another key, got %v", v)
+		default:
+		}
+	}
+}
+
+func TestWatchKeyOwnCopies(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	defer cache.Close()
+
+	a, _ := cache.WatchKey("k", 1)
+	b, _ := cache.WatchKey("k", 1)
+	cache.Set("k", map[string]interface{}{"n": 1}, time.Minute)
+
+	va, _ := receive(t, a)
+	vb, _ := receive(t, b)
+	va.(map[string]interface{})["n"] = 2
+	if vb.(map[string]interface{})["n"] != float64(1) {
+		t.Errorf("Expected watchers to get separate values, got %v", vb)
+	}
+}
+
+func TestWatchKeyClosedOnDelete(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	defer cache.Close()
+
+	cache.Set("k", "v", time.Minute)
+	ch, cancel := cache.WatchKey("k", 1)
+	defer cancel()
+
+	if err := cache.Delete("k"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := receive(t, ch); ok {
+		t.Error("Expected the channel to be closed after Delete")
+	}
+}
+
+func TestWatchKeyClosedOnExpiry(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	defer cache.Close()
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("k", "v", time.Second)
+	ch, _ := cache.WatchKey("k", 1)
+
+	now = now.Add(2 * time.Second)
+	cache.removeExpiredItems()
+	if _, ok := receive(t, ch); ok {
+		t.Error("Expected the channel to be closed after expiry")
+	}
+}
+
+func TestWatchKeyDropsOldest(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+	defer cache.Close()
+
+	ch, cancel := cache.WatchKey("k", 2)
+	defer cancel()
+	for _, v := range []string{"a", "b", "c"} {
+		cache.Set("k", v, time.Minute)
+	}
+
+	for _, want := range []string{"b", "c"} {
+		if v, _ := receive(t, ch); v != want {
+			t.Errorf("Expected %q, got %v", want, v)
+		}
+	}
+}
+
+func TestWatchKeyCancelAndClose(t *testing.T) {
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error"})
+
+	a, cancel := cache.WatchKey("k", 1)
+	cancel()
+	cancel()
+	if _, ok := receive(t, a); ok {
+		t.Error("Expected the channel to be closed by cancel")
+	}
+	cache.Set("k", "v", time.Minute) // must not send on the closed channel
+
+	b, cancelB := cache.WatchKey("k", 1)
+	cache.Close()
+	cancelB()
+	if _, ok := receive(t, b); ok {
+		t.Error("Expected the channel to be closed by Close")
+	}
+
+	c, _ := cache.WatchKey("k", 1)
+	if _, ok := receive(t, c); ok {
+		t.Error("Expected a watch after Close to be closed")
+	}
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	cache, _ := NewLRUWithTTL(10, Options{LogLevel: "error", AuditInterval: time.Minute})
+	for i := 0; i < 10; i++ {
+		_, cancel := cache.WatchKey("k", 1)
+		cancel()
+	}
+	cache.WatchKey("k", 1)
+	cache.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d goroutines after Close, got %d", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,66 @@
+package lrucache
+
+import "sync"
+
+// WatermarkDirection selects which crossings of a Watermark are reported.
+type WatermarkDirection int
+
+const (
+	Rising  WatermarkDirection = iota // utilization reached Level from below
+	Falling                           // utilization dropped below Level
+)
+
+// Watermark calls Callback when the share of the cache size in use crosses
+// Level in the given direction. Callbacks are edge triggered: they fire
+// once per crossing, after the operation that caused it has finished and
+// released the lock, so an operation that writes or removes many entries
+// reports at most one crossing per watermark.
+type Watermark struct {
+	Level     float64 // fraction of the cache size, in (0, 1]
+	Direction WatermarkDirection
+	Callback  func(level float64, entries int)
+}
+
+// watermarkTracker remembers on which side of each watermark utilization
+// was when last checked.
+type watermarkTracker struct {
+	marks []Watermark
+	mu    sync.Mutex
+	above []bool
+}
+
+func newWatermarkTracker(marks []Watermark) *watermarkTracker {
+	return &watermarkTracker{marks: marks, above: make([]bool, len(marks))}
+}
+
+// checkWatermarks fires the callbacks of the watermarks crossed since the
+// last check. Mutating operations defer it before taking the lock, so it
+// runs once they are done.
+func (l *LRU) checkWatermarks() {
+	w := l.watermarks
+	if w == nil {
+		return
+	}
+	l.readLock("scan")
+	entries := l.expHeap.Len()
+	l.lock.RUnlock()
+	utilization := float64(entries) / float64(l.size)
+
+	var fire []Watermark
+	w.mu.Lock()
+	for i, m := range w.marks {
+		above := utilization >= m.Level
+		if above == w.above[i] {
+			continue
+		}
+		w.above[i] = above
+		if above == (m.Direction == Rising) {
+			fire = append(fire, m)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, m := range fire {
+		m.Callback(m.Level, entries)
+	}
+}
@@ -0,0 +1,80 @@
+package lrucache
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatermarks(t *testing.T) {
+	var events []string
+	mark := func(name string) func(float64, int) {
+		return func(level float64, entries int) {
+			events = append(events, fmt.Sprintf("%s at %d", name, entries))
+		}
+	}
+	cache, err := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		Watermarks: []Watermark{
+			{Level: 0.5, Direction: Rising, Callback: mark("half up")},
+			{Level: 0.5, Direction: Falling, Callback: mark("half down")},
+			{Level: 0.8, Direction: Rising, Callback: mark("high up")},
+			{Level: 0.8, Direction: Falling, Callback: mark("high down")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewLRUWithTTL failed: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i, time.Minute)
+	}
+	// Staying full does not fire again.
+	cache.Set("key0", 0, time.Minute)
+	for i := 9; i >= 0; i-- {
+		cache.Delete(fmt.Sprintf("key%d", i))
+	}
+
+	want := []string{
+		"half up at 5",
+		"high up at 8",
+		"high down at 7",
+		"half down at 4",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Expected %v, got %v", want, events)
+	}
+}
+
+func TestWatermarksBulkFiresOnce(t *testing.T) {
+	var events []string
+	cache, _ := NewLRUWithTTL(10, Options{
+		LogLevel: "error",
+		Watermarks: []Watermark{
+			{Level: 0.3, Direction: Rising, Callback: func(level float64, entries int) {
+				events = append(events, fmt.Sprintf("up at %d", entries))
+			}},
+			{Level: 0.3, Direction: Falling, Callback: func(level float64, entries int) {
+				events = append(events, fmt.Sprintf("down at %d", entries))
+			}},
+		},
+	})
+	for i := 0; i < 8; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i, time.Minute)
+	}
+	events = nil
+
+	cache.Clear()
+	for i := 0; i < 8; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i, time.Minute)
+	}
+	cache.RangeMutate(func(key string, value interface{}) (interface{}, RangeAction) {
+		return nil, ActionDelete
+	})
+
+	want := []string{"down at 0", "up at 3", "down at 0"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Expected %v, got %v", want, events)
+	}
+}